@@ -0,0 +1,54 @@
+//
+// https://tools.ietf.org/html/rfc4511
+//
+// AbandonRequest ::= [APPLICATION 16] MessageID
+
+package ldap
+
+import (
+	"errors"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+var errAbandoned = errors.New("ldap: operation abandoned")
+
+// Abandon asks the server to stop processing the operation with the given message ID,
+// such as the MessageID of a PendingSearch. The server sends no response to an abandon
+// request, so Abandon returns as soon as the request is queued. If the abandoned operation
+// is still waiting for its response, it fails with the ResultCode LDAPResultCanceled.
+func (l *Conn) Abandon(messageID int64) error {
+	if l.IsClosing() {
+		return NewError(ErrorNetwork, errConnClosed)
+	}
+	// no response waiter is registered: processMessages writes the request, then
+	// releases the message context of the abandoned operation. It also assigns the
+	// message ID of the request. The reader of an operation abandoning it must call
+	// abandonMessage instead, as processMessages may be blocked delivering it a response.
+	message := &messagePacket{
+		Op:        MessageAbandon,
+		MessageID: messageID,
+	}
+	if !l.sendProcessMessage(message) {
+		return NewError(ErrorNetwork, errConnClosed)
+	}
+	return nil
+}
+
+// abandonMessage abandons the operation of msgCtx, whose responses are read by the caller.
+// The message is finished first, so that processMessages stops waiting for the caller to
+// receive the next response: otherwise, the queue of messages to processMessages may fill
+// up with the following responses, and the abandon request never be sent.
+func (l *Conn) abandonMessage(msgCtx *messageContext) error {
+	l.finishMessage(msgCtx)
+	return l.Abandon(msgCtx.id)
+}
+
+// newAbandonPacket returns the request with the given message ID abandoning the operation
+// with the abandoned message ID
+func newAbandonPacket(messageID, abandoned int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	packet.AppendChild(ber.NewInteger(ber.ClassApplication, ber.TypePrimitive, ApplicationAbandonRequest, abandoned, "Abandon Request"))
+	return packet
+}
@@ -10,7 +10,10 @@
 package ldap
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -55,17 +58,34 @@ func (req *AddRequest) appendTo(envelope *ber.Packet) error {
 
 	envelope.AppendChild(pkt)
 	if len(req.Controls) > 0 {
-		envelope.AppendChild(encodeControls(req.Controls))
+		envelope.AppendChild(EncodeControls(req.Controls))
 	}
 
 	return nil
 }
 
-// Attribute adds an attribute with the given type and values
+// Attribute adds an attribute with the given type and values. If the request already has
+// an attribute of this type, compared case insensitively, the values are appended to it.
 func (req *AddRequest) Attribute(attrType string, attrVals []string) {
+	for i := range req.Attributes {
+		if strings.EqualFold(req.Attributes[i].Type, attrType) {
+			req.Attributes[i].Vals = append(req.Attributes[i].Vals, attrVals...)
+			return
+		}
+	}
 	req.Attributes = append(req.Attributes, Attribute{Type: attrType, Vals: attrVals})
 }
 
+// AttributeBytes is like Attribute for binary values, such as the UTF-16LE encoded
+// unicodePwd values of Active Directory
+func (req *AddRequest) AttributeBytes(attrType string, attrVals [][]byte) {
+	vals := make([]string, len(attrVals))
+	for i, val := range attrVals {
+		vals[i] = string(val)
+	}
+	req.Attribute(attrType, vals)
+}
+
 // NewAddRequest returns an AddRequest for the given DN, with no attributes
 func NewAddRequest(dn string, controls []Control) *AddRequest {
 	return &AddRequest{
@@ -77,13 +97,17 @@ func NewAddRequest(dn string, controls []Control) *AddRequest {
 
 // Add performs the given AddRequest
 func (l *Conn) Add(addRequest *AddRequest) error {
-	msgCtx, err := l.doRequest(addRequest)
+	msgCtx, err := l.doRequest(context.Background(), addRequest)
 	if err != nil {
 		return err
 	}
 	defer l.finishMessage(msgCtx)
 
-	packet, err := l.readPacket(msgCtx)
+	return l.readAddResponse(context.Background(), msgCtx)
+}
+
+func (l *Conn) readAddResponse(ctx context.Context, msgCtx *messageContext) error {
+	packet, err := l.readPacket(ctx, msgCtx)
 	if err != nil {
 		return err
 	}
@@ -94,7 +118,72 @@ func (l *Conn) Add(addRequest *AddRequest) error {
 			return err
 		}
 	} else {
-		log.Printf("Unexpected Response: %d", packet.Children[1].Tag)
+		return NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
 	}
 	return nil
 }
+
+var errAddBatchStopped = errors.New("ldap: add abandoned after a previous add failed")
+
+// AddBatch performs the given add requests, sending them all before waiting for their
+// responses instead of waiting for a round trip per request, and writing them to the network
+// at once. errs holds the error of each request, nil if it succeeded.
+//
+// If stopOnError is true, once a request fails, the requests which are still waiting for
+// their response are abandoned, and their error has the ResultCode LDAPResultCanceled. The
+// server may however have added these entries before receiving the abandon request.
+//
+// err is not nil if not all the requests could be sent, for example because the connection
+// was closed. The requests which were not sent have the same error in errs.
+func (l *Conn) AddBatch(requests []*AddRequest, stopOnError bool) (errs []error, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type addResponse struct {
+		index int
+		err   error
+	}
+	responses := make(chan addResponse, len(requests))
+	errs = make([]error, len(requests))
+	messageIDs := make([]int64, 0, len(requests))
+	for i, addRequest := range requests {
+		msgCtx, sendErr := l.doRequestWithFlags(ctx, addRequest, noFlush)
+		if sendErr != nil {
+			err = sendErr
+			for j := i; j < len(requests); j++ {
+				errs[j] = sendErr
+			}
+			break
+		}
+		messageIDs = append(messageIDs, msgCtx.id)
+		go func(i int, msgCtx *messageContext) {
+			defer l.finishMessage(msgCtx)
+			responses <- addResponse{index: i, err: l.readAddResponse(ctx, msgCtx)}
+		}(i, msgCtx)
+	}
+
+	// a write error is also returned to the sent requests
+	l.Flush()
+
+	received := make([]bool, len(messageIDs))
+	stopped := false
+	for range messageIDs {
+		response := <-responses
+		if stopped && !received[response.index] {
+			continue
+		}
+		received[response.index] = true
+		errs[response.index] = response.err
+		if response.err != nil && stopOnError && !stopped {
+			stopped = true
+			for i, messageID := range messageIDs {
+				if !received[i] {
+					l.Abandon(messageID)
+					errs[i] = NewError(LDAPResultCanceled, errAddBatchStopped)
+				}
+			}
+			cancel()
+		}
+	}
+	return errs, err
+}
@@ -0,0 +1,146 @@
+package ldap
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func newTestAddRequests(n int) []*AddRequest {
+	var requests []*AddRequest
+	for i := 0; i < n; i++ {
+		request := NewAddRequest("cn=user"+string(rune('a'+i))+",dc=example,dc=com", nil)
+		request.Attribute("objectClass", []string{"person"})
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// TestAddBatch tests that the requests are all sent before waiting for the responses,
+// which are matched to the requests whatever their order.
+func TestAddBatch(t *testing.T) {
+	var pending []*ber.Packet
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		pending = append(pending, request)
+		if len(pending) < 3 {
+			return nil
+		}
+		var responses []*ber.Packet
+		for i := len(pending) - 1; i >= 0; i-- {
+			resultCode := uint16(LDAPResultSuccess)
+			if pending[i].Children[1].Children[0].Value.(string) == "cn=userb,dc=example,dc=com" {
+				resultCode = LDAPResultEntryAlreadyExists
+			}
+			responses = append(responses, newResultPacket(pending[i].Children[0].Value.(int64), ApplicationAddResponse, resultCode))
+		}
+		return responses
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		errs, err := conn.AddBatch(newTestAddRequests(3), false)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(errs) != 3 || errs[0] != nil || !IsErrorWithCode(errs[1], LDAPResultEntryAlreadyExists) || errs[2] != nil {
+			t.Errorf("unexpected errors %v", errs)
+		}
+	})
+}
+
+// TestAddBatchStopOnError tests that the requests waiting for a response are abandoned
+// after a failure when stopOnError is true.
+func TestAddBatchStopOnError(t *testing.T) {
+	var mu sync.Mutex
+	var addIDs, abandonedIDs []int64
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		mu.Lock()
+		defer mu.Unlock()
+		messageID := request.Children[0].Value.(int64)
+		switch request.Children[1].Tag {
+		case ApplicationAddRequest:
+			addIDs = append(addIDs, messageID)
+			if len(addIDs) == 3 {
+				// fail the first request once all have been received, and never answer the others
+				return []*ber.Packet{newResultPacket(addIDs[0], ApplicationAddResponse, LDAPResultInsufficientAccessRights)}
+			}
+		case ApplicationAbandonRequest:
+			abandonedID, err := ber.ParseInt64(request.Children[1].Data.Bytes())
+			if err != nil {
+				t.Errorf("invalid abandon request: %s", err)
+			}
+			abandonedIDs = append(abandonedIDs, abandonedID)
+		}
+		return nil
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		errs, err := conn.AddBatch(newTestAddRequests(3), true)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !IsErrorWithCode(errs[0], LDAPResultInsufficientAccessRights) ||
+			!IsErrorWithCode(errs[1], LDAPResultCanceled) || !IsErrorWithCode(errs[2], LDAPResultCanceled) {
+			t.Errorf("unexpected errors %v", errs)
+		}
+		for {
+			mu.Lock()
+			n := len(abandonedIDs)
+			mu.Unlock()
+			if n == 2 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if abandonedIDs[0] != addIDs[1] || abandonedIDs[1] != addIDs[2] {
+		t.Errorf("expected requests %v to be abandoned, got %v", addIDs[1:], abandonedIDs)
+	}
+}
+
+// TestAddBatchClosed tests that requests which could not be sent are reported.
+func TestAddBatchClosed(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet { return nil })
+	conn.Close()
+
+	errs, err := conn.AddBatch(newTestAddRequests(2), false)
+	if !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected ErrorNetwork, got %v", err)
+	}
+	if len(errs) != 2 || errs[0] != err || errs[1] != err {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+func TestAddRequestAttributes(t *testing.T) {
+	req := NewAddRequest("cn=user,dc=example,dc=com", nil)
+	req.Attribute("objectClass", []string{"top"})
+	req.AttributeBytes("unicodePwd", [][]byte{{'"', 0, 'p', 0, '"', 0}})
+	req.Attribute("objectclass", []string{"person"})
+
+	expected := []Attribute{
+		{Type: "objectClass", Vals: []string{"top", "person"}},
+		{Type: "unicodePwd", Vals: []string{"\"\x00p\x00\"\x00"}},
+	}
+	if !reflect.DeepEqual(req.Attributes, expected) {
+		t.Fatalf("got attributes %v, expected %v", req.Attributes, expected)
+	}
+
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	if err := req.appendTo(envelope); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	packet := ber.DecodePacket(envelope.Bytes())
+	value := packet.Children[0].Children[1].Children[1].Children[1].Children[0]
+	if !bytes.Equal(value.Data.Bytes(), []byte{'"', 0, 'p', 0, '"', 0}) {
+		t.Errorf("unexpected encoded binary value %x", value.Data.Bytes())
+	}
+}
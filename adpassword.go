@@ -0,0 +1,54 @@
+// This file contains the Active Directory password operations, which modify the
+// unicodePwd attribute
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/6e803168-f140-4d23-b2d3-c3a8ab5917d2
+//
+
+package ldap
+
+import (
+	"errors"
+)
+
+// EncodeADPassword returns the value of the unicodePwd attribute of Active Directory for
+// the given password: the password enclosed in double quotes, encoded in UTF-16LE
+func EncodeADPassword(password string) []byte {
+	return utf16LE(`"` + password + `"`)
+}
+
+// SetADPassword resets the password of the Active Directory user with the given DN, which
+// requires the right to reset passwords
+//
+// Active Directory only accepts password modifications over an encrypted connection:
+// an LDAPResultConfidentialityRequired error is returned if the connection does not use TLS.
+func (l *Conn) SetADPassword(dn, newPassword string) error {
+	if err := l.checkADPasswordTLS(); err != nil {
+		return err
+	}
+	req := NewModifyRequest(dn, nil)
+	req.Replace("unicodePwd", []string{string(EncodeADPassword(newPassword))})
+	return l.Modify(req)
+}
+
+// ChangeADPassword changes the password of the Active Directory user with the given DN
+// from oldPassword to newPassword, as the user would, which is subject to the password
+// policy of the domain
+//
+// Active Directory only accepts password modifications over an encrypted connection:
+// an LDAPResultConfidentialityRequired error is returned if the connection does not use TLS.
+func (l *Conn) ChangeADPassword(dn, oldPassword, newPassword string) error {
+	if err := l.checkADPasswordTLS(); err != nil {
+		return err
+	}
+	req := NewModifyRequest(dn, nil)
+	req.Delete("unicodePwd", []string{string(EncodeADPassword(oldPassword))})
+	req.Add("unicodePwd", []string{string(EncodeADPassword(newPassword))})
+	return l.Modify(req)
+}
+
+func (l *Conn) checkADPasswordTLS() error {
+	if !l.isTLS {
+		return NewError(LDAPResultConfidentialityRequired, errors.New("ldap: Active Directory requires TLS to modify passwords"))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestEncodeADPassword(t *testing.T) {
+	expected := []byte{'"', 0, 'p', 0, 0xe9, 0, '"', 0}
+	if encoded := EncodeADPassword("pé"); !bytes.Equal(encoded, expected) {
+		t.Errorf("got %x, expected %x", encoded, expected)
+	}
+}
+
+func TestChangeADPassword(t *testing.T) {
+	var changes []Change
+	ptc := newPacketTranslatorConn()
+	conn := NewConn(ptc, true)
+	conn.Start()
+	defer conn.Close()
+	go func() {
+		defer ptc.Close()
+		for {
+			request, err := ptc.ReceiveRequest()
+			if err != nil {
+				return
+			}
+			for _, change := range request.Children[1].Children[1].Children {
+				changes = append(changes, Change{
+					Operation:    uint(change.Children[0].Value.(int64)),
+					Modification: PartialAttribute{Type: change.Children[1].Children[0].Value.(string), Vals: []string{change.Children[1].Children[1].Children[0].Data.String()}},
+				})
+			}
+			ptc.SendResponse(newResultPacket(request.Children[0].Value.(int64), ApplicationModifyResponse, LDAPResultSuccess))
+		}
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.ChangeADPassword("cn=user,dc=example,dc=com", "old", "new"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := conn.SetADPassword("cn=user,dc=example,dc=com", "reset"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	expected := []Change{
+		{DeleteAttribute, PartialAttribute{"unicodePwd", []string{string(EncodeADPassword("old"))}}},
+		{AddAttribute, PartialAttribute{"unicodePwd", []string{string(EncodeADPassword("new"))}}},
+		{ReplaceAttribute, PartialAttribute{"unicodePwd", []string{string(EncodeADPassword("reset"))}}},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %d", len(expected), len(changes))
+	}
+	for i := range expected {
+		if changes[i].Operation != expected[i].Operation || changes[i].Modification.Type != expected[i].Modification.Type || changes[i].Modification.Vals[0] != expected[i].Modification.Vals[0] {
+			t.Errorf("change %d: got %+v, expected %+v", i, changes[i], expected[i])
+		}
+	}
+}
+
+func TestSetADPasswordWithoutTLS(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		t.Errorf("unexpected request")
+		return nil
+	})
+	defer conn.Close()
+
+	if err := conn.SetADPassword("cn=user,dc=example,dc=com", "new"); !IsErrorWithCode(err, LDAPResultConfidentialityRequired) {
+		t.Errorf("expected LDAPResultConfidentialityRequired, got %v", err)
+	}
+	if err := conn.ChangeADPassword("cn=user,dc=example,dc=com", "old", "new"); !IsErrorWithCode(err, LDAPResultConfidentialityRequired) {
+		t.Errorf("expected LDAPResultConfidentialityRequired, got %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -43,7 +45,7 @@ func (req *SimpleBindRequest) appendTo(envelope *ber.Packet) error {
 
 	envelope.AppendChild(pkt)
 	if len(req.Controls) > 0 {
-		envelope.AppendChild(encodeControls(req.Controls))
+		envelope.AppendChild(EncodeControls(req.Controls))
 	}
 
 	return nil
@@ -51,17 +53,39 @@ func (req *SimpleBindRequest) appendTo(envelope *ber.Packet) error {
 
 // SimpleBind performs the simple bind operation defined in the given request
 func (l *Conn) SimpleBind(simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
+	return l.SimpleBindWithContext(context.Background(), simpleBindRequest)
+}
+
+// SimpleBindWithContext performs the simple bind operation defined in the given request.
+// If ctx is cancelled or its deadline is exceeded before the server responds, ctx.Err()
+// is returned. The bind is retried as specified by WithRetry.
+func (l *Conn) SimpleBindWithContext(ctx context.Context, simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
 	if simpleBindRequest.Password == "" && !simpleBindRequest.AllowEmptyPassword {
 		return nil, NewError(ErrorEmptyPassword, errors.New("ldap: empty password not allowed by the client"))
 	}
 
-	msgCtx, err := l.doRequest(simpleBindRequest)
+	var result *SimpleBindResult
+	err := l.withRetry(ctx, func() error {
+		var err error
+		result, err = l.simpleBind(ctx, simpleBindRequest)
+		return err
+	})
+	request := *simpleBindRequest
+	l.recordBind(&err, func(conn *Conn) error {
+		_, err := conn.SimpleBind(&request)
+		return err
+	})
+	return result, err
+}
+
+func (l *Conn) simpleBind(ctx context.Context, simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
+	msgCtx, err := l.doRequest(ctx, simpleBindRequest)
 	if err != nil {
 		return nil, err
 	}
 	defer l.finishMessage(msgCtx)
 
-	packet, err := l.readPacket(msgCtx)
+	packet, err := l.readPacket(ctx, msgCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -98,47 +122,74 @@ func (l *Conn) Bind(username, password string) error {
 	return err
 }
 
-// SASLBind performs a SASL bind operation with the given mechanism and credentials
-func (l *Conn) SASLBind(mechanism string, credentials []byte) ([]byte, error) {
-	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
-	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, l.nextMessageID(), "MessageID"))
-	bindRequest := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindRequest, nil, "Bind Request")
-	bindRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
-	bindRequest.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Name"))
-	saslCreds := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "SaslCredentials")
-	saslCreds.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, mechanism, "Mechanism"))
-	saslCreds.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(credentials), "Credentials"))
-	bindRequest.AppendChild(saslCreds)
-	packet.AppendChild(bindRequest)
-
-	if l.Debug {
-		ber.PrintPacket(packet)
-	}
-
-	msgCtx, err := l.sendMessage(packet)
+// SupportedSASLMechanisms returns the SASL mechanisms advertised by the server in the
+// supportedSASLMechanisms attribute of the RootDSE.
+func (l *Conn) SupportedSASLMechanisms() ([]string, error) {
+	rootDSE, err := l.RootDSE(RootDSEsupportedSASLMechanisms)
 	if err != nil {
 		return nil, err
 	}
-	defer l.finishMessage(msgCtx)
+	return rootDSE.GetAttributeValues(RootDSEsupportedSASLMechanisms), nil
+}
 
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return nil, NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
+// NegotiateSASL returns the first mechanism of preferenceOrder which is advertised by the
+// server, comparing the names case-insensitively. An error with the ResultCode
+// LDAPResultAuthMethodNotSupported is returned if the server supports none of them.
+func (l *Conn) NegotiateSASL(preferenceOrder []string) (string, error) {
+	supported, err := l.SupportedSASLMechanisms()
+	if err != nil {
+		return "", err
 	}
-	packet, err = packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
+	for _, mechanism := range preferenceOrder {
+		for _, s := range supported {
+			if strings.EqualFold(mechanism, s) {
+				return mechanism, nil
+			}
+		}
+	}
+	return "", NewError(LDAPResultAuthMethodNotSupported, fmt.Errorf("ldap: the server supports none of the SASL mechanisms %v", preferenceOrder))
+}
+
+// SASLBind performs a SASL bind operation with the given mechanism and credentials
+func (l *Conn) SASLBind(mechanism string, credentials []byte) ([]byte, error) {
+	return l.SASLBindWithContext(context.Background(), mechanism, credentials)
+}
+
+// SASLBindWithContext performs a SASL bind operation with the given mechanism and credentials.
+// If ctx is cancelled or its deadline is exceeded before the server responds, ctx.Err()
+// is returned.
+//
+// Multi-step mechanisms are driven by calling it once per step: while the exchange is
+// not complete, the returned error has the ResultCode LDAPResultSaslBindInProgress and
+// the server challenge is returned alongside it.
+func (l *Conn) SASLBindWithContext(ctx context.Context, mechanism string, credentials []byte) ([]byte, error) {
+	req := requestFunc(func(envelope *ber.Packet) error {
+		bindRequest := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindRequest, nil, "Bind Request")
+		bindRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
+		bindRequest.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Name"))
+		saslCreds := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "SaslCredentials")
+		saslCreds.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, mechanism, "Mechanism"))
+		saslCreds.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(credentials), "Credentials"))
+		bindRequest.AppendChild(saslCreds)
+		envelope.AppendChild(bindRequest)
+		return nil
+	})
+
+	msgCtx, err := l.doRequest(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	defer l.finishMessage(msgCtx)
 
-	if l.Debug {
-		if err := addLDAPDescriptions(packet); err != nil {
-			return nil, err
-		}
-		ber.PrintPacket(packet)
+	packet, err := l.readPacket(ctx, msgCtx)
+	if err != nil {
+		return nil, err
 	}
 
 	resultCode, resultToken, resultDescription := getSASLBindResultCode(packet)
+	if resultCode == LDAPResultSaslBindInProgress {
+		return resultToken, NewError(resultCode, errors.New(resultDescription))
+	}
 	if resultCode != 0 {
 		return nil, NewError(resultCode, errors.New(resultDescription))
 	}
@@ -156,13 +207,21 @@ func getSASLBindResultCode(packet *ber.Packet) (code uint16, token []byte, descr
 		}
 		if response.ClassType == ber.ClassApplication && response.TagType == ber.TypeConstructed && len(response.Children) >= 3 {
 			// Children[1].Children[2] is the diagnosticMessage which is guaranteed to exist as seen here: https://tools.ietf.org/html/rfc4511#section-4.1.9
-			code = uint16(response.Children[0].Value.(int64))
-			description = response.Children[2].Value.(string)
-			if len(response.Children) >= 4 {
-				responseToken := response.Children[3]
-				token = responseToken.Data.Bytes()
+			resultCode, ok := response.Children[0].Value.(int64)
+			if !ok {
+				return ErrorUnexpectedResponse, nil, "ldap: invalid result code in bind response"
 			}
-			return code, token, description
+			description, ok = response.Children[2].Value.(string)
+			if !ok {
+				return ErrorUnexpectedResponse, nil, "ldap: invalid diagnostic message in bind response"
+			}
+			for _, child := range response.Children[3:] {
+				// serverSaslCreds [7], after the optional referral [3]
+				if child.ClassType == ber.ClassContext && child.Tag == 7 {
+					token = child.Data.Bytes()
+				}
+			}
+			return uint16(resultCode), token, description
 		}
 	}
 
@@ -186,21 +245,25 @@ func (l *Conn) UnauthenticatedBind(username string) error {
 	return err
 }
 
-var externalBindRequest = requestFunc(func(envelope *ber.Packet) error {
-	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindRequest, nil, "Bind Request")
-	pkt.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
-	pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "User Name"))
+// newExternalBindRequest returns a SASL/EXTERNAL bind request with the given authorization
+// identity, which is empty to use the identity derived from the client credentials
+func newExternalBindRequest(authzID string) requestFunc {
+	return requestFunc(func(envelope *ber.Packet) error {
+		pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindRequest, nil, "Bind Request")
+		pkt.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
+		pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "User Name"))
 
-	saslAuth := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, "", "authentication")
-	saslAuth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "EXTERNAL", "SASL Mech"))
-	saslAuth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "SASL Cred"))
+		saslAuth := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, "", "authentication")
+		saslAuth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "EXTERNAL", "SASL Mech"))
+		saslAuth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, authzID, "SASL Cred"))
 
-	pkt.AppendChild(saslAuth)
+		pkt.AppendChild(saslAuth)
 
-	envelope.AppendChild(pkt)
+		envelope.AppendChild(pkt)
 
-	return nil
-})
+		return nil
+	})
+}
 
 // ExternalBind performs SASL/EXTERNAL authentication.
 //
@@ -208,13 +271,34 @@ var externalBindRequest = requestFunc(func(envelope *ber.Packet) error {
 //
 // See https://tools.ietf.org/html/rfc4422#appendix-A
 func (l *Conn) ExternalBind() error {
-	msgCtx, err := l.doRequest(externalBindRequest)
+	return l.ExternalBindWithAuthzID("")
+}
+
+// ExternalBindWithAuthzID performs SASL/EXTERNAL authentication requesting the authorization
+// identity authzID, such as "dn:uid=joe,dc=example,dc=com" or "u:joe", for servers which can
+// map the client credentials, for example a client certificate, to several identities.
+//
+// The bind is retried as specified by WithRetry.
+//
+// See https://tools.ietf.org/html/rfc4422#appendix-A
+func (l *Conn) ExternalBindWithAuthzID(authzID string) error {
+	err := l.withRetry(context.Background(), func() error {
+		return l.externalBind(authzID)
+	})
+	l.recordBind(&err, func(conn *Conn) error {
+		return conn.ExternalBindWithAuthzID(authzID)
+	})
+	return err
+}
+
+func (l *Conn) externalBind(authzID string) error {
+	msgCtx, err := l.doRequest(context.Background(), newExternalBindRequest(authzID))
 	if err != nil {
 		return err
 	}
 	defer l.finishMessage(msgCtx)
 
-	packet, err := l.readPacket(msgCtx)
+	packet, err := l.readPacket(context.Background(), msgCtx)
 	if err != nil {
 		return err
 	}
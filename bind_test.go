@@ -0,0 +1,125 @@
+package ldap
+
+import (
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestGetSASLBindResultCode(t *testing.T) {
+	packet := ber.DecodePacket(newSASLBindResponsePacket(1, LDAPResultSaslBindInProgress, []byte("challenge")).Bytes())
+	code, token, _ := getSASLBindResultCode(packet)
+	if code != LDAPResultSaslBindInProgress || string(token) != "challenge" {
+		t.Errorf("unexpected result %d with token %q", code, token)
+	}
+}
+
+func TestGetSASLBindResultCodeMalformed(t *testing.T) {
+	newResponse := func(children ...*ber.Packet) *ber.Packet {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+		response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindResponse, nil, "Bind Response")
+		for _, child := range children {
+			response.AppendChild(child)
+		}
+		packet.AppendChild(response)
+		return ber.DecodePacket(packet.Bytes())
+	}
+	str := func(s string) *ber.Packet {
+		return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, s, "string")
+	}
+	integer := func(i int64) *ber.Packet {
+		return ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, i, "integer")
+	}
+
+	packets := []*ber.Packet{
+		nil,
+		newResponse(),
+		newResponse(integer(0), str("")),
+		newResponse(str("0"), str(""), str("")),
+		newResponse(integer(0), str(""), integer(0)),
+		newResponse(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "sequence"), str(""), str("")),
+	}
+	// every truncation of a valid response which can still be decoded
+	valid := newSASLBindResponsePacket(1, LDAPResultSaslBindInProgress, []byte("challenge")).Bytes()
+	for i := range valid {
+		if packet, err := ber.DecodePacketErr(valid[:i]); err == nil {
+			packets = append(packets, packet)
+		}
+	}
+
+	for _, packet := range packets {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("panic decoding %v: %v", packet, r)
+				}
+			}()
+			if code, _, _ := getSASLBindResultCode(packet); code != ErrorUnexpectedResponse && code != ErrorNetwork {
+				t.Errorf("expected an error result code decoding %v, got %d", packet, code)
+			}
+		}()
+	}
+}
+
+func TestNegotiateSASL(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Attribute Values")
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "EXTERNAL", "Attribute Value"))
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "GSSAPI", "Attribute Value"))
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, RootDSEsupportedSASLMechanisms, "Attribute Name"))
+		attribute.AppendChild(values)
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		attributes.AppendChild(attribute)
+		entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+		entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Object Name"))
+		entry.AppendChild(attributes)
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+		packet.AppendChild(entry)
+		return []*ber.Packet{
+			packet,
+			newSearchResultDonePacket(messageID, LDAPResultSuccess),
+		}
+	})
+	defer conn.Close()
+
+	mechanisms, err := conn.SupportedSASLMechanisms()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(mechanisms) != 2 || mechanisms[0] != "EXTERNAL" || mechanisms[1] != "GSSAPI" {
+		t.Errorf("unexpected mechanisms %v", mechanisms)
+	}
+
+	mechanism, err := conn.NegotiateSASL([]string{"SCRAM-SHA-256", "gssapi", "EXTERNAL"})
+	if err != nil || mechanism != "gssapi" {
+		t.Errorf("expected gssapi, got %q (%v)", mechanism, err)
+	}
+	if _, err := conn.NegotiateSASL([]string{"DIGEST-MD5"}); !IsErrorWithCode(err, LDAPResultAuthMethodNotSupported) {
+		t.Errorf("expected LDAPResultAuthMethodNotSupported, got %v", err)
+	}
+}
+
+func TestExternalBindWithAuthzID(t *testing.T) {
+	var credentials []string
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		saslAuth := request.Children[1].Children[2]
+		credentials = append(credentials, saslAuth.Children[1].Data.String())
+		return []*ber.Packet{newResultPacket(messageID, ApplicationBindResponse, LDAPResultSuccess)}
+	})
+	defer conn.Close()
+
+	if err := conn.ExternalBind(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.ExternalBindWithAuthzID("dn:uid=joe,dc=example,dc=com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(credentials) != 2 || credentials[0] != "" || credentials[1] != "dn:uid=joe,dc=example,dc=com" {
+		t.Errorf("unexpected SASL credentials %q", credentials)
+	}
+}
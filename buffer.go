@@ -2,6 +2,8 @@ package ldap
 
 import (
 	"bufio"
+	"fmt"
+	"math"
 	"net"
 )
 
@@ -25,3 +27,63 @@ func (b bufferedConn) Peek(n int) ([]byte, error) {
 func (b bufferedConn) Read(p []byte) (int, error) {
 	return b.r.Read(p)
 }
+
+// connWriter writes to the network connection of a Conn, which is replaced by StartTLS
+type connWriter struct {
+	l *Conn
+}
+
+func (w connWriter) Write(p []byte) (int, error) {
+	return w.l.conn.Write(p)
+}
+
+// peekPacketLength returns the content length of the next BER packet without consuming
+// its header, or -1 if the packet has an indefinite length
+func (b bufferedConn) peekPacketLength() (int64, error) {
+	// identifier octets: the tag number continues while the high bit is set in the
+	// octets following a high tag number form first octet
+	n := 1
+	header, err := b.r.Peek(n)
+	if err != nil {
+		return 0, err
+	}
+	if header[0]&0x1f == 0x1f {
+		for {
+			n++
+			if header, err = b.r.Peek(n); err != nil {
+				return 0, err
+			}
+			if header[n-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+
+	// length octets
+	n++
+	if header, err = b.r.Peek(n); err != nil {
+		return 0, err
+	}
+	first := header[n-1]
+	if first&0x80 == 0 {
+		return int64(first), nil
+	}
+	count := int(first & 0x7f)
+	if count == 0 {
+		return -1, nil
+	}
+	if count > 8 {
+		return 0, fmt.Errorf("ldap: invalid BER length of %d octets", count)
+	}
+	if header, err = b.r.Peek(n + count); err != nil {
+		return 0, err
+	}
+	var length uint64
+	for _, octet := range header[n : n+count] {
+		length = length<<8 | uint64(octet)
+	}
+	if length > math.MaxInt64 {
+		return 0, fmt.Errorf("ldap: invalid BER length %d", length)
+	}
+	return int64(length), nil
+}
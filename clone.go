@@ -0,0 +1,60 @@
+package ldap
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// recordBind records rebind, which replays a bind on another connection, if *err is nil
+// once the bind completed. The credentials it holds are kept in memory only, and are
+// never exposed by the Conn.
+func (l *Conn) recordBind(err *error, rebind func(*Conn) error) {
+	if *err != nil {
+		return
+	}
+	l.bindMutex.Lock()
+	l.rebind = rebind
+	l.bindMutex.Unlock()
+}
+
+// Clone establishes a new connection configured as this one: it connects to the same URL
+// with the options given to DialURL or DialDomain, upgrades the connection with StartTLS
+// if this one was, and replays the last successful bind of this connection, so that the
+// new connection has the same identity. The timeout and the maximum packet size are copied.
+//
+// An error is returned if this connection was not established by DialURL or DialDomain,
+// or has never been bound. Binds performed with SASLBind, whose exchange is driven by the
+// caller, are not replayed, and a Kerberos bind is replayed with the same GSSAPIClient.
+func (l *Conn) Clone() (*Conn, error) {
+	if l.dialOpts == nil {
+		return nil, NewError(ErrorNetwork, errors.New("ldap: only the connections established by DialURL can be cloned"))
+	}
+	l.bindMutex.Lock()
+	rebind := l.rebind
+	l.bindMutex.Unlock()
+	if rebind == nil {
+		return nil, NewError(LDAPResultOperationsError, errors.New("ldap: the connection to clone was never bound"))
+	}
+	l.messageMutex.Lock()
+	startTLSConfig := l.startTLSConfig
+	l.messageMutex.Unlock()
+
+	conn, err := dialURL(l.dialAddr, *l.dialOpts)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(time.Duration(atomic.LoadInt64(&l.requestTimeout)))
+	conn.SetMaxPacketSize(int(atomic.LoadInt64(&l.maxPacketSize)))
+	if startTLSConfig != nil {
+		if err := conn.StartTLS(cloneTLSConfig(startTLSConfig)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := rebind(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,67 @@
+package ldap
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	binds := make(chan string, 10)
+	dialer := &testDialer{dial: func(ctx context.Context) (net.Conn, error) {
+		ptc := newPacketTranslatorConn()
+		go func() {
+			defer ptc.Close()
+			for {
+				request, err := ptc.ReceiveRequest()
+				if err != nil {
+					return
+				}
+				messageID := request.Children[0].Value.(int64)
+				bind := request.Children[1]
+				binds <- bind.Children[1].Value.(string) + ":" + string(bind.Children[2].Data.Bytes())
+				if err := ptc.SendResponse(newResultPacket(messageID, ApplicationBindResponse, LDAPResultSuccess)); err != nil {
+					return
+				}
+			}
+		}()
+		return ptc, nil
+	}}
+
+	runWithTimeout(t, time.Second, func() {
+		conn, err := DialURL("ldap://ldap.example.com", WithDialer(dialer))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+		conn.SetTimeout(time.Minute)
+
+		if _, err := conn.Clone(); !IsErrorWithCode(err, LDAPResultOperationsError) {
+			t.Errorf("expected an error cloning a connection never bound, got %v", err)
+		}
+
+		if err := conn.Bind("cn=joe,dc=example,dc=com", "secret"); err != nil {
+			t.Fatalf("unexpected bind error: %s", err)
+		}
+		<-binds
+		clone, err := conn.Clone()
+		if err != nil {
+			t.Fatalf("unexpected clone error: %s", err)
+		}
+		defer clone.Close()
+		if bind := <-binds; bind != "cn=joe,dc=example,dc=com:secret" {
+			t.Errorf("unexpected replayed bind %q", bind)
+		}
+		if clone == conn || clone.requestTimeout != conn.requestTimeout {
+			t.Errorf("expected a new connection with the same timeout")
+		}
+	})
+	if len(dialer.addrs) != 2 || dialer.addrs[1] != "tcp ldap.example.com:389" {
+		t.Errorf("unexpected dialed addresses %v", dialer.addrs)
+	}
+
+	if _, err := NewConn(newPacketTranslatorConn(), false).Clone(); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected an error cloning a connection not established by DialURL, got %v", err)
+	}
+}
@@ -20,6 +20,7 @@
 package ldap
 
 import (
+	"context"
 	"fmt"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
@@ -30,6 +31,8 @@ type CompareRequest struct {
 	DN        string
 	Attribute string
 	Value     string
+	// Controls hold optional controls to send with the request
+	Controls []Control
 }
 
 func (req *CompareRequest) appendTo(envelope *ber.Packet) error {
@@ -38,11 +41,14 @@ func (req *CompareRequest) appendTo(envelope *ber.Packet) error {
 
 	ava := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "AttributeValueAssertion")
 	ava.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, req.Attribute, "AttributeDesc"))
-	ava.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, req.Value, "AssertionValue"))
+	ava.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, req.Value, "AssertionValue"))
 
 	pkt.AppendChild(ava)
 
 	envelope.AppendChild(pkt)
+	if len(req.Controls) > 0 {
+		envelope.AppendChild(EncodeControls(req.Controls))
+	}
 
 	return nil
 }
@@ -50,16 +56,23 @@ func (req *CompareRequest) appendTo(envelope *ber.Packet) error {
 // Compare checks to see if the attribute of the dn matches value. Returns true if it does otherwise
 // false with any error that occurs if any.
 func (l *Conn) Compare(dn, attribute, value string) (bool, error) {
-	msgCtx, err := l.doRequest(&CompareRequest{
+	return l.CompareWithControls(dn, attribute, value, nil)
+}
+
+// CompareWithControls is like Compare, sending the given controls with the request.
+// The value is sent as is, it must not be escaped.
+func (l *Conn) CompareWithControls(dn, attribute, value string, controls []Control) (bool, error) {
+	msgCtx, err := l.doRequest(context.Background(), &CompareRequest{
 		DN:        dn,
 		Attribute: attribute,
-		Value:     value})
+		Value:     value,
+		Controls:  controls})
 	if err != nil {
 		return false, err
 	}
 	defer l.finishMessage(msgCtx)
 
-	packet, err := l.readPacket(msgCtx)
+	packet, err := l.readPacket(context.Background(), msgCtx)
 	if err != nil {
 		return false, err
 	}
@@ -76,5 +89,5 @@ func (l *Conn) Compare(dn, attribute, value string) (bool, error) {
 			return false, err
 		}
 	}
-	return false, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag)
+	return false, NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
 }
@@ -0,0 +1,62 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestCompareWithControls(t *testing.T) {
+	testcases := []struct {
+		resultCode uint16
+		expected   bool
+		errCode    uint16
+	}{
+		{LDAPResultCompareTrue, true, 0},
+		{LDAPResultCompareFalse, false, 0},
+		{LDAPResultNoSuchObject, false, LDAPResultNoSuchObject},
+	}
+
+	for _, tc := range testcases {
+		ptc := newPacketTranslatorConn()
+		conn := NewConn(ptc, false)
+		conn.Start()
+
+		go func(resultCode uint16) {
+			request, err := ptc.ReceiveRequest()
+			if err != nil {
+				return
+			}
+			if len(request.Children) != 3 {
+				t.Errorf("expected controls to be sent with the request")
+			}
+			if value := request.Children[1].Children[1].Children[1].Data.String(); value != "a*b" {
+				t.Errorf("got assertion value %q, expected %q", value, "a*b")
+			}
+			packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, request.Children[0].Value.(int64), "MessageID"))
+			response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationCompareResponse, nil, "Compare Response")
+			response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+			response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+			response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+			packet.AppendChild(response)
+			ptc.SendResponse(packet)
+		}(tc.resultCode)
+
+		runWithTimeout(t, time.Second, func() {
+			result, err := conn.CompareWithControls("cn=group,dc=example,dc=com", "member", "a*b", []Control{NewControlManageDsaIT(false)})
+			if tc.errCode != 0 {
+				if !IsErrorWithCode(err, tc.errCode) {
+					t.Errorf("expected error with code %d, got %v", tc.errCode, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if result != tc.expected {
+				t.Errorf("got %t, expected %t", result, tc.expected)
+			}
+		})
+		conn.Close()
+	}
+}
@@ -1,6 +1,8 @@
 package ldap
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -8,6 +10,8 @@ import (
 	"log"
 	"net"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -26,6 +30,10 @@ const (
 	MessageFinish = 3
 	// MessageTimeout indicates the client-specified timeout for a particular message ID has been reached
 	MessageTimeout = 4
+	// MessageAbandon sends an abandon request and releases the message context of the abandoned message ID
+	MessageAbandon = 5
+	// MessageFlush writes the buffered requests to the network
+	MessageFlush = 6
 )
 
 const (
@@ -35,6 +43,8 @@ const (
 	DefaultLdapsPort = "636"
 )
 
+var errConnClosed = errors.New("ldap: connection closed")
+
 // PacketResponse contains the packet or error encountered reading a response
 type PacketResponse struct {
 	// Packet is the packet read from the server
@@ -53,10 +63,25 @@ func (pr *PacketResponse) ReadPacket() (*ber.Packet, error) {
 
 type messageContext struct {
 	id int64
-	// close(done) should only be called from finishMessage()
-	done chan struct{}
+	// close(done) should only be called from finishMessage(), once
+	done   chan struct{}
+	finish sync.Once
 	// close(responses) should only be called from processMessages(), and only sent to from sendResponse()
 	responses chan *PacketResponse
+	// observation is nil if no Observer is set
+	observation *observation
+	// timer triggers the timeout set with SetTimeout, and is nil if there is none.
+	// timer, timeout and lastActivity are only used within the processMessages() loop.
+	timer        *time.Timer
+	timeout      time.Duration
+	lastActivity time.Time
+}
+
+// stopTimer stops the timeout of the message, if any
+func (msgCtx *messageContext) stopTimer() {
+	if msgCtx.timer != nil {
+		msgCtx.timer.Stop()
+	}
 }
 
 // sendResponse should only be called within the processMessages() loop which
@@ -76,24 +101,47 @@ type messagePacket struct {
 	MessageID int64
 	Packet    *ber.Packet
 	Context   *messageContext
+	// Flush is set if the buffered requests must be written to the network after the request
+	Flush bool
+	// NoTimeout is set if the timeout set with SetTimeout does not apply to the request
+	NoTimeout bool
+	// Done receives the result of a MessageFlush
+	Done chan error
 }
 
 type sendMessageFlags uint
 
 const (
 	startTLS sendMessageFlags = 1 << iota
+	// noFlush leaves the request buffered even if auto flush is enabled
+	noFlush
+	// noTimeout exempts the request from the timeout set with SetTimeout, as the caller
+	// enforces its own deadline
+	noTimeout
 )
 
-// Conn represents an LDAP Connection
+// Conn represents an LDAP Connection.
+//
+// A Conn is safe for concurrent use by multiple goroutines once started: the requests of
+// concurrent operations, such as Search, Compare or Modify, are written one at a time, and
+// each response is returned to the caller of its operation by message ID, so that a slow
+// operation does not delay the others. The authentication state is shared by all the
+// operations: a Bind changes the identity of the operations sent after it, and StartTLS
+// fails while other operations are in progress. The Debug field should be configured
+// before the connection is shared.
 type Conn struct {
 	// requestTimeout is loaded atomically
 	// so we need to ensure 64-bit alignment on 32-bit platforms.
-	requestTimeout      int64
+	requestTimeout int64
+	// maxPacketSize is loaded atomically too
+	maxPacketSize       int64
+	manualFlush         uint32
 	conn                bufferedConn
 	isTLS               bool
 	closing             uint32
 	closeErr            atomic.Value
 	isStartingTLS       bool
+	isStartTLS          bool
 	Debug               debugging
 	chanConfirm         chan struct{}
 	messageContexts     map[int64]*messageContext
@@ -102,9 +150,35 @@ type Conn struct {
 	wgClose             sync.WaitGroup
 	outstandingRequests uint
 	messageMutex        sync.Mutex
-	handlersMutex       sync.Mutex
-	wrHandler           func(*ber.Packet) ([]byte, error)
-	rdHandler           func(reader io.Reader) ([]*ber.Packet, error)
+	// closeMutex is read locked while sending to chanMessage, and locked by Close to mark
+	// the connection as closing, so that no message is sent after the quit message
+	closeMutex     sync.RWMutex
+	handlersMutex  sync.Mutex
+	wrHandler      func(*ber.Packet) ([]byte, error)
+	rdHandler      func(reader io.Reader) ([]*ber.Packet, error)
+	referralConfig *ReferralConfig
+	observer       Observer
+	// logger holds a loggerRef
+	logger atomic.Value
+	// showCredentials is 1 if the credentials are printed in the debug output, see
+	// SetRedactCredentials
+	showCredentials  uint32
+	disconnectNotify chan *DisconnectNotification
+	// tlsConfig is the configuration set with WithTLSConfig, if any
+	tlsConfig *tls.Config
+	// serverHost is the host name of the server given to Dial, DialTLS or DialURL, empty
+	// for the connections created with NewConn and the Unix socket connections
+	serverHost string
+	// dialAddr and dialOpts are the URL and options of DialURL, used by Clone
+	dialAddr string
+	dialOpts *dialOptions
+	// startTLSConfig is the configuration of a successful StartTLS, protected by messageMutex
+	startTLSConfig *tls.Config
+	// rebind replays the last successful bind, see Clone
+	bindMutex sync.Mutex
+	rebind    func(*Conn) error
+	// retryPolicy holds the RetryPolicy set with WithRetry
+	retryPolicy atomic.Value
 }
 
 func defaultWriteHandler(p *ber.Packet) ([]byte, error) {
@@ -119,12 +193,16 @@ func defaultReadHandler(reader io.Reader) ([]*ber.Packet, error) {
 var _ Client = &Conn{}
 
 // DefaultTimeout is a package-level variable that sets the timeout value
-// used for the Dial and DialTLS methods.
+// used for the Dial and DialTLS methods, and by DialURL unless WithConnectTimeout is set.
 //
 // WARNING: since this is a package-level variable, setting this value from
 // multiple places will probably result in undesired behaviour.
 var DefaultTimeout = 60 * time.Second
 
+// DefaultMaxPacketSize is the default maximum size in bytes of the responses read by a
+// Conn, see SetMaxPacketSize.
+const DefaultMaxPacketSize = 16 << 20
+
 // Dial connects to the given address on the given network using net.Dial
 // and then returns a new Conn for the connection.
 func Dial(network, addr string) (*Conn, error) {
@@ -133,6 +211,7 @@ func Dial(network, addr string) (*Conn, error) {
 		return nil, NewError(ErrorNetwork, err)
 	}
 	conn := NewConn(c, false)
+	conn.serverHost = addrHost(addr)
 	conn.Start()
 	return conn, nil
 }
@@ -145,60 +224,288 @@ func DialTLS(network, addr string, config *tls.Config) (*Conn, error) {
 		return nil, NewError(ErrorNetwork, err)
 	}
 	conn := NewConn(c, true)
+	conn.serverHost = addrHost(addr)
 	conn.Start()
 	return conn, nil
 }
 
+// addrHost returns the host of an address given to Dial, such as "ldap.example.com:389"
+func addrHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// ContextDialer establishes the network connections of DialURL. It is implemented by
+// *net.Dialer, and by the SOCKS5 dialer of golang.org/x/net/proxy.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialOpt configures DialURL
+type DialOpt func(*dialOptions)
+
+type dialOptions struct {
+	dialer    ContextDialer
+	tlsConfig *tls.Config
+	ctx       context.Context
+	timeout   time.Duration
+	srvTLS    bool
+}
+
+// WithDialer makes DialURL establish the connection with dialer, for example a *net.Dialer
+// with a local address or a custom resolver, or a dialer going through a proxy. The
+// ldaps:// TLS handshake is performed over the established connection.
+func WithDialer(dialer ContextDialer) DialOpt {
+	return func(o *dialOptions) {
+		o.dialer = dialer
+	}
+}
+
+// WithDialContext makes DialURL give up establishing the connection when ctx is done. ctx
+// has no effect on the connection once it is established.
+func WithDialContext(ctx context.Context) DialOpt {
+	return func(o *dialOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithConnectTimeout sets the time within which DialURL must establish the connection,
+// including the proxy handshake of a dialer set with WithDialer and the TLS handshake.
+// It defaults to DefaultTimeout, and there is no timeout if it is zero.
+func WithConnectTimeout(timeout time.Duration) DialOpt {
+	return func(o *dialOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the connections established by DialURL, for
+// example to require a minimum TLS version or a set of cipher suites. It is used for the
+// TLS handshake of ldaps:// URLs, and by StartTLS when called with a nil config. If its
+// ServerName is empty, the host of the URL is used.
+func WithTLSConfig(config *tls.Config) DialOpt {
+	return func(o *dialOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// WithSRVTLS makes DialDomain look up the _ldaps._tcp SRV records of the domain instead
+// of the _ldap._tcp ones, and connect with TLS to the ports they give. It has no effect on
+// DialURL. Servers which publish only _ldap._tcp records, such as most Active Directory
+// domain controllers, can be secured with StartTLS instead.
+func WithSRVTLS() DialOpt {
+	return func(o *dialOptions) {
+		o.srvTLS = true
+	}
+}
+
+// lookupSRV resolves SRV records, replaced in tests
+var lookupSRV = net.LookupSRV
+
+// DialDomain connects to a server of the given domain, such as an Active Directory domain
+// controller, located with the _ldap._tcp.<domain> SRV records of the DNS. The servers
+// are tried in the order of the records, sorted by priority and randomized by weight as
+// specified in rfc2782, until a connection is established with the given options, see
+// DialURL and WithSRVTLS. If no connection can be established, the returned error lists
+// the error of each attempt.
+func DialDomain(domain string, opts ...DialOpt) (*Conn, error) {
+	var options dialOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	service, scheme := "ldap", "ldap"
+	if options.srvTLS {
+		service, scheme = "ldaps", "ldaps"
+	}
+
+	_, records, err := lookupSRV(service, "tcp", domain)
+	if err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+	if len(records) == 0 {
+		return nil, NewError(ErrorNetwork, fmt.Errorf("ldap: no _%s._tcp SRV record for %s", service, domain))
+	}
+
+	var errs []string
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		addr := fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+		conn, err := DialURL(addr, opts...)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", addr, err))
+	}
+	return nil, NewError(ErrorNetwork, fmt.Errorf("ldap: cannot connect to a server of %s: %s", domain, strings.Join(errs, "; ")))
+}
+
 // DialURL connects to the given ldap URL vie TCP using tls.Dial or net.Dial if ldaps://
 // or ldap:// specified as protocol. On success a new Conn for the connection
 // is returned.
-func DialURL(addr string) (*Conn, error) {
-	lurl, err := url.Parse(addr)
+//
+// The connection is configured with the given options. Without option, it is established
+// with a net.Dialer and must be established within DefaultTimeout.
+func DialURL(addr string, opts ...DialOpt) (*Conn, error) {
+	options := dialOptions{
+		ctx:     context.Background(),
+		timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.dialer == nil {
+		options.dialer = &net.Dialer{}
+	}
+	return dialURL(addr, options)
+}
+
+// dialURL connects to the given ldap URL with the given options, see DialURL
+func dialURL(addr string, options dialOptions) (*Conn, error) {
+	network, address, host, useTLS, err := parseDialURL(addr)
 	if err != nil {
 		return nil, NewError(ErrorNetwork, err)
 	}
+	tlsConfig := &tls.Config{}
+	if options.tlsConfig != nil {
+		tlsConfig = cloneTLSConfig(options.tlsConfig)
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	var handshakeConfig *tls.Config
+	if useTLS {
+		handshakeConfig = tlsConfig
+	}
 
-	host, port, err := net.SplitHostPort(lurl.Host)
+	ctx := options.ctx
+	cancel := func() {}
+	if options.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+	}
+	defer cancel()
+	c, err := dialContext(ctx, options.dialer, network, address, handshakeConfig)
 	if err != nil {
-		// we asume that error is due to missing port
-		host = lurl.Host
-		port = ""
+		return nil, NewError(ErrorNetwork, err)
 	}
+	conn := NewConn(c, useTLS)
+	if options.tlsConfig != nil {
+		conn.tlsConfig = tlsConfig
+	}
+	// the context only applies to establishing this connection
+	options.ctx = context.Background()
+	conn.serverHost = host
+	conn.dialAddr, conn.dialOpts = addr, &options
+	conn.Start()
+	return conn, nil
+}
 
-	switch lurl.Scheme {
-	case "ldapi":
-		if lurl.Path == "" || lurl.Path == "/" {
-			lurl.Path = "/var/run/slapd/ldapi"
+// parseDialURL returns the network and address to dial for the given ldap URL, and
+// whether TLS is used with the given host. The port defaults to 389 for ldap:// and to
+// 636 for ldaps://. The Unix socket path of an ldapi:// URL is either its path, as in
+// "ldapi:///var/run/ldapi", or its URL encoded host, as in "ldapi://%2Fvar%2Frun%2Fldapi".
+func parseDialURL(addr string) (network, address, host string, useTLS bool, err error) {
+	if len(addr) >= len("ldapi://") && strings.EqualFold(addr[:len("ldapi://")], "ldapi://") {
+		path := addr[len("ldapi://"):]
+		if i := strings.IndexAny(path, "?#"); i >= 0 {
+			path = path[:i]
 		}
-		return Dial("unix", lurl.Path)
+		if !strings.HasPrefix(path, "/") {
+			// url.Parse rejects the escaped slashes of the host
+			host := path
+			if i := strings.Index(path, "/"); i >= 0 {
+				host = path[:i]
+			}
+			if path, err = url.QueryUnescape(strings.Replace(host, "+", "%2B", -1)); err != nil {
+				return "", "", "", false, err
+			}
+		}
+		if path == "" || path == "/" {
+			path = "/var/run/slapd/ldapi"
+		}
+		return "unix", path, "", false, nil
+	}
+
+	lurl, err := url.Parse(addr)
+	if err != nil {
+		return "", "", "", false, err
+	}
+
+	host, port := splitURLHost(lurl.Host)
+	switch lurl.Scheme {
 	case "ldap":
 		if port == "" {
 			port = DefaultLdapPort
 		}
-		return Dial("tcp", net.JoinHostPort(host, port))
+		return "tcp", net.JoinHostPort(host, port), host, false, nil
 	case "ldaps":
 		if port == "" {
 			port = DefaultLdapsPort
 		}
-		tlsConf := &tls.Config{
-			ServerName: host,
+		return "tcp", net.JoinHostPort(host, port), host, true, nil
+	}
+	return "", "", "", false, fmt.Errorf("Unknown scheme '%s'", lurl.Scheme)
+}
+
+// splitURLHost splits the host of a URL into its host name, without the brackets of
+// IPv6 literals, and its port, which is empty if missing
+func splitURLHost(hostport string) (host, port string) {
+	if strings.HasPrefix(hostport, "[") {
+		if i := strings.LastIndex(hostport, "]"); i > 0 {
+			return hostport[1:i], strings.TrimPrefix(hostport[i+1:], ":")
 		}
-		return DialTLS("tcp", net.JoinHostPort(host, port), tlsConf)
+	}
+	if strings.Count(hostport, ":") == 1 {
+		i := strings.Index(hostport, ":")
+		return hostport[:i], hostport[i+1:]
+	}
+	return hostport, ""
+}
+
+// dialContext establishes a connection with dialer, and performs the TLS handshake over
+// it if tlsConf is not nil, unless ctx is done first
+func dialContext(ctx context.Context, dialer ContextDialer, network, addr string, tlsConf *tls.Config) (net.Conn, error) {
+	c, err := dialer.DialContext(ctx, network, addr)
+	if err != nil || tlsConf == nil {
+		return c, err
 	}
 
-	return nil, NewError(ErrorNetwork, fmt.Errorf("Unknown scheme '%s'", lurl.Scheme))
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// interrupt the handshake
+			c.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	tlsConn := tls.Client(c, tlsConf)
+	err = tlsConn.Handshake()
+	close(stop)
+	<-stopped
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return tlsConn, nil
 }
 
 // NewConn returns a new Conn using conn for network I/O.
 func NewConn(conn net.Conn, isTLS bool) *Conn {
 	return &Conn{
-		conn:            newBufferedConn(conn),
-		chanConfirm:     make(chan struct{}),
-		chanMessageID:   make(chan int64),
-		chanMessage:     make(chan *messagePacket, 10),
-		messageContexts: map[int64]*messageContext{},
-		requestTimeout:  0,
-		isTLS:           isTLS,
+		conn:             newBufferedConn(conn),
+		chanConfirm:      make(chan struct{}),
+		chanMessageID:    make(chan int64),
+		chanMessage:      make(chan *messagePacket, 10),
+		messageContexts:  map[int64]*messageContext{},
+		requestTimeout:   0,
+		maxPacketSize:    DefaultMaxPacketSize,
+		isTLS:            isTLS,
+		disconnectNotify: make(chan *DisconnectNotification, 1),
 	}
 }
 
@@ -221,16 +528,19 @@ func (l *Conn) setClosing() bool {
 
 // Close closes the connection.
 func (l *Conn) Close() {
-	l.messageMutex.Lock()
-	defer l.messageMutex.Unlock()
+	l.closeMutex.Lock()
+	closing := l.setClosing()
+	l.closeMutex.Unlock()
 
-	if l.setClosing() {
-		l.Debug.Printf("Sending quit message and waiting for confirmation")
-		l.chanMessage <- &messagePacket{Op: MessageQuit}
+	if closing {
+		l.debugf("Sending quit message and waiting for confirmation")
+		select {
+		case l.chanMessage <- &messagePacket{Op: MessageQuit}:
+		case <-l.chanConfirm:
+		}
 		<-l.chanConfirm
-		close(l.chanMessage)
 
-		l.Debug.Printf("Closing network connection")
+		l.debugf("Closing network connection")
 		if err := l.conn.Close(); err != nil {
 			log.Println(err)
 		}
@@ -240,13 +550,69 @@ func (l *Conn) Close() {
 	l.wgClose.Wait()
 }
 
-// SetTimeout sets the time after a request is sent that a MessageTimeout triggers
+// SetTimeout sets the time after which a request fails with a timeout if no response to it
+// is received. The timeout is an idle timeout: it is reset every time a response to the
+// request is received, so that a large search does not time out while its entries are being
+// received.
+//
+// The timeout does not apply to the requests which have their own deadline: the searches
+// with a RequestTimeout, and the operations taking a context which has a deadline, such as
+// SimpleBindWithContext or SearchWithContext, whose deadline bounds the total duration of
+// the request instead. These context variants provide the per-operation timeouts: for
+// example, a short deadline for the binds and a long RequestTimeout for the paged searches.
 func (l *Conn) SetTimeout(timeout time.Duration) {
 	if timeout > 0 {
 		atomic.StoreInt64(&l.requestTimeout, int64(timeout))
 	}
 }
 
+// SetMaxPacketSize sets the maximum size in bytes of the responses read from the server,
+// DefaultMaxPacketSize by default. The length of each response is checked before it is
+// read, so that a broken or malicious server cannot make the client allocate an arbitrary
+// amount of memory: if it is exceeded, the connection is closed and the pending requests
+// fail with an error with the ResultCode LDAPResultProtocolError. A size of 0 disables
+// the limit. The limit does not apply when a read handler is set with SetReadHandler.
+func (l *Conn) SetMaxPacketSize(bytes int) {
+	if bytes >= 0 {
+		atomic.StoreInt64(&l.maxPacketSize, int64(bytes))
+	}
+}
+
+// SetAutoFlush sets whether each request is written to the network as soon as it is sent,
+// which is the default. When it is disabled, the requests are buffered until Flush is
+// called or the buffer is full, so that the requests of a pipeline, sent without waiting
+// for the responses of the previous ones, are written with fewer system calls: an operation
+// waiting for its response, such as Search or Modify, must not be performed until the
+// buffer is flushed.
+func (l *Conn) SetAutoFlush(autoFlush bool) {
+	var manualFlush uint32
+	if !autoFlush {
+		manualFlush = 1
+	}
+	atomic.StoreUint32(&l.manualFlush, manualFlush)
+}
+
+// Flush writes the buffered requests to the network. If the write fails, the connection
+// is closed, and the error is also returned to the pending requests.
+func (l *Conn) Flush() error {
+	done := make(chan error, 1)
+	if !l.sendProcessMessage(&messagePacket{Op: MessageFlush, Done: done}) {
+		return NewError(ErrorNetwork, errConnClosed)
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-l.chanConfirm:
+		// processMessages stopped, possibly after handling the flush
+		select {
+		case err := <-done:
+			return err
+		default:
+			return NewError(ErrorNetwork, errConnClosed)
+		}
+	}
+}
+
 // Returns the next available messageID
 func (l *Conn) nextMessageID() int64 {
 	if messageID, ok := <-l.chanMessageID; ok {
@@ -255,18 +621,29 @@ func (l *Conn) nextMessageID() int64 {
 	return 0
 }
 
-// StartTLS sends the command to start a TLS session and then creates a new TLS Client
+// StartTLS sends the command to start a TLS session and then creates a new TLS Client.
+// If config is nil, the configuration set with WithTLSConfig when dialing is used.
+// The command is retried as specified by WithRetry.
 func (l *Conn) StartTLS(config *tls.Config) error {
 	if l.isTLS {
 		return NewError(ErrorNetwork, errors.New("ldap: already encrypted"))
 	}
+	if config == nil {
+		config = l.tlsConfig
+	}
+	return l.withRetry(context.Background(), func() error {
+		return l.startTLS(config)
+	})
+}
+
+func (l *Conn) startTLS(config *tls.Config) error {
 
 	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
 	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, l.nextMessageID(), "MessageID"))
 	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedRequest, nil, "Start TLS")
 	request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, "1.3.6.1.4.1.1466.20037", "TLS Extended Command"))
 	packet.AppendChild(request)
-	l.Debug.PrintPacket(packet)
+	l.debugPacket(packet)
 
 	msgCtx, err := l.sendMessageWithFlags(packet, startTLS)
 	if err != nil {
@@ -274,14 +651,17 @@ func (l *Conn) StartTLS(config *tls.Config) error {
 	}
 	defer l.finishMessage(msgCtx)
 
-	l.Debug.Printf("%d: waiting for response", msgCtx.id)
+	l.debugf("%d: waiting for response", msgCtx.id)
 
 	packetResponse, ok := <-msgCtx.responses
 	if !ok {
 		return NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
 	}
 	packet, err = packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
+	l.debugf("%d: got response %p", msgCtx.id, packet)
+	if msgCtx.observation != nil {
+		msgCtx.observation.record(packet, err)
+	}
 	if err != nil {
 		return err
 	}
@@ -291,7 +671,7 @@ func (l *Conn) StartTLS(config *tls.Config) error {
 			l.Close()
 			return err
 		}
-		l.Debug.PrintPacket(packet)
+		l.debugPacket(packet)
 	}
 
 	if err := GetLDAPError(packet); err == nil {
@@ -304,7 +684,13 @@ func (l *Conn) StartTLS(config *tls.Config) error {
 
 		l.isTLS = true
 		l.conn = newBufferedConn(conn)
+		l.messageMutex.Lock()
+		l.isStartTLS = true
+		l.startTLSConfig = config
+		l.messageMutex.Unlock()
 	} else {
+		// the reader stopped when receiving the response: resume it on the unencrypted connection
+		go l.reader()
 		return err
 	}
 	go l.reader()
@@ -312,9 +698,17 @@ func (l *Conn) StartTLS(config *tls.Config) error {
 	return nil
 }
 
-// TLSConnectionState returns the client's TLS connection state.
-// The return values are their zero values if StartTLS did
-// not succeed.
+// IsStartTLS returns whether the connection was upgraded to TLS with StartTLS
+func (l *Conn) IsStartTLS() bool {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	return l.isStartTLS
+}
+
+// TLSConnectionState returns the client's TLS connection state, which holds the
+// certificates presented by the server, when the connection uses TLS, either
+// because it was established with DialTLS or upgraded with StartTLS.
+// Otherwise, ok is false and state is the zero value.
 func (l *Conn) TLSConnectionState() (state tls.ConnectionState, ok bool) {
 	tc, ok := l.conn.Conn.(*tls.Conn)
 	if !ok {
@@ -329,18 +723,19 @@ func (l *Conn) sendMessage(packet *ber.Packet) (*messageContext, error) {
 
 func (l *Conn) sendMessageWithFlags(packet *ber.Packet, flags sendMessageFlags) (*messageContext, error) {
 	if l.IsClosing() {
-		return nil, NewError(ErrorNetwork, errors.New("ldap: connection closed"))
+		return nil, l.observeSendError(packet, nil, NewError(ErrorNetwork, errConnClosed))
 	}
 	l.messageMutex.Lock()
-	l.Debug.Printf("flags&startTLS = %d", flags&startTLS)
+	observer := l.observer
+	l.debugf("flags&startTLS = %d", flags&startTLS)
 	if l.isStartingTLS {
 		l.messageMutex.Unlock()
-		return nil, NewError(ErrorNetwork, errors.New("ldap: connection is in startls phase"))
+		return nil, l.observeSendError(packet, observer, NewError(ErrorNetwork, errors.New("ldap: connection is in startls phase")))
 	}
 	if flags&startTLS != 0 {
 		if l.outstandingRequests != 0 {
 			l.messageMutex.Unlock()
-			return nil, NewError(ErrorNetwork, errors.New("ldap: cannot StartTLS with outstanding requests"))
+			return nil, l.observeSendError(packet, observer, NewError(ErrorNetwork, errors.New("ldap: cannot StartTLS with outstanding requests")))
 		}
 		l.isStartingTLS = true
 	}
@@ -359,30 +754,73 @@ func (l *Conn) sendMessageWithFlags(packet *ber.Packet, flags sendMessageFlags)
 			done:      make(chan struct{}),
 			responses: responses,
 		},
+		Flush:     flags&startTLS != 0 || (flags&noFlush == 0 && atomic.LoadUint32(&l.manualFlush) == 0),
+		NoTimeout: flags&noTimeout != 0,
+	}
+	if logger := l.getLogger(); observer != nil || logger != nil {
+		message.Context.observation = newObservation(observer, logger, packet)
+	}
+	if !l.sendProcessMessage(message) {
+		l.messageMutex.Lock()
+		l.outstandingRequests--
+		if flags&startTLS != 0 {
+			l.isStartingTLS = false
+		}
+		l.messageMutex.Unlock()
+		return nil, l.observeSendError(packet, observer, NewError(ErrorNetwork, errConnClosed))
 	}
-	l.sendProcessMessage(message)
 	return message.Context, nil
 }
 
-func (l *Conn) finishMessage(msgCtx *messageContext) {
-	close(msgCtx.done)
-
-	if l.IsClosing() {
-		return
+// observeSendError notifies the observer and the logger, if any, of the failure to send
+// packet. observer is looked up if nil.
+func (l *Conn) observeSendError(packet *ber.Packet, observer Observer, err error) error {
+	if observer == nil {
+		l.messageMutex.Lock()
+		observer = l.observer
+		l.messageMutex.Unlock()
+	}
+	if logger := l.getLogger(); observer != nil || logger != nil {
+		observation := newObservation(observer, logger, packet)
+		observation.err = err
+		observation.done()
 	}
+	return err
+}
 
+// hasOutstandingRequests returns whether some requests are still waiting for a response
+func (l *Conn) hasOutstandingRequests() bool {
 	l.messageMutex.Lock()
-	l.outstandingRequests--
-	if l.isStartingTLS {
-		l.isStartingTLS = false
-	}
-	l.messageMutex.Unlock()
+	defer l.messageMutex.Unlock()
+	return l.outstandingRequests != 0
+}
 
-	message := &messagePacket{
-		Op:        MessageFinish,
-		MessageID: msgCtx.id,
-	}
-	l.sendProcessMessage(message)
+// finishMessage releases the message: processMessages stops delivering its responses,
+// and its message ID is forgotten. It may be called several times.
+func (l *Conn) finishMessage(msgCtx *messageContext) {
+	msgCtx.finish.Do(func() {
+		close(msgCtx.done)
+		if msgCtx.observation != nil {
+			msgCtx.observation.done()
+		}
+
+		if l.IsClosing() {
+			return
+		}
+
+		l.messageMutex.Lock()
+		l.outstandingRequests--
+		if l.isStartingTLS {
+			l.isStartingTLS = false
+		}
+		l.messageMutex.Unlock()
+
+		message := &messagePacket{
+			Op:        MessageFinish,
+			MessageID: msgCtx.id,
+		}
+		l.sendProcessMessage(message)
+	})
 }
 
 func (l *Conn) writeHandler() func(*ber.Packet) ([]byte, error) {
@@ -394,6 +832,26 @@ func (l *Conn) writeHandler() func(*ber.Packet) ([]byte, error) {
 	return defaultWriteHandler
 }
 
+// checkPacketSize returns an error if the length of the next response exceeds the
+// maximum packet size, unless a read handler is set
+func (l *Conn) checkPacketSize() error {
+	maxPacketSize := atomic.LoadInt64(&l.maxPacketSize)
+	l.handlersMutex.Lock()
+	hasReadHandler := l.rdHandler != nil
+	l.handlersMutex.Unlock()
+	if maxPacketSize == 0 || hasReadHandler {
+		return nil
+	}
+	length, err := l.conn.peekPacketLength()
+	if err != nil {
+		return NewError(ErrorNetwork, &NetworkError{Op: "read", Err: err, Partial: true})
+	}
+	if length > maxPacketSize {
+		return NewError(LDAPResultProtocolError, fmt.Errorf("ldap: response of %d bytes exceeds the maximum packet size of %d bytes", length, maxPacketSize))
+	}
+	return nil
+}
+
 func (l *Conn) readHandler() func(reader io.Reader) ([]*ber.Packet, error) {
 	l.handlersMutex.Lock()
 	defer l.handlersMutex.Unlock()
@@ -422,14 +880,23 @@ func (l *Conn) SetWriteHandler(wh func(*ber.Packet) ([]byte, error)) {
 	l.wrHandler = wh
 }
 
+// sendProcessMessage sends the message to processMessages, and returns false if the
+// connection is closing or processMessages stopped. It blocks while the queue of messages is
+// full, which happens when processMessages waits for a response to be received: no lock
+// must be held while calling it.
 func (l *Conn) sendProcessMessage(message *messagePacket) bool {
-	l.messageMutex.Lock()
-	defer l.messageMutex.Unlock()
+	l.closeMutex.RLock()
+	defer l.closeMutex.RUnlock()
 	if l.IsClosing() {
 		return false
 	}
-	l.chanMessage <- message
-	return true
+	select {
+	case l.chanMessage <- message:
+		return true
+	case <-l.chanConfirm:
+		// processMessages stopped
+		return false
+	}
 }
 
 func (l *Conn) processMessages() {
@@ -443,7 +910,8 @@ func (l *Conn) processMessages() {
 			if l.IsClosing() && l.closeErr.Load() != nil {
 				msgCtx.sendResponse(&PacketResponse{Error: l.closeErr.Load().(error)})
 			}
-			l.Debug.Printf("Closing channel for MessageID %d", messageID)
+			l.debugf("Closing channel for MessageID %d", messageID)
+			msgCtx.stopTimer()
 			close(msgCtx.responses)
 			delete(l.messageContexts, messageID)
 		}
@@ -451,6 +919,21 @@ func (l *Conn) processMessages() {
 		close(l.chanConfirm)
 	}()
 
+	// the requests are only written by this goroutine, through a buffer. A write error
+	// leaves a partial request in the stream, so the connection is closed.
+	writer := bufio.NewWriter(connWriter{l})
+	write := func(buf []byte, flush bool) error {
+		_, err := writer.Write(buf)
+		if err == nil && flush {
+			err = writer.Flush()
+		}
+		if err != nil {
+			l.closeErr.Store(NewError(ErrorNetwork, &NetworkError{Op: "write", Err: err}))
+			l.conn.Close()
+		}
+		return err
+	}
+
 	var messageID int64 = 1
 	for {
 		select {
@@ -459,21 +942,30 @@ func (l *Conn) processMessages() {
 		case message := <-l.chanMessage:
 			switch message.Op {
 			case MessageQuit:
-				l.Debug.Printf("Shutting down - quit message received")
+				l.debugf("Shutting down - quit message received")
+				writer.Flush()
 				return
+			case MessageFlush:
+				l.debugf("Flushing the buffered requests")
+				var err error
+				if writer.Buffered() > 0 {
+					if err = write(nil, true); err != nil {
+						err = l.closeErr.Load().(error)
+					}
+				}
+				message.Done <- err
 			case MessageRequest:
 				// Add to message list and write to network
-				l.Debug.Printf("Sending message %d", message.MessageID)
+				l.debugf("Sending message %d", message.MessageID)
 				writefn := l.writeHandler()
 				buf, err := writefn(message.Packet)
 				if err != nil {
-					l.Debug.Printf("Fatal error serializing packet: %s", err.Error())
+					l.debugf("Fatal error serializing packet: %s", err.Error())
 					return
 				}
-				_, err = l.conn.Write(buf)
-				if err != nil {
-					l.Debug.Printf("Error Sending Message: %s", err.Error())
-					message.Context.sendResponse(&PacketResponse{Error: fmt.Errorf("unable to send request: %s", err)})
+				if err = write(buf, message.Flush); err != nil {
+					l.debugf("Error Sending Message: %s", err.Error())
+					message.Context.sendResponse(&PacketResponse{Error: NewError(ErrorNetwork, fmt.Errorf("unable to send request: %s", err))})
 					close(message.Context.responses)
 					break
 				}
@@ -484,41 +976,74 @@ func (l *Conn) processMessages() {
 
 				// Add timeout if defined
 				requestTimeout := time.Duration(atomic.LoadInt64(&l.requestTimeout))
-				if requestTimeout > 0 {
-					go func() {
+				if requestTimeout > 0 && !message.NoTimeout {
+					messageID := message.MessageID
+					message.Context.timeout = requestTimeout
+					message.Context.lastActivity = time.Now()
+					message.Context.timer = time.AfterFunc(requestTimeout, func() {
 						defer func() {
 							if err := recover(); err != nil {
 								log.Printf("ldap: recovered panic in RequestTimeout: %v", err)
 							}
 						}()
-						time.Sleep(requestTimeout)
 						timeoutMessage := &messagePacket{
 							Op:        MessageTimeout,
-							MessageID: message.MessageID,
+							MessageID: messageID,
 						}
 						l.sendProcessMessage(timeoutMessage)
-					}()
+					})
 				}
 			case MessageResponse:
-				l.Debug.Printf("Receiving message %d", message.MessageID)
+				l.debugf("Receiving message %d", message.MessageID)
 				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
+					if msgCtx.timer != nil {
+						msgCtx.lastActivity = time.Now()
+					}
 					msgCtx.sendResponse(&PacketResponse{message.Packet, nil})
 				} else {
-					log.Printf("Received unexpected message %d, %v", message.MessageID, l.IsClosing())
-					l.Debug.PrintPacket(message.Packet)
+					// late responses to a finished or abandoned request are expected
+					l.debugf("Received unexpected message %d, %v", message.MessageID, l.IsClosing())
+					l.debugPacket(message.Packet)
 				}
 			case MessageTimeout:
 				// Handle the timeout by closing the channel
 				// All reads will return immediately
 				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
-					l.Debug.Printf("Receiving message timeout for %d", message.MessageID)
+					// a response was received since the timer was set: wait for the
+					// remaining time since the last response
+					if idle := time.Since(msgCtx.lastActivity); idle < msgCtx.timeout {
+						msgCtx.timer.Reset(msgCtx.timeout - idle)
+						break
+					}
+					l.debugf("Receiving message timeout for %d", message.MessageID)
 					msgCtx.sendResponse(&PacketResponse{message.Packet, errors.New("ldap: connection timed out")})
 					delete(l.messageContexts, message.MessageID)
 					close(msgCtx.responses)
 				}
+			case MessageAbandon:
+				l.debugf("Abandoning message %d", message.MessageID)
+				packet := newAbandonPacket(messageID, message.MessageID)
+				messageID++
+				l.debugPacket(packet)
+				writefn := l.writeHandler()
+				buf, err := writefn(packet)
+				if err != nil {
+					l.debugf("Fatal error serializing packet: %s", err.Error())
+					return
+				}
+				if err = write(buf, true); err != nil {
+					l.debugf("Error Sending Message: %s", err.Error())
+				}
+				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
+					msgCtx.stopTimer()
+					msgCtx.sendResponse(&PacketResponse{Error: NewError(LDAPResultCanceled, errAbandoned)})
+					delete(l.messageContexts, message.MessageID)
+					close(msgCtx.responses)
+				}
 			case MessageFinish:
-				l.Debug.Printf("Finished message %d", message.MessageID)
+				l.debugf("Finished message %d", message.MessageID)
 				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
+					msgCtx.stopTimer()
 					delete(l.messageContexts, message.MessageID)
 					close(msgCtx.responses)
 				}
@@ -540,34 +1065,52 @@ func (l *Conn) reader() {
 
 	for {
 		if cleanstop {
-			l.Debug.Printf("reader clean stopping (without closing the connection)")
+			l.debugf("reader clean stopping (without closing the connection)")
 			return
 		}
 		// Use peek to block until some data is available, this allows us to use a custom read handler
 		// when a SASL layer is enabled
 		var err error
 		var packets []*ber.Packet
+		// partial is true once the first byte of a response is available
+		partial := false
 		_, err = l.conn.Peek(1)
 		if err == nil {
+			partial = true
+			if err = l.checkPacketSize(); err != nil {
+				if !l.IsClosing() {
+					l.closeErr.Store(err)
+					l.debugf("reader error: %s", err)
+				}
+				return
+			}
 			readfn := l.readHandler()
 			packets, err = readfn(l.conn)
 		}
 		if err != nil {
 			// A read error is expected here if we are closing the connection...
-			if !l.IsClosing() {
-				l.closeErr.Store(fmt.Errorf("unable to read LDAP response packet: %s", err))
-				l.Debug.Printf("reader error: %s", err)
+			// ... or if a write error closed it
+			if !l.IsClosing() && l.closeErr.Load() == nil {
+				l.closeErr.Store(NewError(ErrorNetwork, &NetworkError{Op: "read", Err: err, Partial: partial}))
+				l.debugf("reader error: %s", err)
 			}
 			return
 		}
 		for _, packet := range packets {
 			if err := addLDAPDescriptions(packet); err != nil {
-				l.Debug.Printf("descriptions error: %s", err)
+				l.debugf("descriptions error: %s", err)
 			}
 			if len(packet.Children) == 0 {
-				l.Debug.Printf("Received bad ldap packet")
+				l.debugf("Received bad ldap packet")
 				continue
 			}
+			if notification := parseDisconnectNotification(packet); notification != nil {
+				// the server is about to close the connection
+				l.debugf("notice of disconnection received: %d %s", notification.ResultCode, notification.Message)
+				l.closeErr.Store(NewError(notification.ResultCode, fmt.Errorf("ldap: notice of disconnection: %s", notification.Message)))
+				l.disconnectNotify <- notification
+				return
+			}
 			l.messageMutex.Lock()
 			if l.isStartingTLS {
 				cleanstop = true
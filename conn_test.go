@@ -2,13 +2,19 @@ package ldap
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -60,6 +66,50 @@ func TestUnresponsiveConnection(t *testing.T) {
 
 // TestFinishMessage tests that we do not enter deadlock when a goroutine makes
 // a request but does not handle all responses from the server.
+func TestIdleTimeout(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	conn := NewConn(ptc, false)
+	conn.SetTimeout(100 * time.Millisecond)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		for _, stall := range []bool{false, true} {
+			request, err := ptc.ReceiveRequest()
+			if err != nil {
+				return
+			}
+			messageID := request.Children[0].Value.(int64)
+			// the entries take longer than the timeout to be sent, but keep the search active
+			for i := 0; i < 5; i++ {
+				time.Sleep(40 * time.Millisecond)
+				if stall && i == 1 {
+					time.Sleep(200 * time.Millisecond)
+				}
+				if err := ptc.SendResponse(newSearchResultEntryPacket(messageID, fmt.Sprintf("cn=entry%d,dc=example,dc=com", i))); err != nil {
+					return
+				}
+			}
+			if err := ptc.SendResponse(newSearchResultDonePacket(messageID, LDAPResultSuccess)); err != nil {
+				return
+			}
+		}
+	}()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	result, err := conn.Search(searchRequest)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(result.Entries) != 5 {
+		t.Errorf("expected 5 entries, got %d", len(result.Entries))
+	}
+
+	if _, err := conn.Search(searchRequest); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout when the server stalls, got %v", err)
+	}
+}
+
 func TestFinishMessage(t *testing.T) {
 	ptc := newPacketTranslatorConn()
 	defer ptc.Close()
@@ -104,6 +154,54 @@ func TestFinishMessage(t *testing.T) {
 	conn.Close()
 }
 
+// TestSimpleBindWithContextCancel tests that cancelling the context of a bind
+// which is waiting for its response unblocks the caller and releases the
+// message.
+func TestSimpleBindWithContextCancel(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := conn.SimpleBindWithContext(ctx, NewSimpleBindRequest("cn=user", "secret", nil))
+		errs <- err
+	}()
+
+	// Wait for the bind request to hit the wire, then give up on it.
+	runWithTimeout(t, time.Second, func() {
+		if _, err := ptc.ReceiveRequest(); err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+	})
+	cancel()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := <-errs; err != context.Canceled {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+	})
+
+	// The cancelled message must not prevent further requests.
+	msgCtx := testSendRequest(t, ptc, conn)
+	testReceiveResponse(t, ptc, msgCtx)
+	runWithTimeout(t, time.Second, func() {
+		conn.finishMessage(msgCtx)
+	})
+
+	conn.messageMutex.Lock()
+	outstanding := conn.outstandingRequests
+	conn.messageMutex.Unlock()
+	if outstanding != 0 {
+		t.Errorf("expected no outstanding requests, got %d", outstanding)
+	}
+
+	conn.Close()
+}
+
 func testSendRequest(t *testing.T, ptc *packetTranslatorConn, conn *Conn) (msgCtx *messageContext) {
 	var msgID int64
 	runWithTimeout(t, time.Second, func() {
@@ -334,3 +432,626 @@ func (c *packetTranslatorConn) SetReadDeadline(t time.Time) error {
 func (c *packetTranslatorConn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
+
+// closeRecorderConn records that the connection was closed without discarding
+// buffered requests, so they can still be received after Close.
+type closeRecorderConn struct {
+	*packetTranslatorConn
+	closed chan struct{}
+}
+
+func (c *closeRecorderConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestUnbind(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	netConn := &closeRecorderConn{packetTranslatorConn: ptc, closed: make(chan struct{})}
+	conn := NewConn(netConn, false)
+	conn.Start()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.Unbind()
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+		if tag := request.Children[1].Tag; tag != ApplicationUnbindRequest {
+			t.Errorf("expected an unbind request, got %d", tag)
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		<-netConn.closed
+	})
+
+	if _, err := conn.Search(NewSearchRequest("", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected ErrorNetwork after Unbind, got %v", err)
+	}
+}
+
+// TestStartTLSRefused tests that the connection remains usable when the
+// server refuses to start TLS.
+func TestStartTLSRefused(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		if request.Children[1].Tag == ApplicationExtendedRequest {
+			packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+			response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+			response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultProtocolError), "resultCode"))
+			response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+			response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "unsupported", "diagnosticMessage"))
+			packet.AppendChild(response)
+			return []*ber.Packet{packet}
+		}
+		return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultSuccess)}
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.StartTLS(nil); !IsErrorWithCode(err, LDAPResultProtocolError) {
+			t.Errorf("expected LDAPResultProtocolError, got %v", err)
+		}
+		if conn.IsStartTLS() {
+			t.Errorf("expected IsStartTLS to be false")
+		}
+		if _, err := conn.Search(NewSearchRequest("", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)); err != nil {
+			t.Errorf("unexpected error after refused StartTLS: %s", err)
+		}
+	})
+}
+
+func TestTLSConnectionStateWithoutTLS(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	if _, ok := conn.TLSConnectionState(); ok {
+		t.Errorf("expected no TLS connection state for a plain connection")
+	}
+
+	conn = NewConn(tls.Client(ptc, &tls.Config{InsecureSkipVerify: true}), true)
+	if _, ok := conn.TLSConnectionState(); !ok {
+		t.Errorf("expected a TLS connection state for a TLS connection")
+	}
+}
+
+// TestPing tests that Ping performs a base search of the root DSE requesting no attributes.
+func TestPing(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		search := request.Children[1]
+		if search.Tag != ApplicationSearchRequest || search.Children[0].Value.(string) != "" ||
+			search.Children[1].Value.(int64) != int64(ScopeBaseObject) ||
+			len(search.Children[7].Children) != 1 || search.Children[7].Children[0].Value.(string) != "1.1" {
+			return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultProtocolError)}
+		}
+		return []*ber.Packet{
+			newSearchResultEntryPacket(messageID, ""),
+			newSearchResultDonePacket(messageID, LDAPResultSuccess),
+		}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.Ping(context.Background()); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := conn.Ping(ctx); err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+
+		conn.Close()
+		if err := conn.Ping(context.Background()); !IsErrorWithCode(err, ErrorNetwork) {
+			t.Errorf("expected ErrorNetwork on a closed connection, got %v", err)
+		}
+	})
+}
+
+// TestConcurrentRequests tests that the responses of concurrent requests are returned to
+// their caller when the server answers them out of order. Run it with -race.
+func TestConcurrentRequests(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+	go func() {
+		defer ptc.Close()
+		for {
+			request, err := ptc.ReceiveRequest()
+			if err != nil {
+				return
+			}
+			go func() {
+				messageID := request.Children[0].Value.(int64)
+				op := request.Children[1]
+				// answer out of order
+				time.Sleep(time.Duration(messageID%5) * time.Millisecond)
+				var responses []*ber.Packet
+				switch op.Tag {
+				case ApplicationSearchRequest:
+					responses = []*ber.Packet{
+						newSearchResultEntryPacket(messageID, op.Children[0].Value.(string)),
+						newSearchResultDonePacket(messageID, LDAPResultSuccess),
+					}
+				case ApplicationCompareRequest:
+					// the DN is compared to the asserted value
+					code := uint16(LDAPResultCompareFalse)
+					if string(op.Children[0].Data.Bytes()) == string(op.Children[1].Children[1].Data.Bytes()) {
+						code = LDAPResultCompareTrue
+					}
+					responses = []*ber.Packet{newResultPacket(messageID, ApplicationCompareResponse, code)}
+				case ApplicationModifyRequest:
+					responses = []*ber.Packet{newResultPacket(messageID, ApplicationModifyResponse, LDAPResultSuccess)}
+				}
+				for _, response := range responses {
+					ptc.SendResponse(response)
+				}
+			}()
+		}
+	}()
+
+	const workers, requests = 10, 20
+	var wg sync.WaitGroup
+	runWithTimeout(t, 5*time.Second, func() {
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < requests; i++ {
+					dn := fmt.Sprintf("cn=%d-%d,dc=example,dc=com", w, i)
+					result, err := conn.Search(NewSearchRequest(dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+					if err != nil {
+						t.Errorf("unexpected search error: %s", err)
+						return
+					}
+					if len(result.Entries) != 1 || result.Entries[0].DN != dn {
+						t.Errorf("unexpected search result for %s: %v", dn, result.Entries)
+					}
+					if matched, err := conn.Compare(dn, "cn", dn); err != nil || !matched {
+						t.Errorf("unexpected compare result for %s: %t, %v", dn, matched, err)
+					}
+					if matched, err := conn.Compare(dn, "cn", "other"); err != nil || matched {
+						t.Errorf("unexpected compare result for %s: %t, %v", dn, matched, err)
+					}
+					modify := NewModifyRequest(dn, nil)
+					modify.Replace("description", []string{dn})
+					if err := conn.Modify(modify); err != nil {
+						t.Errorf("unexpected modify error: %s", err)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+	})
+}
+
+// testDialer records the dialed addresses and returns the connection of dial
+type testDialer struct {
+	addrs []string
+	dial  func(ctx context.Context) (net.Conn, error)
+}
+
+func (d *testDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.addrs = append(d.addrs, network+" "+addr)
+	return d.dial(ctx)
+}
+
+func TestMaxPacketSize(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		maxPacketSize int
+		response      func(messageID int64) []byte
+		expected      uint16
+	}{
+		{"default limit", -1, func(messageID int64) []byte {
+			// a sequence claiming a length of 1GB
+			return []byte{0x30, 0x84, 0x40, 0x00, 0x00, 0x00, 0x02, 0x01, byte(messageID)}
+		}, LDAPResultProtocolError},
+		{"custom limit", 10, func(messageID int64) []byte {
+			return newSearchResultDonePacket(messageID, LDAPResultSuccess).Bytes()
+		}, LDAPResultProtocolError},
+		{"no limit", 0, func(messageID int64) []byte {
+			return newSearchResultDonePacket(messageID, LDAPResultSuccess).Bytes()
+		}, LDAPResultSuccess},
+	} {
+		client, server := net.Pipe()
+		conn := NewConn(client, false)
+		if test.maxPacketSize >= 0 {
+			conn.SetMaxPacketSize(test.maxPacketSize)
+		}
+		conn.Start()
+		go func(response func(int64) []byte) {
+			defer server.Close()
+			request, err := ber.ReadPacket(server)
+			if err != nil {
+				return
+			}
+			server.Write(response(request.Children[0].Value.(int64)))
+			ioutil.ReadAll(server)
+		}(test.response)
+
+		_, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		if test.expected == LDAPResultSuccess && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		} else if test.expected != LDAPResultSuccess && !IsErrorWithCode(err, test.expected) {
+			t.Errorf("%s: expected result code %d, got %v", test.name, test.expected, err)
+		}
+		conn.Close()
+	}
+}
+
+// writeCountingConn counts the writes to the connection, and fails them once failWrites is set
+type writeCountingConn struct {
+	net.Conn
+	writes     int32
+	failWrites int32
+}
+
+func (c *writeCountingConn) Write(b []byte) (int, error) {
+	atomic.AddInt32(&c.writes, 1)
+	if atomic.LoadInt32(&c.failWrites) != 0 {
+		// a partial write
+		return len(b) / 2, errors.New("connection reset by peer")
+	}
+	return c.Conn.Write(b)
+}
+
+func TestFlush(t *testing.T) {
+	client, server := net.Pipe()
+	counting := &writeCountingConn{Conn: client}
+	conn := NewConn(counting, false)
+	conn.Start()
+	defer conn.Close()
+	go func() {
+		defer server.Close()
+		for {
+			request, err := ber.ReadPacket(server)
+			if err != nil {
+				return
+			}
+			if _, err := server.Write(newSearchResultDonePacket(request.Children[0].Value.(int64), LDAPResultSuccess).Bytes()); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn.SetAutoFlush(false)
+	var searches []*PendingSearch
+	for i := 0; i < 3; i++ {
+		search, err := conn.StartSearch(NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		searches = append(searches, search)
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	runWithTimeout(t, time.Second, func() {
+		for _, search := range searches {
+			if _, err := search.Wait(); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}
+	})
+	if writes := atomic.LoadInt32(&counting.writes); writes != 1 {
+		t.Errorf("expected the buffered requests to be written at once, got %d writes", writes)
+	}
+
+	conn.SetAutoFlush(true)
+	runWithTimeout(t, time.Second, func() {
+		if _, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+	if err := conn.Flush(); err != nil {
+		t.Errorf("unexpected error flushing an empty buffer: %s", err)
+	}
+}
+
+func TestWriteErrorClosesConnection(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	counting := &writeCountingConn{Conn: client}
+	conn := NewConn(counting, false)
+	conn.Start()
+	defer conn.Close()
+
+	conn.SetAutoFlush(false)
+	pending, err := conn.StartSearch(NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	atomic.StoreInt32(&counting.failWrites, 1)
+	if err := conn.Flush(); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected ErrorNetwork, got %v", err)
+	}
+
+	runWithTimeout(t, time.Second, func() {
+		_, err := pending.Wait()
+		if !IsErrorWithCode(err, ErrorNetwork) || !strings.Contains(err.Error(), "write failed") {
+			t.Errorf("expected the write error, got %v", err)
+		}
+		// the connection is closed instead of sending requests after a partial one
+		for !conn.IsClosing() {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	if _, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected ErrorNetwork, got %v", err)
+	}
+}
+
+func TestPeekPacketLength(t *testing.T) {
+	for _, test := range []struct {
+		header   []byte
+		expected int64
+	}{
+		{[]byte{0x30, 0x05}, 5},
+		{[]byte{0x30, 0x82, 0x01, 0x00}, 256},
+		{[]byte{0x30, 0x80}, -1},
+		// high tag number form
+		{[]byte{0x1f, 0x81, 0x01, 0x81, 0x80}, 128},
+	} {
+		client, server := net.Pipe()
+		go server.Write(test.header)
+		length, err := newBufferedConn(client).peekPacketLength()
+		if err != nil || length != test.expected {
+			t.Errorf("%x: expected %d, got %d (%v)", test.header, test.expected, length, err)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestDialDomain(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	closed.Close()
+
+	port := func(ln net.Listener) uint16 {
+		return uint16(ln.Addr().(*net.TCPAddr).Port)
+	}
+	var services []string
+	records := []*net.SRV{{Target: "127.0.0.1.", Port: port(closed)}, {Target: "127.0.0.1.", Port: port(ln)}}
+	defer func(lookup func(string, string, string) (string, []*net.SRV, error)) { lookupSRV = lookup }(lookupSRV)
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		services = append(services, fmt.Sprintf("_%s._%s.%s", service, proto, name))
+		return "", records, nil
+	}
+
+	conn, err := DialDomain("example.com", WithConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if remote := conn.conn.RemoteAddr().String(); remote != ln.Addr().String() {
+		t.Errorf("expected a connection to the second server %s, got %s", ln.Addr(), remote)
+	}
+	conn.Close()
+
+	records = records[:1]
+	_, err = DialDomain("example.com", WithSRVTLS(), WithConnectTimeout(time.Second))
+	if !IsErrorWithCode(err, ErrorNetwork) || !strings.Contains(err.Error(), fmt.Sprintf("ldaps://127.0.0.1:%d", port(closed))) {
+		t.Errorf("expected an error listing the attempts, got %v", err)
+	}
+	if len(services) != 2 || services[0] != "_ldap._tcp.example.com" || services[1] != "_ldaps._tcp.example.com" {
+		t.Errorf("unexpected SRV lookups %v", services)
+	}
+}
+
+func TestDialURLWithDialer(t *testing.T) {
+	dialer := &testDialer{dial: func(ctx context.Context) (net.Conn, error) {
+		return newPacketTranslatorConn(), nil
+	}}
+	runWithTimeout(t, time.Second, func() {
+		conn, err := DialURL("ldap://ldap.example.com", WithDialer(dialer))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer conn.Close()
+		if conn.isTLS {
+			t.Error("expected a connection without TLS")
+		}
+	})
+	if len(dialer.addrs) != 1 || dialer.addrs[0] != "tcp ldap.example.com:389" {
+		t.Errorf("unexpected dialed addresses %v", dialer.addrs)
+	}
+}
+
+func TestDialURLWithDialerTLS(t *testing.T) {
+	handshake := make(chan []byte, 1)
+	dialer := &testDialer{dial: func(ctx context.Context) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			record := make([]byte, 1)
+			io.ReadFull(server, record)
+			handshake <- record
+		}()
+		return client, nil
+	}}
+	runWithTimeout(t, time.Second, func() {
+		if _, err := DialURL("ldaps://ldap.example.com", WithDialer(dialer)); !IsErrorWithCode(err, ErrorNetwork) {
+			t.Errorf("expected ErrorNetwork, got %v", err)
+		}
+	})
+	if len(dialer.addrs) != 1 || dialer.addrs[0] != "tcp ldap.example.com:636" {
+		t.Errorf("unexpected dialed addresses %v", dialer.addrs)
+	}
+	// 0x16 is the content type of the TLS handshake records
+	if record := <-handshake; record[0] != 0x16 {
+		t.Errorf("expected a TLS handshake over the dialed connection, got %x", record)
+	}
+}
+
+func TestDialURLWithDialerTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { DefaultTimeout = timeout }(DefaultTimeout)
+	DefaultTimeout = 10 * time.Millisecond
+
+	dialer := &testDialer{dial: func(ctx context.Context) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	runWithTimeout(t, time.Second, func() {
+		if _, err := DialURL("ldap://ldap.example.com", WithDialer(dialer)); !IsErrorWithCode(err, ErrorNetwork) {
+			t.Errorf("expected ErrorNetwork, got %v", err)
+		}
+	})
+}
+
+func TestDialURLWithConnectTimeout(t *testing.T) {
+	dialer := &testDialer{dial: func(ctx context.Context) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	runWithTimeout(t, time.Second, func() {
+		if _, err := DialURL("ldap://ldap.example.com", WithDialer(dialer), WithConnectTimeout(10*time.Millisecond)); !IsErrorWithCode(err, ErrorNetwork) {
+			t.Errorf("expected ErrorNetwork, got %v", err)
+		}
+	})
+}
+
+func TestDialURLWithDialContext(t *testing.T) {
+	// the server never answers the TLS handshake
+	dialer := &testDialer{dial: func(ctx context.Context) (net.Conn, error) {
+		client, server := net.Pipe()
+		go io.Copy(ioutil.Discard, server)
+		return client, nil
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	runWithTimeout(t, time.Second, func() {
+		_, err := DialURL("ldaps://ldap.example.com", WithDialer(dialer), WithDialContext(ctx), WithConnectTimeout(0))
+		if !IsErrorWithCode(err, ErrorNetwork) || err.(*Error).Err != context.Canceled {
+			t.Errorf("expected ErrorNetwork caused by context.Canceled, got %v", err)
+		}
+	})
+}
+
+// newClientHelloDialer returns a dialer whose server sends the TLS ClientHello it
+// receives to hellos, after answering the StartTLS request if startTLS is true
+func newClientHelloDialer(hellos chan<- *tls.ClientHelloInfo, startTLS bool) *testDialer {
+	return &testDialer{dial: func(ctx context.Context) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			if startTLS {
+				request, err := ber.ReadPacket(server)
+				if err != nil {
+					return
+				}
+				server.Write(newExtendedResponsePacket(request.Children[0].Value.(int64), LDAPResultSuccess, nil).Bytes())
+			}
+			tls.Server(server, &tls.Config{
+				GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+					hellos <- hello
+					return nil, errors.New("no certificate")
+				},
+			}).Handshake()
+		}()
+		return client, nil
+	}}
+}
+
+func TestDialURLWithTLSConfig(t *testing.T) {
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	}
+	for _, url := range []string{"ldaps://ldap.example.com", "ldap://ldap.example.com"} {
+		hellos := make(chan *tls.ClientHelloInfo, 1)
+		dialer := newClientHelloDialer(hellos, url == "ldap://ldap.example.com")
+		runWithTimeout(t, time.Second, func() {
+			conn, err := DialURL(url, WithDialer(dialer), WithTLSConfig(config))
+			if err == nil {
+				defer conn.Close()
+				err = conn.StartTLS(nil)
+			}
+			if !IsErrorWithCode(err, ErrorNetwork) {
+				t.Errorf("%s: expected ErrorNetwork, got %v", url, err)
+			}
+		})
+
+		hello := <-hellos
+		if hello.ServerName != "ldap.example.com" {
+			t.Errorf("%s: expected the server name of the URL, got %q", url, hello.ServerName)
+		}
+		if len(hello.CipherSuites) != 1 || hello.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+			t.Errorf("%s: expected the configured cipher suite, got %v", url, hello.CipherSuites)
+		}
+	}
+	if config.ServerName != "" {
+		t.Errorf("the given configuration was modified")
+	}
+}
+
+func TestParseDialURL(t *testing.T) {
+	testcases := []struct {
+		URL     string
+		Network string
+		Address string
+		Host    string
+		TLS     bool
+	}{
+		{"ldap://ldap.example.com", "tcp", "ldap.example.com:389", "ldap.example.com", false},
+		{"ldap://ldap.example.com:1389", "tcp", "ldap.example.com:1389", "ldap.example.com", false},
+		{"ldaps://ldap.example.com", "tcp", "ldap.example.com:636", "ldap.example.com", true},
+		{"ldaps://ldap.example.com:1636/", "tcp", "ldap.example.com:1636", "ldap.example.com", true},
+		{"ldap://192.0.2.1", "tcp", "192.0.2.1:389", "192.0.2.1", false},
+		{"ldap://[::1]", "tcp", "[::1]:389", "::1", false},
+		{"ldap://[::1]:1389", "tcp", "[::1]:1389", "::1", false},
+		{"ldaps://[2001:db8::1]", "tcp", "[2001:db8::1]:636", "2001:db8::1", true},
+		{"ldaps://[2001:db8::1]:1636", "tcp", "[2001:db8::1]:1636", "2001:db8::1", true},
+		{"ldaps://[fe80::1%25eth0]:1636", "tcp", "[fe80::1%eth0]:1636", "fe80::1%eth0", true},
+		{"ldap://ldap.example.com:", "tcp", "ldap.example.com:389", "ldap.example.com", false},
+		{"ldapi://", "unix", "/var/run/slapd/ldapi", "", false},
+		{"ldapi:///", "unix", "/var/run/slapd/ldapi", "", false},
+		{"ldapi:///tmp/ldapi", "unix", "/tmp/ldapi", "", false},
+		{"ldapi://%2Ftmp%2Fldap%20i/", "unix", "/tmp/ldap i", "", false},
+	}
+
+	for _, tc := range testcases {
+		network, address, host, useTLS, err := parseDialURL(tc.URL)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.URL, err)
+			continue
+		}
+		if network != tc.Network || address != tc.Address || host != tc.Host || useTLS != tc.TLS {
+			t.Errorf("%s: expected %s %s %q %t, got %s %s %q %t", tc.URL, tc.Network, tc.Address, tc.Host, tc.TLS, network, address, host, useTLS)
+		}
+	}
+
+	for _, invalid := range []string{"http://ldap.example.com", "ldap://[::1", "ldapi://%zz"} {
+		if _, _, _, _, err := parseDialURL(invalid); err == nil {
+			t.Errorf("%s: expected an error", invalid)
+		}
+	}
+}
@@ -18,23 +18,69 @@ const (
 	ControlTypeVChuPasswordWarning = "2.16.840.1.113730.3.4.5"
 	// ControlTypeManageDsaIT - https://tools.ietf.org/html/rfc3296
 	ControlTypeManageDsaIT = "2.16.840.1.113730.3.4.2"
+	// ControlTypeAssertion - https://tools.ietf.org/html/rfc4528
+	ControlTypeAssertion = "1.3.6.1.1.12"
+	// ControlTypeProxiedAuthorization - https://tools.ietf.org/html/rfc4370
+	ControlTypeProxiedAuthorization = "2.16.840.1.113730.3.4.18"
+	// ControlTypeServerSideSort - https://www.ietf.org/rfc/rfc2891.txt
+	ControlTypeServerSideSort = "1.2.840.113556.1.4.473"
+	// ControlTypeServerSideSortResponse - https://www.ietf.org/rfc/rfc2891.txt
+	ControlTypeServerSideSortResponse = "1.2.840.113556.1.4.474"
+	// ControlTypeVLVRequest - https://tools.ietf.org/html/draft-ietf-ldapext-ldapv3-vlv-09
+	//
+	// The OIDs assigned by the draft are the ones Active Directory, OpenLDAP and 389 Directory
+	// Server advertise in supportedControl: 2.16.840.1.113556.1.4.479 and .480, sometimes given
+	// for VLV, are not assigned to it, and servers would reject them as unknown critical controls.
+	ControlTypeVLVRequest = "2.16.840.1.113730.3.4.9"
+	// ControlTypeVLVResponse - https://tools.ietf.org/html/draft-ietf-ldapext-ldapv3-vlv-09
+	ControlTypeVLVResponse = "2.16.840.1.113730.3.4.10"
+	// ControlTypeSyncRequest - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncRequest = "1.3.6.1.4.1.4203.1.9.1.1"
+	// ControlTypeSyncState - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncState = "1.3.6.1.4.1.4203.1.9.1.2"
+	// ControlTypeSyncDone - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncDone = "1.3.6.1.4.1.4203.1.9.1.3"
+	// ControlTypeTransactionSpecification - https://tools.ietf.org/html/rfc5805
+	ControlTypeTransactionSpecification = "1.3.6.1.1.21.2"
+	// ControlTypeNoOp - https://tools.ietf.org/html/draft-zeilenga-ldap-noop-01
+	ControlTypeNoOp = "1.3.6.1.4.1.4203.1.10.2"
 
 	// ControlTypeMicrosoftNotification - https://msdn.microsoft.com/en-us/library/aa366983(v=vs.85).aspx
 	ControlTypeMicrosoftNotification = "1.2.840.113556.1.4.528"
 	// ControlTypeMicrosoftShowDeleted - https://msdn.microsoft.com/en-us/library/aa366989(v=vs.85).aspx
 	ControlTypeMicrosoftShowDeleted = "1.2.840.113556.1.4.417"
+	// ControlTypeMicrosoftShowRecycled - LDAP_SERVER_SHOW_RECYCLED_OID of [MS-ADTS]
+	ControlTypeMicrosoftShowRecycled = "1.2.840.113556.1.4.2064"
 	// ControlTypeMicrosoftDirSync - https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3
 	ControlTypeMicrosoftDirSync = "1.2.840.113556.1.4.841"
+	// ControlTypeSubtreeDelete - https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/ec9ae65d-1fc4-4f1d-99cb-5b0df3a1a8b2
+	ControlTypeSubtreeDelete = "1.2.840.113556.1.4.805"
+	// ControlTypePermissiveModify - LDAP_SERVER_PERMISSIVE_MODIFY_OID of [MS-ADTS]
+	ControlTypePermissiveModify = "1.2.840.113556.1.4.1413"
 )
 
 // ControlTypeMap maps controls to text descriptions
 var ControlTypeMap = map[string]string{
-	ControlTypePaging:                "Paging",
-	ControlTypeBeheraPasswordPolicy:  "Password Policy - Behera Draft",
-	ControlTypeManageDsaIT:           "Manage DSA IT",
-	ControlTypeMicrosoftNotification: "Change Notification - Microsoft",
-	ControlTypeMicrosoftShowDeleted:  "Show Deleted Objects - Microsoft",
-	ControlTypeMicrosoftDirSync:      "DirSync - Microsoft",
+	ControlTypePaging:                   "Paging",
+	ControlTypeBeheraPasswordPolicy:     "Password Policy - Behera Draft",
+	ControlTypeManageDsaIT:              "Manage DSA IT",
+	ControlTypeAssertion:                "Assertion",
+	ControlTypeProxiedAuthorization:     "Proxied Authorization",
+	ControlTypeServerSideSort:           "Server Side Sort",
+	ControlTypeServerSideSortResponse:   "Server Side Sort Response",
+	ControlTypeTransactionSpecification: "Transaction Specification",
+	ControlTypeNoOp:                     "No-Op",
+	ControlTypeVLVRequest:               "Virtual List View Request",
+	ControlTypeVLVResponse:              "Virtual List View Response",
+	ControlTypeSyncRequest:              "Sync Request",
+	ControlTypeSyncState:                "Sync State",
+	ControlTypeSyncDone:                 "Sync Done",
+	ControlTypeMicrosoftNotification:    "Change Notification - Microsoft",
+	ControlTypeMicrosoftShowDeleted:     "Show Deleted Objects - Microsoft",
+	ControlTypeMicrosoftShowRecycled:    "Show Recycled Objects - Microsoft",
+	ControlTypeMicrosoftDirSync:         "DirSync - Microsoft",
+	ControlTypeSubtreeDelete:            "Subtree Delete",
+	ControlTypePermissiveModify:         "Permissive Modify",
 }
 
 // Control defines an interface controls provide to encode and describe themselves
@@ -52,6 +98,9 @@ type ControlString struct {
 	ControlType  string
 	Criticality  bool
 	ControlValue string
+	// Packet is the original packet of a control decoded by DecodeControl, which can be
+	// re-emitted unchanged, for example by a proxy. It is ignored by Encode.
+	Packet *ber.Packet
 }
 
 // GetControlType returns the OID
@@ -59,13 +108,15 @@ func (c *ControlString) GetControlType() string {
 	return c.ControlType
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlString) WithCriticality(criticality bool) *ControlString {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlString) Encode() *ber.Packet {
-	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
-	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.ControlType, "Control Type ("+ControlTypeMap[c.ControlType]+")"))
-	if c.Criticality {
-		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
-	}
+	packet := newControlPacket(c.ControlType, c.Criticality)
 	if c.ControlValue != "" {
 		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.ControlValue), "Control Value"))
 	}
@@ -79,6 +130,8 @@ func (c *ControlString) String() string {
 
 // ControlPaging implements the paging control described in https://www.ietf.org/rfc/rfc2696.txt
 type ControlPaging struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// PagingSize indicates the page size
 	PagingSize uint32
 	// Cookie is an opaque value returned by the server to track a paging cursor
@@ -90,10 +143,15 @@ func (c *ControlPaging) GetControlType() string {
 	return ControlTypePaging
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlPaging) WithCriticality(criticality bool) *ControlPaging {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlPaging) Encode() *ber.Packet {
-	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
-	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypePaging, "Control Type ("+ControlTypeMap[ControlTypePaging]+")"))
+	packet := newControlPacket(ControlTypePaging, c.Criticality)
 
 	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Paging)")
 	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Search Control Value")
@@ -114,7 +172,7 @@ func (c *ControlPaging) String() string {
 		"Control Type: %s (%q)  Criticality: %t  PagingSize: %d  Cookie: %q",
 		ControlTypeMap[ControlTypePaging],
 		ControlTypePaging,
-		false,
+		c.Criticality,
 		c.PagingSize,
 		c.Cookie)
 }
@@ -126,13 +184,15 @@ func (c *ControlPaging) SetCookie(cookie []byte) {
 
 // ControlBeheraPasswordPolicy implements the control described in https://tools.ietf.org/html/draft-behera-ldap-password-policy-10
 type ControlBeheraPasswordPolicy struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// Expire contains the number of seconds before a password will expire
 	Expire int64
 	// Grace indicates the remaining number of times a user will be allowed to authenticate with an expired password
 	Grace int64
-	// Error indicates the error code
+	// Error indicates the error code, or -1 if there is no error
 	Error int8
-	// ErrorString is a human readable error
+	// ErrorString is a human readable error, as returned by BeheraPasswordPolicyError(Error).String()
 	ErrorString string
 }
 
@@ -141,12 +201,15 @@ func (c *ControlBeheraPasswordPolicy) GetControlType() string {
 	return ControlTypeBeheraPasswordPolicy
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlBeheraPasswordPolicy) WithCriticality(criticality bool) *ControlBeheraPasswordPolicy {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlBeheraPasswordPolicy) Encode() *ber.Packet {
-	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
-	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeBeheraPasswordPolicy, "Control Type ("+ControlTypeMap[ControlTypeBeheraPasswordPolicy]+")"))
-
-	return packet
+	return newControlPacket(ControlTypeBeheraPasswordPolicy, c.Criticality)
 }
 
 // String returns a human-readable description
@@ -155,7 +218,7 @@ func (c *ControlBeheraPasswordPolicy) String() string {
 		"Control Type: %s (%q)  Criticality: %t  Expire: %d  Grace: %d  Error: %d, ErrorString: %s",
 		ControlTypeMap[ControlTypeBeheraPasswordPolicy],
 		ControlTypeBeheraPasswordPolicy,
-		false,
+		c.Criticality,
 		c.Expire,
 		c.Grace,
 		c.Error,
@@ -164,6 +227,8 @@ func (c *ControlBeheraPasswordPolicy) String() string {
 
 // ControlVChuPasswordMustChange implements the control described in https://tools.ietf.org/html/draft-vchu-ldap-pwd-policy-00
 type ControlVChuPasswordMustChange struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// MustChange indicates if the password is required to be changed
 	MustChange bool
 }
@@ -173,6 +238,12 @@ func (c *ControlVChuPasswordMustChange) GetControlType() string {
 	return ControlTypeVChuPasswordMustChange
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlVChuPasswordMustChange) WithCriticality(criticality bool) *ControlVChuPasswordMustChange {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlVChuPasswordMustChange) Encode() *ber.Packet {
 	return nil
@@ -184,12 +255,14 @@ func (c *ControlVChuPasswordMustChange) String() string {
 		"Control Type: %s (%q)  Criticality: %t  MustChange: %v",
 		ControlTypeMap[ControlTypeVChuPasswordMustChange],
 		ControlTypeVChuPasswordMustChange,
-		false,
+		c.Criticality,
 		c.MustChange)
 }
 
 // ControlVChuPasswordWarning implements the control described in https://tools.ietf.org/html/draft-vchu-ldap-pwd-policy-00
 type ControlVChuPasswordWarning struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// Expire indicates the time in seconds until the password expires
 	Expire int64
 }
@@ -199,6 +272,12 @@ func (c *ControlVChuPasswordWarning) GetControlType() string {
 	return ControlTypeVChuPasswordWarning
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlVChuPasswordWarning) WithCriticality(criticality bool) *ControlVChuPasswordWarning {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlVChuPasswordWarning) Encode() *ber.Packet {
 	return nil
@@ -210,7 +289,7 @@ func (c *ControlVChuPasswordWarning) String() string {
 		"Control Type: %s (%q)  Criticality: %t  Expire: %b",
 		ControlTypeMap[ControlTypeVChuPasswordWarning],
 		ControlTypeVChuPasswordWarning,
-		false,
+		c.Criticality,
 		c.Expire)
 }
 
@@ -225,15 +304,16 @@ func (c *ControlManageDsaIT) GetControlType() string {
 	return ControlTypeManageDsaIT
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlManageDsaIT) WithCriticality(criticality bool) *ControlManageDsaIT {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlManageDsaIT) Encode() *ber.Packet {
-	//FIXME
-	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
-	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeManageDsaIT, "Control Type ("+ControlTypeMap[ControlTypeManageDsaIT]+")"))
-	if c.Criticality {
-		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
-	}
-	return packet
+	// RFC 3296: the controlValue is absent
+	return newControlPacket(ControlTypeManageDsaIT, c.Criticality)
 }
 
 // String returns a human-readable description
@@ -250,28 +330,181 @@ func NewControlManageDsaIT(Criticality bool) *ControlManageDsaIT {
 	return &ControlManageDsaIT{Criticality: Criticality}
 }
 
+// ControlAssertion implements the control described in https://tools.ietf.org/html/rfc4528
+//
+// The operation the control is attached to is only performed if the target entry matches
+// the filter. Otherwise it fails with LDAPResultAssertionFailed.
+type ControlAssertion struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Filter is the assertion the entry must match
+	Filter string
+}
+
+// GetControlType returns the OID
+func (c *ControlAssertion) GetControlType() string {
+	return ControlTypeAssertion
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlAssertion) WithCriticality(criticality bool) *ControlAssertion {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlAssertion) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeAssertion, c.Criticality)
+
+	// the filter is compiled on each call so that changes to Filter are sent. NewControlAssertion
+	// checks that it is valid: an invalid filter results in an empty value, which the server rejects.
+	filterPacket, _ := CompileFilter(c.Filter)
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Assertion)")
+	if filterPacket != nil {
+		p2.AppendChild(filterPacket)
+	}
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlAssertion) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Filter: %s",
+		ControlTypeMap[ControlTypeAssertion],
+		ControlTypeAssertion,
+		c.Criticality,
+		c.Filter)
+}
+
+// NewControlAssertion returns a critical ControlAssertion control for the given filter,
+// or an error if the filter cannot be compiled
+func NewControlAssertion(filter string) (*ControlAssertion, error) {
+	if _, err := CompileFilter(filter); err != nil {
+		return nil, err
+	}
+	return &ControlAssertion{Criticality: true, Filter: filter}, nil
+}
+
+// ControlProxiedAuthorization implements the control described in https://tools.ietf.org/html/rfc4370
+//
+// NewControlProxiedAuthorization returns a critical control: servers which do not support
+// it reject the operation instead of performing it with the identity of the bound user.
+type ControlProxiedAuthorization struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// AuthzID is the authorization identity to perform the operation as, either "dn:" followed
+	// by a DN or "u:" followed by a user name. It is empty for the anonymous identity.
+	AuthzID string
+}
+
+// GetControlType returns the OID
+func (c *ControlProxiedAuthorization) GetControlType() string {
+	return ControlTypeProxiedAuthorization
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlProxiedAuthorization) WithCriticality(criticality bool) *ControlProxiedAuthorization {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlProxiedAuthorization) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeProxiedAuthorization, c.Criticality)
+	// the value is the authzId itself, not a BER encoding of it
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.AuthzID, "Control Value (Proxied Authorization)"))
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlProxiedAuthorization) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  AuthzID: %s",
+		ControlTypeMap[ControlTypeProxiedAuthorization],
+		ControlTypeProxiedAuthorization,
+		c.Criticality,
+		c.AuthzID)
+}
+
+// NewControlProxiedAuthorization returns a critical ControlProxiedAuthorization control
+func NewControlProxiedAuthorization(authzID string) *ControlProxiedAuthorization {
+	return &ControlProxiedAuthorization{Criticality: true, AuthzID: authzID}
+}
+
+// ControlTransactionSpecification implements the control described in https://tools.ietf.org/html/rfc5805,
+// which identifies the transaction an update operation is part of.
+type ControlTransactionSpecification struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Identifier is the transaction identifier returned by the server when starting the transaction
+	Identifier []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlTransactionSpecification) GetControlType() string {
+	return ControlTypeTransactionSpecification
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlTransactionSpecification) WithCriticality(criticality bool) *ControlTransactionSpecification {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlTransactionSpecification) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeTransactionSpecification, c.Criticality)
+	// the value is the transaction identifier itself, not a BER encoding of it
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Identifier), "Control Value (Transaction Specification)"))
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlTransactionSpecification) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Identifier: %q",
+		ControlTypeMap[ControlTypeTransactionSpecification],
+		ControlTypeTransactionSpecification,
+		c.Criticality,
+		c.Identifier)
+}
+
+// NewControlTransactionSpecification returns a critical ControlTransactionSpecification control
+func NewControlTransactionSpecification(identifier []byte) *ControlTransactionSpecification {
+	return &ControlTransactionSpecification{Criticality: true, Identifier: identifier}
+}
+
 // ControlMicrosoftNotification implements the control described in https://msdn.microsoft.com/en-us/library/aa366983(v=vs.85).aspx
-type ControlMicrosoftNotification struct{}
+type ControlMicrosoftNotification struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
 
 // GetControlType returns the OID
 func (c *ControlMicrosoftNotification) GetControlType() string {
 	return ControlTypeMicrosoftNotification
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlMicrosoftNotification) WithCriticality(criticality bool) *ControlMicrosoftNotification {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlMicrosoftNotification) Encode() *ber.Packet {
-	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
-	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftNotification, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftNotification]+")"))
-
-	return packet
+	return newControlPacket(ControlTypeMicrosoftNotification, c.Criticality)
 }
 
 // String returns a human-readable description
 func (c *ControlMicrosoftNotification) String() string {
 	return fmt.Sprintf(
-		"Control Type: %s (%q)",
+		"Control Type: %s (%q)  Criticality: %t",
 		ControlTypeMap[ControlTypeMicrosoftNotification],
-		ControlTypeMicrosoftNotification)
+		ControlTypeMicrosoftNotification,
+		c.Criticality)
 }
 
 // NewControlMicrosoftNotification returns a ControlMicrosoftNotification control
@@ -280,34 +513,210 @@ func NewControlMicrosoftNotification() *ControlMicrosoftNotification {
 }
 
 // ControlMicrosoftShowDeleted implements the control described in https://msdn.microsoft.com/en-us/library/aa366989(v=vs.85).aspx
-type ControlMicrosoftShowDeleted struct{}
+type ControlMicrosoftShowDeleted struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
 
 // GetControlType returns the OID
 func (c *ControlMicrosoftShowDeleted) GetControlType() string {
 	return ControlTypeMicrosoftShowDeleted
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlMicrosoftShowDeleted) WithCriticality(criticality bool) *ControlMicrosoftShowDeleted {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlMicrosoftShowDeleted) Encode() *ber.Packet {
-	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
-	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftShowDeleted, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftShowDeleted]+")"))
-
-	return packet
+	return newControlPacket(ControlTypeMicrosoftShowDeleted, c.Criticality)
 }
 
 // String returns a human-readable description
 func (c *ControlMicrosoftShowDeleted) String() string {
 	return fmt.Sprintf(
-		"Control Type: %s (%q)",
+		"Control Type: %s (%q)  Criticality: %t",
 		ControlTypeMap[ControlTypeMicrosoftShowDeleted],
-		ControlTypeMicrosoftShowDeleted)
+		ControlTypeMicrosoftShowDeleted,
+		c.Criticality)
 }
 
-// NewControlMicrosoftShowDeleted returns a ControlMicrosoftShowDeleted control
+// NewControlMicrosoftShowDeleted returns a ControlMicrosoftShowDeleted control, which makes
+// Active Directory return the deleted objects (tombstones) matching a search. The deleted
+// objects of a domain are found by searching the Deleted Objects container, whose DN is
+// returned by DeletedObjectsDN.
 func NewControlMicrosoftShowDeleted() *ControlMicrosoftShowDeleted {
 	return &ControlMicrosoftShowDeleted{}
 }
 
+// ControlMicrosoftShowRecycled implements the LDAP_SERVER_SHOW_RECYCLED_OID control of [MS-ADTS]
+type ControlMicrosoftShowRecycled struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlMicrosoftShowRecycled) GetControlType() string {
+	return ControlTypeMicrosoftShowRecycled
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlMicrosoftShowRecycled) WithCriticality(criticality bool) *ControlMicrosoftShowRecycled {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftShowRecycled) Encode() *ber.Packet {
+	return newControlPacket(ControlTypeMicrosoftShowRecycled, c.Criticality)
+}
+
+// String returns a human-readable description
+func (c *ControlMicrosoftShowRecycled) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeMicrosoftShowRecycled],
+		ControlTypeMicrosoftShowRecycled,
+		c.Criticality)
+}
+
+// NewControlMicrosoftShowRecycled returns a ControlMicrosoftShowRecycled control, which makes
+// Active Directory return the recycled objects in addition to the deleted objects, when the
+// Recycle Bin is enabled. It is used like ControlMicrosoftShowDeleted.
+func NewControlMicrosoftShowRecycled() *ControlMicrosoftShowRecycled {
+	return &ControlMicrosoftShowRecycled{}
+}
+
+// DeletedObjectsGUID is the well-known GUID of the Deleted Objects container of Active Directory
+const DeletedObjectsGUID = "18E2EA80684F11D2B9AA00C04F79F805"
+
+// DeletedObjectsDN returns the DN binding to the Deleted Objects container of the given domain
+// or naming context, to be used as the base of a search with the ControlMicrosoftShowDeleted
+// control, e.g. "<WKGUID=18E2EA80684F11D2B9AA00C04F79F805,dc=example,dc=com>"
+func DeletedObjectsDN(namingContext string) string {
+	return "<WKGUID=" + DeletedObjectsGUID + "," + namingContext + ">"
+}
+
+// ControlSubtreeDelete implements the subtree delete control, which makes a delete request
+// remove the entry and all its descendants
+type ControlSubtreeDelete struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSubtreeDelete) GetControlType() string {
+	return ControlTypeSubtreeDelete
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlSubtreeDelete) WithCriticality(criticality bool) *ControlSubtreeDelete {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSubtreeDelete) Encode() *ber.Packet {
+	return newControlPacket(ControlTypeSubtreeDelete, c.Criticality)
+}
+
+// String returns a human-readable description
+func (c *ControlSubtreeDelete) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeSubtreeDelete],
+		ControlTypeSubtreeDelete,
+		c.Criticality)
+}
+
+// NewControlSubtreeDelete returns a critical ControlSubtreeDelete control, so that a server
+// which does not support it refuses the deletion instead of deleting only a leaf entry
+func NewControlSubtreeDelete() *ControlSubtreeDelete {
+	return &ControlSubtreeDelete{Criticality: true}
+}
+
+// ControlNoOp implements the No-Op control, which makes the server check an update operation
+// and return its result without performing it. The server returns the LDAPResultNoOperation
+// result code instead of LDAPResultSuccess, which is not reported as an error.
+type ControlNoOp struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlNoOp) GetControlType() string {
+	return ControlTypeNoOp
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlNoOp) WithCriticality(criticality bool) *ControlNoOp {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlNoOp) Encode() *ber.Packet {
+	return newControlPacket(ControlTypeNoOp, c.Criticality)
+}
+
+// String returns a human-readable description
+func (c *ControlNoOp) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeNoOp],
+		ControlTypeNoOp,
+		c.Criticality)
+}
+
+// NewControlNoOp returns a critical ControlNoOp control, so that a server which does not
+// support it refuses the operation instead of performing it
+func NewControlNoOp() *ControlNoOp {
+	return &ControlNoOp{Criticality: true}
+}
+
+// ControlPermissiveModify implements the LDAP_SERVER_PERMISSIVE_MODIFY_OID control of
+// [MS-ADTS], which has no value
+type ControlPermissiveModify struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlPermissiveModify) GetControlType() string {
+	return ControlTypePermissiveModify
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlPermissiveModify) WithCriticality(criticality bool) *ControlPermissiveModify {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlPermissiveModify) Encode() *ber.Packet {
+	return newControlPacket(ControlTypePermissiveModify, c.Criticality)
+}
+
+// String returns a human-readable description
+func (c *ControlPermissiveModify) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypePermissiveModify],
+		ControlTypePermissiveModify,
+		c.Criticality)
+}
+
+// NewControlPermissiveModify returns a ControlPermissiveModify control, which makes Active
+// Directory accept a modify request adding a value which already exists or deleting a value
+// which does not exist, instead of failing with LDAPResultAttributeOrValueExists or
+// LDAPResultNoSuchAttribute. With it, adding a member to a group which already has it
+// succeeds, so that group memberships can be updated idempotently.
+func NewControlPermissiveModify() *ControlPermissiveModify {
+	return &ControlPermissiveModify{}
+}
+
 // Values for ControlMicrosoftDirSync Flag field
 const (
 	DirSyncFlagNone              = 0
@@ -317,8 +726,20 @@ const (
 	DirSyncFlagIncrementalValues = 0x80000000
 )
 
+// Values for ControlMicrosoftDirSync Flag field, named after the LDAP_DIRSYNC_* flags of Active Directory
+const (
+	DirSyncObjectSecurity      = DirSyncFlagObjectSecurity
+	DirSyncAncestorsFirstOrder = DirSyncFlagParentsFirst
+	DirSyncPublicDataOnly      = DirSyncFlagPublicDataOnly
+	// DirSyncIncrementalValues requests only the changed values of linked multi-valued
+	// attributes, see Entry.GetDirSyncValueChanges
+	DirSyncIncrementalValues = DirSyncFlagIncrementalValues
+)
+
 // ControlMicrosoftDirSync implements the DirSync control described in https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3
 type ControlMicrosoftDirSync struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// Flags contains optional flags
 	Flags uint32
 	// MaxBytes specifies the maximum number of bytes to return in the reply message
@@ -332,16 +753,20 @@ func (c *ControlMicrosoftDirSync) GetControlType() string {
 	return ControlTypeMicrosoftDirSync
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlMicrosoftDirSync) WithCriticality(criticality bool) *ControlMicrosoftDirSync {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlMicrosoftDirSync) Encode() *ber.Packet {
-	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
-	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftDirSync, "Control Type (ControlTypeDirSync)"))
-
-	packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "Criticality"))
+	packet := newControlPacket(ControlTypeMicrosoftDirSync, c.Criticality)
 
 	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (ControlMicrosoftDirSync)")
 	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "ControlMicrosoftDirSync Control Value")
-	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(c.Flags), "Flags"))
+	// Active Directory expects the flags as a 32 bits signed integer
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(int32(c.Flags)), "Flags"))
 	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(c.MaxBytes), "MaxBytes"))
 	cookie := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Cookie")
 	cookie.Value = c.Cookie
@@ -359,7 +784,7 @@ func (c *ControlMicrosoftDirSync) String() string {
 		"Control Type: %s (%q)  Criticality: %t  Flags: %d  Cookie: %q",
 		ControlTypeMap[ControlTypePaging],
 		ControlTypePaging,
-		false,
+		c.Criticality,
 		c.Flags,
 		c.Cookie)
 }
@@ -369,13 +794,15 @@ func (c *ControlMicrosoftDirSync) SetCookie(cookie []byte) {
 	c.Cookie = cookie
 }
 
-// NewControlMicrosoftDirSync returns a ControlMicrosoftDirSync control
+// NewControlMicrosoftDirSync returns a critical ControlMicrosoftDirSync control
 func NewControlMicrosoftDirSync() *ControlMicrosoftDirSync {
-	return &ControlMicrosoftDirSync{}
+	return &ControlMicrosoftDirSync{Criticality: true}
 }
 
 // ControlMicrosoftDirSyncResponse implements the DirSync control response described in https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3
 type ControlMicrosoftDirSyncResponse struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// MoreResults is nonzero if there are more changes to retrieve
 	MoreResults uint32
 	// Unused is unused
@@ -389,12 +816,15 @@ func (c *ControlMicrosoftDirSyncResponse) GetControlType() string {
 	return ControlTypeMicrosoftDirSync
 }
 
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlMicrosoftDirSyncResponse) WithCriticality(criticality bool) *ControlMicrosoftDirSyncResponse {
+	c.Criticality = criticality
+	return c
+}
+
 // Encode returns the ber packet representation
 func (c *ControlMicrosoftDirSyncResponse) Encode() *ber.Packet {
-	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
-	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftDirSync, "Control Type (ControlTypeDirSync)"))
-
-	packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "Criticality"))
+	packet := newControlPacket(ControlTypeMicrosoftDirSync, c.Criticality)
 
 	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (ControlMicrosoftDirSyncResponse)")
 	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "ControlMicrosoftDirSyncResponse Control Value")
@@ -416,13 +846,440 @@ func (c *ControlMicrosoftDirSyncResponse) String() string {
 		"Control Type: %s (%q)  Criticality: %t  Data: %d  Count: %d Cookie: %q",
 		ControlTypeMap[ControlTypeMicrosoftDirSync],
 		ControlTypeMicrosoftDirSync,
-		false,
+		c.Criticality,
 		c.MoreResults,
 		c.Unused,
 		c.Cookie)
 }
 
-// FindControl returns the first control of the given type in the list, or nil
+// SortKey describes an attribute to sort on with the server side sort control
+type SortKey struct {
+	// AttributeType is the attribute to sort on
+	AttributeType string
+	// MatchingRule is the optional ordering rule to use instead of the attribute's default one
+	MatchingRule string
+	// ReverseOrder sorts entries in descending order
+	ReverseOrder bool
+}
+
+// ControlServerSideSort implements the sort request control described in https://www.ietf.org/rfc/rfc2891.txt
+type ControlServerSideSort struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// SortKeys lists the attributes to sort on, by decreasing precedence
+	SortKeys []SortKey
+}
+
+// GetControlType returns the OID
+func (c *ControlServerSideSort) GetControlType() string {
+	return ControlTypeServerSideSort
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlServerSideSort) WithCriticality(criticality bool) *ControlServerSideSort {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlServerSideSort) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeServerSideSort, c.Criticality)
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Server Side Sort)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortKeyList")
+	for _, key := range c.SortKeys {
+		keySeq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortKey")
+		keySeq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, key.AttributeType, "Attribute Type"))
+		if key.MatchingRule != "" {
+			keySeq.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, key.MatchingRule, "Ordering Rule"))
+		}
+		if key.ReverseOrder {
+			keySeq.AppendChild(ber.NewBoolean(ber.ClassContext, ber.TypePrimitive, 1, key.ReverseOrder, "Reverse Order"))
+		}
+		seq.AppendChild(keySeq)
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlServerSideSort) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  SortKeys: %v",
+		ControlTypeMap[ControlTypeServerSideSort],
+		ControlTypeServerSideSort,
+		c.Criticality,
+		c.SortKeys)
+}
+
+// NewControlServerSideSort returns a ControlServerSideSort control
+func NewControlServerSideSort(sortKeys []SortKey) *ControlServerSideSort {
+	return &ControlServerSideSort{SortKeys: sortKeys}
+}
+
+// ControlServerSideSortResponse implements the sort response control described in https://www.ietf.org/rfc/rfc2891.txt
+type ControlServerSideSortResponse struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// ResultCode is the LDAP result code of the sort operation
+	ResultCode uint16
+	// AttributeType is the attribute which caused the sort to fail, if reported by the server
+	AttributeType string
+}
+
+// GetControlType returns the OID
+func (c *ControlServerSideSortResponse) GetControlType() string {
+	return ControlTypeServerSideSortResponse
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlServerSideSortResponse) WithCriticality(criticality bool) *ControlServerSideSortResponse {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlServerSideSortResponse) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeServerSideSortResponse, c.Criticality)
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Server Side Sort Response)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortResult")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.ResultCode), "Sort Result"))
+	if c.AttributeType != "" {
+		seq.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, c.AttributeType, "Attribute Type"))
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlServerSideSortResponse) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  ResultCode: %s  AttributeType: %s",
+		ControlTypeMap[ControlTypeServerSideSortResponse],
+		ControlTypeServerSideSortResponse,
+		c.Criticality,
+		LDAPResultCodeMap[c.ResultCode],
+		c.AttributeType)
+}
+
+// ControlVLVRequest implements the virtual list view request control described in
+// https://tools.ietf.org/html/draft-ietf-ldapext-ldapv3-vlv-09, which requests a window of
+// the sorted search results. It must be sent along with a ControlServerSideSort control.
+//
+// The target entry of the window is identified either by its position, with Offset and
+// ContentCount, or, if GreaterThanOrEqual is not nil, as the first entry whose sort key
+// value is greater than or equal to GreaterThanOrEqual.
+type ControlVLVRequest struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// BeforeCount is the number of entries to return before the target entry
+	BeforeCount int
+	// AfterCount is the number of entries to return after the target entry
+	AfterCount int
+	// Offset is the position of the target entry, starting from 1
+	Offset int
+	// ContentCount is the client estimate of the number of entries, 0 if unknown
+	ContentCount int
+	// GreaterThanOrEqual is the assertion value identifying the target entry
+	GreaterThanOrEqual []byte
+	// ContextID is the ContextID of the previous ControlVLVResponse, if any
+	ContextID []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlVLVRequest) GetControlType() string {
+	return ControlTypeVLVRequest
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlVLVRequest) WithCriticality(criticality bool) *ControlVLVRequest {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlVLVRequest) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeVLVRequest, c.Criticality)
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Virtual List View Request)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "VirtualListViewRequest")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(c.BeforeCount), "Before Count"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(c.AfterCount), "After Count"))
+	if c.GreaterThanOrEqual != nil {
+		seq.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 1, string(c.GreaterThanOrEqual), "Greater Than Or Equal"))
+	} else {
+		byOffset := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "By Offset")
+		byOffset.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(c.Offset), "Offset"))
+		byOffset.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(c.ContentCount), "Content Count"))
+		seq.AppendChild(byOffset)
+	}
+	if c.ContextID != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.ContextID), "Context ID"))
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlVLVRequest) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  BeforeCount: %d  AfterCount: %d  Offset: %d  ContentCount: %d  GreaterThanOrEqual: %q  ContextID: %q",
+		ControlTypeMap[ControlTypeVLVRequest],
+		ControlTypeVLVRequest,
+		c.Criticality,
+		c.BeforeCount,
+		c.AfterCount,
+		c.Offset,
+		c.ContentCount,
+		c.GreaterThanOrEqual,
+		c.ContextID)
+}
+
+// NewControlVLVRequest returns a ControlVLVRequest control requesting the entries around the
+// entry at the given position, starting from 1, out of contentCount entries (0 if unknown)
+func NewControlVLVRequest(beforeCount, afterCount, offset, contentCount int) *ControlVLVRequest {
+	return &ControlVLVRequest{
+		BeforeCount:  beforeCount,
+		AfterCount:   afterCount,
+		Offset:       offset,
+		ContentCount: contentCount,
+	}
+}
+
+// ControlVLVResponse implements the virtual list view response control described in
+// https://tools.ietf.org/html/draft-ietf-ldapext-ldapv3-vlv-09
+type ControlVLVResponse struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// TargetPosition is the position of the target entry, starting from 1
+	TargetPosition int
+	// ContentCount is the server estimate of the number of entries
+	ContentCount int
+	// ResultCode is the LDAP result code of the virtual list view operation
+	ResultCode uint16
+	// ContextID is the value to send in the ContextID of the next ControlVLVRequest, if any
+	ContextID []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlVLVResponse) GetControlType() string {
+	return ControlTypeVLVResponse
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlVLVResponse) WithCriticality(criticality bool) *ControlVLVResponse {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlVLVResponse) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeVLVResponse, c.Criticality)
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Virtual List View Response)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "VirtualListViewResponse")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(c.TargetPosition), "Target Position"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(c.ContentCount), "Content Count"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.ResultCode), "Virtual List View Result"))
+	if c.ContextID != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.ContextID), "Context ID"))
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlVLVResponse) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  TargetPosition: %d  ContentCount: %d  ResultCode: %s  ContextID: %q",
+		ControlTypeMap[ControlTypeVLVResponse],
+		ControlTypeVLVResponse,
+		c.Criticality,
+		c.TargetPosition,
+		c.ContentCount,
+		LDAPResultCodeMap[c.ResultCode],
+		c.ContextID)
+}
+
+// ControlSyncRequest implements the sync request control described in
+// https://tools.ietf.org/html/rfc4533, which starts a content synchronization search
+type ControlSyncRequest struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Mode is SyncReplRefreshOnly or SyncReplRefreshAndPersist
+	Mode SyncReplMode
+	// Cookie is the cookie of the last synchronization, nil for a full synchronization
+	Cookie []byte
+	// ReloadHint asks the server to send the full content if the cookie is too old for an
+	// incremental synchronization, instead of failing with LDAPResultSyncRefreshRequired
+	ReloadHint bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncRequest) GetControlType() string {
+	return ControlTypeSyncRequest
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlSyncRequest) WithCriticality(criticality bool) *ControlSyncRequest {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncRequest) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeSyncRequest, c.Criticality)
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync Request)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SyncRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.Mode), "Mode"))
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	}
+	if c.ReloadHint {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.ReloadHint, "Reload Hint"))
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncRequest) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Mode: %d  Cookie: %q  ReloadHint: %t",
+		ControlTypeMap[ControlTypeSyncRequest],
+		ControlTypeSyncRequest,
+		c.Criticality,
+		c.Mode,
+		c.Cookie,
+		c.ReloadHint)
+}
+
+// NewControlSyncRequest returns a critical ControlSyncRequest control
+func NewControlSyncRequest(mode SyncReplMode, cookie []byte) *ControlSyncRequest {
+	return &ControlSyncRequest{Criticality: true, Mode: mode, Cookie: cookie}
+}
+
+// ControlSyncState implements the sync state control described in
+// https://tools.ietf.org/html/rfc4533, sent along with the entries of a content
+// synchronization search
+type ControlSyncState struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// State is SyncReplPresent, SyncReplAdd, SyncReplModify or SyncReplDelete
+	State SyncReplEventType
+	// EntryUUID is the entryUUID of the entry
+	EntryUUID []byte
+	// Cookie is the new synchronization cookie, if any
+	Cookie []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncState) GetControlType() string {
+	return ControlTypeSyncState
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlSyncState) WithCriticality(criticality bool) *ControlSyncState {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncState) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeSyncState, c.Criticality)
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync State)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SyncStateValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.State), "State"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.EntryUUID), "Entry UUID"))
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncState) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  State: %d  EntryUUID: %x  Cookie: %q",
+		ControlTypeMap[ControlTypeSyncState],
+		ControlTypeSyncState,
+		c.Criticality,
+		c.State,
+		c.EntryUUID,
+		c.Cookie)
+}
+
+// ControlSyncDone implements the sync done control described in
+// https://tools.ietf.org/html/rfc4533, sent at the end of a refresh only content
+// synchronization search
+type ControlSyncDone struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Cookie is the new synchronization cookie, if any
+	Cookie []byte
+	// RefreshDeletes is true if the deleted entries were sent, instead of the present ones
+	RefreshDeletes bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncDone) GetControlType() string {
+	return ControlTypeSyncDone
+}
+
+// WithCriticality sets the criticality of the control and returns it
+func (c *ControlSyncDone) WithCriticality(criticality bool) *ControlSyncDone {
+	c.Criticality = criticality
+	return c
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncDone) Encode() *ber.Packet {
+	packet := newControlPacket(ControlTypeSyncDone, c.Criticality)
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync Done)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SyncDoneValue")
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	}
+	if c.RefreshDeletes {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.RefreshDeletes, "Refresh Deletes"))
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncDone) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Cookie: %q  RefreshDeletes: %t",
+		ControlTypeMap[ControlTypeSyncDone],
+		ControlTypeSyncDone,
+		c.Criticality,
+		c.Cookie,
+		c.RefreshDeletes)
+}
+
+// FindControl returns the first control of the given type in the list, or nil.
+//
+// With Go 1.18 or later, GetControl also checks the Go type of the control.
 func FindControl(controls []Control, controlType string) Control {
 	for _, c := range controls {
 		if c.GetControlType() == controlType {
@@ -432,10 +1289,10 @@ func FindControl(controls []Control, controlType string) Control {
 	return nil
 }
 
-// DecodeControl returns a control read from the given packet, or nil if no recognized control can be made
+// DecodeControl returns a control read from the given packet, or nil if no recognized control can be made.
+// Controls of unknown types are returned as a *ControlString holding the original packet.
 func DecodeControl(packet *ber.Packet) (Control, error) {
 	var (
-		ControlType = ""
 		Criticality = false
 		value       *ber.Packet
 	)
@@ -447,29 +1304,25 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 
 	case 1:
 		// just type, no criticality or value
-		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
-		ControlType = packet.Children[0].Value.(string)
 
 	case 2:
-		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
-		ControlType = packet.Children[0].Value.(string)
-
 		// Children[1] could be criticality or value (both are optional)
 		// duck-type on whether this is a boolean
-		if _, ok := packet.Children[1].Value.(bool); ok {
+		if criticality, ok := packet.Children[1].Value.(bool); ok {
 			packet.Children[1].Description = "Criticality"
-			Criticality = packet.Children[1].Value.(bool)
+			Criticality = criticality
 		} else {
 			packet.Children[1].Description = "Control Value"
 			value = packet.Children[1]
 		}
 
 	case 3:
-		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
-		ControlType = packet.Children[0].Value.(string)
-
+		criticality, ok := packet.Children[1].Value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid control criticality")
+		}
 		packet.Children[1].Description = "Criticality"
-		Criticality = packet.Children[1].Value.(bool)
+		Criticality = criticality
 
 		packet.Children[2].Description = "Control Value"
 		value = packet.Children[2]
@@ -479,12 +1332,21 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 		return nil, fmt.Errorf("more than 3 children is invalid for controls")
 	}
 
+	ControlType, ok := packet.Children[0].Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid control type")
+	}
+	packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
+
 	switch ControlType {
 	case ControlTypeManageDsaIT:
 		return NewControlManageDsaIT(Criticality), nil
 	case ControlTypePaging:
+		if value == nil {
+			return nil, fmt.Errorf("invalid paging control")
+		}
 		value.Description += " (Paging)"
-		c := new(ControlPaging)
+		c := &ControlPaging{Criticality: Criticality}
 		if value.Value != nil {
 			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
 			if err != nil {
@@ -494,17 +1356,27 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 			value.Value = nil
 			value.AppendChild(valueChildren)
 		}
+		if len(value.Children) == 0 || len(value.Children[0].Children) < 2 {
+			return nil, fmt.Errorf("invalid paging control")
+		}
 		value = value.Children[0]
 		value.Description = "Search Control Value"
 		value.Children[0].Description = "Paging Size"
 		value.Children[1].Description = "Cookie"
-		c.PagingSize = uint32(value.Children[0].Value.(int64))
+		pagingSize, ok := value.Children[0].Value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid paging size")
+		}
+		c.PagingSize = uint32(pagingSize)
 		c.Cookie = value.Children[1].Data.Bytes()
 		value.Children[1].Value = c.Cookie
 		return c, nil
 	case ControlTypeBeheraPasswordPolicy:
+		c := NewControlBeheraPasswordPolicy().WithCriticality(Criticality)
+		if value == nil {
+			return c, nil
+		}
 		value.Description += " (Password Policy - Behera)"
-		c := NewControlBeheraPasswordPolicy()
 		if value.Value != nil {
 			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
 			if err != nil {
@@ -515,50 +1387,234 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 			value.AppendChild(valueChildren)
 		}
 
+		if len(value.Children) == 0 {
+			return c, nil
+		}
 		sequence := value.Children[0]
 
 		for _, child := range sequence.Children {
 			if child.Tag == 0 {
 				//Warning
+				if len(child.Children) == 0 {
+					return nil, fmt.Errorf("missing password policy warning")
+				}
 				warningPacket := child.Children[0]
-				packet, err := ber.DecodePacketErr(warningPacket.Data.Bytes())
+				val, err := ber.ParseInt64(warningPacket.Data.Bytes())
 				if err != nil {
-					return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+					return nil, fmt.Errorf("failed to decode warning: %s", err)
 				}
-				val, ok := packet.Value.(int64)
-				if ok {
-					if warningPacket.Tag == 0 {
-						//timeBeforeExpiration
-						c.Expire = val
-						warningPacket.Value = c.Expire
-					} else if warningPacket.Tag == 1 {
-						//graceAuthNsRemaining
-						c.Grace = val
-						warningPacket.Value = c.Grace
-					}
+				if warningPacket.Tag == 0 {
+					//timeBeforeExpiration
+					c.Expire = val
+					warningPacket.Value = c.Expire
+				} else if warningPacket.Tag == 1 {
+					//graceAuthNsRemaining
+					c.Grace = val
+					warningPacket.Value = c.Grace
 				}
 			} else if child.Tag == 1 {
 				// Error
-				packet, err := ber.DecodePacketErr(child.Data.Bytes())
+				val, err := ber.ParseInt64(child.Data.Bytes())
 				if err != nil {
-					return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+					return nil, fmt.Errorf("failed to decode error: %s", err)
 				}
-				val, ok := packet.Value.(int8)
-				if !ok {
-					// what to do?
-					val = -1
-				}
-				c.Error = val
+				c.Error = int8(val)
 				child.Value = c.Error
-				c.ErrorString = BeheraPasswordPolicyErrorMap[c.Error]
+				c.ErrorString = BeheraPasswordPolicyError(c.Error).String()
+			}
+		}
+		return c, nil
+	case ControlTypeAssertion:
+		if value == nil {
+			return nil, fmt.Errorf("invalid assertion control")
+		}
+		value.Description += " (Assertion)"
+		filterPacket, err := ber.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+		}
+		filter, err := DecompileFilter(filterPacket)
+		if err != nil {
+			return nil, err
+		}
+		return &ControlAssertion{Criticality: Criticality, Filter: filter}, nil
+	case ControlTypeProxiedAuthorization:
+		c := &ControlProxiedAuthorization{Criticality: Criticality}
+		if value != nil {
+			value.Description += " (Proxied Authorization)"
+			c.AuthzID = string(value.Data.Bytes())
+		}
+		return c, nil
+	case ControlTypeTransactionSpecification:
+		c := &ControlTransactionSpecification{Criticality: Criticality}
+		if value != nil {
+			value.Description += " (Transaction Specification)"
+			c.Identifier = value.Data.Bytes()
+		}
+		return c, nil
+	case ControlTypeServerSideSort:
+		if value == nil {
+			return nil, fmt.Errorf("invalid server side sort control")
+		}
+		value.Description += " (Server Side Sort)"
+		c := &ControlServerSideSort{Criticality: Criticality}
+		valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+		}
+		for _, keySeq := range valueChildren.Children {
+			if len(keySeq.Children) == 0 {
+				return nil, fmt.Errorf("missing attribute type in sort key")
+			}
+			key := SortKey{AttributeType: string(keySeq.Children[0].Data.Bytes())}
+			for _, child := range keySeq.Children[1:] {
+				switch child.Tag {
+				case 0:
+					key.MatchingRule = string(child.Data.Bytes())
+				case 1:
+					data := child.Data.Bytes()
+					key.ReverseOrder = len(data) > 0 && data[0] != 0
+				}
+			}
+			c.SortKeys = append(c.SortKeys, key)
+		}
+		return c, nil
+	case ControlTypeServerSideSortResponse:
+		if value == nil {
+			return nil, fmt.Errorf("invalid server side sort response control")
+		}
+		value.Description += " (Server Side Sort Response)"
+		c := &ControlServerSideSortResponse{Criticality: Criticality}
+		valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+		}
+		if len(valueChildren.Children) == 0 {
+			return nil, fmt.Errorf("missing sort result")
+		}
+		resultCode, ok := valueChildren.Children[0].Value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid sort result")
+		}
+		c.ResultCode = uint16(resultCode)
+		for _, child := range valueChildren.Children[1:] {
+			if child.Tag == 0 {
+				c.AttributeType = string(child.Data.Bytes())
+			}
+		}
+		return c, nil
+	case ControlTypeVLVRequest:
+		if value == nil {
+			return nil, fmt.Errorf("invalid virtual list view request")
+		}
+		value.Description += " (Virtual List View Request)"
+		c := &ControlVLVRequest{Criticality: Criticality}
+		valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+		}
+		if len(valueChildren.Children) < 3 {
+			return nil, fmt.Errorf("invalid virtual list view request")
+		}
+		beforeCount, ok1 := valueChildren.Children[0].Value.(int64)
+		afterCount, ok2 := valueChildren.Children[1].Value.(int64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid virtual list view request counts")
+		}
+		c.BeforeCount, c.AfterCount = int(beforeCount), int(afterCount)
+		target := valueChildren.Children[2]
+		switch target.Tag {
+		case 0:
+			if len(target.Children) != 2 {
+				return nil, fmt.Errorf("invalid virtual list view request offset")
+			}
+			offset, ok1 := target.Children[0].Value.(int64)
+			contentCount, ok2 := target.Children[1].Value.(int64)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("invalid virtual list view request offset")
+			}
+			c.Offset, c.ContentCount = int(offset), int(contentCount)
+		case 1:
+			c.GreaterThanOrEqual = target.Data.Bytes()
+		}
+		if len(valueChildren.Children) > 3 {
+			c.ContextID = valueChildren.Children[3].Data.Bytes()
+		}
+		return c, nil
+	case ControlTypeVLVResponse:
+		if value == nil {
+			return nil, fmt.Errorf("invalid virtual list view response")
+		}
+		value.Description += " (Virtual List View Response)"
+		c := &ControlVLVResponse{Criticality: Criticality}
+		valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+		}
+		if len(valueChildren.Children) < 3 {
+			return nil, fmt.Errorf("invalid virtual list view response")
+		}
+		targetPosition, ok1 := valueChildren.Children[0].Value.(int64)
+		contentCount, ok2 := valueChildren.Children[1].Value.(int64)
+		resultCode, ok3 := valueChildren.Children[2].Value.(int64)
+		if !ok1 || !ok2 || !ok3 {
+			return nil, fmt.Errorf("invalid virtual list view response")
+		}
+		c.TargetPosition, c.ContentCount, c.ResultCode = int(targetPosition), int(contentCount), uint16(resultCode)
+		if len(valueChildren.Children) > 3 {
+			c.ContextID = valueChildren.Children[3].Data.Bytes()
+		}
+		return c, nil
+	case ControlTypeSyncState:
+		if value == nil {
+			return nil, fmt.Errorf("invalid sync state control")
+		}
+		value.Description += " (Sync State)"
+		c := &ControlSyncState{Criticality: Criticality}
+		valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+		}
+		if len(valueChildren.Children) < 2 {
+			return nil, fmt.Errorf("invalid sync state control")
+		}
+		state, ok := valueChildren.Children[0].Value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid sync state control")
+		}
+		c.State = SyncReplEventType(state)
+		c.EntryUUID = valueChildren.Children[1].Data.Bytes()
+		if len(valueChildren.Children) > 2 {
+			c.Cookie = valueChildren.Children[2].Data.Bytes()
+		}
+		return c, nil
+	case ControlTypeSyncDone:
+		c := &ControlSyncDone{Criticality: Criticality}
+		if value == nil {
+			return c, nil
+		}
+		value.Description += " (Sync Done)"
+		valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+		}
+		for _, child := range valueChildren.Children {
+			switch child.Tag {
+			case ber.TagOctetString:
+				c.Cookie = child.Data.Bytes()
+			case ber.TagBoolean:
+				c.RefreshDeletes, _ = child.Value.(bool)
 			}
 		}
 		return c, nil
 	case ControlTypeVChuPasswordMustChange:
-		c := &ControlVChuPasswordMustChange{MustChange: true}
+		c := &ControlVChuPasswordMustChange{Criticality: Criticality, MustChange: true}
 		return c, nil
 	case ControlTypeVChuPasswordWarning:
-		c := &ControlVChuPasswordWarning{Expire: -1}
+		if value == nil {
+			return nil, fmt.Errorf("invalid password warning control")
+		}
+		c := &ControlVChuPasswordWarning{Criticality: Criticality, Expire: -1}
 		expireStr := ber.DecodeString(value.Data.Bytes())
 
 		expire, err := strconv.ParseInt(expireStr, 10, 64)
@@ -570,12 +1626,23 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 
 		return c, nil
 	case ControlTypeMicrosoftNotification:
-		return NewControlMicrosoftNotification(), nil
+		return NewControlMicrosoftNotification().WithCriticality(Criticality), nil
 	case ControlTypeMicrosoftShowDeleted:
-		return NewControlMicrosoftShowDeleted(), nil
+		return NewControlMicrosoftShowDeleted().WithCriticality(Criticality), nil
+	case ControlTypeMicrosoftShowRecycled:
+		return NewControlMicrosoftShowRecycled().WithCriticality(Criticality), nil
+	case ControlTypeSubtreeDelete:
+		return NewControlSubtreeDelete().WithCriticality(Criticality), nil
+	case ControlTypeNoOp:
+		return NewControlNoOp().WithCriticality(Criticality), nil
+	case ControlTypePermissiveModify:
+		return NewControlPermissiveModify().WithCriticality(Criticality), nil
 	case ControlTypeMicrosoftDirSync:
+		if value == nil {
+			return nil, fmt.Errorf("invalid DirSync control")
+		}
 		value.Description += " (DirSync response)"
-		c := new(ControlMicrosoftDirSyncResponse)
+		c := &ControlMicrosoftDirSyncResponse{Criticality: Criticality}
 		if value.Value != nil {
 			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
 			if err != nil {
@@ -585,23 +1652,31 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 			value.Value = nil
 			value.AppendChild(valueChildren)
 		}
+		if len(value.Children) == 0 || len(value.Children[0].Children) < 3 {
+			return nil, fmt.Errorf("invalid DirSync control")
+		}
 		value = value.Children[0]
 		value.Description = "DirSync Control Value"
 		value.Children[0].Description = "MoreResults"
 		value.Children[1].Description = "Unused"
 		value.Children[2].Description = "Cookie"
-		c.MoreResults = uint32(value.Children[0].Value.(int64))
-		c.Unused = uint32(value.Children[1].Value.(int64))
+		moreResults, ok1 := value.Children[0].Value.(int64)
+		unused, ok2 := value.Children[1].Value.(int64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid DirSync control")
+		}
+		c.MoreResults = uint32(moreResults)
+		c.Unused = uint32(unused)
 		c.Cookie = value.Children[2].Data.Bytes()
-		value.Children[1].Value = c.Cookie
+		value.Children[2].Value = c.Cookie
 
 		return c, nil
 	default:
-		c := new(ControlString)
+		c := &ControlString{Criticality: Criticality, Packet: packet}
 		c.ControlType = ControlType
 		c.Criticality = Criticality
 		if value != nil {
-			c.ControlValue = value.Value.(string)
+			c.ControlValue = string(value.Data.Bytes())
 		}
 		return c, nil
 	}
@@ -630,10 +1705,39 @@ func NewControlBeheraPasswordPolicy() *ControlBeheraPasswordPolicy {
 	}
 }
 
-func encodeControls(controls []Control) *ber.Packet {
+// newControlPacket returns the Control sequence holding the control type, and the
+// criticality only if true since it defaults to false
+func newControlPacket(controlType string, criticality bool) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, controlType, "Control Type ("+ControlTypeMap[controlType]+")"))
+	if criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, criticality, "Criticality"))
+	}
+	return packet
+}
+
+// EncodeControls returns the Controls packet of a request or response holding the given
+// controls, the reverse of DecodeControls
+func EncodeControls(controls []Control) *ber.Packet {
 	packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
 	for _, control := range controls {
 		packet.AppendChild(control.Encode())
 	}
 	return packet
 }
+
+// DecodeControls returns the controls of the Controls packet of a request or response, the
+// reverse of EncodeControls. Each control is decoded with DecodeControl, so that the controls
+// of unknown types are returned as a *ControlString, which is encoded with the same type,
+// criticality and value.
+func DecodeControls(packet *ber.Packet) ([]Control, error) {
+	controls := make([]Control, 0, len(packet.Children))
+	for i, child := range packet.Children {
+		control, err := DecodeControl(child)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: failed to decode control %d: %s", i, err)
+		}
+		controls = append(controls, control)
+	}
+	return controls, nil
+}
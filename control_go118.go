@@ -0,0 +1,21 @@
+//go:build go1.18
+// +build go1.18
+
+package ldap
+
+// GetControl returns the first control of type T in the list, and whether one was found.
+// Unlike a type assertion on the result of FindControl, it does not panic if the server
+// returned a control of an unexpected type:
+//
+//	if paging, ok := GetControl[*ControlPaging](result.Controls); ok {
+//		cookie = paging.Cookie
+//	}
+func GetControl[T Control](controls []Control) (T, bool) {
+	for _, c := range controls {
+		if control, ok := c.(T); ok {
+			return control, true
+		}
+	}
+	var zero T
+	return zero, false
+}
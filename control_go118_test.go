@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package ldap
+
+import "testing"
+
+func TestGetControl(t *testing.T) {
+	paging := NewControlPaging(100)
+	dirSync := &ControlMicrosoftDirSyncResponse{Cookie: []byte("cookie")}
+	controls := []Control{NewControlManageDsaIT(true), paging, dirSync}
+
+	if c, ok := GetControl[*ControlPaging](controls); !ok || c != paging {
+		t.Errorf("expected the paging control, got %v", c)
+	}
+	if c, ok := GetControl[*ControlMicrosoftDirSyncResponse](controls); !ok || c != dirSync {
+		t.Errorf("expected the DirSync response control, got %v", c)
+	}
+	if c, ok := GetControl[*ControlMicrosoftDirSync](controls); ok || c != nil {
+		t.Errorf("expected no DirSync request control, got %v", c)
+	}
+	if _, ok := GetControl[*ControlPaging](nil); ok {
+		t.Errorf("expected no control in an empty list")
+	}
+}
@@ -20,6 +20,196 @@ func TestControlManageDsaIT(t *testing.T) {
 	runControlTest(t, NewControlManageDsaIT(false))
 }
 
+func TestControlAssertion(t *testing.T) {
+	control, err := NewControlAssertion("(&(objectClass=person)(uid=joe))")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	runControlTest(t, control)
+
+	// the filter set after the control was built is sent
+	control.Filter = "(uid=jane)"
+	decoded, err := DecodeControl(ber.DecodePacket(control.Encode().Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if filter := decoded.(*ControlAssertion).Filter; filter != "(uid=jane)" {
+		t.Errorf("expected the filter (uid=jane), got %s", filter)
+	}
+
+	if _, err := NewControlAssertion("(uid=joe"); !IsErrorWithCode(err, ErrorFilterCompile) {
+		t.Errorf("expected ErrorFilterCompile, got %v", err)
+	}
+}
+
+func TestControlProxiedAuthorization(t *testing.T) {
+	runControlTest(t, NewControlProxiedAuthorization("dn:uid=joe,dc=example,dc=com"))
+	runControlTest(t, NewControlProxiedAuthorization("u:joe"))
+	runControlTest(t, NewControlProxiedAuthorization(""))
+}
+
+func TestControlTransactionSpecification(t *testing.T) {
+	runControlTest(t, NewControlTransactionSpecification([]byte("txn\x00\x01")))
+}
+
+func TestControlServerSideSort(t *testing.T) {
+	runControlTest(t, NewControlServerSideSort([]SortKey{{AttributeType: "cn"}}))
+	runControlTest(t, NewControlServerSideSort([]SortKey{
+		{AttributeType: "sn", MatchingRule: "2.5.13.3", ReverseOrder: true},
+		{AttributeType: "givenName", ReverseOrder: true},
+	}))
+	runControlTest(t, &ControlServerSideSortResponse{ResultCode: LDAPResultSuccess})
+	runControlTest(t, &ControlServerSideSortResponse{ResultCode: LDAPResultNoSuchAttribute, AttributeType: "sn"})
+}
+
+func TestControlVLV(t *testing.T) {
+	runControlTest(t, NewControlVLVRequest(0, 19, 1, 0))
+	runControlTest(t, &ControlVLVRequest{BeforeCount: 5, AfterCount: 5, Offset: 50, ContentCount: 1000, ContextID: []byte("ctx")})
+	runControlTest(t, &ControlVLVRequest{AfterCount: 10, GreaterThanOrEqual: []byte("smith")})
+	runControlTest(t, &ControlVLVResponse{TargetPosition: 50, ContentCount: 1000, ResultCode: LDAPResultSuccess, ContextID: []byte("ctx")})
+	runControlTest(t, &ControlVLVResponse{ResultCode: LDAPResultOffsetRangeError})
+}
+
+// TestDecodeControlWithoutValue tests that the controls which require a value are rejected
+// without one instead of making the decoding panic
+func TestDecodeControlWithoutValue(t *testing.T) {
+	for _, controlType := range []string{
+		ControlTypeServerSideSort,
+		ControlTypeServerSideSortResponse,
+		ControlTypeAssertion,
+		ControlTypeVLVRequest,
+		ControlTypeVLVResponse,
+	} {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, controlType, "Control Type"))
+		if _, err := DecodeControl(ber.DecodePacket(packet.Bytes())); err == nil {
+			t.Errorf("%s: expected an error for a control without value", controlType)
+		}
+	}
+}
+
+func TestDecodeControlsMalformed(t *testing.T) {
+	newControl := func(children ...*ber.Packet) *ber.Packet {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+		for _, child := range children {
+			packet.AppendChild(child)
+		}
+		return packet
+	}
+	newString := func(value string) *ber.Packet {
+		return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "")
+	}
+	newInteger := func(value int64) *ber.Packet {
+		return ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, value, "")
+	}
+	pagingValue := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	pagingValue.AppendChild(newString("size"))
+	pagingValue.AppendChild(newString("cookie"))
+
+	tests := []struct {
+		description string
+		control     *ber.Packet
+	}{
+		{"paging without value", newControl(newString(ControlTypePaging))},
+		{"paging with a value which is not a sequence", newControl(newString(ControlTypePaging), newString(string(newInteger(1).Bytes())))},
+		{"paging with an invalid size", newControl(newString(ControlTypePaging), newString(string(pagingValue.Bytes())))},
+		{"password warning without value", newControl(newString(ControlTypeVChuPasswordWarning))},
+		{"control type which is not a string", newControl(newInteger(1))},
+		{"control type which is not a string with a value", newControl(newInteger(1), newString("value"))},
+		{"criticality which is not a boolean", newControl(newString(ControlTypeManageDsaIT), newString("true"), newString("value"))},
+	}
+	for _, test := range tests {
+		controls := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		controls.AppendChild(test.control)
+		if _, err := DecodeControls(ber.DecodePacket(controls.Bytes())); err == nil {
+			t.Errorf("%s: expected an error", test.description)
+		}
+	}
+}
+
+func TestDecodeControlVLVWithoutChildren(t *testing.T) {
+	for _, controlType := range []string{ControlTypeVLVRequest, ControlTypeVLVResponse} {
+		value := ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 1, "")
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, controlType, "Control Type"))
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(value.Bytes()), "Control Value"))
+		if _, err := DecodeControl(ber.DecodePacket(packet.Bytes())); err == nil {
+			t.Errorf("%s: expected an error for a value without children", controlType)
+		}
+	}
+}
+
+func TestControlBeheraPasswordPolicyDecode(t *testing.T) {
+	newResponse := func(children ...*ber.Packet) *ber.Packet {
+		sequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PasswordPolicyResponseValue")
+		for _, child := range children {
+			sequence.AppendChild(child)
+		}
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeBeheraPasswordPolicy, "Control Type"))
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(sequence.Bytes()), "Control Value"))
+		return ber.DecodePacket(packet.Bytes())
+	}
+	newWarning := func(tag ber.Tag, value int64) *ber.Packet {
+		warning := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Warning")
+		warning.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, tag, value, ""))
+		return warning
+	}
+
+	tests := []struct {
+		name     string
+		packet   *ber.Packet
+		expire   int64
+		grace    int64
+		errCode  int8
+		errorStr string
+	}{
+		{"empty", newResponse(), -1, -1, -1, ""},
+		{"expire", newResponse(newWarning(0, 300)), 300, -1, -1, ""},
+		{"grace", newResponse(newWarning(1, 2)), -1, 2, -1, ""},
+		{"error", newResponse(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 1, BeheraAccountLocked, "")), -1, -1, BeheraAccountLocked, "Account locked"},
+		{"grace and error", newResponse(newWarning(1, 0), ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 1, BeheraChangeAfterReset, "")), -1, 0, BeheraChangeAfterReset, "Password must be changed"},
+	}
+	for _, tc := range tests {
+		control, err := DecodeControl(tc.packet)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err)
+			continue
+		}
+		c, ok := control.(*ControlBeheraPasswordPolicy)
+		if !ok {
+			t.Errorf("%s: expected *ControlBeheraPasswordPolicy, got %T", tc.name, control)
+			continue
+		}
+		if c.Expire != tc.expire || c.Grace != tc.grace || c.Error != tc.errCode {
+			t.Errorf("%s: got expire=%d grace=%d error=%d, expected expire=%d grace=%d error=%d",
+				tc.name, c.Expire, c.Grace, c.Error, tc.expire, tc.grace, tc.errCode)
+		}
+		if c.ErrorString != tc.errorStr || BeheraPasswordPolicyError(c.Error).String() != tc.errorStr {
+			t.Errorf("%s: got error string %q, expected %q", tc.name, c.ErrorString, tc.errorStr)
+		}
+		if c.Error >= 0 && BeheraPasswordPolicyErrorMap[c.Error] != tc.errorStr {
+			t.Errorf("%s: got error map description %q, expected %q", tc.name, BeheraPasswordPolicyErrorMap[c.Error], tc.errorStr)
+		}
+	}
+
+	// the request control has no value
+	request := ber.DecodePacket(NewControlBeheraPasswordPolicy().Encode().Bytes())
+	if control, err := DecodeControl(request); err != nil {
+		t.Errorf("request: unexpected error: %s", err)
+	} else if _, ok := control.(*ControlBeheraPasswordPolicy); !ok {
+		t.Errorf("request: expected *ControlBeheraPasswordPolicy, got %T", control)
+	}
+	emptyWarning := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Warning")
+	if _, err := DecodeControl(newResponse(emptyWarning)); err == nil {
+		t.Errorf("expected an error for a warning without value")
+	}
+
+	if s := BeheraPasswordPolicyError(42).String(); s != "Unknown password policy error 42" {
+		t.Errorf("unexpected description for unknown error code: %q", s)
+	}
+}
+
 func TestControlMicrosoftNotification(t *testing.T) {
 	runControlTest(t, NewControlMicrosoftNotification())
 }
@@ -28,6 +218,28 @@ func TestControlMicrosoftShowDeleted(t *testing.T) {
 	runControlTest(t, NewControlMicrosoftShowDeleted())
 }
 
+func TestControlMicrosoftShowRecycled(t *testing.T) {
+	runControlTest(t, NewControlMicrosoftShowRecycled())
+}
+
+func TestDeletedObjectsDN(t *testing.T) {
+	if dn := DeletedObjectsDN("dc=example,dc=com"); dn != "<WKGUID=18E2EA80684F11D2B9AA00C04F79F805,dc=example,dc=com>" {
+		t.Errorf("unexpected DN %q", dn)
+	}
+}
+
+func TestControlNoOp(t *testing.T) {
+	runControlTest(t, NewControlNoOp())
+}
+
+func TestControlSubtreeDelete(t *testing.T) {
+	runControlTest(t, NewControlSubtreeDelete())
+}
+
+func TestControlPermissiveModify(t *testing.T) {
+	runControlTest(t, NewControlPermissiveModify())
+}
+
 func TestControlString(t *testing.T) {
 	runControlTest(t, NewControlString("x", true, "y"))
 	runControlTest(t, NewControlString("x", true, ""))
@@ -35,6 +247,113 @@ func TestControlString(t *testing.T) {
 	runControlTest(t, NewControlString("x", false, ""))
 }
 
+func TestEncodeDecodeControls(t *testing.T) {
+	controls := []Control{
+		NewControlPaging(100),
+		NewControlManageDsaIT(true),
+		NewControlString("1.2.3.4", true, "value"),
+		NewControlString("1.2.3.5", false, ""),
+	}
+	encoded := EncodeControls(controls).Bytes()
+
+	decoded, err := DecodeControls(ber.DecodePacket(encoded))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(decoded) != len(controls) {
+		t.Fatalf("expected %d controls, got %v", len(controls), decoded)
+	}
+	if c, ok := decoded[2].(*ControlString); !ok || c.ControlType != "1.2.3.4" || !c.Criticality || c.ControlValue != "value" {
+		t.Errorf("unexpected unknown control %v", decoded[2])
+	}
+	if reencoded := EncodeControls(decoded).Bytes(); !bytes.Equal(reencoded, encoded) {
+		t.Errorf("expected the controls to survive the round trip, got %x instead of %x", reencoded, encoded)
+	}
+
+	invalid := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+	invalid.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control"))
+	if _, err := DecodeControls(invalid); err == nil {
+		t.Errorf("expected an error decoding a control without type")
+	}
+}
+
+func TestDecodeControlUnknownPacket(t *testing.T) {
+	// an explicit FALSE criticality is not emitted by Encode
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "1.2.3.4", "Control Type"))
+	packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, false, "Criticality"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "value", "Control Value"))
+	original := packet.Bytes()
+
+	control, err := DecodeControl(ber.DecodePacket(original))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	c, ok := control.(*ControlString)
+	if !ok || c.ControlType != "1.2.3.4" || c.ControlValue != "value" {
+		t.Fatalf("unexpected control %v", control)
+	}
+	if c.Packet == nil || !bytes.Equal(c.Packet.Bytes(), original) {
+		t.Errorf("expected the original packet %x, got %v", original, c.Packet)
+	}
+	if bytes.Equal(c.Encode().Bytes(), original) {
+		t.Errorf("expected Encode to drop the FALSE criticality")
+	}
+}
+
+func TestControlCriticality(t *testing.T) {
+	assertion, err := NewControlAssertion("(uid=joe)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, criticality := range []bool{false, true} {
+		for _, control := range []Control{
+			NewControlPaging(100).WithCriticality(criticality),
+			NewControlManageDsaIT(false).WithCriticality(criticality),
+			assertion.WithCriticality(criticality),
+			NewControlProxiedAuthorization("u:joe").WithCriticality(criticality),
+			NewControlTransactionSpecification([]byte("txn")).WithCriticality(criticality),
+			NewControlMicrosoftNotification().WithCriticality(criticality),
+			NewControlMicrosoftShowDeleted().WithCriticality(criticality),
+			NewControlMicrosoftShowRecycled().WithCriticality(criticality),
+			NewControlSubtreeDelete().WithCriticality(criticality),
+			NewControlNoOp().WithCriticality(criticality),
+			NewControlPermissiveModify().WithCriticality(criticality),
+			NewControlServerSideSort([]SortKey{{AttributeType: "cn"}}).WithCriticality(criticality),
+			NewControlVLVRequest(0, 19, 1, 0).WithCriticality(criticality),
+			NewControlString("x", false, "y").WithCriticality(criticality),
+		} {
+			runControlTest(t, control)
+
+			packet := control.Encode()
+			hasCriticality := len(packet.Children) > 1 && packet.Children[1].Tag == ber.TagBoolean
+			if hasCriticality != criticality {
+				t.Errorf("%T: expected the criticality to be encoded only when true, got %v", control, packet.Children)
+			}
+			decoded, err := DecodeControl(packet)
+			if err != nil {
+				t.Fatalf("%T: unexpected error: %s", control, err)
+			}
+			if got := reflect.ValueOf(decoded).Elem().FieldByName("Criticality").Bool(); got != criticality {
+				t.Errorf("%T: expected the decoded criticality to be %t", control, criticality)
+			}
+		}
+	}
+
+	for _, control := range []Control{
+		NewControlProxiedAuthorization("u:joe"),
+		NewControlTransactionSpecification([]byte("txn")),
+		NewControlMicrosoftDirSync(),
+		NewControlSubtreeDelete(),
+		NewControlNoOp(),
+		NewControlSyncRequest(SyncReplRefreshOnly, nil),
+	} {
+		if packet := control.Encode(); len(packet.Children) < 2 || packet.Children[1].Tag != ber.TagBoolean {
+			t.Errorf("%T: expected the control to be critical by default", control)
+		}
+	}
+}
+
 func runControlTest(t *testing.T, originalControl Control) {
 	header := ""
 	if callerpc, _, line, ok := runtime.Caller(1); ok {
@@ -80,6 +399,10 @@ func TestDescribeControlManageDsaIT(t *testing.T) {
 	runAddControlDescriptions(t, NewControlManageDsaIT(true), "Control Type (Manage DSA IT)", "Criticality")
 }
 
+func TestDescribeControlProxiedAuthorization(t *testing.T) {
+	runAddControlDescriptions(t, NewControlProxiedAuthorization("u:joe"), "Control Type (Proxied Authorization)", "Criticality", "Control Value")
+}
+
 func TestDescribeControlPaging(t *testing.T) {
 	runAddControlDescriptions(t, NewControlPaging(100), "Control Type (Paging)", "Control Value (Paging)")
 	runAddControlDescriptions(t, NewControlPaging(0), "Control Type (Paging)", "Control Value (Paging)")
@@ -93,6 +416,22 @@ func TestDescribeControlMicrosoftShowDeleted(t *testing.T) {
 	runAddControlDescriptions(t, NewControlMicrosoftShowDeleted(), "Control Type (Show Deleted Objects - Microsoft)")
 }
 
+func TestDescribeControlMicrosoftShowRecycled(t *testing.T) {
+	runAddControlDescriptions(t, NewControlMicrosoftShowRecycled(), "Control Type (Show Recycled Objects - Microsoft)")
+}
+
+func TestDescribeControlNoOp(t *testing.T) {
+	runAddControlDescriptions(t, NewControlNoOp(), "Control Type (No-Op)", "Criticality")
+}
+
+func TestDescribeControlSubtreeDelete(t *testing.T) {
+	runAddControlDescriptions(t, NewControlSubtreeDelete(), "Control Type (Subtree Delete)", "Criticality")
+}
+
+func TestDescribeControlPermissiveModify(t *testing.T) {
+	runAddControlDescriptions(t, NewControlPermissiveModify(), "Control Type (Permissive Modify)")
+}
+
 func TestDescribeControlString(t *testing.T) {
 	runAddControlDescriptions(t, NewControlString("x", true, "y"), "Control Type ()", "Criticality", "Control Value")
 	runAddControlDescriptions(t, NewControlString("x", true, ""), "Control Type ()", "Criticality")
@@ -108,7 +447,7 @@ func runAddControlDescriptions(t *testing.T, originalControl Control, childDescr
 		}
 	}
 
-	encodedControls := encodeControls([]Control{originalControl})
+	encodedControls := EncodeControls([]Control{originalControl})
 	addControlDescriptions(encodedControls)
 	encodedPacket := encodedControls.Children[0]
 	if len(encodedPacket.Children) != len(childDescriptions) {
@@ -1,7 +1,12 @@
 package ldap
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"log"
+	"sync/atomic"
+	"time"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -28,3 +33,126 @@ func (debug debugging) PrintPacket(packet *ber.Packet) {
 		ber.PrintPacket(packet)
 	}
 }
+
+// redactedValue replaces the credentials in the printed packets, unless they are shown
+const redactedValue = "<redacted>"
+
+// debugLogger receives the debug output of a Conn instead of the standard logger, see SetLogger
+type debugLogger interface {
+	printf(format string, args ...interface{})
+	printPacket(packet *ber.Packet)
+	logRequest(messageID int64, op string, resultCode uint16, d time.Duration, err error)
+}
+
+// loggerRef wraps the debugLogger of a Conn, as an atomic.Value cannot store a nil interface
+type loggerRef struct {
+	debugLogger
+}
+
+func (l *Conn) setLogger(logger debugLogger) {
+	l.logger.Store(loggerRef{logger})
+}
+
+func (l *Conn) getLogger() debugLogger {
+	ref, _ := l.logger.Load().(loggerRef)
+	return ref.debugLogger
+}
+
+// SetRedactCredentials sets whether the bind credentials and the passwords of password
+// modify requests are replaced by "<redacted>" in the packets printed when Debug is enabled.
+// They are redacted by default: SetRedactCredentials(false) prints them, which should only
+// be done to debug the credentials themselves.
+func (l *Conn) SetRedactCredentials(redact bool) {
+	var value uint32
+	if !redact {
+		value = 1
+	}
+	atomic.StoreUint32(&l.showCredentials, value)
+}
+
+// debugf writes debug output to the logger of the connection if Debug is enabled
+func (l *Conn) debugf(format string, args ...interface{}) {
+	if !l.Debug {
+		return
+	}
+	if logger := l.getLogger(); logger != nil {
+		logger.printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// debugPacket dumps a packet to the logger of the connection if Debug is enabled
+func (l *Conn) debugPacket(packet *ber.Packet) {
+	if !l.Debug {
+		return
+	}
+	if atomic.LoadUint32(&l.showCredentials) == 0 {
+		packet = redactCredentials(packet)
+	}
+	if logger := l.getLogger(); logger != nil {
+		logger.printPacket(packet)
+		return
+	}
+	ber.PrintPacket(packet)
+}
+
+// redactCredentials returns a copy of packet without its credentials if it is a bind or
+// password modify request, or packet itself otherwise
+func redactCredentials(packet *ber.Packet) *ber.Packet {
+	if len(packet.Children) < 2 || packet.Children[1].ClassType != ber.ClassApplication {
+		return packet
+	}
+	op := packet.Children[1]
+	switch {
+	case op.Tag == ApplicationBindRequest && len(op.Children) >= 3:
+		packet = clonePacket(packet)
+		credentials := packet.Children[1].Children[2]
+		if credentials.TagType == ber.TypeConstructed {
+			// SASL credentials are optional
+			if len(credentials.Children) < 2 {
+				return packet
+			}
+			credentials = credentials.Children[1]
+		}
+		redactPacket(credentials)
+	case op.Tag == ApplicationExtendedRequest && len(op.Children) >= 2 && op.Children[0].Data.String() == passwordModifyOID:
+		packet = clonePacket(packet)
+		redactPacket(packet.Children[1].Children[1])
+	}
+	return packet
+}
+
+func clonePacket(packet *ber.Packet) *ber.Packet {
+	clone := &ber.Packet{
+		Identifier:  packet.Identifier,
+		Value:       packet.Value,
+		ByteValue:   packet.ByteValue,
+		Description: packet.Description,
+		Data:        bytes.NewBuffer(append([]byte{}, packet.Data.Bytes()...)),
+	}
+	for _, child := range packet.Children {
+		clone.Children = append(clone.Children, clonePacket(child))
+	}
+	return clone
+}
+
+func redactPacket(packet *ber.Packet) {
+	packet.Value = redactedValue
+	packet.ByteValue = nil
+	packet.Data = bytes.NewBufferString(redactedValue)
+	packet.Children = nil
+}
+
+// writePacket writes a dump of packet in the format of ber.PrintPacket to out
+func writePacket(out io.Writer, packet *ber.Packet, indent string) {
+	description := ""
+	if packet.Description != "" {
+		description = packet.Description + ": "
+	}
+	fmt.Fprintf(out, "%s%s(%s, %s, %s) Len=%d %q\n", indent, description, ber.ClassMap[packet.ClassType],
+		ber.TypeMap[packet.TagType], fmt.Sprintf("0x%02X", packet.Tag), packet.Data.Len(), fmt.Sprint(packet.Value))
+	for _, child := range packet.Children {
+		writePacket(out, child, indent+" ")
+	}
+}
@@ -6,7 +6,8 @@
 package ldap
 
 import (
-	"log"
+	"context"
+	"fmt"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -25,7 +26,7 @@ func (req *DelRequest) appendTo(envelope *ber.Packet) error {
 
 	envelope.AppendChild(pkt)
 	if len(req.Controls) > 0 {
-		envelope.AppendChild(encodeControls(req.Controls))
+		envelope.AppendChild(EncodeControls(req.Controls))
 	}
 
 	return nil
@@ -41,13 +42,13 @@ func NewDelRequest(DN string, Controls []Control) *DelRequest {
 
 // Del executes the given delete request
 func (l *Conn) Del(delRequest *DelRequest) error {
-	msgCtx, err := l.doRequest(delRequest)
+	msgCtx, err := l.doRequest(context.Background(), delRequest)
 	if err != nil {
 		return err
 	}
 	defer l.finishMessage(msgCtx)
 
-	packet, err := l.readPacket(msgCtx)
+	packet, err := l.readPacket(context.Background(), msgCtx)
 	if err != nil {
 		return err
 	}
@@ -58,7 +59,40 @@ func (l *Conn) Del(delRequest *DelRequest) error {
 			return err
 		}
 	} else {
-		log.Printf("Unexpected Response: %d", packet.Children[1].Tag)
+		return NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
 	}
 	return nil
 }
+
+// DelSubtree deletes the entry dn and all its descendants with a single delete request
+// carrying the subtree delete control. The control is supported by Active Directory and
+// by some other servers, see SupportsControl(ControlTypeSubtreeDelete): the others refuse
+// the critical control with LDAPResultUnavailableCriticalExtension, and DelRecursive can
+// be used instead.
+func (l *Conn) DelSubtree(dn string) error {
+	return l.Del(NewDelRequest(dn, []Control{NewControlSubtreeDelete()}))
+}
+
+// delRecursivePageSize is the page size of the searches listing the children of an entry in
+// DelRecursive, below the default MaxPageSize of 1000 of Active Directory
+const delRecursivePageSize = 500
+
+// DelRecursive deletes the entry dn and all its descendants without requiring the subtree
+// delete control, which is supported by any server but needs a search and a delete request
+// per entry: the children of each entry are deleted before it, bottom-up. The children are
+// listed with a paged search, so that entries with more children than the server returns
+// at once can be deleted. The deletion is not atomic, the entries deleted before an error
+// remain deleted.
+func (l *Conn) DelRecursive(dn string) error {
+	searchRequest := NewSearchRequest(dn, ScopeSingleLevel, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"1.1"}, nil)
+	result, err := l.SearchWithPaging(searchRequest, delRecursivePageSize)
+	if err != nil {
+		return err
+	}
+	for _, child := range result.Entries {
+		if err := l.DelRecursive(child.DN); err != nil {
+			return err
+		}
+	}
+	return l.Del(NewDelRequest(dn, nil))
+}
@@ -0,0 +1,107 @@
+package ldap
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestDelSubtree(t *testing.T) {
+	var controls []Control
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		if len(request.Children) == 3 {
+			for _, child := range request.Children[2].Children {
+				control, err := DecodeControl(child)
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				controls = append(controls, control)
+			}
+		}
+		return []*ber.Packet{newResultPacket(messageID, ApplicationDelResponse, LDAPResultSuccess)}
+	})
+	defer conn.Close()
+
+	if err := conn.DelSubtree("ou=people,dc=example,dc=com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(controls) != 1 || !reflect.DeepEqual(controls[0], NewControlSubtreeDelete()) {
+		t.Errorf("expected a critical subtree delete control, got %v", controls)
+	}
+}
+
+func TestDelRecursive(t *testing.T) {
+	tree := map[string][]string{
+		"ou=people,dc=example,dc=com":                    {"ou=admins,ou=people,dc=example,dc=com", "uid=joe,ou=people,dc=example,dc=com"},
+		"ou=admins,ou=people,dc=example,dc=com":          {"uid=root,ou=admins,ou=people,dc=example,dc=com"},
+		"uid=joe,ou=people,dc=example,dc=com":            nil,
+		"uid=root,ou=admins,ou=people,dc=example,dc=com": nil,
+	}
+	var lock sync.Mutex
+	var deleted []string
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		lock.Lock()
+		defer lock.Unlock()
+		messageID := request.Children[0].Value.(int64)
+		op := request.Children[1]
+		if op.Tag == ApplicationDelRequest {
+			dn := op.Data.String()
+			if len(tree[dn]) > 0 {
+				return []*ber.Packet{newResultPacket(messageID, ApplicationDelResponse, LDAPResultNotAllowedOnNonLeaf)}
+			}
+			deleted = append(deleted, dn)
+			delete(tree, dn)
+			for parent, children := range tree {
+				for i, child := range children {
+					if child == dn {
+						tree[parent] = append(children[:i:i], children[i+1:]...)
+					}
+				}
+			}
+			return []*ber.Packet{newResultPacket(messageID, ApplicationDelResponse, LDAPResultSuccess)}
+		}
+
+		// like Active Directory beyond its MaxPageSize, the server returns at most one
+		// entry per page, and fails searches which are not paged
+		children := tree[op.Children[0].Value.(string)]
+		var paging *ControlPaging
+		if len(request.Children) == 3 {
+			controls, err := DecodeControls(request.Children[2])
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			paging, _ = FindControl(controls, ControlTypePaging).(*ControlPaging)
+		}
+		if paging == nil {
+			if len(children) > 1 {
+				return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultSizeLimitExceeded)}
+			}
+			paging = &ControlPaging{}
+		}
+		offset, _ := strconv.Atoi(string(paging.Cookie))
+		var responses []*ber.Packet
+		if offset < len(children) {
+			responses = append(responses, newSearchResultEntryPacket(messageID, children[offset]))
+		}
+		var cookie []byte
+		if offset+1 < len(children) {
+			cookie = []byte(strconv.Itoa(offset + 1))
+		}
+		return append(responses, newSearchResultDonePacket(messageID, LDAPResultSuccess, &ControlPaging{Cookie: cookie}))
+	})
+	defer conn.Close()
+
+	if err := conn.DelRecursive("ou=people,dc=example,dc=com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(tree) != 0 || len(deleted) != 4 || !strings.HasPrefix(deleted[0], "uid=root,") || deleted[3] != "ou=people,dc=example,dc=com" {
+		t.Errorf("unexpected deletions %v, remaining %v", deleted, tree)
+	}
+}
@@ -0,0 +1,227 @@
+// This file contains the DIGEST-MD5 SASL mechanism as specified in rfc 2831
+//
+// https://tools.ietf.org/html/rfc2831
+//
+
+package ldap
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	enchex "encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SASLMechanismDigestMD5 is the name of the DIGEST-MD5 SASL mechanism
+const SASLMechanismDigestMD5 = "DIGEST-MD5"
+
+type digestMD5Client struct {
+	username  string
+	realm     string
+	password  string
+	digestURI string
+	cnonce    string
+
+	nonce string
+	nc    string
+	qop   string
+}
+
+// response computes the digest-response to the given digest-challenge
+func (c *digestMD5Client) response(challenge []byte) ([]byte, error) {
+	directives, err := parseDigestMD5Directives(challenge)
+	if err != nil {
+		return nil, err
+	}
+	c.nonce = directives["nonce"]
+	if c.nonce == "" {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: DIGEST-MD5 challenge has no nonce"))
+	}
+	if algorithm := directives["algorithm"]; algorithm != "md5-sess" {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: unsupported DIGEST-MD5 algorithm %q", algorithm))
+	}
+	if qop, ok := directives["qop"]; ok && !containsToken(qop, "auth") {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: unsupported DIGEST-MD5 qop %q", qop))
+	}
+	if c.realm == "" {
+		c.realm = directives["realm"]
+	}
+	c.nc = "00000001"
+	c.qop = "auth"
+
+	var response []string
+	response = append(response, "username="+quoteDigestMD5Value(c.username))
+	if c.realm != "" {
+		response = append(response, "realm="+quoteDigestMD5Value(c.realm))
+	}
+	response = append(response,
+		"nonce="+quoteDigestMD5Value(c.nonce),
+		"cnonce="+quoteDigestMD5Value(c.cnonce),
+		"nc="+c.nc,
+		"qop="+c.qop,
+		"digest-uri="+quoteDigestMD5Value(c.digestURI),
+		"response="+c.digest("AUTHENTICATE:"+c.digestURI),
+	)
+	if directives["charset"] == "utf-8" {
+		response = append(response, "charset=utf-8")
+	}
+	return []byte(strings.Join(response, ",")), nil
+}
+
+// verify checks the rspauth sent by the server after a successful authentication
+func (c *digestMD5Client) verify(responseAuth []byte) error {
+	directives, err := parseDigestMD5Directives(responseAuth)
+	if err != nil {
+		return err
+	}
+	rspauth, ok := directives["rspauth"]
+	if !ok {
+		return NewError(ErrorUnexpectedResponse, errors.New("ldap: missing DIGEST-MD5 rspauth"))
+	}
+	if rspauth != c.digest(":"+c.digestURI) {
+		return NewError(ErrorUnexpectedResponse, errors.New("ldap: DIGEST-MD5 rspauth mismatch"))
+	}
+	return nil
+}
+
+// digest computes the response-value for the given A2
+func (c *digestMD5Client) digest(a2 string) string {
+	h := md5.Sum([]byte(c.username + ":" + c.realm + ":" + c.password))
+	a1 := string(h[:]) + ":" + c.nonce + ":" + c.cnonce
+	ha1 := md5.Sum([]byte(a1))
+	ha2 := md5.Sum([]byte(a2))
+	kd := md5.Sum([]byte(enchex.EncodeToString(ha1[:]) + ":" + c.nonce + ":" + c.nc + ":" + c.cnonce + ":" + c.qop + ":" + enchex.EncodeToString(ha2[:])))
+	return enchex.EncodeToString(kd[:])
+}
+
+// parseDigestMD5Directives parses a comma separated list of name=value
+// directives, where value may be a quoted-string. Only the first occurrence
+// of a directive is kept.
+func parseDigestMD5Directives(data []byte) (map[string]string, error) {
+	directives := make(map[string]string)
+	s := string(data)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return directives, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: invalid DIGEST-MD5 directive %q", s))
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var buf []byte
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				buf = append(buf, s[i])
+			}
+			if i == len(s) {
+				return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: unterminated quoted-string in DIGEST-MD5 challenge"))
+			}
+			value = string(buf)
+			s = s[i+1:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		if _, ok := directives[name]; !ok {
+			directives[name] = value
+		}
+	}
+}
+
+func quoteDigestMD5Value(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func containsToken(list, token string) bool {
+	for _, t := range strings.Split(list, ",") {
+		if strings.TrimSpace(t) == token {
+			return true
+		}
+	}
+	return false
+}
+
+// DigestMD5Bind performs a SASL bind using the DIGEST-MD5 mechanism.
+//
+// When realm is empty, the realm offered in the server challenge is used.
+// The digest-uri is built from the host name of the server given to Dial,
+// DialTLS or DialURL ("ldap/<host>"), which servers compare to their fully
+// qualified domain name, so the server must be dialed by this name rather than
+// by address. The host of the remote address is used for the connections
+// created with NewConn, and "localhost" for the Unix socket connections.
+// An error is returned if the server does not prove that it knows the
+// password (missing or wrong rspauth).
+//
+// The exchange is restarted as specified by WithRetry.
+func (l *Conn) DigestMD5Bind(username, realm, password string) (err error) {
+	defer l.recordBind(&err, func(conn *Conn) error {
+		return conn.DigestMD5Bind(username, realm, password)
+	})
+	return l.withRetry(context.Background(), func() error {
+		return l.digestMD5Bind(username, realm, password)
+	})
+}
+
+func (l *Conn) digestMD5Bind(username, realm, password string) error {
+	cnonce := make([]byte, 16)
+	if _, err := rand.Read(cnonce); err != nil {
+		return fmt.Errorf("ldap: failed to generate DIGEST-MD5 cnonce: %s", err)
+	}
+	host := l.serverHost
+	if host == "" {
+		host = "localhost"
+		if addr := l.conn.RemoteAddr(); addr != nil && addr.Network() != "unix" {
+			host = addrHost(addr.String())
+		}
+	}
+	client := &digestMD5Client{
+		username:  username,
+		realm:     realm,
+		password:  password,
+		digestURI: "ldap/" + host,
+		cnonce:    enchex.EncodeToString(cnonce),
+	}
+
+	challenge, err := l.SASLBind(SASLMechanismDigestMD5, nil)
+	if !IsErrorWithCode(err, LDAPResultSaslBindInProgress) {
+		if err == nil {
+			err = NewError(ErrorUnexpectedResponse, errors.New("ldap: DIGEST-MD5 exchange ended early"))
+		}
+		return err
+	}
+
+	response, err := client.response(challenge)
+	if err != nil {
+		return err
+	}
+
+	responseAuth, err := l.SASLBind(SASLMechanismDigestMD5, response)
+	inProgress := IsErrorWithCode(err, LDAPResultSaslBindInProgress)
+	if err != nil && !inProgress {
+		return err
+	}
+	if err := client.verify(responseAuth); err != nil {
+		return err
+	}
+	if inProgress {
+		// the server sent rspauth as a challenge: complete with an empty response
+		_, err = l.SASLBind(SASLMechanismDigestMD5, nil)
+	}
+	return err
+}
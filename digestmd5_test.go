@@ -0,0 +1,130 @@
+package ldap
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestDigestMD5Client(t *testing.T) {
+	// Example from rfc 2831 section 4
+	client := &digestMD5Client{
+		username:  "chris",
+		password:  "secret",
+		digestURI: "imap/elwood.innosoft.com",
+		cnonce:    "OA6MHXh6VqTrRk",
+	}
+	challenge := `realm="elwood.innosoft.com",nonce="OA6MG9tEQGm2hh",qop="auth",algorithm=md5-sess,charset=utf-8`
+
+	response, err := client.response([]byte(challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	directives, err := parseDigestMD5Directives(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"username":   "chris",
+		"realm":      "elwood.innosoft.com",
+		"nonce":      "OA6MG9tEQGm2hh",
+		"cnonce":     "OA6MHXh6VqTrRk",
+		"nc":         "00000001",
+		"qop":        "auth",
+		"digest-uri": "imap/elwood.innosoft.com",
+		"response":   "d388dad90d4bbd760a152321f2143af7",
+		"charset":    "utf-8",
+	}
+	for name, value := range expected {
+		if directives[name] != value {
+			t.Errorf("unexpected %s: got %q, expected %q", name, directives[name], value)
+		}
+	}
+
+	if err := client.verify([]byte("rspauth=ea40f60335c427b5527b84dbabcdfffd")); err != nil {
+		t.Errorf("valid rspauth rejected: %s", err)
+	}
+	if err := client.verify([]byte("rspauth=00000000000000000000000000000000")); err == nil {
+		t.Error("expected wrong rspauth to be rejected")
+	}
+	if err := client.verify([]byte("")); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected missing rspauth to be rejected, got %v", err)
+	}
+}
+
+func TestParseDigestMD5Directives(t *testing.T) {
+	directives, err := parseDigestMD5Directives([]byte(`realm="a\"b,c" , nonce=xyz,qop="auth,auth-int",realm="other"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if directives["realm"] != `a"b,c` {
+		t.Errorf("unexpected realm %q", directives["realm"])
+	}
+	if directives["nonce"] != "xyz" {
+		t.Errorf("unexpected nonce %q", directives["nonce"])
+	}
+	if !containsToken(directives["qop"], "auth") {
+		t.Errorf("unexpected qop %q", directives["qop"])
+	}
+
+	if _, err := parseDigestMD5Directives([]byte(`realm="unterminated`)); err == nil {
+		t.Error("expected unterminated quoted-string to be rejected")
+	}
+}
+
+// TestDigestMD5BindDigestURI tests that the digest-uri holds the host name the server was
+// dialed with rather than its address
+func TestDigestMD5BindDigestURI(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	digestURI := make(chan string, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			request, err := ber.ReadPacket(c)
+			if err != nil {
+				return
+			}
+			messageID := request.Children[0].Value.(int64)
+			saslCreds := request.Children[1].Children[2]
+			response := newSASLBindResponsePacket(messageID, LDAPResultSaslBindInProgress, []byte(`realm="example.com",nonce="OA6MG9tEQGm2hh",qop="auth",algorithm=md5-sess,charset=utf-8`))
+			if len(saslCreds.Children) > 1 && saslCreds.Children[1].Data.Len() > 0 {
+				directives, err := parseDigestMD5Directives(saslCreds.Children[1].Data.Bytes())
+				if err != nil {
+					t.Errorf("invalid response: %s", err)
+				}
+				digestURI <- directives["digest-uri"]
+				response = newSASLBindResponsePacket(messageID, LDAPResultInvalidCredentials, nil)
+			}
+			if _, err := c.Write(response.Bytes()); err != nil {
+				return
+			}
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	conn, err := Dial("tcp", net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Skipf("unable to dial localhost: %s", err)
+	}
+	defer conn.Close()
+
+	runWithTimeout(t, 2*time.Second, func() {
+		if err := conn.DigestMD5Bind("chris", "", "secret"); !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+			t.Errorf("expected LDAPResultInvalidCredentials, got %v", err)
+		}
+		if uri := <-digestURI; uri != "ldap/localhost" {
+			t.Errorf("expected the digest-uri ldap/localhost, got %q", uri)
+		}
+	})
+}
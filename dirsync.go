@@ -3,10 +3,46 @@ package ldap
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
-// SearchWithDirSync accepts a search request and a sync cookie
+// SearchWithDirSync accepts a search request and a sync cookie. flags is a combination of
+// DirSyncObjectSecurity, DirSyncAncestorsFirstOrder, DirSyncPublicDataOnly and DirSyncIncrementalValues.
+//
+// All the changes are accumulated in memory: use SearchWithDirSyncAsync to process them
+// page by page. If an error occurs, the last cookie successfully received is returned
+// along with the changes received up to that cookie, so that the synchronization can be
+// resumed from there.
 func (l *Conn) SearchWithDirSync(searchRequest *SearchRequest, cookie []byte, flags uint32) (*SearchResult, []byte, error) {
+	searchResult := new(SearchResult)
+	newCookie, err := l.SearchWithDirSyncAsync(searchRequest, cookie, flags, func(result *SearchResult) error {
+		searchResult.Entries = append(searchResult.Entries, result.Entries...)
+		searchResult.Referrals = append(searchResult.Referrals, result.Referrals...)
+		searchResult.Controls = append(searchResult.Controls, result.Controls...)
+		return nil
+	})
+	return searchResult, newCookie, err
+}
+
+// SearchWithDirSyncAsync performs a DirSync search starting from the given sync cookie,
+// calling fn with each page of changes returned by the server, and returns the cookie to
+// use for the next synchronization.
+//
+// If an error occurs, including an error returned by fn, the cookie of the last page
+// successfully processed by fn is returned along with the error, or the given cookie if
+// there is none, so that the synchronization can be resumed from there.
+func (l *Conn) SearchWithDirSyncAsync(searchRequest *SearchRequest, cookie []byte, flags uint32, fn func(*SearchResult) error) ([]byte, error) {
+	return l.SearchWithDirSyncPaging(searchRequest, cookie, flags, 0, fn)
+}
+
+// SearchWithDirSyncPaging is like SearchWithDirSyncAsync, but if pagingSize is not 0, each
+// set of changes returned for a DirSync cookie is itself retrieved with a paged search of
+// pagingSize entries per page, fn being called with each page.
+//
+// The DirSync cookie is only returned once all the pages of its changes are processed: if
+// an error occurs in the middle of them, the synchronization resumes from the previous
+// DirSync cookie, and the pages already processed are returned again.
+func (l *Conn) SearchWithDirSyncPaging(searchRequest *SearchRequest, cookie []byte, flags uint32, pagingSize uint32, fn func(*SearchResult) error) ([]byte, error) {
 	var dirSyncControl *ControlMicrosoftDirSync
 
 	control := FindControl(searchRequest.Controls, ControlTypeMicrosoftDirSync)
@@ -16,51 +52,99 @@ func (l *Conn) SearchWithDirSync(searchRequest *SearchRequest, cookie []byte, fl
 	} else {
 		castControl, ok := control.(*ControlMicrosoftDirSync)
 		if !ok {
-			return nil, nil, fmt.Errorf("expected dirSync control to be of type *ControlMicrosoftDirSync, got %v", control)
+			return cookie, fmt.Errorf("expected dirSync control to be of type *ControlMicrosoftDirSync, got %v", control)
 		}
 		dirSyncControl = castControl
 	}
 
+	var pagingControl *ControlPaging
+	if pagingSize > 0 {
+		control := FindControl(searchRequest.Controls, ControlTypePaging)
+		if control == nil {
+			pagingControl = NewControlPaging(pagingSize)
+			searchRequest.Controls = append(searchRequest.Controls, pagingControl)
+		} else {
+			castControl, ok := control.(*ControlPaging)
+			if !ok {
+				return cookie, fmt.Errorf("expected paging control to be of type *ControlPaging, got %v", control)
+			}
+			pagingControl = castControl
+			pagingControl.PagingSize = pagingSize
+		}
+	}
+
 	dirSyncControl.SetCookie(cookie)
 	dirSyncControl.Flags = flags
+	if pagingControl != nil {
+		defer pagingControl.SetCookie(nil)
+	}
 
-	var newCookie []byte
-	searchResult := new(SearchResult)
 	for {
 		result, err := l.Search(searchRequest)
 		if err != nil {
-			return searchResult, nil, err
+			return cookie, err
 		}
 		if result == nil {
-			return searchResult, nil, NewError(ErrorNetwork, errors.New("ldap: packet not received"))
+			return cookie, NewError(ErrorNetwork, errors.New("ldap: packet not received"))
 		}
 
-		for _, entry := range result.Entries {
-			searchResult.Entries = append(searchResult.Entries, entry)
-		}
-		for _, referral := range result.Referrals {
-			searchResult.Referrals = append(searchResult.Referrals, referral)
-		}
-		for _, control := range result.Controls {
-			searchResult.Controls = append(searchResult.Controls, control)
+		if pagingControl != nil {
+			// the paging cookie is tracked apart from the DirSync one, which is only
+			// returned with the last page
+			pagingResult, ok := FindControl(result.Controls, ControlTypePaging).(*ControlPaging)
+			if !ok {
+				return cookie, NewError(ErrorUnexpectedResponse, errors.New("ldap: paging control missing from search response"))
+			}
+			if len(pagingResult.Cookie) > 0 {
+				pagingControl.SetCookie(pagingResult.Cookie)
+				if err := fn(result); err != nil {
+					l.abandonPaging(searchRequest, pagingControl)
+					return cookie, err
+				}
+				continue
+			}
+			pagingControl.SetCookie(nil)
 		}
 
-		l.Debug.Printf("Looking for DirSync Control...")
-		dirSyncResult := FindControl(result.Controls, ControlTypeMicrosoftDirSync)
-		if dirSyncResult == nil {
-			return searchResult, nil, NewError(ErrorNetwork, errors.New("ldap: response is missing DirSync control"))
+		l.debugf("Looking for DirSync Control...")
+		dirSyncResponse, ok := FindControl(result.Controls, ControlTypeMicrosoftDirSync).(*ControlMicrosoftDirSyncResponse)
+		if !ok {
+			return cookie, NewError(ErrorNetwork, errors.New("ldap: response is missing DirSync control"))
+		}
+		if len(dirSyncResponse.Cookie) == 0 {
+			return cookie, NewError(ErrorNetwork, errors.New("ldap: empty cookie in DirSync control response"))
 		}
 
-		dirSyncResponse := dirSyncResult.(*ControlMicrosoftDirSyncResponse)
-		newCookie = dirSyncResponse.Cookie
-		if len(newCookie) == 0 {
-			return searchResult, nil, NewError(ErrorNetwork, errors.New("ldap: empty cookie in DirSync control response"))
+		if err := fn(result); err != nil {
+			return cookie, err
 		}
+		cookie = dirSyncResponse.Cookie
 		if dirSyncResponse.MoreResults == 0 {
-			break
+			return cookie, nil
 		}
-		dirSyncControl.SetCookie(newCookie)
+		dirSyncControl.SetCookie(cookie)
 	}
+}
 
-	return searchResult, newCookie, nil
+// GetDirSyncValueChanges returns the values of the named linked multi-valued attribute,
+// such as member, which were added and removed, as returned by a DirSync search with the
+// DirSyncIncrementalValues flag: Active Directory then returns the added values as the
+// attribute "member;range=1-1" and the removed values as the attribute "member;range=0-0".
+//
+// Attributes returned without a range option, including all attributes when the
+// DirSyncIncrementalValues flag is not set, hold their whole new value.
+func (e *Entry) GetDirSyncValueChanges(attribute string) (added, removed []string) {
+	for _, attr := range e.Attributes {
+		name, low, high, ok := parseRangeOption(attr.Name)
+		if !ok || !strings.EqualFold(name, attribute) {
+			continue
+		}
+		switch {
+		case low == 1 && high == 1:
+			added = append(added, attr.S...)
+		case low == 0 && high == 0:
+			removed = append(removed, attr.S...)
+		}
+	}
+	return added, removed
 }
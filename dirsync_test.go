@@ -0,0 +1,207 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// newDirSyncTestConn returns a connection to a server returning one entry per page, with
+// the cookie "1", "2"... and failing once all the pages have been returned
+func newDirSyncTestConn(t *testing.T, pages int) *Conn {
+	return newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		// the request control value has the same structure as the response one
+		control, err := DecodeControl(request.Children[2].Children[0])
+		if err != nil {
+			t.Errorf("failed to decode request control: %s", err)
+			return nil
+		}
+		dirSync := control.(*ControlMicrosoftDirSyncResponse)
+		if dirSync.MoreResults != DirSyncFlagObjectSecurity {
+			t.Errorf("unexpected DirSync flags %#x", dirSync.MoreResults)
+		}
+		page := 0
+		if len(dirSync.Cookie) > 0 {
+			page = int(dirSync.Cookie[0] - '0')
+		}
+		if page >= pages {
+			return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultBusy)}
+		}
+		response := &ControlMicrosoftDirSyncResponse{Cookie: []byte{byte('1' + page)}, MoreResults: 1}
+		return []*ber.Packet{
+			newSearchResultEntryPacket(messageID, "cn=entry"+string(response.Cookie)+",dc=example,dc=com"),
+			newSearchResultDonePacket(messageID, LDAPResultSuccess, response),
+		}
+	})
+}
+
+func newDirSyncTestRequest() *SearchRequest {
+	return NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+}
+
+// TestSearchWithDirSyncPartialFailure tests that the last good cookie is returned on failure.
+func TestSearchWithDirSyncPartialFailure(t *testing.T) {
+	conn := newDirSyncTestConn(t, 2)
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		result, cookie, err := conn.SearchWithDirSync(newDirSyncTestRequest(), nil, DirSyncFlagObjectSecurity)
+		if !IsErrorWithCode(err, LDAPResultBusy) {
+			t.Errorf("expected LDAPResultBusy, got %v", err)
+		}
+		if string(cookie) != "2" {
+			t.Errorf("expected the last good cookie, got %q", cookie)
+		}
+		if len(result.Entries) != 2 {
+			t.Errorf("expected the 2 entries received, got %d", len(result.Entries))
+		}
+
+		result, cookie, err = conn.SearchWithDirSync(newDirSyncTestRequest(), cookie, DirSyncFlagObjectSecurity)
+		if !IsErrorWithCode(err, LDAPResultBusy) || string(cookie) != "2" || len(result.Entries) != 0 {
+			t.Errorf("expected the given cookie to be returned with no entries, got %q, %d entries, %v", cookie, len(result.Entries), err)
+		}
+	})
+}
+
+// TestSearchWithDirSyncAsync tests that pages are passed to the callback as received, and
+// that the cookie of a page is not returned if the callback fails to process it.
+func TestSearchWithDirSyncAsync(t *testing.T) {
+	conn := newDirSyncTestConn(t, 3)
+	defer conn.Close()
+
+	errCallback := errors.New("callback error")
+	runWithTimeout(t, time.Second, func() {
+		var dns []string
+		cookie, err := conn.SearchWithDirSyncAsync(newDirSyncTestRequest(), nil, DirSyncFlagObjectSecurity, func(result *SearchResult) error {
+			if len(dns) == 2 {
+				return errCallback
+			}
+			for _, entry := range result.Entries {
+				dns = append(dns, entry.DN)
+			}
+			return nil
+		})
+		if err != errCallback {
+			t.Errorf("expected the callback error, got %v", err)
+		}
+		if string(cookie) != "2" {
+			t.Errorf("expected the cookie of the last processed page, got %q", cookie)
+		}
+		if len(dns) != 2 || dns[0] != "cn=entry1,dc=example,dc=com" || dns[1] != "cn=entry2,dc=example,dc=com" {
+			t.Errorf("unexpected entries %v", dns)
+		}
+	})
+}
+
+func TestControlMicrosoftDirSyncFlags(t *testing.T) {
+	control := NewControlMicrosoftDirSync()
+	control.Flags = DirSyncObjectSecurity | DirSyncIncrementalValues
+	packet := ber.DecodePacket(control.Encode().Bytes())
+	value := ber.DecodePacket(packet.Children[2].Data.Bytes())
+	if flags := value.Children[0].Value.(int64); flags != -2147483647 {
+		t.Errorf("expected the flags to be encoded as a 32 bits signed integer, got %d", flags)
+	}
+}
+
+func TestDecodeControlMicrosoftDirSyncMalformed(t *testing.T) {
+	truncated := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "DirSync Control Value")
+	truncated.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MoreResults"))
+	for _, value := range []*ber.Packet{nil, truncated} {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftDirSync, "Control Type"))
+		if value != nil {
+			packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(value.Bytes()), "Control Value"))
+		}
+		if _, err := DecodeControl(ber.DecodePacket(packet.Bytes())); err == nil {
+			t.Errorf("expected an error decoding a malformed DirSync control")
+		}
+	}
+}
+
+func TestEntryGetDirSyncValueChanges(t *testing.T) {
+	entry := NewEntry("cn=group,dc=example,dc=com", map[string][]string{
+		"member;range=1-1": {"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com"},
+		"member;range=0-0": {"cn=c,dc=example,dc=com"},
+		"description":      {"new description"},
+	})
+	added, removed := entry.GetDirSyncValueChanges("member")
+	if !reflect.DeepEqual(added, []string{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com"}) {
+		t.Errorf("unexpected added values %v", added)
+	}
+	if !reflect.DeepEqual(removed, []string{"cn=c,dc=example,dc=com"}) {
+		t.Errorf("unexpected removed values %v", removed)
+	}
+	if added, removed := entry.GetDirSyncValueChanges("description"); added != nil || removed != nil {
+		t.Errorf("expected no changes for an attribute without range, got %v and %v", added, removed)
+	}
+}
+
+// TestSearchWithDirSyncPaging tests that the paging and DirSync cookies are tracked
+// independently, and that the DirSync cookie is only returned with the last page.
+func TestSearchWithDirSyncPaging(t *testing.T) {
+	const dirSyncRounds, pagesPerRound = 2, 3
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		var dirSync *ControlMicrosoftDirSyncResponse
+		var paging *ControlPaging
+		for _, child := range request.Children[2].Children {
+			control, err := DecodeControl(child)
+			if err != nil {
+				t.Errorf("failed to decode request control: %s", err)
+				return nil
+			}
+			switch c := control.(type) {
+			case *ControlMicrosoftDirSyncResponse:
+				dirSync = c
+			case *ControlPaging:
+				paging = c
+			}
+		}
+		if dirSync == nil || paging == nil || paging.PagingSize != 1 {
+			t.Errorf("unexpected request controls %v", request.Children[2].Children)
+			return nil
+		}
+
+		round, page := 0, 0
+		if len(dirSync.Cookie) > 0 {
+			round = int(dirSync.Cookie[0] - '0')
+		}
+		if len(paging.Cookie) > 0 {
+			page = int(paging.Cookie[0] - '0')
+		}
+		entry := newSearchResultEntryPacket(messageID, fmt.Sprintf("cn=entry%d-%d,dc=example,dc=com", round, page))
+		if page < pagesPerRound-1 {
+			return []*ber.Packet{entry, newSearchResultDonePacket(messageID, LDAPResultSuccess, &ControlPaging{Cookie: []byte{byte('1' + page)}})}
+		}
+		response := &ControlMicrosoftDirSyncResponse{Cookie: []byte{byte('1' + round)}}
+		if round < dirSyncRounds-1 {
+			response.MoreResults = 1
+		}
+		return []*ber.Packet{entry, newSearchResultDonePacket(messageID, LDAPResultSuccess, &ControlPaging{}, response)}
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		var dns []string
+		cookie, err := conn.SearchWithDirSyncPaging(newDirSyncTestRequest(), nil, 0, 1, func(result *SearchResult) error {
+			for _, entry := range result.Entries {
+				dns = append(dns, entry.DN)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(cookie) != "2" {
+			t.Errorf("expected the last DirSync cookie, got %q", cookie)
+		}
+		if len(dns) != dirSyncRounds*pagesPerRound || dns[0] != "cn=entry0-0,dc=example,dc=com" || dns[len(dns)-1] != "cn=entry1-2,dc=example,dc=com" {
+			t.Errorf("unexpected entries %v", dns)
+		}
+	})
+}
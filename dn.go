@@ -46,6 +46,7 @@ import (
 	enchex "encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/go-asn1-ber/asn1-ber"
@@ -168,6 +169,9 @@ func ParseDN(str string) (*DN, error) {
 			buffer.WriteByte(char)
 		}
 	}
+	if escaping {
+		return nil, errors.New("got corrupted escaped character")
+	}
 	if buffer.Len() > 0 {
 		if len(attribute.Type) == 0 {
 			return nil, errors.New("DN ended with incomplete type, value pair")
@@ -194,6 +198,39 @@ func (d *DN) Equal(other *DN) bool {
 	return true
 }
 
+// String returns the canonical string representation of the DN as defined in rfc4514 2.
+// Attribute types are lowercased, the attributes of multi-valued RDNs are sorted, and
+// values are escaped so that equal DNs have the same representation.
+func (d *DN) String() string {
+	rdns := make([]string, len(d.RDNs))
+	for i, rdn := range d.RDNs {
+		rdns[i] = rdn.String()
+	}
+	return strings.Join(rdns, ",")
+}
+
+// canonicalDN returns a representation of dn which is the same for the DNs which only differ
+// by the case of their attribute types and values, by the order of the attributes of their
+// multi-valued RDNs, or by insignificant spaces, as in the caseIgnoreMatch rule. dn is
+// lowercased if it cannot be parsed.
+func canonicalDN(dn string) string {
+	parsed, err := ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(dn)
+	}
+	rdns := make([]string, len(parsed.RDNs))
+	for i, rdn := range parsed.RDNs {
+		attrs := make([]string, len(rdn.Attributes))
+		for j, attr := range rdn.Attributes {
+			value := strings.Join(strings.Fields(strings.ToLower(attr.Value)), " ")
+			attrs[j] = strings.ToLower(attr.Type) + "=" + escapeDNValue(value)
+		}
+		sort.Strings(attrs)
+		rdns[i] = strings.Join(attrs, "+")
+	}
+	return strings.Join(rdns, ",")
+}
+
 // AncestorOf returns true if the other DN consists of at least one RDN followed by all the RDNs of the current DN.
 // "ou=widgets,o=acme.com" is an ancestor of "ou=sprockets,ou=widgets,o=acme.com"
 // "ou=widgets,o=acme.com" is not an ancestor of "ou=sprockets,ou=widgets,o=foo.com"
@@ -212,6 +249,15 @@ func (d *DN) AncestorOf(other *DN) bool {
 	return true
 }
 
+// Parent returns the DN of the parent entry, which is the DN without its leftmost RDN.
+// Returns nil for the root DN, which has no RDN.
+func (d *DN) Parent() *DN {
+	if len(d.RDNs) == 0 {
+		return nil
+	}
+	return &DN{RDNs: d.RDNs[1:]}
+}
+
 // Equal returns true if the RelativeDNs are equal as defined by rfc4517 4.2.15 (distinguishedNameMatch).
 // Relative distinguished names are the same if and only if they have the same number of AttributeTypeAndValues
 // and each attribute of the first RDN is the same as the attribute of the second RDN with the same attribute type.
@@ -224,6 +270,16 @@ func (r *RelativeDN) Equal(other *RelativeDN) bool {
 	return r.hasAllAttributes(other.Attributes) && other.hasAllAttributes(r.Attributes)
 }
 
+// String returns the canonical string representation of the RelativeDN
+func (r *RelativeDN) String() string {
+	attrs := make([]string, len(r.Attributes))
+	for i, attr := range r.Attributes {
+		attrs[i] = attr.String()
+	}
+	sort.Strings(attrs)
+	return strings.Join(attrs, "+")
+}
+
 func (r *RelativeDN) hasAllAttributes(attrs []*AttributeTypeAndValue) bool {
 	for _, attr := range attrs {
 		found := false
@@ -245,3 +301,70 @@ func (r *RelativeDN) hasAllAttributes(attrs []*AttributeTypeAndValue) bool {
 func (a *AttributeTypeAndValue) Equal(other *AttributeTypeAndValue) bool {
 	return strings.EqualFold(a.Type, other.Type) && a.Value == other.Value
 }
+
+// String returns the canonical string representation of the AttributeTypeAndValue
+func (a *AttributeTypeAndValue) String() string {
+	return strings.ToLower(a.Type) + "=" + escapeDNValue(a.Value)
+}
+
+// EscapeDN escapes an attribute value as defined in rfc4514 2.4, so that it can be safely
+// inserted in a DN, as in "cn=" + EscapeDN(name) + ",dc=example,dc=com". The characters
+// in the set `"+,;<=>\`, a leading `#`, leading and trailing spaces, and control characters
+// are escaped.
+func EscapeDN(value string) string {
+	return escapeDNValue(value)
+}
+
+// UnescapeDN returns the attribute value escaped by EscapeDN, or an error if value contains
+// an invalid escape sequence
+func UnescapeDN(value string) (string, error) {
+	var buffer bytes.Buffer
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		if char != '\\' {
+			buffer.WriteByte(char)
+			continue
+		}
+		i++
+		if i == len(value) {
+			return "", errors.New("got corrupted escaped character")
+		}
+		switch char = value[i]; char {
+		case ' ', '"', '#', '+', ',', ';', '<', '=', '>', '\\':
+			buffer.WriteByte(char)
+			continue
+		}
+		if i+1 == len(value) {
+			return "", errors.New("got corrupted escaped character")
+		}
+		dst := []byte{0}
+		if _, err := enchex.Decode(dst, []byte(value[i:i+2])); err != nil {
+			return "", fmt.Errorf("failed to decode escaped character: %s", err)
+		}
+		buffer.WriteByte(dst[0])
+		i++
+	}
+	return buffer.String(), nil
+}
+
+// escapeDNValue escapes a value as defined in rfc4514 2.4
+func escapeDNValue(value string) string {
+	var buffer bytes.Buffer
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		switch {
+		case char == '"' || char == '+' || char == ',' || char == ';' || char == '<' || char == '=' || char == '>' || char == '\\':
+			buffer.WriteByte('\\')
+			buffer.WriteByte(char)
+		case char == '#' && i == 0:
+			buffer.WriteString("\\#")
+		case char == ' ' && (i == 0 || i == len(value)-1):
+			buffer.WriteString("\\ ")
+		case char < ' ' || char == 0x7f:
+			fmt.Fprintf(&buffer, "\\%02x", char)
+		default:
+			buffer.WriteByte(char)
+		}
+	}
+	return buffer.String()
+}
@@ -76,6 +76,7 @@ func TestErrorDNParsing(t *testing.T) {
 		"*":                       "DN ended with incomplete type, value pair",
 		"cn=Jim\\0Test":           "failed to decode escaped character: encoding/hex: invalid byte: U+0054 'T'",
 		"cn=Jim\\0":               "got corrupted escaped character",
+		"cn=Jim\\":                "got corrupted escaped character",
 		"DC=example,=net":         "DN ended with incomplete type, value pair",
 		"1=#0402486":              "failed to decode BER encoding: encoding/hex: odd length hex string",
 		"test,DC=example,DC=com":  "incomplete type, value pair",
@@ -188,6 +189,8 @@ func TestDNAncestor(t *testing.T) {
 
 		// Descendant
 		{"ou=C,ou=B,o=A", "ou=E,ou=C,ou=B,o=A", true},
+		{"DC=x", "CN=a, DC=x", true},
+		{"dc=x", "CN=a,  DC=x", true},
 	}
 
 	for i, tc := range testcases {
@@ -207,3 +210,118 @@ func TestDNAncestor(t *testing.T) {
 		}
 	}
 }
+
+func TestDNString(t *testing.T) {
+	testcases := []struct {
+		DN       string
+		Expected string
+	}{
+		{"", ""},
+		{"CN=Jim\\2C \\22Hasse Hö\\22 Hansson!, DC=dummy, DC=com", `cn=Jim\, \"Hasse Hö\" Hansson!,dc=dummy,dc=com`},
+		{"OU=Sales+CN=J. Smith,DC=example,DC=net", "cn=J. Smith+ou=Sales,dc=example,dc=net"},
+		{"cn=a\\+b,dc=net", `cn=a\+b,dc=net`},
+		{"cn=\\#a\\20,dc=net", `cn=\#a\ ,dc=net`},
+		{"1.3.6.1.4.1.1466.0=#04024869", "1.3.6.1.4.1.1466.0=Hi"},
+		{"cn=a\\0Db", `cn=a\0db`},
+	}
+
+	for i, tc := range testcases {
+		dn, err := ParseDN(tc.DN)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if actual := dn.String(); actual != tc.Expected {
+			t.Errorf("%d: expected %q, got %q", i, tc.Expected, actual)
+			continue
+		}
+		reparsed, err := ParseDN(dn.String())
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if !reparsed.Equal(dn) {
+			t.Errorf("%d: %q does not parse back to an equal DN", i, dn.String())
+		}
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	testcases := []struct {
+		Value    string
+		Expected string
+	}{
+		{"", ""},
+		{"Smith, John", `Smith\, John`},
+		{`a+b"c;d<e=f>g\h`, `a\+b\"c\;d\<e\=f\>g\\h`},
+		{"#1 fan", `\#1 fan`},
+		{"a#b", "a#b"},
+		{" padded ", `\ padded\ `},
+		{" ", `\ `},
+		{"a\x00b\rc", `a\00b\0dc`},
+		{"Hö", "Hö"},
+	}
+
+	for i, tc := range testcases {
+		escaped := EscapeDN(tc.Value)
+		if escaped != tc.Expected {
+			t.Errorf("%d: expected %q, got %q", i, tc.Expected, escaped)
+		}
+		unescaped, err := UnescapeDN(escaped)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		} else if unescaped != tc.Value {
+			t.Errorf("%d: expected %q to unescape to %q, got %q", i, escaped, tc.Value, unescaped)
+		}
+		dn, err := ParseDN("cn=" + escaped + ",dc=example,dc=com")
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		} else if dn.RDNs[0].Attributes[0].Value != tc.Value {
+			t.Errorf("%d: expected DN value %q, got %q", i, tc.Value, dn.RDNs[0].Attributes[0].Value)
+		}
+	}
+
+	if unescaped, err := UnescapeDN(`Jim\2C \22Hasse\22`); err != nil || unescaped != `Jim, "Hasse"` {
+		t.Errorf("unexpected result %q, %v", unescaped, err)
+	}
+	for _, invalid := range []string{`a\`, `a\4`, `a\zz`} {
+		if _, err := UnescapeDN(invalid); err == nil {
+			t.Errorf("expected an error unescaping %q", invalid)
+		}
+	}
+}
+
+func TestDNParent(t *testing.T) {
+	testcases := []struct {
+		DN     string
+		Parent string
+	}{
+		{"CN=a, OU=b, DC=x", "ou=b,dc=x"},
+		{"cn=a+sn=b,dc=x", "dc=x"},
+		{"dc=x", ""},
+	}
+
+	for i, tc := range testcases {
+		dn, err := ParseDN(tc.DN)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		parent := dn.Parent()
+		if parent == nil {
+			t.Errorf("%d: unexpected nil parent", i)
+			continue
+		}
+		if actual := parent.String(); actual != tc.Parent {
+			t.Errorf("%d: expected parent %q, got %q", i, tc.Parent, actual)
+		}
+		if !parent.AncestorOf(dn) {
+			t.Errorf("%d: expected %q to be an ancestor of %q", i, tc.Parent, tc.DN)
+		}
+	}
+
+	root, _ := ParseDN("")
+	if root.Parent() != nil {
+		t.Errorf("expected nil parent for the root DN")
+	}
+}
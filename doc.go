@@ -1,4 +1,7 @@
 /*
 Package ldap provides basic LDAP v3 functionality.
+
+A Conn can perform several operations at once: it may be shared by multiple goroutines,
+the responses being returned to each operation by message ID.
 */
 package ldap
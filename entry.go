@@ -0,0 +1,395 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAttributeNotFound is returned by the typed getters of Entry when the entry has no value for the attribute
+var ErrAttributeNotFound = errors.New("ldap: attribute not found")
+
+// windowsEpoch is the origin of Active Directory FILETIME values
+var windowsEpoch = time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// GetAttributeValueInt64 returns the first value of the named attribute parsed as an Integer (rfc4517 3.3.16)
+func (e *Entry) GetAttributeValueInt64(attribute string) (int64, error) {
+	values := e.GetAttributeValues(attribute)
+	if len(values) == 0 {
+		return 0, ErrAttributeNotFound
+	}
+	i, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ldap: invalid integer value for attribute %s: %s", attribute, err)
+	}
+	return i, nil
+}
+
+// GetAttributeValueBool returns the first value of the named attribute parsed as a Boolean (rfc4517 3.3.3),
+// which is either "TRUE" or "FALSE"
+func (e *Entry) GetAttributeValueBool(attribute string) (bool, error) {
+	values := e.GetAttributeValues(attribute)
+	if len(values) == 0 {
+		return false, ErrAttributeNotFound
+	}
+	b, err := parseBoolean(values[0])
+	if err != nil {
+		return false, fmt.Errorf("ldap: invalid boolean value for attribute %s: %s", attribute, err)
+	}
+	return b, nil
+}
+
+// parseBoolean parses a Boolean value as defined in rfc4517 3.3.3
+func parseBoolean(value string) (bool, error) {
+	switch value {
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is neither TRUE nor FALSE", value)
+}
+
+// GetAttributeValueTime returns the first value of the named attribute parsed as a time.
+//
+// Both Generalized Time values (rfc4517 3.3.13), such as "20060102150405.0Z", and
+// Active Directory FILETIME integers, such as the value of pwdLastSet, are supported.
+// The FILETIME values 0 and 9223372036854775807, which Active Directory uses for "never",
+// are returned as the zero time.Time.
+func (e *Entry) GetAttributeValueTime(attribute string) (time.Time, error) {
+	values := e.GetAttributeValues(attribute)
+	if len(values) == 0 {
+		return time.Time{}, ErrAttributeNotFound
+	}
+	t, err := parseTime(values[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ldap: invalid time value for attribute %s: %s", attribute, err)
+	}
+	return t, nil
+}
+
+// parseTime parses either a Generalized Time value or an Active Directory FILETIME integer
+func parseTime(value string) (time.Time, error) {
+	if fileTime, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if fileTime == 0 || fileTime == 1<<63-1 {
+			return time.Time{}, nil
+		}
+		// FILETIME counts 100-nanosecond intervals: split it to avoid overflowing time.Duration
+		seconds := fileTime / 1e7
+		return windowsEpoch.AddDate(0, 0, int(seconds/86400)).Add(time.Duration(seconds%86400)*time.Second + time.Duration(fileTime%1e7)*100), nil
+	}
+	return parseGeneralizedTime(value)
+}
+
+// parseGeneralizedTime parses a Generalized Time value as defined in rfc4517 3.3.13:
+//
+//	GeneralizedTime = century year month day hour
+//	                     [ minute [ second / leap-second ] ]
+//	                     [ fraction ]
+//	                     g-time-zone
+func parseGeneralizedTime(value string) (time.Time, error) {
+	var location *time.Location
+	switch i := strings.LastIndexAny(value, "Z+-"); {
+	case i < 0:
+		return time.Time{}, errors.New("missing time zone")
+	case value[i] == 'Z':
+		if i != len(value)-1 {
+			return time.Time{}, errors.New("invalid time zone")
+		}
+		location = time.UTC
+		value = value[:i]
+	default:
+		offset := value[i+1:]
+		if len(offset) != 2 && len(offset) != 4 {
+			return time.Time{}, errors.New("invalid time zone")
+		}
+		hours, err := strconv.Atoi(offset[:2])
+		if err != nil {
+			return time.Time{}, errors.New("invalid time zone")
+		}
+		minutes := 0
+		if len(offset) == 4 {
+			if minutes, err = strconv.Atoi(offset[2:]); err != nil {
+				return time.Time{}, errors.New("invalid time zone")
+			}
+		}
+		seconds := hours*3600 + minutes*60
+		if value[i] == '-' {
+			seconds = -seconds
+		}
+		location = time.FixedZone("", seconds)
+		value = value[:i]
+	}
+
+	fraction := 0.0
+	if i := strings.IndexAny(value, ".,"); i >= 0 {
+		f, err := strconv.ParseFloat("0."+value[i+1:], 64)
+		if err != nil || i == len(value)-1 {
+			return time.Time{}, errors.New("invalid fraction")
+		}
+		fraction = f
+		value = value[:i]
+	}
+
+	var layout string
+	var unit time.Duration
+	switch len(value) {
+	case 10:
+		layout, unit = "2006010215", time.Hour
+	case 12:
+		layout, unit = "200601021504", time.Minute
+	case 14:
+		layout, unit = "20060102150405", time.Second
+	default:
+		return time.Time{}, errors.New("invalid length")
+	}
+	t, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(time.Duration(fraction * float64(unit))), nil
+}
+
+// GetAttributeRangeValues returns all the values of the named attribute, retrieving with
+// conn the values which the server did not return with the entry. Active Directory returns
+// at most about 1500 values of a multi-valued attribute such as member, as the attribute
+// "member;range=0-1499": the following ranges are requested with base searches of the entry
+// until the last range, such as "member;range=1500-*", is received.
+func (e *Entry) GetAttributeRangeValues(conn *Conn, attribute string) ([]string, error) {
+	values, high, found := e.rangeValues(attribute)
+	if !found {
+		return nil, ErrAttributeNotFound
+	}
+	if high < 0 {
+		return values, nil
+	}
+	next, err := conn.getRangedValues(e.DN, attribute, high+1)
+	if err != nil {
+		return nil, err
+	}
+	return append(values, next...), nil
+}
+
+// GetAllRangedValues returns all the values of the named attribute of the entry with the
+// given DN, requesting them range by range as described in Entry.GetAttributeRangeValues
+func (l *Conn) GetAllRangedValues(dn, attribute string) ([]string, error) {
+	return l.getRangedValues(dn, attribute, 0)
+}
+
+// getRangedValues returns the values of the named attribute from the given index
+func (l *Conn) getRangedValues(dn, attribute string, low int) ([]string, error) {
+	values := []string{}
+	for {
+		searchRequest := NewSearchRequest(
+			dn,
+			ScopeBaseObject, NeverDerefAliases, 0, 0, false,
+			"(objectClass=*)",
+			[]string{fmt.Sprintf("%s;range=%d-*", attribute, low)},
+			nil)
+		result, err := l.Search(searchRequest)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Entries) != 1 {
+			return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: expected 1 entry for %s, got %d", dn, len(result.Entries)))
+		}
+		next, high, found := result.Entries[0].rangeValues(attribute)
+		if !found {
+			if low == 0 {
+				// the entry has no value for the attribute
+				return values, nil
+			}
+			return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: missing range of attribute %s from value %d", attribute, low))
+		}
+		values = append(values, next...)
+		if high < 0 {
+			return values, nil
+		}
+		low = high + 1
+	}
+}
+
+// rangeValues returns the values of the named attribute, and the upper bound of their
+// range, which is -1 if the entry holds all the remaining values
+func (e *Entry) rangeValues(attribute string) (values []string, high int, found bool) {
+	for _, attr := range e.Attributes {
+		if strings.EqualFold(attr.Name, attribute) {
+			return attr.S, -1, true
+		}
+		name, _, rangeHigh, ok := parseRangeOption(attr.Name)
+		if ok && strings.EqualFold(name, attribute) {
+			return attr.S, rangeHigh, true
+		}
+	}
+	return nil, 0, false
+}
+
+// parseRangeOption splits an attribute description holding a range option, such as
+// "member;range=0-1499", into the attribute description without the range option and the
+// bounds of the range. high is -1 for the "*" upper bound of the last range.
+func parseRangeOption(description string) (name string, low, high int, ok bool) {
+	options := strings.Split(description, ";")
+	for i, option := range options[1:] {
+		if len(option) < 6 || !strings.EqualFold(option[:6], "range=") {
+			continue
+		}
+		bounds := strings.SplitN(option[6:], "-", 2)
+		if len(bounds) != 2 {
+			return "", 0, 0, false
+		}
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil || low < 0 {
+			return "", 0, 0, false
+		}
+		high := -1
+		if bounds[1] != "*" {
+			high, err = strconv.Atoi(bounds[1])
+			if err != nil || high < low {
+				return "", 0, 0, false
+			}
+		}
+		options = append(options[:i+1], options[i+2:]...)
+		return strings.Join(options, ";"), low, high, true
+	}
+	return description, 0, 0, false
+}
+
+// SplitAttributeOptions splits an attribute description (rfc4512 2.5), such as
+// "description;lang-en", "userCertificate;binary" or "member;range=0-1499", into the
+// attribute name and its options, which are nil if there are none
+func SplitAttributeOptions(name string) (base string, options []string) {
+	parts := strings.Split(name, ";")
+	if len(parts) == 1 {
+		return name, nil
+	}
+	return parts[0], parts[1:]
+}
+
+// attributeOptionsKey returns the key of an option set in the map returned by
+// GetAttributeValuesWithOptions: the lower case options, sorted and joined with ";"
+func attributeOptionsKey(options []string) string {
+	keys := make([]string, len(options))
+	for i, option := range options {
+		keys[i] = strings.ToLower(option)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ";")
+}
+
+// GetAttributeValuesWithOptions returns the values of all the variants of the named
+// attribute, keyed by their option set. As the options are case insensitive and unordered,
+// the key holds the lower case options sorted and joined with ";": the values of
+// "description;lang-en" are keyed by "lang-en", and the values of "description" by "".
+func (e *Entry) GetAttributeValuesWithOptions(attribute string) map[string][]string {
+	values := make(map[string][]string)
+	for _, attr := range e.Attributes {
+		base, options := SplitAttributeOptions(attr.Name)
+		if strings.EqualFold(base, attribute) {
+			key := attributeOptionsKey(options)
+			values[key] = append(values[key], attr.S...)
+		}
+	}
+	return values
+}
+
+// GetAttributeValuesAllOptions returns the values of the named attribute and of all its
+// variants with options, such as "description;lang-en", in the order of the attributes of
+// the entry, or an empty list. Unlike GetAttributeValues, the attribute name must not
+// hold options.
+func (e *Entry) GetAttributeValuesAllOptions(attribute string) []string {
+	values := []string{}
+	for _, attr := range e.Attributes {
+		if base, _ := SplitAttributeOptions(attr.Name); strings.EqualFold(base, attribute) {
+			values = append(values, attr.S...)
+		}
+	}
+	return values
+}
+
+// AttributeChange is a change of the values of an attribute between two entries, returned
+// by DiffEntries
+type AttributeChange struct {
+	// Name is the name of the attribute
+	Name string
+	// Added are the values of the new entry missing from the old one
+	Added []string
+	// Removed are the values of the old entry missing from the new one
+	Removed []string
+}
+
+// DiffEntries returns the changes of the attributes from oldEntry to newEntry, sorted
+// by attribute name. The attribute names are compared case insensitively, and the values as
+// multisets of raw values: their order is ignored, but a value present twice in an entry and
+// once in the other is reported as added or removed once. A nil entry has no attributes.
+func DiffEntries(oldEntry, newEntry *Entry) []AttributeChange {
+	type attributePair struct {
+		name     string
+		old, new []string
+	}
+	pairs := make(map[string]*attributePair)
+	collect := func(entry *Entry, isNew bool) {
+		if entry == nil {
+			return
+		}
+		for _, attr := range entry.Attributes {
+			key := strings.ToLower(attr.Name)
+			pair, ok := pairs[key]
+			if !ok {
+				pair = &attributePair{name: attr.Name}
+				pairs[key] = pair
+			}
+			// attributes built with only their string values have no raw values
+			values := attr.S
+			if len(attr.ByteValues) == len(attr.S) {
+				values = make([]string, len(attr.ByteValues))
+				for i, value := range attr.ByteValues {
+					values[i] = string(value)
+				}
+			}
+			if isNew {
+				pair.name = attr.Name
+				pair.new = append(pair.new, values...)
+			} else {
+				pair.old = append(pair.old, values...)
+			}
+		}
+	}
+	collect(oldEntry, false)
+	collect(newEntry, true)
+
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var changes []AttributeChange
+	for _, key := range keys {
+		pair := pairs[key]
+		counts := make(map[string]int, len(pair.old))
+		for _, value := range pair.old {
+			counts[value]++
+		}
+		change := AttributeChange{Name: pair.name}
+		for _, value := range pair.new {
+			if counts[value] > 0 {
+				counts[value]--
+			} else {
+				change.Added = append(change.Added, value)
+			}
+		}
+		for _, value := range pair.old {
+			if counts[value] > 0 {
+				counts[value]--
+				change.Removed = append(change.Removed, value)
+			}
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
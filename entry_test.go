@@ -0,0 +1,342 @@
+package ldap
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestEntryTypedGetters(t *testing.T) {
+	entry := NewEntry("cn=jdoe,dc=example,dc=com", map[string][]string{
+		"whenCreated":        {"20200102030405.0Z"},
+		"modifyTimestamp":    {"202001020304+0130"},
+		"pwdLastSet":         {"132223104000000000"},
+		"accountExpires":     {"9223372036854775807"},
+		"uidNumber":          {"1000"},
+		"pwdMustChange":      {"TRUE"},
+		"pwdAllowUserChange": {"FALSE"},
+		"cn":                 {"jdoe"},
+	})
+
+	timeTests := map[string]time.Time{
+		"whenCreated":     time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC),
+		"modifyTimestamp": time.Date(2020, time.January, 2, 1, 34, 0, 0, time.UTC),
+		"pwdLastSet":      time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"accountExpires":  time.Time{},
+	}
+	for attribute, expected := range timeTests {
+		actual, err := entry.GetAttributeValueTime(attribute)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", attribute, err)
+		} else if !actual.Equal(expected) {
+			t.Errorf("%s: expected %s, got %s", attribute, expected, actual)
+		}
+	}
+	if _, err := entry.GetAttributeValueTime("cn"); err == nil {
+		t.Errorf("expected an error parsing an invalid time")
+	}
+
+	if i, err := entry.GetAttributeValueInt64("uidNumber"); err != nil || i != 1000 {
+		t.Errorf("expected 1000, got %d (%v)", i, err)
+	}
+	if _, err := entry.GetAttributeValueInt64("cn"); err == nil {
+		t.Errorf("expected an error parsing an invalid integer")
+	}
+
+	if b, err := entry.GetAttributeValueBool("pwdMustChange"); err != nil || !b {
+		t.Errorf("expected true, got %t (%v)", b, err)
+	}
+	if b, err := entry.GetAttributeValueBool("pwdAllowUserChange"); err != nil || b {
+		t.Errorf("expected false, got %t (%v)", b, err)
+	}
+	if _, err := entry.GetAttributeValueBool("cn"); err == nil {
+		t.Errorf("expected an error parsing an invalid boolean")
+	}
+
+	if _, err := entry.GetAttributeValueTime("missing"); err != ErrAttributeNotFound {
+		t.Errorf("expected ErrAttributeNotFound, got %v", err)
+	}
+	if _, err := entry.GetAttributeValueInt64("missing"); err != ErrAttributeNotFound {
+		t.Errorf("expected ErrAttributeNotFound, got %v", err)
+	}
+	if _, err := entry.GetAttributeValueBool("missing"); err != ErrAttributeNotFound {
+		t.Errorf("expected ErrAttributeNotFound, got %v", err)
+	}
+}
+
+func TestEntryGettersIgnoreCase(t *testing.T) {
+	// the entries returned by different servers for the same attributes
+	small := NewEntry("cn=jdoe,dc=example,dc=com", map[string][]string{
+		"samaccountname":   {"jdoe"},
+		"MEMBER;RANGE=0-*": {"cn=a,dc=example,dc=com"},
+	})
+	large := newBenchmarkEntry(10)
+	large.Attributes = append(large.Attributes,
+		&EntryAttribute{Name: "sAMAccountName", S: []string{"jdoe"}, ByteValues: [][]byte{[]byte("jdoe")}},
+		&EntryAttribute{Name: "member;range=0-*", S: []string{"cn=a,dc=example,dc=com"}})
+
+	for entry, returnedName := range map[*Entry]string{small: "samaccountname", large: "sAMAccountName"} {
+		for _, name := range []string{"sAMAccountName", "samaccountname", "SAMACCOUNTNAME"} {
+			if value := entry.GetAttributeValue(name); value != "jdoe" {
+				t.Errorf("%s: expected jdoe, got %q", name, value)
+			}
+			if value := entry.GetRawAttributeValue(name); string(value) != "jdoe" {
+				t.Errorf("%s: expected raw value jdoe, got %q", name, value)
+			}
+			if attr := entry.GetAttribute(name); attr == nil || attr.Name != returnedName {
+				t.Errorf("%s: expected the attribute %s, got %v", name, returnedName, attr)
+			}
+		}
+		if values, _, found := entry.rangeValues("Member"); !found || len(values) != 1 {
+			t.Errorf("expected the values of the member range, got %v", values)
+		}
+		if value := entry.GetAttributeValue("cn"); value != "" {
+			t.Errorf("expected no cn value, got %q", value)
+		}
+	}
+}
+
+func TestParseRangeOption(t *testing.T) {
+	tests := []struct {
+		description string
+		name        string
+		low, high   int
+		ok          bool
+	}{
+		{"member", "member", 0, 0, false},
+		{"member;range=0-1499", "member", 0, 1499, true},
+		{"member;Range=1500-*", "member", 1500, -1, true},
+		{"member;binary;range=0-0", "member;binary", 0, 0, true},
+		{"member;range=10-2", "", 0, 0, false},
+		{"member;range=x-*", "", 0, 0, false},
+		{"member;range=0", "", 0, 0, false},
+	}
+	for _, tc := range tests {
+		name, low, high, ok := parseRangeOption(tc.description)
+		if name != tc.name || low != tc.low || high != tc.high || ok != tc.ok {
+			t.Errorf("%q: got %q, %d, %d, %t, expected %q, %d, %d, %t",
+				tc.description, name, low, high, ok, tc.name, tc.low, tc.high, tc.ok)
+		}
+	}
+}
+
+func TestSplitAttributeOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		options []string
+	}{
+		{"description", "description", nil},
+		{"description;lang-en", "description", []string{"lang-en"}},
+		{"userCertificate;binary", "userCertificate", []string{"binary"}},
+		{"member;binary;range=0-1499", "member", []string{"binary", "range=0-1499"}},
+	}
+	for _, tc := range tests {
+		base, options := SplitAttributeOptions(tc.name)
+		if base != tc.base || !reflect.DeepEqual(options, tc.options) {
+			t.Errorf("%q: got %q, %q, expected %q, %q", tc.name, base, options, tc.base, tc.options)
+		}
+	}
+}
+
+func TestGetAttributeValuesWithOptions(t *testing.T) {
+	entry := &Entry{
+		DN: "cn=jdoe,dc=example,dc=com",
+		Attributes: []*EntryAttribute{
+			{Name: "description", S: []string{"default"}},
+			{Name: "cn", S: []string{"jdoe"}},
+			{Name: "Description;lang-EN", S: []string{"english"}},
+			{Name: "description;lang-fr;x-test", S: []string{"french"}},
+			{Name: "description;x-test;lang-fr", S: []string{"français"}},
+		},
+	}
+
+	expected := map[string][]string{
+		"":               {"default"},
+		"lang-en":        {"english"},
+		"lang-fr;x-test": {"french", "français"},
+	}
+	if values := entry.GetAttributeValuesWithOptions("DESCRIPTION"); !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %q, got %q", expected, values)
+	}
+	if values := entry.GetAttributeValuesAllOptions("description"); !reflect.DeepEqual(values, []string{"default", "english", "french", "français"}) {
+		t.Errorf("unexpected values of all the options: %q", values)
+	}
+	if values := entry.GetAttributeValues("description"); !reflect.DeepEqual(values, []string{"default"}) {
+		t.Errorf("expected only the value without options, got %q", values)
+	}
+	if values := entry.GetAttributeValuesAllOptions("sn"); len(values) != 0 {
+		t.Errorf("expected no values, got %q", values)
+	}
+}
+
+func TestGetAttributeRangeValues(t *testing.T) {
+	members := []string{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com", "cn=c,dc=example,dc=com"}
+	// the server returns one value per range
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		requested := request.Children[1].Children[7].Children[0].Value.(string)
+		_, low, high, ok := parseRangeOption(requested)
+		if !ok || high != -1 || low >= len(members) {
+			return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultUnwillingToPerform)}
+		}
+		name := fmt.Sprintf("member;range=%d-%d", low, low)
+		if low == len(members)-1 {
+			name = fmt.Sprintf("member;range=%d-*", low)
+		}
+		return []*ber.Packet{
+			newSearchResultEntryPacket(messageID, "cn=group,dc=example,dc=com", name, members[low]),
+			newSearchResultDonePacket(messageID, LDAPResultSuccess),
+		}
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		values, err := conn.GetAllRangedValues("cn=group,dc=example,dc=com", "member")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(values, members) {
+			t.Errorf("got %v, expected %v", values, members)
+		}
+
+		entry := NewEntry("cn=group,dc=example,dc=com", map[string][]string{"member;range=0-0": {members[0]}})
+		values, err = entry.GetAttributeRangeValues(conn, "member")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(values, members) {
+			t.Errorf("got %v, expected %v", values, members)
+		}
+
+		entry = NewEntry("cn=group,dc=example,dc=com", map[string][]string{"member": {members[0]}})
+		if values, err := entry.GetAttributeRangeValues(conn, "member"); err != nil || len(values) != 1 {
+			t.Errorf("expected the values of an attribute without range, got %v, %v", values, err)
+		}
+		if _, err := entry.GetAttributeRangeValues(conn, "uniqueMember"); err != ErrAttributeNotFound {
+			t.Errorf("expected ErrAttributeNotFound, got %v", err)
+		}
+	})
+}
+
+// newBenchmarkEntry returns an entry with n attributes named attr0 to attrN
+func newBenchmarkEntry(n int) *Entry {
+	attributes := make(map[string][]string, n)
+	for i := 0; i < n; i++ {
+		attributes[fmt.Sprintf("attr%d", i)] = []string{fmt.Sprintf("value%d", i)}
+	}
+	return NewEntry("cn=a,dc=example,dc=com", attributes)
+}
+
+func TestEntryIndexAttributes(t *testing.T) {
+	entry := newBenchmarkEntry(10)
+	entry.Attributes = append(entry.Attributes,
+		&EntryAttribute{Name: "sAMAccountName", S: []string{"jdoe"}},
+		&EntryAttribute{Name: "SAMACCOUNTNAME", S: []string{"duplicate"}})
+	index := entry.IndexAttributes()
+	for _, name := range []string{"sAMAccountName", "samaccountname", "SAMACCOUNTNAME"} {
+		if value := index.GetAttributeValue(name); value != "jdoe" {
+			t.Errorf("%s: expected jdoe, got %q", name, value)
+		}
+	}
+	if values := index.GetAttributeValues("missing"); len(values) != 0 {
+		t.Errorf("expected no values, got %v", values)
+	}
+
+	// the getters of the entry reflect the changes made after the index was built, whether
+	// an attribute is replaced in place or renamed
+	entry.Attributes[0] = &EntryAttribute{Name: "mail", S: []string{"jdoe@example.com"}}
+	entry.Attributes[10].Name = "uid"
+	if value := entry.GetAttributeValue("mail"); value != "jdoe@example.com" {
+		t.Errorf("expected the replaced attribute, got %q", value)
+	}
+	if value := entry.GetAttributeValue("uid"); value != "jdoe" {
+		t.Errorf("expected the renamed attribute, got %q", value)
+	}
+	if value := entry.GetAttributeValue("sAMAccountName"); value != "duplicate" {
+		t.Errorf("expected the remaining sAMAccountName attribute, got %q", value)
+	}
+	if value := entry.IndexAttributes().GetAttributeValue("uid"); value != "jdoe" {
+		t.Errorf("expected a new index to hold the renamed attribute, got %q", value)
+	}
+}
+
+// BenchmarkEntryGetAttributeValues looks up each attribute of an entry, as a batch job
+// reading the entries returned by a search does, with the getters of the entry and with an
+// index of its attributes
+func BenchmarkEntryGetAttributeValues(b *testing.B) {
+	for _, n := range []int{5, 50, 200} {
+		names := make([]string, n)
+		for i := range names {
+			names[i] = fmt.Sprintf("attr%d", i)
+		}
+		entry := newBenchmarkEntry(n)
+		b.Run(fmt.Sprintf("%d attributes", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, name := range names {
+					entry.GetAttributeValue(name)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("%d attributes indexed", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				index := entry.IndexAttributes()
+				for _, name := range names {
+					index.GetAttributeValue(name)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffEntries(t *testing.T) {
+	old := NewEntry("cn=joe,dc=example,dc=com", map[string][]string{
+		"cn":          {"joe"},
+		"mail":        {"joe@example.com", "j@example.com"},
+		"member":      {"a", "b", "b", "c"},
+		"description": {"old"},
+		"title":       {"engineer"},
+	})
+	newEntry := NewEntry("cn=joe,dc=example,dc=com", map[string][]string{
+		"CN":          {"joe"},
+		"mail":        {"j@example.com", "joe@example.com"},
+		"member":      {"c", "b", "d", "d"},
+		"description": {"new"},
+		"telephone":   {"1234"},
+	})
+
+	expected := []AttributeChange{
+		{Name: "description", Added: []string{"new"}, Removed: []string{"old"}},
+		{Name: "member", Added: []string{"d", "d"}, Removed: []string{"a", "b"}},
+		{Name: "telephone", Added: []string{"1234"}},
+		{Name: "title", Removed: []string{"engineer"}},
+	}
+	if changes := DiffEntries(old, newEntry); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes:\n%+v\nexpected:\n%+v", changes, expected)
+	}
+
+	if changes := DiffEntries(old, old); len(changes) != 0 {
+		t.Errorf("expected no change between identical entries, got %+v", changes)
+	}
+	if changes := DiffEntries(nil, newEntry); len(changes) != 5 || changes[0].Name != "CN" || len(changes[0].Removed) != 0 {
+		t.Errorf("unexpected changes from a nil entry: %+v", changes)
+	}
+
+	// attributes built with only their string values
+	stringsOnly := &Entry{DN: old.DN, Attributes: []*EntryAttribute{
+		{Name: "cn", S: []string{"joe"}},
+		{Name: "title", S: []string{"manager"}},
+	}}
+	expected = []AttributeChange{
+		{Name: "description", Removed: []string{"old"}},
+		{Name: "mail", Removed: []string{"joe@example.com", "j@example.com"}},
+		{Name: "member", Removed: []string{"a", "b", "b", "c"}},
+		{Name: "title", Added: []string{"manager"}, Removed: []string{"engineer"}},
+	}
+	if changes := DiffEntries(old, stringsOnly); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes to string values:\n%+v\nexpected:\n%+v", changes, expected)
+	}
+}
@@ -81,6 +81,7 @@ const (
 	LDAPResultAssertionFailed                    = 122
 	LDAPResultAuthorizationDenied                = 123
 	LDAPResultSyncRefreshRequired                = 4096
+	LDAPResultNoOperation                        = 16654
 
 	ErrorNetwork            = 200
 	ErrorFilterCompile      = 201
@@ -160,6 +161,7 @@ var LDAPResultCodeMap = map[uint16]string{
 	LDAPResultCannotCancel:                       "The identified operation does not support cancellation or the cancel operation cannot be performed",
 	LDAPResultAssertionFailed:                    "An assertion control given in the LDAP operation evaluated to false causing the operation to not be performed",
 	LDAPResultSyncRefreshRequired:                "Refresh Required",
+	LDAPResultNoOperation:                        "No Operation",
 	LDAPResultInvalidResponse:                    "Invalid Response",
 	LDAPResultAmbiguousResponse:                  "Ambiguous Response",
 	LDAPResultTLSNotSupported:                    "Tls Not Supported",
@@ -184,12 +186,78 @@ type Error struct {
 	ResultCode uint16
 	// MatchedDN is the matchedDN returned if any
 	MatchedDN string
+	// Referral contains the referral URIs returned with a LDAPResultReferral result, if any
+	Referral []string
 }
 
 func (e *Error) Error() string {
 	return fmt.Sprintf("LDAP Result Code %d %q: %s", e.ResultCode, LDAPResultCodeMap[e.ResultCode], e.Err.Error())
 }
 
+// Is returns true if target is an *Error with the same result code, so that
+// errors.Is(err, ErrInvalidCredentials) returns true for any LDAPResultInvalidCredentials error
+func (e *Error) Is(target error) bool {
+	targetErr, ok := target.(*Error)
+	return ok && targetErr.ResultCode == e.ResultCode
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NetworkError is the underlying error of the ErrorNetwork errors returned to pending
+// requests when the connection fails, for example when the server closes it while a
+// search is in progress. It can be retrieved with errors.As to decide whether to reconnect.
+type NetworkError struct {
+	// Op is the failed operation, "read" when the connection failed while reading a response
+	Op string
+	// Err is the original error, such as io.EOF or a connection reset
+	Err error
+	// Partial is true if the connection failed after some bytes of a response were read
+	Partial bool
+}
+
+func (e *NetworkError) Error() string {
+	if e.Partial {
+		return fmt.Sprintf("ldap: %s failed after a partial response: %s", e.Op, e.Err)
+	}
+	return fmt.Sprintf("ldap: %s failed: %s", e.Op, e.Err)
+}
+
+// Unwrap returns the original error
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// Errors matching, with errors.Is, any error with the same result code
+var (
+	ErrTimeLimitExceeded        = newResultCodeError(LDAPResultTimeLimitExceeded)
+	ErrSizeLimitExceeded        = newResultCodeError(LDAPResultSizeLimitExceeded)
+	ErrAuthMethodNotSupported   = newResultCodeError(LDAPResultAuthMethodNotSupported)
+	ErrStrongAuthRequired       = newResultCodeError(LDAPResultStrongAuthRequired)
+	ErrReferral                 = newResultCodeError(LDAPResultReferral)
+	ErrAdminLimitExceeded       = newResultCodeError(LDAPResultAdminLimitExceeded)
+	ErrNoSuchAttribute          = newResultCodeError(LDAPResultNoSuchAttribute)
+	ErrNoSuchObject             = newResultCodeError(LDAPResultNoSuchObject)
+	ErrInvalidDNSyntax          = newResultCodeError(LDAPResultInvalidDNSyntax)
+	ErrInvalidCredentials       = newResultCodeError(LDAPResultInvalidCredentials)
+	ErrInsufficientAccessRights = newResultCodeError(LDAPResultInsufficientAccessRights)
+	ErrBusy                     = newResultCodeError(LDAPResultBusy)
+	ErrUnavailable              = newResultCodeError(LDAPResultUnavailable)
+	ErrUnwillingToPerform       = newResultCodeError(LDAPResultUnwillingToPerform)
+	ErrConstraintViolation      = newResultCodeError(LDAPResultConstraintViolation)
+	ErrAttributeOrValueExists   = newResultCodeError(LDAPResultAttributeOrValueExists)
+	ErrObjectClassViolation     = newResultCodeError(LDAPResultObjectClassViolation)
+	ErrNotAllowedOnNonLeaf      = newResultCodeError(LDAPResultNotAllowedOnNonLeaf)
+	ErrEntryAlreadyExists       = newResultCodeError(LDAPResultEntryAlreadyExists)
+	ErrNetwork                  = newResultCodeError(ErrorNetwork)
+)
+
+func newResultCodeError(resultCode uint16) error {
+	return &Error{ResultCode: resultCode, Err: fmt.Errorf("%s", LDAPResultCodeMap[resultCode])}
+}
+
 // GetLDAPError creates an Error out of a BER packet representing a LDAPResult
 // The return is an error object. It can be casted to a Error structure.
 // This function returns nil if resultCode in the LDAPResult sequence is success(0).
@@ -205,11 +273,13 @@ func GetLDAPError(packet *ber.Packet) error {
 		}
 		if response.ClassType == ber.ClassApplication && response.TagType == ber.TypeConstructed && len(response.Children) >= 3 {
 			resultCode := uint16(response.Children[0].Value.(int64))
-			if resultCode == 0 { // No error
+			// noOperation is the success of an operation sent with the No-Op control
+			if resultCode == LDAPResultSuccess || resultCode == LDAPResultNoOperation {
 				return nil
 			}
 			return &Error{ResultCode: resultCode, MatchedDN: response.Children[1].Value.(string),
-				Err: fmt.Errorf("%s", response.Children[2].Value.(string))}
+				Referral: getReferral(packet),
+				Err:      fmt.Errorf("%s", response.Children[2].Value.(string))}
 		}
 	}
 
@@ -234,3 +304,25 @@ func IsErrorWithCode(err error, desiredResultCode uint16) bool {
 
 	return serverError.ResultCode == desiredResultCode
 }
+
+// IsErrorAnyOf returns true if the given error is an LDAP error with any of the given result codes
+func IsErrorAnyOf(err error, codes ...uint16) bool {
+	for _, code := range codes {
+		if IsErrorWithCode(err, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAlreadyExists returns true if the given error is an LDAP error with the
+// LDAPResultEntryAlreadyExists result code
+func IsAlreadyExists(err error) bool {
+	return IsErrorWithCode(err, LDAPResultEntryAlreadyExists)
+}
+
+// IsNoSuchObject returns true if the given error is an LDAP error with the
+// LDAPResultNoSuchObject result code
+func IsNoSuchObject(err error) bool {
+	return IsErrorWithCode(err, LDAPResultNoSuchObject)
+}
@@ -0,0 +1,69 @@
+//go:build go1.13
+// +build go1.13
+
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// TestErrorsIs tests that errors.Is and errors.As work with the errors returned by GetLDAPError.
+func TestErrorsIs(t *testing.T) {
+	err := GetLDAPError(newResultPacket(1, ApplicationBindResponse, LDAPResultInvalidCredentials))
+	wrapped := fmt.Errorf("bind failed: %w", err)
+
+	if !errors.Is(wrapped, ErrInvalidCredentials) {
+		t.Errorf("expected %v to match ErrInvalidCredentials", wrapped)
+	}
+	if errors.Is(wrapped, ErrSizeLimitExceeded) {
+		t.Errorf("expected %v not to match ErrSizeLimitExceeded", wrapped)
+	}
+	var ldapErr *Error
+	if !errors.As(wrapped, &ldapErr) || ldapErr.ResultCode != LDAPResultInvalidCredentials {
+		t.Errorf("expected %v to be an *Error with LDAPResultInvalidCredentials", wrapped)
+	}
+
+	cause := errors.New("connection reset")
+	if err := NewError(ErrorNetwork, cause); !errors.Is(err, cause) || !errors.Is(err, ErrNetwork) {
+		t.Errorf("expected %v to match its underlying error and ErrNetwork", err)
+	}
+}
+
+// TestNetworkError tests that requests pending when the server closes the connection
+// receive a NetworkError telling whether a partial response was read.
+func TestNetworkError(t *testing.T) {
+	for _, partial := range []bool{false, true} {
+		client, server := net.Pipe()
+		conn := NewConn(client, false)
+		conn.Start()
+		go func() {
+			defer server.Close()
+			if _, err := ber.ReadPacket(server); err != nil {
+				return
+			}
+			if partial {
+				response := newSearchResultDonePacket(1, LDAPResultSuccess).Bytes()
+				server.Write(response[:len(response)/2])
+			}
+		}()
+
+		_, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(uid=joe)", nil, nil))
+		var netErr *NetworkError
+		if !errors.Is(err, ErrNetwork) || !errors.As(err, &netErr) {
+			t.Fatalf("expected a NetworkError, got %v", err)
+		}
+		if netErr.Op != "read" || netErr.Partial != partial || netErr.Err == nil {
+			t.Errorf("unexpected error %+v", netErr)
+		}
+		if !partial && !errors.Is(err, io.EOF) {
+			t.Errorf("expected %v to match io.EOF", err)
+		}
+		conn.Close()
+	}
+}
@@ -3,6 +3,7 @@ package ldap
 import (
 	"errors"
 	"net"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -80,6 +81,25 @@ func TestGetLDAPError(t *testing.T) {
 	if ldapError.Err.Error() != diagnosticMessage {
 		t.Errorf("Got incorrect error message in LDAP error; got %v, expected %v", ldapError.Err.Error(), diagnosticMessage)
 	}
+	if ldapError.MatchedDN != "dc=example,dc=org" {
+		t.Errorf("Got incorrect matchedDN in LDAP error; got %v, expected %v", ldapError.MatchedDN, "dc=example,dc=org")
+	}
+	if ldapError.Referral != nil {
+		t.Errorf("Got unexpected referral in LDAP error: %v", ldapError.Referral)
+	}
+}
+
+// TestGetLDAPErrorReferral tests parsing of the referral URIs of a result.
+func TestGetLDAPErrorReferral(t *testing.T) {
+	uris := []string{"ldap://dc1.example.com/dc=example,dc=com", "ldap://dc2.example.com/dc=example,dc=com"}
+	err := GetLDAPError(newReferralResultPacket(1, ApplicationModifyResponse, uris...))
+	if !IsErrorWithCode(err, LDAPResultReferral) {
+		t.Fatalf("Expected a LDAPResultReferral error, got: %v", err)
+	}
+	ldapError := err.(*Error)
+	if !reflect.DeepEqual(ldapError.Referral, uris) {
+		t.Errorf("Got incorrect referral in LDAP error; got %v, expected %v", ldapError.Referral, uris)
+	}
 }
 
 // TestGetLDAPErrorSuccess tests parsing of a result with no error (resultCode == 0).
@@ -97,6 +117,40 @@ func TestGetLDAPErrorSuccess(t *testing.T) {
 	}
 }
 
+// TestIsErrorAnyOf tests the result code predicates on the errors of update operations.
+func TestIsErrorAnyOf(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		switch request.Children[1].Tag {
+		case ApplicationAddRequest:
+			return []*ber.Packet{newResultPacket(messageID, ApplicationAddResponse, LDAPResultEntryAlreadyExists)}
+		case ApplicationDelRequest:
+			return []*ber.Packet{newResultPacket(messageID, ApplicationDelResponse, LDAPResultNoSuchObject)}
+		}
+		// answer with the wrong response type
+		return []*ber.Packet{newResultPacket(messageID, ApplicationBindResponse, LDAPResultSuccess)}
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		err := conn.Add(NewAddRequest("cn=a,dc=example,dc=com", nil))
+		if !IsAlreadyExists(err) || IsNoSuchObject(err) || !IsErrorAnyOf(err, LDAPResultNoSuchObject, LDAPResultEntryAlreadyExists) {
+			t.Errorf("unexpected predicate results for %v", err)
+		}
+		err = conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil))
+		if !IsNoSuchObject(err) || IsAlreadyExists(err) || IsErrorAnyOf(err, LDAPResultEntryAlreadyExists) {
+			t.Errorf("unexpected predicate results for %v", err)
+		}
+		err = conn.Modify(NewModifyRequest("cn=a,dc=example,dc=com", nil))
+		if !IsErrorWithCode(err, ErrorUnexpectedResponse) {
+			t.Errorf("expected ErrorUnexpectedResponse, got %v", err)
+		}
+	})
+	if IsErrorAnyOf(nil, LDAPResultSuccess) || IsErrorAnyOf(errors.New("error")) {
+		t.Error("expected IsErrorAnyOf to be false")
+	}
+}
+
 // signalErrConn is a helpful type used with TestConnReadErr. It implements the
 // net.Conn interface to be used as a connection for the test. Most methods are
 // no-ops but the Read() method blocks until it receives a signal which it
@@ -0,0 +1,95 @@
+// This file contains the generic extended operation as specified in rfc 4511 section 4.12
+//
+// https://tools.ietf.org/html/rfc4511#section-4.12
+//
+
+package ldap
+
+import (
+	"context"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// ExtendedRequest is the request of an extended operation, such as a vendor specific one,
+// whose request and response values are encoded and decoded by the caller
+type ExtendedRequest struct {
+	// Name is the OID of the extended operation
+	Name string
+	// Value is the encoded request value, which is omitted if nil
+	Value []byte
+	// Controls are optional controls to send with the request
+	Controls []Control
+}
+
+// ExtendedResponse holds the server response to an ExtendedRequest
+type ExtendedResponse struct {
+	// Name is the OID of the response, which most operations omit
+	Name string
+	// Value is the raw response value, nil if the server returned none
+	Value []byte
+	// Controls are the returned controls
+	Controls []Control
+}
+
+// NewExtendedRequest returns the request of the extended operation with the given OID and
+// encoded value
+func NewExtendedRequest(name string, value []byte) *ExtendedRequest {
+	return &ExtendedRequest{
+		Name:  name,
+		Value: value,
+	}
+}
+
+func (req *ExtendedRequest) appendTo(envelope *ber.Packet) error {
+	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedRequest, nil, "Extended Request")
+	pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, req.Name, "Extended Request Name"))
+	if req.Value != nil {
+		pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 1, string(req.Value), "Extended Request Value"))
+	}
+	envelope.AppendChild(pkt)
+	if len(req.Controls) > 0 {
+		envelope.AppendChild(EncodeControls(req.Controls))
+	}
+
+	return nil
+}
+
+// Extended performs the given extended operation and returns the name and raw value of
+// the response. When the server returns an error, the response is returned along with it.
+func (l *Conn) Extended(extendedRequest *ExtendedRequest) (*ExtendedResponse, error) {
+	msgCtx, err := l.doRequest(context.Background(), extendedRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer l.finishMessage(msgCtx)
+
+	packet, err := l.readPacket(context.Background(), msgCtx)
+	if err != nil {
+		return nil, err
+	}
+	if packet.Children[1].Tag != ApplicationExtendedResponse {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
+	}
+
+	response := &ExtendedResponse{}
+	for _, child := range packet.Children[1].Children {
+		if child.ClassType != ber.ClassContext {
+			continue
+		}
+		switch child.Tag {
+		case 10:
+			response.Name = string(child.Data.Bytes())
+		case 11:
+			response.Value = append([]byte{}, child.Data.Bytes()...)
+		}
+	}
+	if len(packet.Children) == 3 {
+		if response.Controls, err = DecodeControls(packet.Children[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, GetLDAPError(packet)
+}
@@ -0,0 +1,71 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// newNamedExtendedResponsePacket is like newExtendedResponsePacket, with a response name
+// and a control
+func newNamedExtendedResponsePacket(messageID int64, resultCode uint16, name string, value []byte) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	if name != "" {
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 10, name, "responseName"))
+	}
+	if value != nil {
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 11, string(value), "responseValue"))
+	}
+	packet.AppendChild(response)
+	packet.AppendChild(EncodeControls([]Control{NewControlManageDsaIT(false)}))
+	return packet
+}
+
+func TestExtended(t *testing.T) {
+	const oid = "1.3.6.1.4.1.99999.1"
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		operation := request.Children[1]
+		if operation.Tag != ApplicationExtendedRequest || operation.Children[0].Data.String() != oid {
+			t.Errorf("unexpected request %v", operation)
+			return nil
+		}
+		if len(operation.Children) < 2 {
+			return []*ber.Packet{newNamedExtendedResponsePacket(messageID, LDAPResultProtocolError, "", nil)}
+		}
+		// echo the request value, reversed
+		value := operation.Children[1].Data.Bytes()
+		reversed := make([]byte, len(value))
+		for i, b := range value {
+			reversed[len(value)-1-i] = b
+		}
+		return []*ber.Packet{newNamedExtendedResponsePacket(messageID, LDAPResultSuccess, oid+".1", reversed)}
+	})
+	defer conn.Close()
+
+	response, err := conn.Extended(NewExtendedRequest(oid, []byte{0x30, 0x00, 0xff}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if response.Name != oid+".1" || !bytes.Equal(response.Value, []byte{0xff, 0x00, 0x30}) {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if len(response.Controls) != 1 || response.Controls[0].GetControlType() != ControlTypeManageDsaIT {
+		t.Errorf("unexpected response controls %v", response.Controls)
+	}
+
+	// the response is returned along with an error
+	response, err = conn.Extended(NewExtendedRequest(oid, nil))
+	if !IsErrorWithCode(err, LDAPResultProtocolError) {
+		t.Errorf("expected LDAPResultProtocolError, got %v", err)
+	}
+	if response == nil || response.Value != nil || len(response.Controls) != 1 {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
@@ -87,6 +87,61 @@ func CompileFilter(filter string) (*ber.Packet, error) {
 	return packet, nil
 }
 
+// SubstituteFilterValues returns a copy of a compiled filter in which the assertion values
+// found as keys of the values map are replaced by the associated values.
+//
+// This allows compiling a filter such as "(&(objectClass=person)(uid=USER))" once, and
+// deriving the filter for a given user without parsing it again. The substituted values
+// are used as is and need not be escaped, as they are not parsed. Attribute descriptions
+// and matching rules are never substituted.
+func SubstituteFilterValues(packet *ber.Packet, values map[string]string) *ber.Packet {
+	clone := ber.Encode(packet.ClassType, packet.TagType, packet.Tag, nil, packet.Description)
+	if packet.TagType == ber.TypePrimitive {
+		clone.Value = packet.Value
+		clone.Data.Write(packet.Data.Bytes())
+		return clone
+	}
+
+	for i, child := range packet.Children {
+		switch {
+		case packet.ClassType != ber.ClassContext:
+			child = SubstituteFilterValues(child, values)
+		case packet.Tag == FilterAnd || packet.Tag == FilterOr || packet.Tag == FilterNot:
+			child = SubstituteFilterValues(child, values)
+		case packet.Tag == FilterEqualityMatch || packet.Tag == FilterGreaterOrEqual || packet.Tag == FilterLessOrEqual || packet.Tag == FilterApproxMatch:
+			if i == 1 {
+				child = substituteFilterValue(child, values)
+			} else {
+				child = SubstituteFilterValues(child, values)
+			}
+		case packet.Tag == FilterSubstrings:
+			if i == 1 {
+				substrings := ber.Encode(child.ClassType, child.TagType, child.Tag, nil, child.Description)
+				for _, substring := range child.Children {
+					substrings.AppendChild(substituteFilterValue(substring, values))
+				}
+				child = substrings
+			} else {
+				child = SubstituteFilterValues(child, values)
+			}
+		case packet.Tag == FilterExtensibleMatch && child.Tag == MatchingRuleAssertionMatchValue:
+			child = substituteFilterValue(child, values)
+		default:
+			child = SubstituteFilterValues(child, values)
+		}
+		clone.AppendChild(child)
+	}
+	return clone
+}
+
+func substituteFilterValue(packet *ber.Packet, values map[string]string) *ber.Packet {
+	value, ok := values[string(packet.Data.Bytes())]
+	if !ok {
+		return SubstituteFilterValues(packet, values)
+	}
+	return ber.NewString(packet.ClassType, packet.TagType, packet.Tag, value, packet.Description)
+}
+
 // DecompileFilter converts a packet representation of a filter into a string representation
 func DecompileFilter(packet *ber.Packet) (ret string, err error) {
 	defer func() {
@@ -0,0 +1,131 @@
+package ldap
+
+import (
+	"bytes"
+)
+
+// Filter is a search filter built programmatically. Its String method returns the
+// string representation of the filter, suitable for SearchRequest.Filter.
+//
+// Assertion values given to the NewFilterXXX functions are escaped with EscapeFilter,
+// so user input cannot alter the structure of the filter. Attribute descriptions are
+// used as is.
+type Filter interface {
+	String() string
+}
+
+type filterSet struct {
+	operator string
+	filters  []Filter
+}
+
+func (f *filterSet) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("(")
+	buffer.WriteString(f.operator)
+	for _, filter := range f.filters {
+		buffer.WriteString(filter.String())
+	}
+	buffer.WriteString(")")
+	return buffer.String()
+}
+
+type filterNot struct {
+	filter Filter
+}
+
+func (f *filterNot) String() string {
+	return "(!" + f.filter.String() + ")"
+}
+
+type filterAssertion struct {
+	attribute string
+	operator  string
+	value     string
+}
+
+func (f *filterAssertion) String() string {
+	return "(" + f.attribute + f.operator + EscapeFilter(f.value) + ")"
+}
+
+type filterPresent struct {
+	attribute string
+}
+
+func (f *filterPresent) String() string {
+	return "(" + f.attribute + "=*)"
+}
+
+type filterSubstrings struct {
+	attribute string
+	initial   string
+	any       []string
+	final     string
+}
+
+func (f *filterSubstrings) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("(")
+	buffer.WriteString(f.attribute)
+	buffer.WriteString("=")
+	buffer.WriteString(EscapeFilter(f.initial))
+	buffer.WriteString("*")
+	for _, value := range f.any {
+		if value == "" {
+			continue
+		}
+		buffer.WriteString(EscapeFilter(value))
+		buffer.WriteString("*")
+	}
+	buffer.WriteString(EscapeFilter(f.final))
+	buffer.WriteString(")")
+	return buffer.String()
+}
+
+// NewFilterAnd returns a filter matching entries matched by all the given filters
+func NewFilterAnd(filters ...Filter) Filter {
+	return &filterSet{operator: "&", filters: filters}
+}
+
+// NewFilterOr returns a filter matching entries matched by any of the given filters
+func NewFilterOr(filters ...Filter) Filter {
+	return &filterSet{operator: "|", filters: filters}
+}
+
+// NewFilterNot returns a filter matching entries not matched by the given filter
+func NewFilterNot(filter Filter) Filter {
+	return &filterNot{filter: filter}
+}
+
+// NewFilterEqualityMatch returns a filter matching entries where the attribute equals the value
+func NewFilterEqualityMatch(attribute, value string) Filter {
+	return &filterAssertion{attribute: attribute, operator: "=", value: value}
+}
+
+// NewFilterGreaterOrEqual returns a filter matching entries where the attribute is greater than or equal to the value
+func NewFilterGreaterOrEqual(attribute, value string) Filter {
+	return &filterAssertion{attribute: attribute, operator: ">=", value: value}
+}
+
+// NewFilterLessOrEqual returns a filter matching entries where the attribute is less than or equal to the value
+func NewFilterLessOrEqual(attribute, value string) Filter {
+	return &filterAssertion{attribute: attribute, operator: "<=", value: value}
+}
+
+// NewFilterApproxMatch returns a filter matching entries where the attribute approximately matches the value
+func NewFilterApproxMatch(attribute, value string) Filter {
+	return &filterAssertion{attribute: attribute, operator: "~=", value: value}
+}
+
+// NewFilterPresent returns a filter matching entries having the attribute
+func NewFilterPresent(attribute string) Filter {
+	return &filterPresent{attribute: attribute}
+}
+
+// NewFilterSubstrings returns a filter matching entries where the attribute starts with initial,
+// contains the any values in order, and ends with final. Empty components are omitted: when all
+// of them are empty, the filter is equivalent to NewFilterPresent. Each component is escaped
+// separately, so that a "*" in a component matches a literal "*" instead of separating substrings.
+func NewFilterSubstrings(attribute, initial string, any []string, final string) Filter {
+	return &filterSubstrings{attribute: attribute, initial: initial, any: any, final: final}
+}
@@ -252,3 +252,111 @@ func BenchmarkFilterDecompile(b *testing.B) {
 		DecompileFilter(filters[i%maxIdx])
 	}
 }
+
+func TestFilterBuilder(t *testing.T) {
+	testcases := []struct {
+		filter   Filter
+		expected string
+	}{
+		{NewFilterEqualityMatch("uid", "jdoe"), "(uid=jdoe)"},
+		{NewFilterEqualityMatch("uid", "a)(uid=*"), `(uid=a\29\28uid=\2a)`},
+		{NewFilterPresent("mail"), "(mail=*)"},
+		{NewFilterGreaterOrEqual("uidNumber", "1000"), "(uidNumber>=1000)"},
+		{NewFilterLessOrEqual("uidNumber", "2000"), "(uidNumber<=2000)"},
+		{NewFilterApproxMatch("sn", "Miler"), "(sn~=Miler)"},
+		{NewFilterNot(NewFilterEqualityMatch("sn", "Miller")), "(!(sn=Miller))"},
+		{NewFilterSubstrings("cn", "Mi", []string{"l", "", "e"}, "r"), "(cn=Mi*l*e*r)"},
+		{NewFilterSubstrings("cn", "", []string{"*"}, ""), `(cn=*\2a*)`},
+		{
+			NewFilterAnd(
+				NewFilterEqualityMatch("objectClass", "person"),
+				NewFilterOr(NewFilterEqualityMatch("sn", "Miller"), NewFilterEqualityMatch("givenName", "Bob")),
+			),
+			"(&(objectClass=person)(|(sn=Miller)(givenName=Bob)))",
+		},
+	}
+
+	for _, tc := range testcases {
+		if actual := tc.filter.String(); actual != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, actual)
+			continue
+		}
+		if _, err := CompileFilter(tc.filter.String()); err != nil {
+			t.Errorf("unable to compile %q: %s", tc.expected, err)
+		}
+	}
+}
+
+func TestFilterSubstringsEscaping(t *testing.T) {
+	term := "a*b)c"
+	testcases := []struct {
+		filter   Filter
+		expected string
+		tags     []ber.Tag
+	}{
+		{NewFilterSubstrings("cn", "", []string{term}, ""), `(cn=*a\2ab\29c*)`, []ber.Tag{FilterSubstringsAny}},
+		{NewFilterSubstrings("cn", term, nil, ""), `(cn=a\2ab\29c*)`, []ber.Tag{FilterSubstringsInitial}},
+		{NewFilterSubstrings("cn", "", nil, term), `(cn=*a\2ab\29c)`, []ber.Tag{FilterSubstringsFinal}},
+		{
+			NewFilterSubstrings("cn", term, []string{term}, term),
+			`(cn=a\2ab\29c*a\2ab\29c*a\2ab\29c)`,
+			[]ber.Tag{FilterSubstringsInitial, FilterSubstringsAny, FilterSubstringsFinal},
+		},
+	}
+
+	for _, tc := range testcases {
+		if actual := tc.filter.String(); actual != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, actual)
+			continue
+		}
+		packet, err := CompileFilter(tc.filter.String())
+		if err != nil {
+			t.Errorf("unable to compile %q: %s", tc.expected, err)
+			continue
+		}
+		// the escaped characters are literal characters of each substring
+		substrings := packet.Children[1].Children
+		if len(substrings) != len(tc.tags) {
+			t.Errorf("%q: expected %d substrings, got %d", tc.expected, len(tc.tags), len(substrings))
+			continue
+		}
+		for i, substring := range substrings {
+			if substring.Tag != tc.tags[i] || substring.Data.String() != term {
+				t.Errorf("%q: unexpected substring %d with tag %d: %q", tc.expected, i, substring.Tag, substring.Data.String())
+			}
+		}
+	}
+}
+
+func TestSubstituteFilterValues(t *testing.T) {
+	testcases := []struct {
+		filter   string
+		values   map[string]string
+		expected string
+	}{
+		{"(&(objectClass=person)(uid=USER))", map[string]string{"USER": "a)(uid=*"}, `(&(objectClass=person)(uid=a\29\28uid=\2a))`},
+		{"(|(cn=USER*)(!(sn>=USER)))", map[string]string{"USER": "jdoe"}, "(|(cn=jdoe*)(!(sn>=jdoe)))"},
+		{"(USER=USER)", map[string]string{"USER": "x"}, "(USER=x)"},
+		{"(cn:caseExactMatch:=USER)", map[string]string{"USER": "x"}, "(cn:caseExactMatch:=x)"},
+		{"(uid=*)", map[string]string{"uid": "x"}, "(uid=*)"},
+	}
+
+	for _, tc := range testcases {
+		packet, err := CompileFilter(tc.filter)
+		if err != nil {
+			t.Errorf("unable to compile %q: %s", tc.filter, err)
+			continue
+		}
+		substituted, err := DecompileFilter(SubstituteFilterValues(packet, tc.values))
+		if err != nil {
+			t.Errorf("unable to decompile %q: %s", tc.filter, err)
+			continue
+		}
+		if substituted != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, substituted)
+		}
+		if original, _ := DecompileFilter(packet); original != tc.filter {
+			t.Errorf("original filter was modified: %q", original)
+		}
+	}
+}
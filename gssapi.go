@@ -0,0 +1,98 @@
+// This file contains the GSSAPI SASL mechanism as specified in rfc 4752
+//
+// https://tools.ietf.org/html/rfc4752
+//
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SASLMechanismGSSAPI is the name of the GSSAPI SASL mechanism
+const SASLMechanismGSSAPI = "GSSAPI"
+
+// GSSAPIClient establishes the GSS-API security context of a GSSAPI bind, typically with
+// Kerberos. It allows a Kerberos implementation such as gokrb5 to be plugged in without
+// this package depending on it.
+type GSSAPIClient interface {
+	// InitSecContext processes the token received from the server, nil on the first call,
+	// and returns the token to send to the server. needContinue is false once the security
+	// context is established.
+	InitSecContext(target string, token []byte) (outputToken []byte, needContinue bool, err error)
+	// NegotiateSaslAuth unwraps the security layer token sent by the server once the security
+	// context is established, and returns the wrapped response selecting the security layer
+	// and authorization identity, as described in rfc 4752 section 3.1.
+	NegotiateSaslAuth(token []byte, authzid string) ([]byte, error)
+	// DeleteSecContext releases the security context
+	DeleteSecContext() error
+}
+
+// GSSAPIBind performs a SASL/GSSAPI bind using client to establish the security context with
+// the given service principal, such as "ldap/dc1.example.com". authzid may be empty to use
+// the identity associated with the credentials of client.
+//
+// No security layer is installed on the connection: use TLS to protect it. The exchange is
+// restarted with a new security context as specified by WithRetry.
+func (l *Conn) GSSAPIBind(client GSSAPIClient, servicePrincipal, authzid string) error {
+	return l.GSSAPIBindWithMechanism(client, SASLMechanismGSSAPI, servicePrincipal, authzid)
+}
+
+// GSSAPIBindWithMechanism performs a Kerberos bind as GSSAPIBind, with the SASL mechanism
+// SASLMechanismGSSAPI or SASLMechanismGSSSPNEGO. With GSS-SPNEGO, required by some Active
+// Directory servers, the Kerberos tokens of client are wrapped in SPNEGO tokens, and
+// authzid is ignored since there is no security layer negotiation.
+func (l *Conn) GSSAPIBindWithMechanism(client GSSAPIClient, mechanism, servicePrincipal, authzid string) (err error) {
+	defer client.DeleteSecContext()
+	defer l.recordBind(&err, func(conn *Conn) error {
+		return conn.GSSAPIBindWithMechanism(client, mechanism, servicePrincipal, authzid)
+	})
+
+	attempts := 0
+	return l.withRetry(context.Background(), func() error {
+		if attempts++; attempts > 1 {
+			// the security context of the failed attempt cannot be continued
+			if err := client.DeleteSecContext(); err != nil {
+				return err
+			}
+		}
+		return l.gssapiBind(client, mechanism, servicePrincipal, authzid)
+	})
+}
+
+func (l *Conn) gssapiBind(client GSSAPIClient, mechanism, servicePrincipal, authzid string) error {
+	switch mechanism {
+	case SASLMechanismGSSAPI:
+	case SASLMechanismGSSSPNEGO:
+		return l.gssSPNEGOBind(client, servicePrincipal)
+	default:
+		return NewError(LDAPResultAuthMethodNotSupported, fmt.Errorf("ldap: unsupported Kerberos SASL mechanism %q", mechanism))
+	}
+
+	var token []byte
+	for {
+		outputToken, needContinue, err := client.InitSecContext(servicePrincipal, token)
+		if err != nil {
+			return err
+		}
+		token, err = l.SASLBind(SASLMechanismGSSAPI, outputToken)
+		if !IsErrorWithCode(err, LDAPResultSaslBindInProgress) {
+			if err == nil {
+				err = NewError(ErrorUnexpectedResponse, errors.New("ldap: GSSAPI exchange ended before the security layer negotiation"))
+			}
+			return err
+		}
+		if !needContinue {
+			break
+		}
+	}
+
+	response, err := client.NegotiateSaslAuth(token, authzid)
+	if err != nil {
+		return err
+	}
+	_, err = l.SASLBind(SASLMechanismGSSAPI, response)
+	return err
+}
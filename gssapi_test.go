@@ -0,0 +1,124 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// fakeGSSAPIClient establishes a context in two legs, as Kerberos with mutual authentication
+type fakeGSSAPIClient struct {
+	t       *testing.T
+	legs    int
+	deleted bool
+}
+
+func (c *fakeGSSAPIClient) InitSecContext(target string, token []byte) ([]byte, bool, error) {
+	if target != "ldap/dc1.example.com" {
+		c.t.Errorf("unexpected target %q", target)
+	}
+	if token == nil {
+		// a new context is established
+		c.legs = 0
+	}
+	c.legs++
+	switch c.legs {
+	case 1:
+		if token != nil {
+			c.t.Errorf("unexpected initial token %q", token)
+		}
+		return []byte("AP-REQ"), true, nil
+	default:
+		if string(token) != "AP-REP" {
+			c.t.Errorf("unexpected token %q", token)
+		}
+		return nil, false, nil
+	}
+}
+
+func (c *fakeGSSAPIClient) NegotiateSaslAuth(token []byte, authzid string) ([]byte, error) {
+	if string(token) != "wrapped-layers" {
+		c.t.Errorf("unexpected security layer token %q", token)
+	}
+	return []byte("wrapped-" + authzid), nil
+}
+
+func (c *fakeGSSAPIClient) DeleteSecContext() error {
+	c.deleted = true
+	return nil
+}
+
+func newSASLBindResponsePacket(messageID int64, resultCode uint16, serverSaslCreds []byte) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindResponse, nil, "Bind Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	if serverSaslCreds != nil {
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 7, string(serverSaslCreds), "serverSaslCreds"))
+	}
+	packet.AppendChild(response)
+	return packet
+}
+
+func TestGSSAPIBind(t *testing.T) {
+	var received [][]byte
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		saslCreds := request.Children[1].Children[2]
+		if mechanism := saslCreds.Children[0].Value.(string); mechanism != SASLMechanismGSSAPI {
+			return []*ber.Packet{newSASLBindResponsePacket(messageID, LDAPResultAuthMethodNotSupported, nil)}
+		}
+		credentials := saslCreds.Children[1].Data.Bytes()
+		received = append(received, credentials)
+		switch len(received) {
+		case 1:
+			return []*ber.Packet{newSASLBindResponsePacket(messageID, LDAPResultSaslBindInProgress, []byte("AP-REP"))}
+		case 2:
+			return []*ber.Packet{newSASLBindResponsePacket(messageID, LDAPResultSaslBindInProgress, []byte("wrapped-layers"))}
+		default:
+			return []*ber.Packet{newSASLBindResponsePacket(messageID, LDAPResultSuccess, nil)}
+		}
+	})
+	defer conn.Close()
+
+	client := &fakeGSSAPIClient{t: t}
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.GSSAPIBind(client, "ldap/dc1.example.com", "u:joe"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	expected := [][]byte{[]byte("AP-REQ"), {}, []byte("wrapped-u:joe")}
+	if len(received) != len(expected) {
+		t.Fatalf("expected %d bind requests, got %d", len(expected), len(received))
+	}
+	for i := range expected {
+		if !bytes.Equal(received[i], expected[i]) {
+			t.Errorf("bind request %d: got credentials %q, expected %q", i, received[i], expected[i])
+		}
+	}
+	if !client.deleted {
+		t.Error("expected the security context to be deleted")
+	}
+}
+
+func TestGSSAPIBindFailure(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		return []*ber.Packet{newSASLBindResponsePacket(request.Children[0].Value.(int64), LDAPResultInvalidCredentials, nil)}
+	})
+	defer conn.Close()
+
+	client := &fakeGSSAPIClient{t: t}
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.GSSAPIBind(client, "ldap/dc1.example.com", ""); !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+			t.Errorf("expected LDAPResultInvalidCredentials, got %v", err)
+		}
+	})
+	if !client.deleted {
+		t.Error("expected the security context to be deleted")
+	}
+}
@@ -30,6 +30,7 @@ const (
 	ApplicationSearchResultReference = 19
 	ApplicationExtendedRequest       = 23
 	ApplicationExtendedResponse      = 24
+	ApplicationIntermediateResponse  = 25
 )
 
 // ApplicationMap contains human readable descriptions of LDAP Application Codes
@@ -54,6 +55,7 @@ var ApplicationMap = map[uint8]string{
 	ApplicationSearchResultReference: "Search Result Reference",
 	ApplicationExtendedRequest:       "Extended Request",
 	ApplicationExtendedResponse:      "Extended Response",
+	ApplicationIntermediateResponse:  "Intermediate Response",
 }
 
 // Ldap Behera Password Policy Draft 10 (https://tools.ietf.org/html/draft-behera-ldap-password-policy-10)
@@ -82,6 +84,20 @@ var BeheraPasswordPolicyErrorMap = map[int8]string{
 	BeheraPasswordInHistory:           "New password is in list of old passwords",
 }
 
+// BeheraPasswordPolicyError is an error code of the Behera Password Policy response control
+type BeheraPasswordPolicyError int8
+
+// String returns a human readable description of the error code, or "" if there is no error (-1)
+func (e BeheraPasswordPolicyError) String() string {
+	if e < 0 {
+		return ""
+	}
+	if description, ok := BeheraPasswordPolicyErrorMap[int8(e)]; ok {
+		return description
+	}
+	return fmt.Sprintf("Unknown password policy error %d", int8(e))
+}
+
 // Adds descriptions to an LDAP Response packet for debugging
 func addLDAPDescriptions(packet *ber.Packet) (err error) {
 	defer func() {
@@ -141,6 +157,7 @@ func addLDAPDescriptions(packet *ber.Packet) (err error) {
 	case ApplicationExtendedRequest:
 		err = addRequestDescriptions(packet)
 	case ApplicationExtendedResponse:
+	case ApplicationIntermediateResponse:
 	}
 
 	return err
@@ -223,12 +240,8 @@ func addControlDescriptions(packet *ber.Packet) error {
 				if child.Tag == 0 {
 					//Warning
 					warningPacket := child.Children[0]
-					packet, err := ber.DecodePacketErr(warningPacket.Data.Bytes())
-					if err != nil {
-						return fmt.Errorf("failed to decode data bytes: %s", err)
-					}
-					val, ok := packet.Value.(int64)
-					if ok {
+					val, err := ber.ParseInt64(warningPacket.Data.Bytes())
+					if err == nil {
 						if warningPacket.Tag == 0 {
 							//timeBeforeExpiration
 							value.Description += " (TimeBeforeExpiration)"
@@ -241,12 +254,8 @@ func addControlDescriptions(packet *ber.Packet) error {
 					}
 				} else if child.Tag == 1 {
 					// Error
-					packet, err := ber.DecodePacketErr(child.Data.Bytes())
+					val, err := ber.ParseInt64(child.Data.Bytes())
 					if err != nil {
-						return fmt.Errorf("failed to decode data bytes: %s", err)
-					}
-					val, ok := packet.Value.(int8)
-					if !ok {
 						val = -1
 					}
 					child.Description = "Error"
@@ -338,3 +347,17 @@ func EscapeFilter(filter string) string {
 	}
 	return string(buf)
 }
+
+// EscapeFilterBytes escapes every byte of the given value as \HH, as needed for the binary
+// values of filters such as the objectGUID and objectSid attributes of Active Directory,
+// e.g. "(objectGUID=" + EscapeFilterBytes(guid) + ")". To escape only the special
+// characters of a binary value, use EscapeFilter(string(value)).
+func EscapeFilterBytes(value []byte) string {
+	buf := make([]byte, len(value)*3)
+	for i, c := range value {
+		buf[i*3+0] = '\\'
+		buf[i*3+1] = hex[c>>4]
+		buf[i*3+2] = hex[c&0xf]
+	}
+	return string(buf)
+}
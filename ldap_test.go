@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"testing"
@@ -275,6 +276,27 @@ func TestEscapeFilter(t *testing.T) {
 	}
 }
 
+func TestEscapeFilterBytes(t *testing.T) {
+	if got, want := EscapeFilterBytes([]byte{0x00, 'a', 0x2a, 0xff}), `\00\61\2a\ff`; got != want {
+		t.Errorf("Got %s, expected %s", got, want)
+	}
+	if got := EscapeFilterBytes(nil); got != "" {
+		t.Errorf("Got %q for no byte", got)
+	}
+
+	guid, err := ParseGUID("{a0ae5b2f-5c4e-4c5f-9d4b-2b1a3c9e8f01}")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	packet, err := CompileFilter("(objectGUID=" + EscapeFilterBytes(guid) + ")")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if value := packet.Children[1].Data.Bytes(); !bytes.Equal(value, guid) {
+		t.Errorf("unexpected assertion value %x, expected %x", value, guid)
+	}
+}
+
 func TestCompare(t *testing.T) {
 	fmt.Printf("TestCompare: starting...\n")
 	l, err := Dial("tcp", fmt.Sprintf("%s:%d", ldapServer, ldapPort))
@@ -0,0 +1,501 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// ldifLineLength is the maximum length of the lines written by MarshalLDIF, longer lines
+// are folded
+const ldifLineLength = 76
+
+// MarshalLDIF writes the entries to w in the LDIF format described in rfc2849, as in:
+//
+//	version: 1
+//
+//	dn: uid=joe,dc=example,dc=com
+//	uid: joe
+//	description:: IGxlYWRpbmcgc3BhY2U=
+//
+// The raw values of the attributes are written, and the values which are not safe strings,
+// such as values with control characters, non-ASCII characters, a leading space or colon,
+// are base64-encoded. The lines longer than 76 columns are folded.
+func MarshalLDIF(entries []*Entry, w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("version: 1\n")
+	for _, entry := range entries {
+		buf.WriteByte('\n')
+		writeLDIFLine(&buf, "dn", []byte(entry.DN))
+		for _, attr := range entry.Attributes {
+			if len(attr.ByteValues) == len(attr.S) {
+				for _, value := range attr.ByteValues {
+					writeLDIFLine(&buf, attr.Name, value)
+				}
+			} else {
+				for _, value := range attr.S {
+					writeLDIFLine(&buf, attr.Name, []byte(value))
+				}
+			}
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		buf.Reset()
+	}
+	if buf.Len() > 0 {
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+	return nil
+}
+
+// writeLDIFLine writes the "name: value" line of an attribute value, base64-encoded if it
+// is not a safe string, and folded if it is too long
+func writeLDIFLine(buf *bytes.Buffer, name string, value []byte) {
+	var line string
+	if isLDIFSafeString(value) {
+		line = name + ": " + string(value)
+	} else {
+		line = name + ":: " + base64.StdEncoding.EncodeToString(value)
+	}
+
+	// continuation lines start with a space
+	width := ldifLineLength
+	for len(line) > width {
+		buf.WriteString(line[:width])
+		buf.WriteString("\n ")
+		line = line[width:]
+		width = ldifLineLength - 1
+	}
+	buf.WriteString(line)
+	buf.WriteByte('\n')
+}
+
+// isLDIFSafeString returns true if value is a SAFE-STRING (rfc2849) without control
+// characters, which can be written without base64 encoding. Values ending with a space are
+// base64-encoded too, since the trailing spaces of a line may be lost.
+func isLDIFSafeString(value []byte) bool {
+	if len(value) == 0 {
+		return true
+	}
+	switch value[0] {
+	case ' ', ':', '<':
+		return false
+	}
+	if value[len(value)-1] == ' ' {
+		return false
+	}
+	for _, c := range value {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// LDIF change types of the ChangeType field of LDIFEntry
+const (
+	LDIFChangeTypeAdd    = "add"
+	LDIFChangeTypeDelete = "delete"
+	LDIFChangeTypeModify = "modify"
+	LDIFChangeTypeModRDN = "modrdn"
+)
+
+// LDIF holds the records of an LDIF file parsed by ParseLDIF
+type LDIF struct {
+	// Version is the version given by the "version:" line, 0 if there is none
+	Version int
+	// Entries are the records of the file, in order
+	Entries []*LDIFEntry
+}
+
+// LDIFEntry is a record of an LDIF file: an entry, or a change record
+type LDIFEntry struct {
+	// DN is the DN of the entry
+	DN string
+	// ChangeType is empty for an entry, or one of the LDIFChangeTypeXXX constants for a
+	// change record. The "moddn" change type is returned as LDIFChangeTypeModRDN.
+	ChangeType string
+	// Controls are the controls of a change record
+	Controls []Control
+	// Attributes are the attributes of an entry or of an add record
+	Attributes []Attribute
+	// Changes are the changes of a modify record
+	Changes []Change
+	// NewRDN, DeleteOldRDN and NewSuperior are the fields of a modrdn record
+	NewRDN       string
+	DeleteOldRDN bool
+	NewSuperior  string
+}
+
+// ToAddRequest returns the request adding the entry, or performing the add record
+func (e *LDIFEntry) ToAddRequest() (*AddRequest, error) {
+	if e.ChangeType != "" && e.ChangeType != LDIFChangeTypeAdd {
+		return nil, fmt.Errorf("ldap: cannot make an add request from a %s LDIF record", e.ChangeType)
+	}
+	req := NewAddRequest(e.DN, e.Controls)
+	req.Attributes = append(req.Attributes, e.Attributes...)
+	return req, nil
+}
+
+// ToModifyRequest returns the request performing the modify record
+func (e *LDIFEntry) ToModifyRequest() (*ModifyRequest, error) {
+	if e.ChangeType != LDIFChangeTypeModify {
+		return nil, fmt.Errorf("ldap: cannot make a modify request from a %s LDIF record", e.changeTypeName())
+	}
+	req := NewModifyRequest(e.DN, e.Controls)
+	req.Changes = append(req.Changes, e.Changes...)
+	return req, nil
+}
+
+// ToDelRequest returns the request performing the delete record
+func (e *LDIFEntry) ToDelRequest() (*DelRequest, error) {
+	if e.ChangeType != LDIFChangeTypeDelete {
+		return nil, fmt.Errorf("ldap: cannot make a delete request from a %s LDIF record", e.changeTypeName())
+	}
+	return NewDelRequest(e.DN, e.Controls), nil
+}
+
+// ToModifyDNRequest returns the request performing the modrdn record
+func (e *LDIFEntry) ToModifyDNRequest() (*ModifyDNRequest, error) {
+	if e.ChangeType != LDIFChangeTypeModRDN {
+		return nil, fmt.Errorf("ldap: cannot make a modify DN request from a %s LDIF record", e.changeTypeName())
+	}
+	req := NewModifyDNRequest(e.DN, e.NewRDN, e.DeleteOldRDN, e.NewSuperior)
+	req.Controls = e.Controls
+	return req, nil
+}
+
+func (e *LDIFEntry) changeTypeName() string {
+	if e.ChangeType == "" {
+		return "content"
+	}
+	return e.ChangeType
+}
+
+// ldifLine is an unfolded line of an LDIF file
+type ldifLine struct {
+	// number is the number of the first line in the file
+	number int
+	text   string
+}
+
+// ParseLDIF parses the LDIF file (rfc2849) read from r, which holds either entries or
+// change records. Folded lines are unfolded, and base64-encoded values ("::") are decoded.
+// Values referenced by URL (":<") are read from the file of a file:// URL, the other URL
+// schemes are not supported.
+//
+// The values of the modify records are grouped in add:, delete:, replace: or increment:
+// blocks, each terminated by a "-" line, which are checked.
+func ParseLDIF(r io.Reader) (*LDIF, error) {
+	records, err := readLDIFRecords(r)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &LDIF{}
+	for i, record := range records {
+		if i == 0 && strings.HasPrefix(record[0].text, "version:") {
+			name, value, err := parseLDIFLine(record[0])
+			if err != nil {
+				return nil, err
+			}
+			if name != "version" || string(value) != "1" {
+				return nil, fmt.Errorf("ldap: LDIF line %d: unsupported version %q", record[0].number, value)
+			}
+			l.Version = 1
+			if record = record[1:]; len(record) == 0 {
+				continue
+			}
+		}
+		entry, err := parseLDIFRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		l.Entries = append(l.Entries, entry)
+	}
+	return l, nil
+}
+
+// readLDIFRecords returns the unfolded lines of the records of the file, without comments
+func readLDIFRecords(r io.Reader) ([][]ldifLine, error) {
+	var records [][]ldifLine
+	var record []ldifLine
+	comment := false
+	reader := bufio.NewReader(r)
+	for number := 1; ; number++ {
+		text, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if text == "" && err == io.EOF {
+			break
+		}
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+
+		switch {
+		case strings.HasPrefix(text, " "):
+			// continuation of the previous line
+			if comment {
+				break
+			}
+			if len(record) == 0 {
+				return nil, fmt.Errorf("ldap: LDIF line %d: unexpected continuation line", number)
+			}
+			record[len(record)-1].text += text[1:]
+		case strings.HasPrefix(text, "#"):
+			comment = true
+		case text == "":
+			comment = false
+			if len(record) > 0 {
+				records = append(records, record)
+				record = nil
+			}
+		default:
+			comment = false
+			record = append(record, ldifLine{number: number, text: text})
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(record) > 0 {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseLDIFRecord parses the lines of a record
+func parseLDIFRecord(lines []ldifLine) (*LDIFEntry, error) {
+	name, value, err := parseLDIFLine(lines[0])
+	if err != nil {
+		return nil, err
+	}
+	if name != "dn" {
+		return nil, fmt.Errorf("ldap: LDIF line %d: expected a dn: line, got %q", lines[0].number, lines[0].text)
+	}
+	entry := &LDIFEntry{DN: string(value)}
+	lines = lines[1:]
+
+	for len(lines) > 0 && strings.HasPrefix(lines[0].text, "control:") {
+		control, err := parseLDIFControl(lines[0])
+		if err != nil {
+			return nil, err
+		}
+		entry.Controls = append(entry.Controls, control)
+		lines = lines[1:]
+	}
+
+	if len(lines) > 0 && strings.HasPrefix(lines[0].text, "changetype:") {
+		_, value, err := parseLDIFLine(lines[0])
+		if err != nil {
+			return nil, err
+		}
+		switch changeType := string(value); changeType {
+		case LDIFChangeTypeAdd, LDIFChangeTypeDelete, LDIFChangeTypeModify, LDIFChangeTypeModRDN:
+			entry.ChangeType = changeType
+		case "moddn":
+			entry.ChangeType = LDIFChangeTypeModRDN
+		default:
+			return nil, fmt.Errorf("ldap: LDIF line %d: unknown change type %q", lines[0].number, changeType)
+		}
+		lines = lines[1:]
+	} else if len(entry.Controls) > 0 {
+		return nil, errors.New("ldap: LDIF controls are only allowed in change records")
+	}
+
+	switch entry.ChangeType {
+	case "", LDIFChangeTypeAdd:
+		err = entry.parseAttributes(lines)
+	case LDIFChangeTypeDelete:
+		if len(lines) > 0 {
+			err = fmt.Errorf("ldap: LDIF line %d: unexpected line in a delete record", lines[0].number)
+		}
+	case LDIFChangeTypeModify:
+		err = entry.parseChanges(lines)
+	case LDIFChangeTypeModRDN:
+		err = entry.parseModRDN(lines)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// parseAttributes parses the attributes of an entry or of an add record
+func (e *LDIFEntry) parseAttributes(lines []ldifLine) error {
+	if len(lines) == 0 {
+		return fmt.Errorf("ldap: LDIF entry %s has no attributes", e.DN)
+	}
+	for _, line := range lines {
+		name, value, err := parseLDIFLine(line)
+		if err != nil {
+			return err
+		}
+		e.appendAttribute(name, string(value))
+	}
+	return nil
+}
+
+// appendAttribute appends value to the values of the attribute name
+func (e *LDIFEntry) appendAttribute(name, value string) {
+	for i := range e.Attributes {
+		if strings.EqualFold(e.Attributes[i].Type, name) {
+			e.Attributes[i].Vals = append(e.Attributes[i].Vals, value)
+			return
+		}
+	}
+	e.Attributes = append(e.Attributes, Attribute{Type: name, Vals: []string{value}})
+}
+
+// ldifModifyOperations maps the operations of the blocks of modify records to the
+// operations of Change
+var ldifModifyOperations = map[string]uint{
+	"add":       AddAttribute,
+	"delete":    DeleteAttribute,
+	"replace":   ReplaceAttribute,
+	"increment": IncrementAttribute,
+}
+
+// parseChanges parses the blocks of a modify record
+func (e *LDIFEntry) parseChanges(lines []ldifLine) error {
+	var change *Change
+	for _, line := range lines {
+		if line.text == "-" {
+			if change == nil {
+				return fmt.Errorf("ldap: LDIF line %d: unexpected \"-\" line", line.number)
+			}
+			e.Changes = append(e.Changes, *change)
+			change = nil
+			continue
+		}
+		name, value, err := parseLDIFLine(line)
+		if err != nil {
+			return err
+		}
+		if change == nil {
+			operation, ok := ldifModifyOperations[name]
+			if !ok {
+				return fmt.Errorf("ldap: LDIF line %d: expected an add:, delete:, replace: or increment: line, got %q", line.number, line.text)
+			}
+			change = &Change{Operation: operation, Modification: PartialAttribute{Type: string(value)}}
+			continue
+		}
+		if !strings.EqualFold(name, change.Modification.Type) {
+			return fmt.Errorf("ldap: LDIF line %d: value of %s in a block modifying %s", line.number, name, change.Modification.Type)
+		}
+		change.Modification.Vals = append(change.Modification.Vals, string(value))
+	}
+	if change != nil {
+		return fmt.Errorf("ldap: LDIF modify record of %s: missing \"-\" line after the changes of %s", e.DN, change.Modification.Type)
+	}
+	return nil
+}
+
+// parseModRDN parses the fields of a modrdn record
+func (e *LDIFEntry) parseModRDN(lines []ldifLine) error {
+	hasDeleteOldRDN := false
+	for _, line := range lines {
+		name, value, err := parseLDIFLine(line)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "newrdn":
+			e.NewRDN = string(value)
+		case "deleteoldrdn":
+			switch string(value) {
+			case "0":
+				e.DeleteOldRDN = false
+			case "1":
+				e.DeleteOldRDN = true
+			default:
+				return fmt.Errorf("ldap: LDIF line %d: deleteoldrdn must be 0 or 1, got %q", line.number, value)
+			}
+			hasDeleteOldRDN = true
+		case "newsuperior":
+			e.NewSuperior = string(value)
+		default:
+			return fmt.Errorf("ldap: LDIF line %d: unexpected line in a modrdn record", line.number)
+		}
+	}
+	if e.NewRDN == "" || !hasDeleteOldRDN {
+		return fmt.Errorf("ldap: LDIF modrdn record of %s requires newrdn: and deleteoldrdn: lines", e.DN)
+	}
+	return nil
+}
+
+// parseLDIFControl parses a "control: oid [true|false] [value]" line
+func parseLDIFControl(line ldifLine) (Control, error) {
+	spec := strings.TrimLeft(strings.TrimPrefix(line.text, "control:"), " ")
+	var value []byte
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		var err error
+		if value, err = decodeLDIFValue(line, spec[i:]); err != nil {
+			return nil, err
+		}
+		spec = spec[:i]
+	}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("ldap: LDIF line %d: invalid control %q", line.number, line.text)
+	}
+	criticality := false
+	if len(fields) == 2 {
+		switch fields[1] {
+		case "true":
+			criticality = true
+		case "false":
+		default:
+			return nil, fmt.Errorf("ldap: LDIF line %d: invalid control criticality %q", line.number, fields[1])
+		}
+	}
+	return NewControlString(fields[0], criticality, string(value)), nil
+}
+
+// parseLDIFLine returns the attribute name and the decoded value of a "name: value",
+// "name:: base64" or "name:< url" line
+func parseLDIFLine(line ldifLine) (string, []byte, error) {
+	i := strings.IndexByte(line.text, ':')
+	if i <= 0 {
+		return "", nil, fmt.Errorf("ldap: LDIF line %d: expected an attribute value, got %q", line.number, line.text)
+	}
+	value, err := decodeLDIFValue(line, line.text[i:])
+	if err != nil {
+		return "", nil, err
+	}
+	return line.text[:i], value, nil
+}
+
+// decodeLDIFValue decodes the value-spec of a line, starting with its colon
+func decodeLDIFValue(line ldifLine, spec string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(spec, "::"):
+		value, err := base64.StdEncoding.DecodeString(strings.TrimLeft(spec[2:], " "))
+		if err != nil {
+			return nil, fmt.Errorf("ldap: LDIF line %d: invalid base64 value: %s", line.number, err)
+		}
+		return value, nil
+	case strings.HasPrefix(spec, ":<"):
+		u, err := url.Parse(strings.TrimLeft(spec[2:], " "))
+		if err != nil {
+			return nil, fmt.Errorf("ldap: LDIF line %d: invalid URL: %s", line.number, err)
+		}
+		if u.Scheme != "file" {
+			return nil, fmt.Errorf("ldap: LDIF line %d: unsupported URL scheme %q", line.number, u.Scheme)
+		}
+		value, err := ioutil.ReadFile(u.Path)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: LDIF line %d: %s", line.number, err)
+		}
+		return value, nil
+	}
+	return []byte(strings.TrimLeft(spec[1:], " ")), nil
+}
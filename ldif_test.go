@@ -0,0 +1,217 @@
+package ldap
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalLDIF(t *testing.T) {
+	photo := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10}
+	entries := []*Entry{
+		{
+			DN: "uid=joe,dc=example,dc=com",
+			Attributes: []*EntryAttribute{
+				{Name: "uid", S: []string{"joe"}, ByteValues: [][]byte{[]byte("joe")}},
+				{Name: "cn", S: []string{"José", " leading space"}},
+				{Name: "jpegPhoto", S: []string{string(photo)}, ByteValues: [][]byte{photo}},
+				{Name: "description", S: []string{strings.Repeat("x", 100)}},
+			},
+		},
+		{
+			DN: "cn=Réseau,dc=example,dc=com",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := MarshalLDIF(entries, &buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "version: 1\n" +
+		"\n" +
+		"dn: uid=joe,dc=example,dc=com\n" +
+		"uid: joe\n" +
+		"cn:: Sm9zw6k=\n" +
+		"cn:: IGxlYWRpbmcgc3BhY2U=\n" +
+		"jpegPhoto:: /9j/4AAQ\n" +
+		"description: " + strings.Repeat("x", 63) + "\n" +
+		" " + strings.Repeat("x", 37) + "\n" +
+		"\n" +
+		"dn:: Y249UsOpc2VhdSxkYz1leGFtcGxlLGRjPWNvbQ==\n"
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestIsLDIFSafeString(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"":            true,
+		"joe":         true,
+		"a: b <c>":    true,
+		":joe":        false,
+		"<joe":        false,
+		" joe":        false,
+		"joe ":        false,
+		"jo\ne":       false,
+		"jo\x00e":     false,
+		"jo\te":       false,
+		"Jos\xc3\xa9": false,
+	} {
+		if got := isLDIFSafeString([]byte(value)); got != expected {
+			t.Errorf("%q: expected %t, got %t", value, expected, got)
+		}
+	}
+}
+
+func TestParseLDIF(t *testing.T) {
+	file, err := ioutil.TempFile("", "ldif")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.Remove(file.Name())
+	file.Write([]byte{0xff, 0xd8})
+	file.Close()
+
+	l, err := ParseLDIF(strings.NewReader("version: 1\n" +
+		"\n" +
+		"# an entry\n" +
+		"#  with a folded comment\n" +
+		"dn: uid=joe,dc=exam\n" +
+		" ple,dc=com\n" +
+		"objectClass: top\n" +
+		"objectClass: person\n" +
+		"cn:: Sm9zw6k=\n" +
+		"jpegPhoto:< file://" + file.Name() + "\n" +
+		"\n" +
+		"dn: uid=joe,dc=example,dc=com\n" +
+		"control: 1.2.840.113556.1.4.805 true\n" +
+		"changetype: modify\n" +
+		"add: mail\n" +
+		"mail: joe@example.com\n" +
+		"mail: jdoe@example.com\n" +
+		"-\n" +
+		"delete: description\n" +
+		"-\n" +
+		"replace: cn\n" +
+		"cn: Joe\n" +
+		"-\n" +
+		"\n" +
+		"dn: uid=jane,dc=example,dc=com\r\n" +
+		"changetype: add\r\n" +
+		"uid: jane\r\n" +
+		"\r\n" +
+		"dn: uid=jane,dc=example,dc=com\n" +
+		"changetype: moddn\n" +
+		"newrdn: uid=janet\n" +
+		"deleteoldrdn: 1\n" +
+		"newsuperior: ou=people,dc=example,dc=com\n" +
+		"\n" +
+		"dn: uid=joe,dc=example,dc=com\n" +
+		"changetype: delete\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if l.Version != 1 || len(l.Entries) != 5 {
+		t.Fatalf("unexpected LDIF %+v", l)
+	}
+
+	add, err := l.Entries[0].ToAddRequest()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedAdd := &AddRequest{
+		DN: "uid=joe,dc=example,dc=com",
+		Attributes: []Attribute{
+			{Type: "objectClass", Vals: []string{"top", "person"}},
+			{Type: "cn", Vals: []string{"José"}},
+			{Type: "jpegPhoto", Vals: []string{"\xff\xd8"}},
+		},
+	}
+	if !reflect.DeepEqual(add, expectedAdd) {
+		t.Errorf("got %+v, expected %+v", add, expectedAdd)
+	}
+	if _, err := l.Entries[0].ToModifyRequest(); err == nil {
+		t.Errorf("expected an error making a modify request from an entry")
+	}
+
+	modify, err := l.Entries[1].ToModifyRequest()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedModify := NewModifyRequest("uid=joe,dc=example,dc=com", []Control{NewControlString(ControlTypeSubtreeDelete, true, "")})
+	expectedModify.Add("mail", []string{"joe@example.com", "jdoe@example.com"})
+	expectedModify.Delete("description", nil)
+	expectedModify.Replace("cn", []string{"Joe"})
+	if !reflect.DeepEqual(modify, expectedModify) {
+		t.Errorf("got %+v, expected %+v", modify, expectedModify)
+	}
+
+	if add, err := l.Entries[2].ToAddRequest(); err != nil || add.DN != "uid=jane,dc=example,dc=com" || len(add.Attributes) != 1 {
+		t.Errorf("unexpected add request %+v (%v)", add, err)
+	}
+	modifyDN, err := l.Entries[3].ToModifyDNRequest()
+	if err != nil || !reflect.DeepEqual(modifyDN, NewModifyDNRequest("uid=jane,dc=example,dc=com", "uid=janet", true, "ou=people,dc=example,dc=com")) {
+		t.Errorf("unexpected modify DN request %+v (%v)", modifyDN, err)
+	}
+	if del, err := l.Entries[4].ToDelRequest(); err != nil || del.DN != "uid=joe,dc=example,dc=com" {
+		t.Errorf("unexpected delete request %+v (%v)", del, err)
+	}
+}
+
+func TestParseLDIFRoundTrip(t *testing.T) {
+	entries := []*Entry{
+		NewEntry("uid=joe,dc=example,dc=com", map[string][]string{
+			"cn":          {"José", " leading space"},
+			"description": {strings.Repeat("x", 100)},
+		}),
+	}
+	var buf bytes.Buffer
+	if err := MarshalLDIF(entries, &buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	l, err := ParseLDIF(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	add, err := l.Entries[0].ToAddRequest()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, attr := range add.Attributes {
+		if !reflect.DeepEqual(attr.Vals, entries[0].GetAttributeValues(attr.Type)) {
+			t.Errorf("unexpected values %q of %s", attr.Vals, attr.Type)
+		}
+	}
+}
+
+func TestParseLDIFErrors(t *testing.T) {
+	for _, test := range []struct {
+		ldif     string
+		expected string
+	}{
+		{"version: 2\n", "unsupported version"},
+		{" continued\n", "unexpected continuation line"},
+		{"cn: joe\n", "expected a dn: line"},
+		{"dn: uid=joe\n", "has no attributes"},
+		{"dn: uid=joe\ncn:: !!!\n", "invalid base64 value"},
+		{"dn: uid=joe\njpegPhoto:< http://example.com/joe.jpg\n", "unsupported URL scheme"},
+		{"dn: uid=joe\nchangetype: rename\n", "unknown change type"},
+		{"dn: uid=joe\ncontrol: 1.2.3\ncn: joe\n", "only allowed in change records"},
+		{"dn: uid=joe\ncontrol: 1.2.3 maybe\nchangetype: delete\n", "invalid control criticality"},
+		{"dn: uid=joe\nchangetype: delete\ncn: joe\n", "unexpected line in a delete record"},
+		{"dn: uid=joe\nchangetype: modify\nadd: mail\nmail: joe@example.com\n", "missing \"-\" line"},
+		{"dn: uid=joe\nchangetype: modify\nadd: mail\ncn: joe\n-\n", "value of cn in a block modifying mail"},
+		{"dn: uid=joe\nchangetype: modify\nmail: joe@example.com\n-\n", "expected an add:, delete:, replace: or increment: line"},
+		{"dn: uid=joe\nchangetype: modify\n-\n", "unexpected \"-\" line"},
+		{"dn: uid=joe\nchangetype: modrdn\nnewrdn: uid=jane\n", "requires newrdn: and deleteoldrdn: lines"},
+		{"dn: uid=joe\nchangetype: modrdn\nnewrdn: uid=jane\ndeleteoldrdn: yes\n", "deleteoldrdn must be 0 or 1"},
+	} {
+		_, err := ParseLDIF(strings.NewReader(test.ldif))
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", test.ldif, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// generalizedTimeLayout formats a time as a Generalized Time value (rfc4517 3.3.13) in UTC
+const generalizedTimeLayout = "20060102150405.999999999Z"
+
+// MarshalAddRequest returns an AddRequest adding the entry described by the struct v, or
+// by the struct v points to, as the reverse of Entry.Unmarshal. Each exported field is
+// added as the attribute named by its `ldap:"name"` tag, or by the field name if it has no
+// tag. Fields tagged `ldap:"-"` are skipped, as are fields with the omitempty option, as in
+// `ldap:"mail,omitempty"`, whose value is the zero value or an empty slice.
+//
+// If dn is empty, the DN of the entry is the value of the field tagged `ldap:"dn"` or with
+// the dn option, which is not added as an attribute.
+//
+// The supported field types are those of Entry.Unmarshal: time.Time values are added as
+// Generalized Time values in UTC, and []byte values are added as is.
+func MarshalAddRequest(dn string, v interface{}) (*AddRequest, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ldap: MarshalAddRequest expects a struct or a pointer to a struct, got %T", v)
+	}
+	rt := rv.Type()
+
+	req := NewAddRequest(dn, nil)
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := parseFieldTag(field)
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		if tag.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		values, err := marshalValues(fv)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: cannot marshal field %s: %s", field.Name, err)
+		}
+		if tag.dn {
+			if dn == "" && len(values) > 0 {
+				req.DN = values[0]
+			}
+			continue
+		}
+		// an attribute of an added entry has at least one value
+		if len(values) > 0 {
+			req.Attribute(tag.name, values)
+		}
+	}
+	if req.DN == "" {
+		return nil, errors.New("ldap: MarshalAddRequest requires a DN")
+	}
+	return req, nil
+}
+
+// isEmptyValue returns true if fv is the zero value of its type, or an empty slice
+func isEmptyValue(fv reflect.Value) bool {
+	if fv.Type() == timeType {
+		return fv.Interface().(time.Time).IsZero()
+	}
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice:
+		return fv.Len() == 0
+	case reflect.Bool:
+		return !fv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fv.Int() == 0
+	case reflect.Ptr:
+		return fv.IsNil()
+	}
+	return false
+}
+
+// marshalValues returns the attribute values of the field value fv
+func marshalValues(fv reflect.Value) ([]string, error) {
+	if fv.Kind() == reflect.Slice && fv.Type() != bytesType {
+		values := make([]string, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			if fv.Index(i).Kind() == reflect.Ptr && fv.Index(i).IsNil() {
+				continue
+			}
+			value, err := marshalValue(fv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	if fv.Kind() == reflect.Ptr && fv.IsNil() {
+		return nil, nil
+	}
+	value, err := marshalValue(fv)
+	if err != nil {
+		return nil, err
+	}
+	return []string{value}, nil
+}
+
+// marshalValue returns the attribute value of the field value fv
+func marshalValue(fv reflect.Value) (string, error) {
+	switch fv.Type() {
+	case bytesType:
+		return string(fv.Bytes()), nil
+	case timeType:
+		return fv.Interface().(time.Time).UTC().Format(generalizedTimeLayout), nil
+	case dnType:
+		return fv.Interface().(*DN).String(), nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), nil
+	case reflect.Bool:
+		if fv.Bool() {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported field type %s", fv.Type())
+}
@@ -0,0 +1,105 @@
+package ldap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalAddRequest(t *testing.T) {
+	type user struct {
+		DN        string    `ldap:",dn"`
+		UID       string    `ldap:"uid"`
+		Mail      []string  `ldap:"mail,omitempty"`
+		UIDNumber int       `ldap:"uidNumber"`
+		Locked    bool      `ldap:"locked,omitempty"`
+		Created   time.Time `ldap:"createTimestamp,omitempty"`
+		Photo     []byte    `ldap:"jpegPhoto,omitempty"`
+		Manager   *DN       `ldap:"manager"`
+		Ignored   string    `ldap:"-"`
+	}
+	manager, _ := ParseDN("uid=boss,dc=example,dc=com")
+	u := user{
+		DN:        "uid=joe,dc=example,dc=com",
+		UID:       "joe",
+		UIDNumber: 1000,
+		Created:   time.Date(2020, 1, 2, 4, 4, 5, 500000000, time.FixedZone("", 3600)),
+		Photo:     []byte("\xff\xd8"),
+		Manager:   manager,
+		Ignored:   "ignored",
+	}
+
+	req, err := MarshalAddRequest("", &u)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if req.DN != u.DN {
+		t.Errorf("expected the DN of the DN field, got %q", req.DN)
+	}
+	expected := []Attribute{
+		{Type: "uid", Vals: []string{"joe"}},
+		{Type: "uidNumber", Vals: []string{"1000"}},
+		{Type: "createTimestamp", Vals: []string{"20200102030405.5Z"}},
+		{Type: "jpegPhoto", Vals: []string{"\xff\xd8"}},
+		{Type: "manager", Vals: []string{"uid=boss,dc=example,dc=com"}},
+	}
+	if !reflect.DeepEqual(req.Attributes, expected) {
+		t.Errorf("unexpected attributes %v", req.Attributes)
+	}
+
+	// round trip through Entry.Unmarshal
+	entry := &Entry{DN: req.DN}
+	for _, attr := range req.Attributes {
+		entry.Attributes = append(entry.Attributes, &EntryAttribute{Name: attr.Type, S: attr.Vals})
+	}
+	var decoded user
+	if err := entry.Unmarshal(&decoded); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	u.Ignored = ""
+	if !decoded.Created.Equal(u.Created) || !decoded.Manager.Equal(u.Manager) {
+		t.Errorf("unexpected round trip %+v", decoded)
+	}
+	decoded.Created, decoded.Manager, u.Created, u.Manager = time.Time{}, nil, time.Time{}, nil
+	if !reflect.DeepEqual(decoded, u) {
+		t.Errorf("unexpected round trip %+v", decoded)
+	}
+
+	req, err = MarshalAddRequest("uid=jane,dc=example,dc=com", user{Locked: true, Mail: []string{"a@example.com", "b@example.com"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if req.DN != "uid=jane,dc=example,dc=com" {
+		t.Errorf("expected the DN argument, got %q", req.DN)
+	}
+	expected = []Attribute{
+		{Type: "uid", Vals: []string{""}},
+		{Type: "mail", Vals: []string{"a@example.com", "b@example.com"}},
+		{Type: "uidNumber", Vals: []string{"0"}},
+		{Type: "locked", Vals: []string{"TRUE"}},
+	}
+	if !reflect.DeepEqual(req.Attributes, expected) {
+		t.Errorf("unexpected attributes %v", req.Attributes)
+	}
+}
+
+func TestMarshalAddRequestErrors(t *testing.T) {
+	for _, test := range []struct {
+		v        interface{}
+		expected string
+	}{
+		{nil, "expects a struct"},
+		{"uid=joe", "expects a struct"},
+		{struct{ UID string }{"joe"}, "requires a DN"},
+		{struct {
+			DN    string `ldap:"dn"`
+			Ratio float64
+		}{"uid=joe", 1}, "unsupported field type float64"},
+	} {
+		_, err := MarshalAddRequest("", test.v)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%T: expected an error containing %q, got %v", test.v, test.expected, err)
+		}
+	}
+}
@@ -11,7 +11,8 @@
 package ldap
 
 import (
-	"log"
+	"context"
+	"fmt"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -22,6 +23,8 @@ type ModifyDNRequest struct {
 	NewRDN       string
 	DeleteOldRDN bool
 	NewSuperior  string
+	// Controls hold optional controls to send with the request
+	Controls []Control
 }
 
 // NewModifyDNRequest creates a new request which can be passed to ModifyDN().
@@ -55,6 +58,9 @@ func (req *ModifyDNRequest) appendTo(envelope *ber.Packet) error {
 	}
 
 	envelope.AppendChild(pkt)
+	if len(req.Controls) > 0 {
+		envelope.AppendChild(EncodeControls(req.Controls))
+	}
 
 	return nil
 }
@@ -62,13 +68,13 @@ func (req *ModifyDNRequest) appendTo(envelope *ber.Packet) error {
 // ModifyDN renames the given DN and optionally move to another base (when the "newSup" argument
 // to NewModifyDNRequest() is not "").
 func (l *Conn) ModifyDN(m *ModifyDNRequest) error {
-	msgCtx, err := l.doRequest(m)
+	msgCtx, err := l.doRequest(context.Background(), m)
 	if err != nil {
 		return err
 	}
 	defer l.finishMessage(msgCtx)
 
-	packet, err := l.readPacket(msgCtx)
+	packet, err := l.readPacket(context.Background(), msgCtx)
 	if err != nil {
 		return err
 	}
@@ -79,7 +85,7 @@ func (l *Conn) ModifyDN(m *ModifyDNRequest) error {
 			return err
 		}
 	} else {
-		log.Printf("Unexpected Response: %d", packet.Children[1].Tag)
+		return NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
 	}
 	return nil
 }
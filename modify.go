@@ -9,7 +9,8 @@
 //                add     (0),
 //                delete  (1),
 //                replace (2),
-//                ...  },
+//                ...,
+//                increment (3) -- rfc4525 --  },
 //           modification    PartialAttribute } }
 //
 // PartialAttribute ::= SEQUENCE {
@@ -26,7 +27,11 @@
 package ldap
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -36,8 +41,14 @@ const (
 	AddAttribute     = 0
 	DeleteAttribute  = 1
 	ReplaceAttribute = 2
+	// IncrementAttribute is the increment operation of rfc4525
+	IncrementAttribute = 3
 )
 
+// FeatureModifyIncrement is the OID announced in the supportedFeatures of the root DSE by
+// the servers supporting the increment operation of rfc4525
+const FeatureModifyIncrement = "1.3.6.1.1.14"
+
 // PartialAttribute for a ModifyRequest as defined in https://tools.ietf.org/html/rfc4511
 type PartialAttribute struct {
 	// Type is the type of the partial attribute
@@ -97,6 +108,14 @@ func (req *ModifyRequest) Replace(attrType string, attrVals []string) {
 	req.appendChange(ReplaceAttribute, attrType, attrVals)
 }
 
+// Increment appends to the list of changes the increment of the given attribute by delta,
+// which is performed atomically by the server as specified in rfc4525. The servers which
+// do not announce FeatureModifyIncrement fail the request, usually with
+// LDAPResultUnwillingToPerform.
+func (req *ModifyRequest) Increment(attribute string, delta int) {
+	req.appendChange(IncrementAttribute, attribute, []string{strconv.Itoa(delta)})
+}
+
 func (req *ModifyRequest) appendChange(operation uint, attrType string, attrVals []string) {
 	req.Changes = append(req.Changes, Change{operation, PartialAttribute{Type: attrType, Vals: attrVals}})
 }
@@ -112,7 +131,7 @@ func (req *ModifyRequest) appendTo(envelope *ber.Packet) error {
 
 	envelope.AppendChild(pkt)
 	if len(req.Controls) > 0 {
-		envelope.AppendChild(encodeControls(req.Controls))
+		envelope.AppendChild(EncodeControls(req.Controls))
 	}
 
 	return nil
@@ -126,26 +145,160 @@ func NewModifyRequest(dn string, controls []Control) *ModifyRequest {
 	}
 }
 
+// OperationalAttributes are the operational attributes maintained by the servers, which
+// DiffModify ignores in addition to the attributes given to it
+var OperationalAttributes = []string{
+	// rfc4512 and rfc3045
+	"createTimestamp", "modifyTimestamp", "creatorsName", "modifiersName",
+	"structuralObjectClass", "governingStructureRule", "subschemaSubentry",
+	"hasSubordinates", "entryDN", "entryUUID", "entryCSN", "contextCSN",
+	"vendorName", "vendorVersion",
+	// Active Directory
+	"whenCreated", "whenChanged", "uSNCreated", "uSNChanged", "objectGUID",
+	"distinguishedName", "instanceType", "objectCategory", "dSCorePropagationData",
+}
+
+// DiffModify returns the modify request changing the attributes of the entry with the
+// given DN from current to desired. Attributes are replaced if their values differ, added
+// if they are missing from current, and deleted if they are missing from desired or if
+// desired has no value for them. The values are compared as sets, and the attribute names
+// case insensitively.
+//
+// The attributes of OperationalAttributes and ignore are left unchanged. The request has
+// no change if the attributes are the same.
+func DiffModify(dn string, current, desired map[string][]string, ignore ...string) *ModifyRequest {
+	ignored := make(map[string]bool, len(OperationalAttributes)+len(ignore))
+	for _, names := range [][]string{OperationalAttributes, ignore} {
+		for _, name := range names {
+			ignored[strings.ToLower(name)] = true
+		}
+	}
+
+	type attributePair struct {
+		name             string
+		current, desired []string
+	}
+	pairs := make(map[string]*attributePair)
+	for name, values := range current {
+		key := strings.ToLower(name)
+		if ignored[key] || len(values) == 0 {
+			continue
+		}
+		pairs[key] = &attributePair{name: name, current: values}
+	}
+	for name, values := range desired {
+		key := strings.ToLower(name)
+		if ignored[key] {
+			continue
+		}
+		pair, ok := pairs[key]
+		if !ok {
+			pair = &attributePair{}
+			pairs[key] = pair
+		}
+		pair.name, pair.desired = name, values
+	}
+
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	req := NewModifyRequest(dn, nil)
+	for _, key := range keys {
+		pair := pairs[key]
+		switch {
+		case len(pair.desired) == 0:
+			if len(pair.current) > 0 {
+				req.Delete(pair.name, []string{})
+			}
+		case len(pair.current) == 0:
+			req.Add(pair.name, pair.desired)
+		case !sameValues(pair.current, pair.desired):
+			req.Replace(pair.name, pair.desired)
+		}
+	}
+	return req
+}
+
+// sameValues returns whether a and b hold the same set of values
+func sameValues(a, b []string) bool {
+	setA := make(map[string]bool, len(a))
+	for _, value := range a {
+		setA[value] = true
+	}
+	setB := make(map[string]bool, len(b))
+	for _, value := range b {
+		if !setA[value] {
+			return false
+		}
+		setB[value] = true
+	}
+	return len(setA) == len(setB)
+}
+
+// ModifyResult holds the server's response to a modify request
+type ModifyResult struct {
+	// Controls are the returned controls, such as the entry returned by a post-read control
+	Controls []Control
+}
+
 // Modify performs the ModifyRequest
 func (l *Conn) Modify(modifyRequest *ModifyRequest) error {
-	msgCtx, err := l.doRequest(modifyRequest)
+	_, err := l.ModifyWithResult(modifyRequest)
+	return err
+}
+
+// ModifyWithResult performs the ModifyRequest and returns the result, with the controls
+// returned by the server. The result is also returned alongside the error of a failed
+// modification, since controls such as the password policy control are returned with it.
+func (l *Conn) ModifyWithResult(modifyRequest *ModifyRequest) (*ModifyResult, error) {
+	return l.modifyFollowingReferrals(l.getReferralConfig(), modifyRequest, 0)
+}
+
+// modifyFollowingReferrals performs the modification, following the referral returned by
+// the server if config is not nil. hops is the number of referrals already followed.
+func (l *Conn) modifyFollowingReferrals(config *ReferralConfig, modifyRequest *ModifyRequest, hops int) (*ModifyResult, error) {
+	msgCtx, err := l.doRequest(context.Background(), modifyRequest)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer l.finishMessage(msgCtx)
 
-	packet, err := l.readPacket(msgCtx)
+	packet, err := l.readPacket(context.Background(), msgCtx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if packet.Children[1].Tag != ApplicationModifyResponse {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
 	}
 
-	if packet.Children[1].Tag == ApplicationModifyResponse {
-		err := GetLDAPError(packet)
-		if err != nil {
-			return err
+	result := &ModifyResult{
+		Controls: make([]Control, 0),
+	}
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			decodedChild, decodeErr := DecodeControl(child)
+			if decodeErr != nil {
+				return nil, fmt.Errorf("failed to decode child control: %s", decodeErr)
+			}
+			result.Controls = append(result.Controls, decodedChild)
 		}
-	} else {
-		log.Printf("Unexpected Response: %d", packet.Children[1].Tag)
 	}
-	return nil
+
+	err = GetLDAPError(packet)
+	if config != nil && IsErrorWithCode(err, LDAPResultReferral) {
+		_, err = followReferral(config, getReferral(packet), hops, func(conn *Conn, ref *referralURL) error {
+			referred := *modifyRequest
+			if ref.dn != "" {
+				referred.DN = ref.dn
+			}
+			var referredErr error
+			result, referredErr = conn.modifyFollowingReferrals(config, &referred, hops+1)
+			return referredErr
+		})
+	}
+	return result, err
 }
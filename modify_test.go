@@ -0,0 +1,125 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestDiffModify(t *testing.T) {
+	current := map[string][]string{
+		"cn":              {"John Doe"},
+		"mail":            {"jdoe@example.com", "john.doe@example.com"},
+		"telephoneNumber": {"+1 555 0100"},
+		"description":     {"old"},
+		"title":           {"Engineer"},
+		"modifyTimestamp": {"20200102030405Z"},
+		"employeeNumber":  {"42"},
+	}
+	desired := map[string][]string{
+		"CN":          {"John Doe"},
+		"mail":        {"john.doe@example.com", "jdoe@example.com"},
+		"description": {"new"},
+		"title":       {},
+		"givenName":   {"John"},
+		"manager":     {},
+	}
+
+	req := DiffModify("cn=jdoe,dc=example,dc=com", current, desired, "employeeNumber")
+	expected := []Change{
+		{ReplaceAttribute, PartialAttribute{Type: "description", Vals: []string{"new"}}},
+		{AddAttribute, PartialAttribute{Type: "givenName", Vals: []string{"John"}}},
+		{DeleteAttribute, PartialAttribute{Type: "telephoneNumber", Vals: []string{}}},
+		{DeleteAttribute, PartialAttribute{Type: "title", Vals: []string{}}},
+	}
+	if req.DN != "cn=jdoe,dc=example,dc=com" || !reflect.DeepEqual(req.Changes, expected) {
+		t.Errorf("got changes %v, expected %v", req.Changes, expected)
+	}
+
+	if req := DiffModify("cn=jdoe,dc=example,dc=com", current, current); len(req.Changes) != 0 {
+		t.Errorf("expected no change, got %v", req.Changes)
+	}
+}
+
+func TestModifyRequestIncrement(t *testing.T) {
+	req := NewModifyRequest("cn=uidNext,dc=example,dc=com", nil)
+	req.Increment("uidNumber", -2)
+
+	var change *ber.Packet
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		change = request.Children[1].Children[1].Children[0]
+		return []*ber.Packet{newResultPacket(request.Children[0].Value.(int64), ApplicationModifyResponse, LDAPResultUnwillingToPerform)}
+	})
+	defer conn.Close()
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.Modify(req); !IsErrorWithCode(err, LDAPResultUnwillingToPerform) {
+			t.Errorf("expected LDAPResultUnwillingToPerform, got %v", err)
+		}
+	})
+
+	if operation := change.Children[0].Value.(int64); operation != IncrementAttribute {
+		t.Errorf("expected the increment operation, got %d", operation)
+	}
+	attribute := change.Children[1]
+	values := attribute.Children[1].Children
+	if attribute.Children[0].Value.(string) != "uidNumber" || len(values) != 1 || values[0].Value.(string) != "-2" {
+		t.Errorf("expected a single -2 value for uidNumber")
+	}
+}
+
+func TestModifyWithResult(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		code := uint16(LDAPResultSuccess)
+		if messageID == 2 {
+			code = LDAPResultConstraintViolation
+		}
+		response := newResultPacket(messageID, ApplicationModifyResponse, code)
+		response.AppendChild(EncodeControls([]Control{NewControlManageDsaIT(true)}))
+		return []*ber.Packet{response}
+	})
+	defer conn.Close()
+
+	req := NewModifyRequest("uid=joe,dc=example,dc=com", nil)
+	req.Replace("mail", []string{"joe@example.com"})
+	result, err := conn.ModifyWithResult(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(result.Controls) != 1 || result.Controls[0].GetControlType() != ControlTypeManageDsaIT {
+		t.Errorf("unexpected controls %v", result.Controls)
+	}
+
+	// the controls are also returned with an error
+	result, err = conn.ModifyWithResult(req)
+	if !IsErrorWithCode(err, LDAPResultConstraintViolation) {
+		t.Errorf("expected LDAPResultConstraintViolation, got %v", err)
+	}
+	if result == nil || len(result.Controls) != 1 {
+		t.Errorf("expected the response controls, got %v", result)
+	}
+
+	if err := conn.Modify(req); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestModifyNoOp(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		if len(request.Children) < 3 {
+			t.Errorf("expected the request to have the No-Op control")
+			return nil
+		}
+		return []*ber.Packet{newResultPacket(messageID, ApplicationModifyResponse, LDAPResultNoOperation)}
+	})
+	defer conn.Close()
+
+	req := NewModifyRequest("uid=joe,dc=example,dc=com", []Control{NewControlNoOp()})
+	req.Replace("mail", []string{"joe@example.com"})
+	if err := conn.Modify(req); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,64 @@
+// This file contains the Notice of Disconnection unsolicited notification as specified
+// in rfc 4511 4.4.1
+//
+// https://tools.ietf.org/html/rfc4511#section-4.4.1
+//
+
+package ldap
+
+import (
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const (
+	noticeOfDisconnectionOID = "1.3.6.1.4.1.1466.20036"
+)
+
+// DisconnectNotification is the Notice of Disconnection sent by a server which is about
+// to close the connection, for example because it is shutting down
+type DisconnectNotification struct {
+	// ResultCode is the reason of the disconnection, such as LDAPResultUnavailable,
+	// LDAPResultProtocolError or LDAPResultStrongAuthRequired
+	ResultCode uint16
+	// Message is the diagnostic message sent by the server
+	Message string
+}
+
+// DisconnectNotify returns the channel receiving the Notice of Disconnection if the server
+// sends one. The connection is then closed, and the requests waiting for a response fail
+// with an error having the ResultCode of the notification. The channel is never closed.
+func (l *Conn) DisconnectNotify() <-chan *DisconnectNotification {
+	return l.disconnectNotify
+}
+
+// parseDisconnectNotification returns the Notice of Disconnection held by the unsolicited
+// notification packet, or nil if packet is another message
+func parseDisconnectNotification(packet *ber.Packet) *DisconnectNotification {
+	if len(packet.Children) < 2 {
+		return nil
+	}
+	if messageID, ok := packet.Children[0].Value.(int64); !ok || messageID != 0 {
+		return nil
+	}
+	response := packet.Children[1]
+	if response.ClassType != ber.ClassApplication || response.Tag != ApplicationExtendedResponse || len(response.Children) < 3 {
+		return nil
+	}
+
+	var responseName string
+	for _, child := range response.Children[3:] {
+		if child.ClassType == ber.ClassContext && child.Tag == 10 {
+			responseName = child.Data.String()
+		}
+	}
+	if responseName != noticeOfDisconnectionOID {
+		return nil
+	}
+
+	notification := &DisconnectNotification{}
+	if resultCode, ok := response.Children[0].Value.(int64); ok {
+		notification.ResultCode = uint16(resultCode)
+	}
+	notification.Message, _ = response.Children[2].Value.(string)
+	return notification
+}
@@ -0,0 +1,67 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestDisconnectNotify(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil))
+	}()
+	runWithTimeout(t, time.Second, func() {
+		if _, err := ptc.ReceiveRequest(); err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+	})
+
+	notice := newNoticeOfDisconnectionPacket(0, LDAPResultUnavailable, "server shutting down")
+	if err := ptc.SendResponse(notice); err != nil {
+		t.Fatalf("unable to send response packet: %s", err)
+	}
+
+	runWithTimeout(t, time.Second, func() {
+		notification := <-conn.DisconnectNotify()
+		if notification.ResultCode != LDAPResultUnavailable || notification.Message != "server shutting down" {
+			t.Errorf("unexpected notification %+v", notification)
+		}
+		if err := <-errs; !IsErrorWithCode(err, LDAPResultUnavailable) {
+			t.Errorf("expected LDAPResultUnavailable, got %v", err)
+		}
+	})
+	if !conn.IsClosing() {
+		t.Errorf("expected the connection to be closed")
+	}
+}
+
+func TestParseDisconnectNotification(t *testing.T) {
+	if parseDisconnectNotification(newExtendedResponsePacket(0, LDAPResultUnavailable, nil)) != nil {
+		t.Errorf("unexpected notification without responseName")
+	}
+	response := newNoticeOfDisconnectionPacket(1, LDAPResultUnavailable, "")
+	if parseDisconnectNotification(response) != nil {
+		t.Errorf("unexpected notification for message 1")
+	}
+}
+
+func newNoticeOfDisconnectionPacket(messageID int64, resultCode uint16, message string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "diagnosticMessage"))
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 10, noticeOfDisconnectionOID, "responseName"))
+	packet.AppendChild(response)
+	return packet
+}
@@ -0,0 +1,291 @@
+// This file contains the NTLM authentication as specified in MS-NLMP, performed over the
+// GSS-SPNEGO SASL mechanism, which Active Directory accepts with raw NTLMSSP messages
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp
+//
+
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	enchex "encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// SASLMechanismGSSSPNEGO is the name of the GSS-SPNEGO SASL mechanism
+const SASLMechanismGSSSPNEGO = "GSS-SPNEGO"
+
+const (
+	ntlmNegotiateUnicode                 = 0x00000001
+	ntlmRequestTarget                    = 0x00000004
+	ntlmNegotiateNTLM                    = 0x00000200
+	ntlmNegotiateAlwaysSign              = 0x00008000
+	ntlmNegotiateExtendedSessionSecurity = 0x00080000
+	ntlmNegotiateTargetInfo              = 0x00800000
+	ntlmNegotiate128                     = 0x20000000
+	ntlmNegotiate56                      = 0x80000000
+
+	ntlmNegotiateFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign |
+		ntlmNegotiateExtendedSessionSecurity | ntlmNegotiate128 | ntlmNegotiate56
+
+	// ntlmAvTimestamp is the AvId of the server time in the target info
+	ntlmAvTimestamp = 7
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// NTLMBind performs an NTLMv2 authentication over the GSS-SPNEGO SASL mechanism
+//
+// No security layer is installed on the connection: use TLS to protect it. The exchange is
+// restarted as specified by WithRetry.
+func (l *Conn) NTLMBind(domain, username, password string) error {
+	return l.ntlmBind(domain, username, ntlmHash(password))
+}
+
+// NTLMBindWithHash performs an NTLMv2 authentication over the GSS-SPNEGO SASL mechanism,
+// using the hex encoded NT hash of the password instead of the password
+func (l *Conn) NTLMBindWithHash(domain, username, hash string) error {
+	ntHash, err := enchex.DecodeString(hash)
+	if err != nil || len(ntHash) != md4Size {
+		return errors.New("ldap: invalid NT hash")
+	}
+	return l.ntlmBind(domain, username, ntHash)
+}
+
+func (l *Conn) ntlmBind(domain, username string, ntHash []byte) (err error) {
+	defer l.recordBind(&err, func(conn *Conn) error {
+		return conn.ntlmBind(domain, username, ntHash)
+	})
+	return l.withRetry(context.Background(), func() error {
+		return l.ntlmExchange(domain, username, ntHash)
+	})
+}
+
+// ntlmExchange performs the NEGOTIATE, CHALLENGE and AUTHENTICATE exchange of an NTLM bind
+func (l *Conn) ntlmExchange(domain, username string, ntHash []byte) error {
+	challenge, err := l.SASLBind(SASLMechanismGSSSPNEGO, ntlmNegotiateMessage())
+	if !IsErrorWithCode(err, LDAPResultSaslBindInProgress) {
+		if err == nil {
+			err = NewError(ErrorUnexpectedResponse, errors.New("ldap: NTLM exchange ended early"))
+		}
+		return err
+	}
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return fmt.Errorf("ldap: failed to generate NTLM client challenge: %s", err)
+	}
+	authenticate, err := ntlmAuthenticateMessage(challenge, domain, username, ntHash, clientChallenge, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = l.SASLBind(SASLMechanismGSSSPNEGO, authenticate)
+	return err
+}
+
+// ntlmNegotiateMessage returns the NEGOTIATE_MESSAGE (type 1), without domain and workstation
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmNegotiateFlags)
+	// the domain and workstation fields are left empty, with their offset at the end
+	binary.LittleEndian.PutUint32(msg[20:], 32)
+	binary.LittleEndian.PutUint32(msg[28:], 32)
+	return msg
+}
+
+// ntlmChallenge holds the fields used from the CHALLENGE_MESSAGE (type 2)
+type ntlmChallenge struct {
+	flags           uint32
+	serverChallenge []byte
+	targetInfo      []byte
+}
+
+func parseNTLMChallenge(msg []byte) (*ntlmChallenge, error) {
+	if len(msg) < 32 || !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid NTLM challenge message"))
+	}
+	c := &ntlmChallenge{
+		flags:           binary.LittleEndian.Uint32(msg[20:]),
+		serverChallenge: msg[24:32],
+	}
+	if c.flags&ntlmNegotiateTargetInfo != 0 && len(msg) >= 48 {
+		length := int(binary.LittleEndian.Uint16(msg[40:]))
+		offset := int(binary.LittleEndian.Uint32(msg[44:]))
+		if offset+length > len(msg) {
+			return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid NTLM challenge target info"))
+		}
+		c.targetInfo = msg[offset : offset+length]
+	}
+	return c, nil
+}
+
+// timestamp returns the MsvAvTimestamp of the target info, if any
+func (c *ntlmChallenge) timestamp() []byte {
+	info := c.targetInfo
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		length := int(binary.LittleEndian.Uint16(info[2:]))
+		if len(info) < 4+length {
+			break
+		}
+		if id == ntlmAvTimestamp && length == 8 {
+			return info[4:12]
+		}
+		info = info[4+length:]
+	}
+	return nil
+}
+
+// ntlmAuthenticateMessage returns the AUTHENTICATE_MESSAGE (type 3) answering the given
+// CHALLENGE_MESSAGE with NTLMv2 responses
+func ntlmAuthenticateMessage(challengeMsg []byte, domain, username string, ntHash, clientChallenge []byte, now time.Time) ([]byte, error) {
+	challenge, err := parseNTLMChallenge(challengeMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := challenge.timestamp()
+	if timestamp == nil {
+		timestamp = make([]byte, 8)
+		binary.LittleEndian.PutUint64(timestamp, ntlmFileTime(now))
+	}
+	ntResponse, lmResponse := ntlmV2Responses(ntHash, domain, username, challenge.serverChallenge, clientChallenge, timestamp, challenge.targetInfo)
+
+	flags := ntlmNegotiateFlags & challenge.flags
+	if flags&ntlmNegotiateUnicode == 0 {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: NTLM server does not support unicode"))
+	}
+	fields := [][]byte{lmResponse, ntResponse, utf16LE(domain), utf16LE(username), nil, nil}
+
+	const headerSize = 64
+	msg := make([]byte, headerSize)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	offset := headerSize
+	for i, field := range fields {
+		binary.LittleEndian.PutUint16(msg[12+8*i:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(msg[14+8*i:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(msg[16+8*i:], uint32(offset))
+		offset += len(field)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags)
+	for _, field := range fields {
+		msg = append(msg, field...)
+	}
+	return msg, nil
+}
+
+// ntlmV2Responses computes the NTLMv2 and LMv2 responses, as described in MS-NLMP section 3.3.2
+func ntlmV2Responses(ntHash []byte, domain, username string, serverChallenge, clientChallenge, timestamp, targetInfo []byte) (ntResponse, lmResponse []byte) {
+	responseKey := hmacMD5(ntHash, utf16LE(strings.ToUpper(username)+domain))
+
+	var temp []byte
+	temp = append(temp, 1, 1, 0, 0, 0, 0, 0, 0)
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	ntProof := hmacMD5(responseKey, append(append([]byte{}, serverChallenge...), temp...))
+	ntResponse = append(ntProof, temp...)
+
+	lmResponse = hmacMD5(responseKey, append(append([]byte{}, serverChallenge...), clientChallenge...))
+	lmResponse = append(lmResponse, clientChallenge...)
+	return ntResponse, lmResponse
+}
+
+// ntlmFileTime returns t as a number of 100 nanoseconds intervals since January 1, 1601 UTC
+func ntlmFileTime(t time.Time) uint64 {
+	const epochDelta = 116444736000000000
+	return uint64(t.UnixNano()/100) + epochDelta
+}
+
+// ntlmHash returns the NT hash of the given password
+func ntlmHash(password string) []byte {
+	return md4Sum(utf16LE(password))
+}
+
+func hmacMD5(key, data []byte) []byte {
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func utf16LE(s string) []byte {
+	codes := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(codes))
+	for i, code := range codes {
+		binary.LittleEndian.PutUint16(b[2*i:], code)
+	}
+	return b
+}
+
+const md4Size = 16
+
+// md4Sum computes the MD4 digest of data as specified in rfc 1320, which is
+// not provided by the standard library and is only needed for the NT hash
+func md4Sum(data []byte) []byte {
+	length := uint64(len(data)) * 8
+	msg := append([]byte{}, data...)
+	msg = append(msg, 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	msg = append(msg, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(msg[len(msg)-8:], length)
+
+	a, b, c, d := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+	var x [16]uint32
+	for len(msg) > 0 {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(msg[4*i:])
+		}
+		aa, bb, cc, dd := a, b, c, d
+
+		for _, i := range []uint{0, 4, 8, 12} {
+			a = md4Rotate(a+(b&c|^b&d)+x[i], 3)
+			d = md4Rotate(d+(a&b|^a&c)+x[i+1], 7)
+			c = md4Rotate(c+(d&a|^d&b)+x[i+2], 11)
+			b = md4Rotate(b+(c&d|^c&a)+x[i+3], 19)
+		}
+		for _, i := range []uint{0, 1, 2, 3} {
+			a = md4Rotate(a+(b&c|b&d|c&d)+x[i]+0x5a827999, 3)
+			d = md4Rotate(d+(a&b|a&c|b&c)+x[i+4]+0x5a827999, 5)
+			c = md4Rotate(c+(d&a|d&b|a&b)+x[i+8]+0x5a827999, 9)
+			b = md4Rotate(b+(c&d|c&a|d&a)+x[i+12]+0x5a827999, 13)
+		}
+		for _, i := range []uint{0, 2, 1, 3} {
+			a = md4Rotate(a+(b^c^d)+x[i]+0x6ed9eba1, 3)
+			d = md4Rotate(d+(a^b^c)+x[i+8]+0x6ed9eba1, 9)
+			c = md4Rotate(c+(d^a^b)+x[i+4]+0x6ed9eba1, 11)
+			b = md4Rotate(b+(c^d^a)+x[i+12]+0x6ed9eba1, 15)
+		}
+
+		a, b, c, d = a+aa, b+bb, c+cc, d+dd
+		msg = msg[64:]
+	}
+
+	sum := make([]byte, md4Size)
+	binary.LittleEndian.PutUint32(sum[0:], a)
+	binary.LittleEndian.PutUint32(sum[4:], b)
+	binary.LittleEndian.PutUint32(sum[8:], c)
+	binary.LittleEndian.PutUint32(sum[12:], d)
+	return sum
+}
+
+func md4Rotate(x uint32, n uint) uint32 {
+	return x<<n | x>>(32-n)
+}
@@ -0,0 +1,112 @@
+package ldap
+
+import (
+	"bytes"
+	enchex "encoding/hex"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := enchex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestMD4(t *testing.T) {
+	// Test suite from rfc 1320 appendix A.5
+	tests := map[string]string{
+		"":                           "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"a":                          "bde52cb31de33e46245e05fbdbd6fb24",
+		"abc":                        "a448017aaf21d8525fc10ae87aa6729d",
+		"abcdefghijklmnopqrstuvwxyz": "d79e1c308aa5bbcdeea8ed63df412da9",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	}
+	for input, expected := range tests {
+		if sum := enchex.EncodeToString(md4Sum([]byte(input))); sum != expected {
+			t.Errorf("MD4(%q): got %s, expected %s", input, sum, expected)
+		}
+	}
+}
+
+func TestNTLMv2Responses(t *testing.T) {
+	// Example from MS-NLMP section 4.2.4
+	ntHash := ntlmHash("Password")
+	if hash := enchex.EncodeToString(ntHash); hash != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Errorf("unexpected NT hash %s", hash)
+	}
+	serverChallenge := mustDecodeHex(t, "0123456789abcdef")
+	clientChallenge := mustDecodeHex(t, "aaaaaaaaaaaaaaaa")
+	targetInfo := mustDecodeHex(t, "02000c0044006f006d00610069006e0001000c0053006500720076006500720000000000")
+	ntResponse, lmResponse := ntlmV2Responses(ntHash, "Domain", "User", serverChallenge, clientChallenge, make([]byte, 8), targetInfo)
+
+	if proof := enchex.EncodeToString(ntResponse[:16]); proof != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Errorf("unexpected NTProofStr %s", proof)
+	}
+	if lm := enchex.EncodeToString(lmResponse); lm != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Errorf("unexpected LMv2 response %s", lm)
+	}
+}
+
+func newNTLMChallengeMessage(serverChallenge, targetInfo []byte) []byte {
+	msg := make([]byte, 48)
+	copy(msg, ntlmSignature)
+	msg[8] = 2
+	flags := uint32(ntlmNegotiateFlags | ntlmNegotiateTargetInfo)
+	msg[20], msg[21], msg[22], msg[23] = byte(flags), byte(flags>>8), byte(flags>>16), byte(flags>>24)
+	copy(msg[24:], serverChallenge)
+	msg[40], msg[42] = byte(len(targetInfo)), byte(len(targetInfo))
+	msg[44] = 48
+	return append(msg, targetInfo...)
+}
+
+func TestNTLMBind(t *testing.T) {
+	serverChallenge := []byte("\x01\x23\x45\x67\x89\xab\xcd\xef")
+	targetInfo := []byte("\x07\x00\x08\x00\x00\x01\x02\x03\x04\x05\x06\x07\x00\x00\x00\x00")
+	var authenticate []byte
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		saslCreds := request.Children[1].Children[2]
+		if mechanism := saslCreds.Children[0].Value.(string); mechanism != SASLMechanismGSSSPNEGO {
+			return []*ber.Packet{newSASLBindResponsePacket(messageID, LDAPResultAuthMethodNotSupported, nil)}
+		}
+		credentials := saslCreds.Children[1].Data.Bytes()
+		if bytes.Equal(credentials, ntlmNegotiateMessage()) {
+			return []*ber.Packet{newSASLBindResponsePacket(messageID, LDAPResultSaslBindInProgress, newNTLMChallengeMessage(serverChallenge, targetInfo))}
+		}
+		authenticate = credentials
+		return []*ber.Packet{newSASLBindResponsePacket(messageID, LDAPResultSuccess, nil)}
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.NTLMBindWithHash("Domain", "User", "a4f49c406510bdcab6824ee7c30fd852"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	// the client challenge is random: compute the expected message with the one sent
+	if len(authenticate) < 64+24+16+8+8 {
+		t.Fatalf("authenticate message too short: %x", authenticate)
+	}
+	clientChallenge := authenticate[64+24+16+8+8 : 64+24+16+8+8+8]
+	expected, err := ntlmAuthenticateMessage(newNTLMChallengeMessage(serverChallenge, targetInfo), "Domain", "User",
+		ntlmHash("Password"), clientChallenge, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(authenticate, expected) {
+		t.Errorf("unexpected authenticate message:\n%x\nexpected:\n%x", authenticate, expected)
+	}
+	if !bytes.Equal(authenticate[64+24+16+8:64+24+16+8+8], targetInfo[4:12]) {
+		t.Errorf("expected the server timestamp to be used")
+	}
+
+	if err := conn.NTLMBindWithHash("Domain", "User", "xyz"); err == nil {
+		t.Error("expected an invalid hash to be rejected")
+	}
+}
@@ -0,0 +1,100 @@
+package ldap
+
+import (
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Observer is notified of the completion of the operations performed on a Conn, for
+// example to collect latency and error rate metrics
+type Observer interface {
+	// ObserveRequest is called when the operation op, such as "bind" or "search",
+	// completes after d. resultCode is the result code returned by the server, or the
+	// ErrorXXX code of err if no result was received, for example ErrorNetwork when the
+	// connection is closed. err is the error of the operation, if any.
+	ObserveRequest(op string, resultCode uint16, d time.Duration, err error)
+}
+
+// observerOperations contains the operation names reported to the Observer
+var observerOperations = map[ber.Tag]string{
+	ApplicationBindRequest:     "bind",
+	ApplicationUnbindRequest:   "unbind",
+	ApplicationSearchRequest:   "search",
+	ApplicationModifyRequest:   "modify",
+	ApplicationAddRequest:      "add",
+	ApplicationDelRequest:      "delete",
+	ApplicationModifyDNRequest: "modifyDN",
+	ApplicationCompareRequest:  "compare",
+	ApplicationAbandonRequest:  "abandon",
+	ApplicationExtendedRequest: "extended",
+}
+
+// SetObserver sets the observer notified of the completion of each operation, or removes
+// it if observer is nil. It only applies to the operations started afterwards.
+func (l *Conn) SetObserver(observer Observer) {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	l.observer = observer
+}
+
+// observation tracks the outcome of an operation for the Observer and the logger
+type observation struct {
+	observer  Observer
+	logger    debugLogger
+	messageID int64
+	op        string
+	start     time.Time
+	result    *ber.Packet
+	err       error
+}
+
+func newObservation(observer Observer, logger debugLogger, packet *ber.Packet) *observation {
+	messageID, _ := packet.Children[0].Value.(int64)
+	return &observation{
+		observer:  observer,
+		logger:    logger,
+		messageID: messageID,
+		op:        observerOperations[packet.Children[1].Tag],
+		start:     time.Now(),
+	}
+}
+
+// record records a response, or the error reading it
+func (o *observation) record(packet *ber.Packet, err error) {
+	if err != nil {
+		o.err = err
+		return
+	}
+	if len(packet.Children) < 2 {
+		o.result = packet
+		return
+	}
+	switch packet.Children[1].Tag {
+	case ApplicationSearchResultEntry, ApplicationSearchResultReference, ApplicationIntermediateResponse:
+	default:
+		o.result = packet
+	}
+}
+
+// done notifies the observer and the logger of the outcome of the operation
+func (o *observation) done() {
+	err := o.err
+	if err == nil && o.result != nil {
+		err = GetLDAPError(o.result)
+	}
+	var resultCode uint16
+	if err != nil {
+		resultCode = ErrorNetwork
+		if ldapErr, ok := err.(*Error); ok {
+			resultCode = ldapErr.ResultCode
+		}
+	}
+	d := time.Since(o.start)
+	if o.observer != nil {
+		o.observer.ObserveRequest(o.op, resultCode, d, err)
+	}
+	if o.logger != nil {
+		o.logger.logRequest(o.messageID, o.op, resultCode, d, err)
+	}
+}
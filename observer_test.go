@@ -0,0 +1,91 @@
+package ldap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+type observedRequest struct {
+	op         string
+	resultCode uint16
+	err        error
+}
+
+type testObserver struct {
+	mu       sync.Mutex
+	requests []observedRequest
+}
+
+func (o *testObserver) ObserveRequest(op string, resultCode uint16, d time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requests = append(o.requests, observedRequest{op, resultCode, err})
+}
+
+func TestObserver(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		switch request.Children[1].Tag {
+		case ApplicationBindRequest:
+			return []*ber.Packet{newResultPacket(messageID, ApplicationBindResponse, LDAPResultInvalidCredentials)}
+		case ApplicationSearchRequest:
+			return []*ber.Packet{
+				newSearchResultEntryPacket(messageID, "cn=a,dc=example,dc=com", "cn", "a"),
+				newSearchResultDonePacket(messageID, LDAPResultSuccess),
+			}
+		}
+		return []*ber.Packet{newResultPacket(messageID, ApplicationDelResponse, LDAPResultNoSuchObject)}
+	})
+
+	observer := &testObserver{}
+	conn.SetObserver(observer)
+	runWithTimeout(t, time.Second, func() {
+		conn.Bind("cn=admin,dc=example,dc=com", "wrong")
+		conn.Search(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(cn=a)", nil, nil))
+		conn.Del(NewDelRequest("cn=missing,dc=example,dc=com", nil))
+		conn.Close()
+		conn.Del(NewDelRequest("cn=missing,dc=example,dc=com", nil))
+	})
+
+	expected := []observedRequest{
+		{"bind", LDAPResultInvalidCredentials, nil},
+		{"search", LDAPResultSuccess, nil},
+		{"delete", LDAPResultNoSuchObject, nil},
+		{"delete", ErrorNetwork, nil},
+	}
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if len(observer.requests) != len(expected) {
+		t.Fatalf("expected %d observed requests, got %d: %v", len(expected), len(observer.requests), observer.requests)
+	}
+	for i, request := range observer.requests {
+		if request.op != expected[i].op || request.resultCode != expected[i].resultCode {
+			t.Errorf("%d: expected %s with result code %d, got %s with %d", i, expected[i].op, expected[i].resultCode, request.op, request.resultCode)
+		}
+		if (request.resultCode == LDAPResultSuccess) != (request.err == nil) {
+			t.Errorf("%d: unexpected error %v for result code %d", i, request.err, request.resultCode)
+		}
+	}
+}
+
+func TestObserverUnset(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		return []*ber.Packet{newResultPacket(request.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess)}
+	})
+	defer conn.Close()
+
+	observer := &testObserver{}
+	conn.SetObserver(observer)
+	conn.SetObserver(nil)
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+	if len(observer.requests) != 0 {
+		t.Errorf("expected no observed request, got %v", observer.requests)
+	}
+}
@@ -35,11 +35,8 @@ type PasswordModifyResult struct {
 	Referral string
 }
 
-func (req *PasswordModifyRequest) appendTo(envelope *ber.Packet) error {
-	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedRequest, nil, "Password Modify Extended Operation")
-	pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, passwordModifyOID, "Extended Request Name: Password Modify OID"))
-
-	extendedRequestValue := ber.Encode(ber.ClassContext, ber.TypePrimitive, 1, nil, "Extended Request Value: Password Modify Request")
+// extendedRequest returns the extended request carrying the encoded password modify request
+func (req *PasswordModifyRequest) extendedRequest() *ExtendedRequest {
 	passwordModifyRequestValue := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Password Modify Request")
 	if req.UserIdentity != "" {
 		passwordModifyRequestValue.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, req.UserIdentity, "User Identity"))
@@ -50,13 +47,12 @@ func (req *PasswordModifyRequest) appendTo(envelope *ber.Packet) error {
 	if req.NewPassword != "" {
 		passwordModifyRequestValue.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 2, req.NewPassword, "New Password"))
 	}
-	extendedRequestValue.AppendChild(passwordModifyRequestValue)
 
-	pkt.AppendChild(extendedRequestValue)
-
-	envelope.AppendChild(pkt)
+	return NewExtendedRequest(passwordModifyOID, passwordModifyRequestValue.Bytes())
+}
 
-	return nil
+func (req *PasswordModifyRequest) appendTo(envelope *ber.Packet) error {
+	return req.extendedRequest().appendTo(envelope)
 }
 
 // NewPasswordModifyRequest creates a new PasswordModifyRequest
@@ -86,43 +82,27 @@ func NewPasswordModifyRequest(userIdentity string, oldPassword string, newPasswo
 
 // PasswordModify performs the modification request
 func (l *Conn) PasswordModify(passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error) {
-	msgCtx, err := l.doRequest(passwordModifyRequest)
-	if err != nil {
+	response, err := l.Extended(passwordModifyRequest.extendedRequest())
+	if response == nil {
 		return nil, err
 	}
-	defer l.finishMessage(msgCtx)
 
-	packet, err := l.readPacket(msgCtx)
+	result := &PasswordModifyResult{}
 	if err != nil {
-		return nil, err
+		if ldapErr, ok := err.(*Error); ok && len(ldapErr.Referral) > 0 {
+			result.Referral = ldapErr.Referral[0]
+		}
+		return result, err
 	}
 
-	result := &PasswordModifyResult{}
-
-	if packet.Children[1].Tag == ApplicationExtendedResponse {
-		err := GetLDAPError(packet)
+	if response.Value != nil {
+		passwordModifyResponseValue, err := ber.DecodePacketErr(response.Value)
 		if err != nil {
-			if IsErrorWithCode(err, LDAPResultReferral) {
-				for _, child := range packet.Children[1].Children {
-					if child.Tag == 3 {
-						result.Referral = child.Children[0].Value.(string)
-					}
-				}
-			}
-			return result, err
+			return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("failed to decode password modify response value: %s", err))
 		}
-	} else {
-		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
-	}
-
-	extendedResponse := packet.Children[1]
-	for _, child := range extendedResponse.Children {
-		if child.Tag == 11 {
-			passwordModifyResponseValue := ber.DecodePacket(child.Data.Bytes())
-			if len(passwordModifyResponseValue.Children) == 1 {
-				if passwordModifyResponseValue.Children[0].Tag == 0 {
-					result.GeneratedPassword = ber.DecodeString(passwordModifyResponseValue.Children[0].Data.Bytes())
-				}
+		for _, value := range passwordModifyResponseValue.Children {
+			if value.ClassType == ber.ClassContext && value.Tag == 0 {
+				result.GeneratedPassword = string(value.Data.Bytes())
 			}
 		}
 	}
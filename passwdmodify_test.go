@@ -0,0 +1,83 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestPasswordModifyRequestEncoding(t *testing.T) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	if err := NewPasswordModifyRequest("", "", "secret").appendTo(envelope); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	value, err := ber.DecodePacketErr(envelope.Children[0].Children[1].Data.Bytes())
+	if err != nil {
+		t.Fatalf("unable to decode request value: %s", err)
+	}
+	// An empty user identity must be omitted so the server targets the bound user
+	if len(value.Children) != 1 || value.Children[0].Tag != 2 {
+		t.Fatalf("expected only the new password in the request value, got %d fields", len(value.Children))
+	}
+	if got := string(value.Children[0].Data.Bytes()); got != "secret" {
+		t.Errorf("got new password %q, expected %q", got, "secret")
+	}
+}
+
+func TestPasswordModifyGeneratedPassword(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, request.Children[0].Value.(int64), "MessageID"))
+		response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+		response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+		response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+		response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+		value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Password Modify Response")
+		value.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, "generated", "Generated Password"))
+		responseValue := ber.Encode(ber.ClassContext, ber.TypePrimitive, 11, nil, "responseValue")
+		responseValue.Data.Write(value.Bytes())
+		response.AppendChild(responseValue)
+		packet.AppendChild(response)
+		ptc.SendResponse(packet)
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		result, err := conn.PasswordModify(NewPasswordModifyRequest("", "old", ""))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result.GeneratedPassword != "generated" {
+			t.Errorf("got generated password %q, expected %q", result.GeneratedPassword, "generated")
+		}
+	})
+}
+
+func TestPasswordModifyReferral(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		return []*ber.Packet{newReferralResultPacket(request.Children[0].Value.(int64), ApplicationExtendedResponse, "ldap://primary.example.com/")}
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		result, err := conn.PasswordModify(NewPasswordModifyRequest("", "old", "new"))
+		if !IsErrorWithCode(err, LDAPResultReferral) {
+			t.Fatalf("expected a referral error, got %v", err)
+		}
+		if result == nil || result.Referral != "ldap://primary.example.com/" {
+			t.Errorf("expected the referral in the result, got %+v", result)
+		}
+	})
+}
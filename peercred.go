@@ -0,0 +1,42 @@
+package ldap
+
+import (
+	"errors"
+	"net"
+)
+
+// PeerCred holds the credentials of the process at the other end of a Unix socket, as
+// reported by the kernel, see Conn.PeerCredentials
+type PeerCred struct {
+	// UID is the user ID of the peer process
+	UID uint32
+	// GID is the group ID of the peer process
+	GID uint32
+	// PID is the process ID of the peer process, or 0 if the platform does not report it
+	PID int32
+}
+
+// ErrNotUnixSocket is returned by Conn.PeerCredentials if the connection is not a Unix
+// socket connection, for example established with ldapi://
+var ErrNotUnixSocket = errors.New("ldap: not a unix socket")
+
+// PeerCredentials returns the credentials of the server process, which the kernel reports
+// for Unix socket connections established with ldapi://, with SO_PEERCRED on Linux and
+// LOCAL_PEERCRED on FreeBSD, DragonFly BSD and macOS. They allow to check that the server
+// is run by the expected user before sending it credentials, or cross-check the identity
+// it maps to an ExternalBind.
+//
+// An error with the ResultCode ErrorNetwork is returned if the connection is not a Unix
+// socket connection, matching ErrNotUnixSocket with errors.Is, or if the credentials cannot
+// be retrieved, for example on platforms which do not support it.
+func (l *Conn) PeerCredentials() (*PeerCred, error) {
+	uc, ok := l.conn.Conn.(*net.UnixConn)
+	if !ok {
+		return nil, NewError(ErrorNetwork, ErrNotUnixSocket)
+	}
+	cred, err := getPeerCred(uc)
+	if err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+	return cred, nil
+}
@@ -0,0 +1,54 @@
+//go:build (darwin || dragonfly || freebsd) && go1.9
+// +build darwin dragonfly freebsd
+// +build go1.9
+
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// solLocal and localPeerCred are the SOL_LOCAL level and its LOCAL_PEERCRED option, which
+// are not defined by the syscall package
+const (
+	solLocal      = 0x0
+	localPeerCred = 0x1
+)
+
+// xucred is the struct xucred returned by LOCAL_PEERCRED, see sys/ucred.h
+type xucred struct {
+	Version uint32
+	UID     uint32
+	NGroups int16
+	Groups  [16]uint32
+}
+
+// getPeerCred returns the credentials of the peer of uc with LOCAL_PEERCRED, which does
+// not report the process ID
+func getPeerCred(uc *net.UnixConn) (*PeerCred, error) {
+	rc, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var cred xucred
+	var errno syscall.Errno
+	err = rc.Control(func(fd uintptr) {
+		size := uint32(unsafe.Sizeof(cred))
+		_, _, errno = syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, solLocal, localPeerCred,
+			uintptr(unsafe.Pointer(&cred)), uintptr(unsafe.Pointer(&size)), 0)
+	})
+	if err == nil && errno != 0 {
+		err = errno
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: getting LOCAL_PEERCRED: %s", err)
+	}
+	if cred.NGroups < 1 {
+		return nil, errors.New("ldap: LOCAL_PEERCRED returned no group")
+	}
+	return &PeerCred{UID: cred.UID, GID: cred.Groups[0]}, nil
+}
@@ -0,0 +1,30 @@
+//go:build linux && go1.9
+// +build linux,go1.9
+
+package ldap
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// getPeerCred returns the credentials of the peer of uc with SO_PEERCRED
+func getPeerCred(uc *net.UnixConn) (*PeerCred, error) {
+	rc, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var ucred *syscall.Ucred
+	var credErr error
+	err = rc.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: getting SO_PEERCRED: %s", err)
+	}
+	return &PeerCred{UID: ucred.Uid, GID: ucred.Gid, PID: ucred.Pid}, nil
+}
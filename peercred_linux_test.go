@@ -0,0 +1,47 @@
+//go:build go1.9
+// +build go1.9
+
+package ldap
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPeerCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ldapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := net.Listen("unix", filepath.Join(dir, "ldapi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		if c, err := l.Accept(); err == nil {
+			defer c.Close()
+			ioutil.ReadAll(c)
+		}
+	}()
+
+	c, err := net.Dial("unix", filepath.Join(dir, "ldapi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := NewConn(c, false)
+	defer c.Close()
+
+	cred, err := conn.PeerCredentials()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if int(cred.UID) != os.Getuid() || int(cred.GID) != os.Getgid() || int(cred.PID) != os.Getpid() {
+		t.Errorf("expected the credentials of the test process, got %+v", cred)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || dragonfly || freebsd) || !go1.9
+// +build !linux,!darwin,!dragonfly,!freebsd !go1.9
+
+package ldap
+
+import (
+	"errors"
+	"net"
+)
+
+// getPeerCred is not supported on this platform
+func getPeerCred(uc *net.UnixConn) (*PeerCred, error) {
+	return nil, errors.New("ldap: peer credentials are not supported on this platform")
+}
@@ -0,0 +1,16 @@
+package ldap
+
+import (
+	"testing"
+)
+
+func TestPeerCredentialsNotUnixSocket(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	_, err := conn.PeerCredentials()
+	if !IsErrorWithCode(err, ErrorNetwork) || err.(*Error).Err != ErrNotUnixSocket {
+		t.Errorf("expected ErrNotUnixSocket, got %v", err)
+	}
+}
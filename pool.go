@@ -0,0 +1,153 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Pool maintains a bounded set of established connections which can be shared
+// between goroutines, one operation sequence at a time.
+//
+// Connections are handed out with whatever bind state they were returned with:
+// callers needing a specific identity should bind after Get.
+type Pool struct {
+	dial  func() (*Conn, error)
+	idle  chan *Conn
+	slots chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var errPoolClosed = errors.New("ldap: pool closed")
+
+// NewPool returns a pool of at most size connections, established with dialFn as needed
+func NewPool(dialFn func() (*Conn, error), size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{
+		dial:  dialFn,
+		idle:  make(chan *Conn, size),
+		slots: make(chan struct{}, size),
+	}
+}
+
+// Get returns an idle connection from the pool, or establishes a new one if the pool is
+// not full. Otherwise it waits until a connection is returned with Put or until ctx is done.
+//
+// Idle connections are checked with Ping before being handed out, and
+// discarded if the check fails.
+func (p *Pool) Get(ctx context.Context) (*Conn, error) {
+	for {
+		var conn *Conn
+		select {
+		case c, ok := <-p.idle:
+			if !ok {
+				return nil, NewError(ErrorNetwork, errPoolClosed)
+			}
+			conn = c
+		default:
+			select {
+			case c, ok := <-p.idle:
+				if !ok {
+					return nil, NewError(ErrorNetwork, errPoolClosed)
+				}
+				conn = c
+			case p.slots <- struct{}{}:
+				return p.open()
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		if err := p.validate(ctx, conn); err != nil {
+			p.discard(conn)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			continue
+		}
+		return conn, nil
+	}
+}
+
+func (p *Pool) open() (*Conn, error) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		<-p.slots
+		return nil, NewError(ErrorNetwork, errPoolClosed)
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (p *Pool) validate(ctx context.Context, conn *Conn) error {
+	if conn.IsClosing() {
+		return NewError(ErrorNetwork, errConnClosed)
+	}
+	return conn.Ping(ctx)
+}
+
+// Put returns a connection obtained with Get to the pool. It never blocks, even if the
+// connection was already returned or does not come from the pool.
+//
+// Connections which are closed, or which still have requests in flight (such as a bind
+// which was not waited for), are closed and discarded instead of being reused.
+func (p *Pool) Put(conn *Conn) {
+	if conn == nil {
+		return
+	}
+	if conn.IsClosing() || conn.hasOutstandingRequests() {
+		p.discard(conn)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.discard(conn)
+		return
+	}
+	select {
+	case p.idle <- conn:
+	default:
+		// more connections are returned than were handed out, by putting a connection
+		// twice or one which does not come from the pool: it holds no slot
+		conn.Close()
+	}
+}
+
+func (p *Pool) discard(conn *Conn) {
+	conn.Close()
+	// the slot is released without blocking, in case the connection does not come from
+	// the pool and holds none
+	select {
+	case <-p.slots:
+	default:
+	}
+}
+
+// Close closes all the idle connections of the pool. Connections currently handed out
+// are closed when they are returned with Put, and subsequent calls to Get fail.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.idle)
+	for conn := range p.idle {
+		conn.Close()
+		<-p.slots
+	}
+}
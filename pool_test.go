@@ -0,0 +1,139 @@
+package ldap
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestPool(t *testing.T, size int) (*Pool, chan *packetTranslatorConn) {
+	dialed := make(chan *packetTranslatorConn, size)
+	pool := NewPool(func() (*Conn, error) {
+		ptc := newPacketTranslatorConn()
+		conn := NewConn(ptc, false)
+		conn.Start()
+		dialed <- ptc
+		return conn, nil
+	}, size)
+	return pool, dialed
+}
+
+// TestPoolReuse tests that returned connections are validated and handed out again,
+// and that Get waits for a connection when the pool is full.
+func TestPoolReuse(t *testing.T) {
+	pool, dialed := newTestPool(t, 1)
+	defer pool.Close()
+
+	conn, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ptc := <-dialed
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	pool.Put(conn)
+
+	go func() {
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		ptc.SendResponse(newSearchResultDonePacket(request.Children[0].Value.(int64), LDAPResultSuccess))
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		reused, err := pool.Get(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if reused != conn {
+			t.Errorf("expected the idle connection to be reused")
+		}
+		pool.Put(reused)
+	})
+}
+
+// TestPoolDiscard tests that connections with requests in flight or failing
+// validation are not handed out again.
+func TestPoolDiscard(t *testing.T) {
+	pool, dialed := newTestPool(t, 1)
+	defer pool.Close()
+
+	conn, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testSendRequest(t, <-dialed, conn)
+	pool.Put(conn)
+	if !conn.IsClosing() {
+		t.Errorf("expected connection with a request in flight to be closed")
+	}
+
+	conn, err = pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ptc := <-dialed
+	pool.Put(conn)
+	ptc.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		fresh, err := pool.Get(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if fresh == conn {
+			t.Errorf("expected a broken connection to be discarded")
+		}
+		pool.Put(fresh)
+	})
+}
+
+func TestPoolClose(t *testing.T) {
+	pool, dialed := newTestPool(t, 2)
+
+	conn, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	<-dialed
+	pool.Close()
+	pool.Put(conn)
+
+	if !conn.IsClosing() {
+		t.Errorf("expected connection returned to a closed pool to be closed")
+	}
+	if _, err := pool.Get(context.Background()); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected ErrorNetwork, got %v", err)
+	}
+}
+
+// TestPoolPutExtraConn tests that returning more connections than were handed out
+// does not block the pool.
+func TestPoolPutExtraConn(t *testing.T) {
+	pool, dialed := newTestPool(t, 1)
+
+	conn, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	<-dialed
+	foreign := NewConn(newPacketTranslatorConn(), false)
+	foreign.Start()
+
+	runWithTimeout(t, time.Second, func() {
+		pool.Put(conn)
+		pool.Put(conn)
+		pool.Put(foreign)
+		if !foreign.IsClosing() {
+			t.Errorf("expected the connection which does not fit in the pool to be closed")
+		}
+		pool.Close()
+		pool.Put(foreign)
+	})
+}
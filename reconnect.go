@@ -0,0 +1,220 @@
+package ldap
+
+import (
+	"sync"
+)
+
+// ReconnectingConn wraps a Conn which is transparently redialed and rebound when it is
+// found to be broken, for example after an idle connection was reset by a firewall.
+//
+// An operation failing with an ErrorNetwork error is retried once on a new connection:
+//   - read-only operations (Search, SearchWithPaging, Compare, WhoAmI) are retried on
+//     any network error;
+//   - operations modifying the directory are retried only when the request could not be
+//     sent at all, so that a request which may already have been processed by the server
+//     is never replayed.
+//
+// Errors returned by the server, such as invalidCredentials, are never retried.
+type ReconnectingConn struct {
+	dial func() (*Conn, error)
+
+	mu   sync.Mutex
+	bind func(*Conn) error
+	conn *Conn
+	// closed is set by Close, after which no connection is dialed anymore
+	closed bool
+}
+
+// NewReconnectingConn establishes a connection with dialFn, binds it with bindFn if not nil,
+// and returns a ReconnectingConn which repeats both steps whenever the connection is lost
+func NewReconnectingConn(dialFn func() (*Conn, error), bindFn func(*Conn) error) (*ReconnectingConn, error) {
+	r := &ReconnectingConn{dial: dialFn, bind: bindFn}
+	conn, err := r.connect(bindFn)
+	if err != nil {
+		return nil, err
+	}
+	r.conn = conn
+	return r, nil
+}
+
+func (r *ReconnectingConn) connect(bindFn func(*Conn) error) (*Conn, error) {
+	conn, err := r.dial()
+	if err != nil {
+		return nil, err
+	}
+	if bindFn != nil {
+		if err := bindFn(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// current returns the connection to use, reconnecting first if it is known to be closed
+func (r *ReconnectingConn) current() (*Conn, error) {
+	r.mu.Lock()
+	conn, closed := r.conn, r.closed
+	r.mu.Unlock()
+	if closed {
+		return nil, NewError(ErrorNetwork, errConnClosed)
+	}
+	if conn.IsClosing() {
+		return r.reconnect(conn)
+	}
+	return conn, nil
+}
+
+// reconnect replaces the broken connection, unless another goroutine already did or the
+// ReconnectingConn was closed
+func (r *ReconnectingConn) reconnect(broken *Conn) (*Conn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, NewError(ErrorNetwork, errConnClosed)
+	}
+	if r.conn != broken {
+		return r.conn, nil
+	}
+	conn, err := r.connect(r.bind)
+	if err != nil {
+		return nil, err
+	}
+	broken.Close()
+	r.conn = conn
+	return conn, nil
+}
+
+// do runs fn on the current connection, retrying it once on a new connection if
+// it fails with a network error which retry accepts
+func (r *ReconnectingConn) do(retry func(error) bool, fn func(*Conn) error) error {
+	conn, err := r.current()
+	if err != nil {
+		return err
+	}
+	err = fn(conn)
+	if err == nil || !retry(err) {
+		return err
+	}
+	conn, rerr := r.reconnect(conn)
+	if rerr != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// isNotSentError reports whether err means the request was not sent to the server
+func isNotSentError(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.ResultCode == ErrorNetwork && e.Err == errConnClosed
+}
+
+func isNetworkError(err error) bool {
+	return IsErrorWithCode(err, ErrorNetwork)
+}
+
+// Conn returns the current underlying connection
+func (r *ReconnectingConn) Conn() *Conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.conn
+}
+
+// Close closes the underlying connection for good: the operations performed afterwards fail
+// with a network error instead of reconnecting
+func (r *ReconnectingConn) Close() {
+	r.mu.Lock()
+	r.closed = true
+	conn := r.conn
+	r.mu.Unlock()
+	conn.Close()
+}
+
+// Bind performs a bind with the given username and password, which is then
+// replayed on every new connection in place of the bind function given to NewReconnectingConn
+func (r *ReconnectingConn) Bind(username, password string) error {
+	bindFn := func(conn *Conn) error {
+		return conn.Bind(username, password)
+	}
+	err := r.do(isNetworkError, bindFn)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.bind = bindFn
+	r.mu.Unlock()
+	return nil
+}
+
+// Search performs the given search request, see Conn.Search
+func (r *ReconnectingConn) Search(searchRequest *SearchRequest) (result *SearchResult, err error) {
+	err = r.do(isNetworkError, func(conn *Conn) error {
+		result, err = conn.Search(searchRequest)
+		return err
+	})
+	return result, err
+}
+
+// SearchWithPaging performs the given search request with paging, see Conn.SearchWithPaging
+func (r *ReconnectingConn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32) (result *SearchResult, err error) {
+	err = r.do(isNetworkError, func(conn *Conn) error {
+		result, err = conn.SearchWithPaging(searchRequest, pagingSize)
+		return err
+	})
+	return result, err
+}
+
+// Compare checks whether the given attribute of the entry has the given value, see Conn.Compare
+func (r *ReconnectingConn) Compare(dn, attribute, value string) (matched bool, err error) {
+	err = r.do(isNetworkError, func(conn *Conn) error {
+		matched, err = conn.Compare(dn, attribute, value)
+		return err
+	})
+	return matched, err
+}
+
+// WhoAmI returns the authorization identity of the session, see Conn.WhoAmI
+func (r *ReconnectingConn) WhoAmI(controls []Control) (result *WhoAmIResult, err error) {
+	err = r.do(isNetworkError, func(conn *Conn) error {
+		result, err = conn.WhoAmI(controls)
+		return err
+	})
+	return result, err
+}
+
+// Add performs the given add request, see Conn.Add
+func (r *ReconnectingConn) Add(addRequest *AddRequest) error {
+	return r.do(isNotSentError, func(conn *Conn) error {
+		return conn.Add(addRequest)
+	})
+}
+
+// Del performs the given delete request, see Conn.Del
+func (r *ReconnectingConn) Del(delRequest *DelRequest) error {
+	return r.do(isNotSentError, func(conn *Conn) error {
+		return conn.Del(delRequest)
+	})
+}
+
+// Modify performs the given modify request, see Conn.Modify
+func (r *ReconnectingConn) Modify(modifyRequest *ModifyRequest) error {
+	return r.do(isNotSentError, func(conn *Conn) error {
+		return conn.Modify(modifyRequest)
+	})
+}
+
+// ModifyDN performs the given modify DN request, see Conn.ModifyDN
+func (r *ReconnectingConn) ModifyDN(m *ModifyDNRequest) error {
+	return r.do(isNotSentError, func(conn *Conn) error {
+		return conn.ModifyDN(m)
+	})
+}
+
+// PasswordModify performs the given password modify request, see Conn.PasswordModify
+func (r *ReconnectingConn) PasswordModify(passwordModifyRequest *PasswordModifyRequest) (result *PasswordModifyResult, err error) {
+	err = r.do(isNotSentError, func(conn *Conn) error {
+		result, err = conn.PasswordModify(passwordModifyRequest)
+		return err
+	})
+	return result, err
+}
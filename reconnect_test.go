@@ -0,0 +1,194 @@
+package ldap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func newResultPacket(messageID int64, application ber.Tag, resultCode uint16) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, application, nil, "Response")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	packet.AppendChild(result)
+	return packet
+}
+
+// reconnectTestServer counts the requests received on the connections it dials, and
+// drops the connection without answering when an add request is received if dropAdds is set
+type reconnectTestServer struct {
+	mu         sync.Mutex
+	dials      int
+	binds      int
+	adds       int
+	dropAdds   bool
+	bindResult uint16
+	conns      []*packetTranslatorConn
+}
+
+func (s *reconnectTestServer) dial() (*Conn, error) {
+	ptc := newPacketTranslatorConn()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	s.mu.Lock()
+	s.dials++
+	s.conns = append(s.conns, ptc)
+	s.mu.Unlock()
+	go func() {
+		defer ptc.Close()
+		for {
+			request, err := ptc.ReceiveRequest()
+			if err != nil {
+				return
+			}
+			messageID := request.Children[0].Value.(int64)
+			var response *ber.Packet
+			s.mu.Lock()
+			switch request.Children[1].Tag {
+			case ApplicationBindRequest:
+				s.binds++
+				response = newResultPacket(messageID, ApplicationBindResponse, s.bindResult)
+			case ApplicationAddRequest:
+				s.adds++
+				if s.dropAdds {
+					s.mu.Unlock()
+					return
+				}
+				response = newResultPacket(messageID, ApplicationAddResponse, LDAPResultSuccess)
+			case ApplicationSearchRequest:
+				response = newSearchResultDonePacket(messageID, LDAPResultSuccess)
+			}
+			s.mu.Unlock()
+			if err := ptc.SendResponse(response); err != nil {
+				return
+			}
+		}
+	}()
+	return conn, nil
+}
+
+// breakConn closes the last dialed connection as a reset by the network would
+func (s *reconnectTestServer) breakConn(r *ReconnectingConn) {
+	s.mu.Lock()
+	ptc := s.conns[len(s.conns)-1]
+	s.mu.Unlock()
+	ptc.Close()
+	conn := r.Conn()
+	for !conn.IsClosing() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *reconnectTestServer) counts() (dials, binds, adds int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials, s.binds, s.adds
+}
+
+// TestReconnectingConnReconnects tests that a broken connection is redialed and rebound.
+func TestReconnectingConnReconnects(t *testing.T) {
+	server := &reconnectTestServer{}
+	r, err := NewReconnectingConn(server.dial, func(conn *Conn) error {
+		return conn.Bind("cn=admin,dc=example,dc=com", "secret")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer r.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		server.breakConn(r)
+		req := NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		if _, err := r.Search(req); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		server.breakConn(r)
+		if err := r.Add(NewAddRequest("cn=new,dc=example,dc=com", nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	if dials, binds, adds := server.counts(); dials != 3 || binds != 3 || adds != 1 {
+		t.Errorf("expected 3 dials, 3 binds and 1 add, got %d, %d and %d", dials, binds, adds)
+	}
+}
+
+// TestReconnectingConnNoReplay tests that a request which may have been processed by the
+// server is not sent again.
+func TestReconnectingConnNoReplay(t *testing.T) {
+	server := &reconnectTestServer{dropAdds: true}
+	r, err := NewReconnectingConn(server.dial, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer r.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		err := r.Add(NewAddRequest("cn=new,dc=example,dc=com", nil))
+		if !IsErrorWithCode(err, ErrorNetwork) {
+			t.Errorf("expected ErrorNetwork, got %v", err)
+		}
+	})
+
+	if dials, _, adds := server.counts(); dials != 1 || adds != 1 {
+		t.Errorf("expected 1 dial and 1 add, got %d and %d", dials, adds)
+	}
+}
+
+// TestReconnectingConnBindError tests that LDAP errors of the replayed bind are returned.
+func TestReconnectingConnBindError(t *testing.T) {
+	server := &reconnectTestServer{}
+	r, err := NewReconnectingConn(server.dial, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer r.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := r.Bind("cn=admin,dc=example,dc=com", "secret"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		server.mu.Lock()
+		server.bindResult = LDAPResultInvalidCredentials
+		server.mu.Unlock()
+		server.breakConn(r)
+
+		req := NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		if _, err := r.Search(req); !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+			t.Errorf("expected LDAPResultInvalidCredentials, got %v", err)
+		}
+	})
+
+	if dials, binds, _ := server.counts(); dials != 2 || binds != 2 {
+		t.Errorf("expected 2 dials and 2 binds, got %d and %d", dials, binds)
+	}
+}
+
+// TestReconnectingConnClose tests that a closed ReconnectingConn does not reconnect.
+func TestReconnectingConnClose(t *testing.T) {
+	server := &reconnectTestServer{}
+	r, err := NewReconnectingConn(server.dial, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		req := NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		if _, err := r.Search(req); !IsErrorWithCode(err, ErrorNetwork) {
+			t.Errorf("expected ErrorNetwork, got %v", err)
+		}
+		if err := r.Bind("cn=admin,dc=example,dc=com", "secret"); !IsErrorWithCode(err, ErrorNetwork) {
+			t.Errorf("expected ErrorNetwork, got %v", err)
+		}
+	})
+
+	if dials, _, _ := server.counts(); dials != 1 {
+		t.Errorf("expected 1 dial, got %d", dials)
+	}
+}
@@ -0,0 +1,188 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// DefaultMaxReferralHops is the default number of successive referrals followed for an operation
+const DefaultMaxReferralHops = 5
+
+// ReferralConfig configures how the referrals returned by servers are followed
+type ReferralConfig struct {
+	// MaxHops limits the number of successive referrals followed for an operation.
+	// DefaultMaxReferralHops is used when zero.
+	MaxHops int
+	// Dial connects to the server of a referral, given the scheme and host of the
+	// referral URL, such as "ldaps://dc2.example.com:636". DialURL is used when nil,
+	// which uses TLS for ldaps:// URLs.
+	Dial func(addr string) (*Conn, error)
+	// Bind authenticates the connections to the servers of referrals, for instance
+	// with the credentials used on the original connection. The connections are left
+	// anonymous when nil.
+	Bind func(conn *Conn) error
+	// Allow reports whether the referral URL may be followed, before its server is dialed,
+	// so that a server cannot make the client connect to arbitrary hosts, for instance with
+	// ReferralHostAllowlist. The search references which are not allowed are kept in
+	// SearchResult.Referrals, and the operations receiving a referral result which is not
+	// allowed fail with LDAPResultReferral. All the referrals are followed when nil.
+	Allow func(url string) bool
+}
+
+func (c *ReferralConfig) maxHops() int {
+	if c.MaxHops == 0 {
+		return DefaultMaxReferralHops
+	}
+	return c.MaxHops
+}
+
+// ReferralHostAllowlist returns a ReferralConfig Allow function allowing the referral URLs
+// whose host is one of the given hosts, which are compared case insensitively. A host with
+// a port, such as "dc1.example.com:636", only allows the URLs with this port, and a host
+// without a port allows the URLs with any port.
+func ReferralHostAllowlist(hosts ...string) func(url string) bool {
+	return func(rawURL string) bool {
+		lurl, err := url.Parse(rawURL)
+		if err != nil {
+			return false
+		}
+		hostname := lurl.Host
+		if host, _, err := net.SplitHostPort(lurl.Host); err == nil {
+			hostname = host
+		}
+		for _, host := range hosts {
+			if strings.EqualFold(host, lurl.Host) || strings.EqualFold(host, hostname) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FollowReferrals makes Search and Modify operations follow the referrals returned by
+// the server, using the given configuration: the operation is performed again on the
+// referred server and, for searches, the entries are merged with the ones received on
+// this connection. Referrals which could not be followed are kept in SearchResult.Referrals.
+//
+// Referrals are not followed when config is nil, which is the default.
+func (l *Conn) FollowReferrals(config *ReferralConfig) {
+	l.handlersMutex.Lock()
+	defer l.handlersMutex.Unlock()
+	l.referralConfig = config
+}
+
+func (l *Conn) getReferralConfig() *ReferralConfig {
+	l.handlersMutex.Lock()
+	defer l.handlersMutex.Unlock()
+	return l.referralConfig
+}
+
+// referralURL holds the parts of an LDAP URL (rfc4516) used to follow a referral
+type referralURL struct {
+	// addr is the scheme and host of the URL
+	addr   string
+	dn     string
+	scope  int
+	filter string
+}
+
+func parseReferralURL(rawURL string) (*referralURL, error) {
+	lurl, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if lurl.Scheme != "ldap" && lurl.Scheme != "ldaps" {
+		return nil, fmt.Errorf("unsupported scheme %q", lurl.Scheme)
+	}
+	if lurl.Host == "" {
+		return nil, errors.New("missing host")
+	}
+
+	ref := &referralURL{
+		addr:  lurl.Scheme + "://" + lurl.Host,
+		dn:    strings.TrimPrefix(lurl.Path, "/"),
+		scope: -1,
+	}
+	// the query holds attributes?scope?filter?extensions
+	parts := strings.Split(lurl.RawQuery, "?")
+	if len(parts) > 1 {
+		switch parts[1] {
+		case "base":
+			ref.scope = ScopeBaseObject
+		case "one":
+			ref.scope = ScopeSingleLevel
+		case "sub":
+			ref.scope = ScopeWholeSubtree
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if ref.filter, err = url.QueryUnescape(parts[2]); err != nil {
+			return nil, err
+		}
+	}
+	return ref, nil
+}
+
+// getReferral returns the referral URLs of the LDAPResult of a response packet
+func getReferral(packet *ber.Packet) []string {
+	var referral []string
+	if len(packet.Children) < 2 {
+		return referral
+	}
+	for _, child := range packet.Children[1].Children {
+		if child.ClassType == ber.ClassContext && child.Tag == 3 {
+			for _, uri := range child.Children {
+				referral = append(referral, string(uri.Data.Bytes()))
+			}
+		}
+	}
+	return referral
+}
+
+// followReferral performs fn on the server of the first of the given alternative referral
+// URLs which can be connected to. hops is the number of referrals already followed.
+// followed reports whether fn was called.
+func followReferral(config *ReferralConfig, referral []string, hops int, fn func(conn *Conn, ref *referralURL) error) (followed bool, err error) {
+	if hops >= config.maxHops() {
+		return false, NewError(LDAPResultReferralLimitExceeded, errors.New("ldap: referral hop limit exceeded"))
+	}
+	dial := config.Dial
+	if dial == nil {
+		dial = func(addr string) (*Conn, error) {
+			return DialURL(addr)
+		}
+	}
+
+	err = NewError(LDAPResultReferral, errors.New("ldap: empty referral"))
+	for _, rawURL := range referral {
+		ref, parseErr := parseReferralURL(rawURL)
+		if parseErr != nil {
+			err = NewError(LDAPResultReferral, fmt.Errorf("ldap: invalid referral %q: %s", rawURL, parseErr))
+			continue
+		}
+		if config.Allow != nil && !config.Allow(rawURL) {
+			err = NewError(LDAPResultReferral, fmt.Errorf("ldap: referral %q not allowed", rawURL))
+			continue
+		}
+		var conn *Conn
+		conn, err = dial(ref.addr)
+		if err != nil {
+			continue
+		}
+		if config.Bind != nil {
+			if err = config.Bind(conn); err != nil {
+				conn.Close()
+				continue
+			}
+		}
+		err = fn(conn, ref)
+		conn.Close()
+		return true, err
+	}
+	return false, err
+}
@@ -0,0 +1,212 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// newTestServerConn returns a connection to a fake server answering each request with the
+// responses returned by respond.
+func newTestServerConn(respond func(request *ber.Packet) []*ber.Packet) *Conn {
+	ptc := newPacketTranslatorConn()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	go func() {
+		defer ptc.Close()
+		for {
+			request, err := ptc.ReceiveRequest()
+			if err != nil {
+				return
+			}
+			for _, response := range respond(request) {
+				if err := ptc.SendResponse(response); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return conn
+}
+
+func newSearchResultReferencePacket(messageID int64, uris ...string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	reference := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultReference, nil, "Search Result Reference")
+	for _, uri := range uris {
+		reference.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, uri, "URI"))
+	}
+	packet.AppendChild(reference)
+	return packet
+}
+
+func newReferralResultPacket(messageID int64, application ber.Tag, uris ...string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, application, nil, "Response")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultReferral), "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	referral := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "Referral")
+	for _, uri := range uris {
+		referral.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, uri, "URI"))
+	}
+	result.AppendChild(referral)
+	packet.AppendChild(result)
+	return packet
+}
+
+func TestSearchFollowReferrals(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		return []*ber.Packet{
+			newSearchResultEntryPacket(messageID, "cn=a,dc=example,dc=com"),
+			newSearchResultReferencePacket(messageID, "ldap://dc2.example.com/ou=b,dc=example,dc=com"),
+			newSearchResultReferencePacket(messageID, "ldap://unreachable.example.com/ou=c,dc=example,dc=com"),
+			newSearchResultDonePacket(messageID, LDAPResultSuccess),
+		}
+	})
+	defer conn.Close()
+
+	var dialed []string
+	var bound int
+	conn.FollowReferrals(&ReferralConfig{
+		Dial: func(addr string) (*Conn, error) {
+			dialed = append(dialed, addr)
+			if addr != "ldap://dc2.example.com" {
+				return nil, NewError(ErrorNetwork, errPacketTranslatorConnClosed)
+			}
+			return newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+				messageID := request.Children[0].Value.(int64)
+				if baseDN := request.Children[1].Children[0].Value.(string); baseDN != "ou=b,dc=example,dc=com" {
+					t.Errorf("unexpected base DN for referred search: %s", baseDN)
+				}
+				return []*ber.Packet{
+					newSearchResultEntryPacket(messageID, "cn=b,ou=b,dc=example,dc=com"),
+					newSearchResultDonePacket(messageID, LDAPResultSuccess),
+				}
+			}), nil
+		},
+		Bind: func(conn *Conn) error {
+			bound++
+			return nil
+		},
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		result, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(result.Entries) != 2 || result.Entries[1].DN != "cn=b,ou=b,dc=example,dc=com" {
+			t.Errorf("expected entries from both servers, got %d", len(result.Entries))
+		}
+		if len(result.Referrals) != 1 || result.Referrals[0] != "ldap://unreachable.example.com/ou=c,dc=example,dc=com" {
+			t.Errorf("expected the unreachable referral to be kept, got %v", result.Referrals)
+		}
+	})
+	if len(dialed) != 2 || bound != 1 {
+		t.Errorf("unexpected dials %v and binds %d", dialed, bound)
+	}
+}
+
+func TestSearchReferralAllowlist(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		return []*ber.Packet{
+			newSearchResultReferencePacket(messageID, "ldap://DC2.example.com:389/ou=b,dc=example,dc=com"),
+			newSearchResultReferencePacket(messageID, "ldap://169.254.169.254/ou=c,dc=example,dc=com"),
+			newSearchResultDonePacket(messageID, LDAPResultSuccess),
+		}
+	})
+	defer conn.Close()
+
+	var dialed []string
+	conn.FollowReferrals(&ReferralConfig{
+		Dial: func(addr string) (*Conn, error) {
+			dialed = append(dialed, addr)
+			return newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+				messageID := request.Children[0].Value.(int64)
+				return []*ber.Packet{
+					newSearchResultEntryPacket(messageID, "cn=b,ou=b,dc=example,dc=com"),
+					newSearchResultDonePacket(messageID, LDAPResultSuccess),
+				}
+			}), nil
+		},
+		Allow: ReferralHostAllowlist("dc1.example.com", "dc2.example.com"),
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		result, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(result.Entries) != 1 {
+			t.Errorf("expected the entry of the allowed referral, got %d entries", len(result.Entries))
+		}
+		if len(result.Referrals) != 1 || result.Referrals[0] != "ldap://169.254.169.254/ou=c,dc=example,dc=com" {
+			t.Errorf("expected the disallowed referral to be kept, got %v", result.Referrals)
+		}
+	})
+	if len(dialed) != 1 || dialed[0] != "ldap://DC2.example.com:389" {
+		t.Errorf("unexpected dials %v", dialed)
+	}
+}
+
+func TestReferralHostAllowlist(t *testing.T) {
+	allow := ReferralHostAllowlist("dc1.example.com", "dc2.example.com:636")
+	for url, expected := range map[string]bool{
+		"ldap://dc1.example.com/dc=example,dc=com":      true,
+		"ldaps://DC1.example.com:636/dc=example,dc=com": true,
+		"ldaps://dc2.example.com:636/dc=example,dc=com": true,
+		"ldap://dc2.example.com:389/dc=example,dc=com":  false,
+		"ldap://dc2.example.com/dc=example,dc=com":      false,
+		"ldap://dc1.example.com.evil.com/":              false,
+		"ldap://localhost/":                             false,
+	} {
+		if allowed := allow(url); allowed != expected {
+			t.Errorf("%s: expected %t, got %t", url, expected, allowed)
+		}
+	}
+}
+
+func TestModifyReferralHopLimit(t *testing.T) {
+	referring := func(request *ber.Packet) []*ber.Packet {
+		return []*ber.Packet{newReferralResultPacket(request.Children[0].Value.(int64), ApplicationModifyResponse, "ldap://dc2.example.com/cn=a,dc=example,dc=com")}
+	}
+	conn := newTestServerConn(referring)
+	defer conn.Close()
+
+	dials := 0
+	conn.FollowReferrals(&ReferralConfig{
+		MaxHops: 2,
+		Dial: func(addr string) (*Conn, error) {
+			dials++
+			return newTestServerConn(referring), nil
+		},
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		err := conn.Modify(NewModifyRequest("cn=a,dc=example,dc=com", nil))
+		if !IsErrorWithCode(err, LDAPResultReferralLimitExceeded) {
+			t.Errorf("expected LDAPResultReferralLimitExceeded, got %v", err)
+		}
+	})
+	if dials != 2 {
+		t.Errorf("expected 2 referrals to be followed, got %d", dials)
+	}
+}
+
+func TestParseReferralURL(t *testing.T) {
+	ref, err := parseReferralURL("ldaps://dc2.example.com:636/ou=b,dc=example,dc=com??one?(uid=a%20b)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ref.addr != "ldaps://dc2.example.com:636" || ref.dn != "ou=b,dc=example,dc=com" || ref.scope != ScopeSingleLevel || ref.filter != "(uid=a b)" {
+		t.Errorf("unexpected parsed referral %+v", ref)
+	}
+	if _, err := parseReferralURL("http://example.com/"); err == nil {
+		t.Errorf("expected an error for a non LDAP URL")
+	}
+}
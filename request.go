@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
@@ -21,7 +22,19 @@ func (f requestFunc) appendTo(p *ber.Packet) error {
 	return f(p)
 }
 
-func (l *Conn) doRequest(req request) (*messageContext, error) {
+func (l *Conn) doRequest(ctx context.Context, req request) (*messageContext, error) {
+	return l.doRequestWithFlags(ctx, req, 0)
+}
+
+func (l *Conn) doRequestWithFlags(ctx context.Context, req request, flags sendMessageFlags) (*messageContext, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// the deadline of ctx overrides the timeout of the connection
+	if _, ok := ctx.Deadline(); ok {
+		flags |= noTimeout
+	}
+
 	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
 	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, l.nextMessageID(), "MessageID"))
 	if err := req.appendTo(packet); err != nil {
@@ -29,25 +42,43 @@ func (l *Conn) doRequest(req request) (*messageContext, error) {
 	}
 
 	if l.Debug {
-		l.Debug.PrintPacket(packet)
+		l.debugPacket(packet)
 	}
 
-	msgCtx, err := l.sendMessage(packet)
+	msgCtx, err := l.sendMessageWithFlags(packet, flags)
 	if err != nil {
 		return nil, err
 	}
-	l.Debug.Printf("%d: returning", msgCtx.id)
+	l.debugf("%d: returning", msgCtx.id)
 	return msgCtx, nil
 }
 
-func (l *Conn) readPacket(msgCtx *messageContext) (*ber.Packet, error) {
-	l.Debug.Printf("%d: waiting for response", msgCtx.id)
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return nil, NewError(ErrorNetwork, errRespChanClosed)
+// readPacket waits for the next response to the given message. If ctx is
+// done first, ctx.Err() is returned; the caller is still expected to call
+// finishMessage so that the message ID is released.
+func (l *Conn) readPacket(ctx context.Context, msgCtx *messageContext) (*ber.Packet, error) {
+	packet, err := l.readResponse(ctx, msgCtx)
+	if msgCtx.observation != nil {
+		msgCtx.observation.record(packet, err)
+	}
+	return packet, err
+}
+
+func (l *Conn) readResponse(ctx context.Context, msgCtx *messageContext) (*ber.Packet, error) {
+	l.debugf("%d: waiting for response", msgCtx.id)
+	var packetResponse *PacketResponse
+	select {
+	case <-ctx.Done():
+		l.debugf("%d: context done while waiting for response: %s", msgCtx.id, ctx.Err())
+		return nil, ctx.Err()
+	case resp, ok := <-msgCtx.responses:
+		if !ok {
+			return nil, NewError(ErrorNetwork, errRespChanClosed)
+		}
+		packetResponse = resp
 	}
 	packet, err := packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
+	l.debugf("%d: got response %p", msgCtx.id, packet)
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +91,7 @@ func (l *Conn) readPacket(msgCtx *messageContext) (*ber.Packet, error) {
 		if err = addLDAPDescriptions(packet); err != nil {
 			return nil, err
 		}
-		l.Debug.PrintPacket(packet)
+		l.debugPacket(packet)
 	}
 	return packet, nil
 }
@@ -0,0 +1,79 @@
+package ldap
+
+import (
+	"context"
+	"time"
+)
+
+// RetryPolicy specifies how StartTLS and the binds which can be safely repeated are retried
+// when they fail with a transient error, see Conn.WithRetry
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of an operation, including the first one.
+	// The operations are not retried if it is less than 2.
+	MaxAttempts int
+	// Backoff returns the delay before the given retry, 1 for the first one. There is no
+	// delay if it is nil. See ExponentialBackoff.
+	Backoff func(retry int) time.Duration
+	// Retryable returns whether an operation failing with err can be retried. If it is nil,
+	// the operations failing with an ErrorNetwork error or the LDAPResultBusy or
+	// LDAPResultUnavailable result codes are retried.
+	Retryable func(err error) bool
+}
+
+// ExponentialBackoff returns a RetryPolicy Backoff doubling the delay at every retry,
+// starting with initial, up to max if it is not zero
+func ExponentialBackoff(initial, max time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		delay := initial
+		for i := 1; i < retry && (max <= 0 || delay < max); i++ {
+			delay *= 2
+		}
+		if max > 0 && delay > max {
+			delay = max
+		}
+		return delay
+	}
+}
+
+// isTransientError returns whether err is a network error, or one of the result codes a
+// server returns when it is temporarily unable to process the operation
+func isTransientError(err error) bool {
+	return IsErrorWithCode(err, ErrorNetwork) || IsErrorWithCode(err, LDAPResultBusy) || IsErrorWithCode(err, LDAPResultUnavailable)
+}
+
+// WithRetry makes StartTLS and the binds, including the SASL binds whose exchange is then
+// restarted from the beginning, retry on the connection when they fail with an error which
+// policy considers transient, such as a server behind a load balancer temporarily returning
+// LDAPResultBusy, and returns the connection. SASLBind is not retried, as it performs a
+// single step of an exchange driven by the caller. The operations are retried on the same
+// connection, so that they are not retried once the connection is closed: see
+// ReconnectingConn to establish a new connection. Authentication failures, such as
+// LDAPResultInvalidCredentials, are not retried by the default policy.
+func (l *Conn) WithRetry(policy RetryPolicy) *Conn {
+	l.retryPolicy.Store(policy)
+	return l
+}
+
+// withRetry runs fn, retrying it as specified by the retry policy of the connection until
+// it succeeds or ctx is done
+func (l *Conn) withRetry(ctx context.Context, fn func() error) error {
+	policy, _ := l.retryPolicy.Load().(RetryPolicy)
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = isTransientError
+	}
+
+	err := fn()
+	for retry := 1; retry < policy.MaxAttempts && err != nil && retryable(err) && !l.IsClosing(); retry++ {
+		if policy.Backoff != nil {
+			l.debugf("retrying in %s after %s", policy.Backoff(retry), err)
+			select {
+			case <-time.After(policy.Backoff(retry)):
+			case <-ctx.Done():
+				return err
+			}
+		}
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package ldap
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestRetryBind(t *testing.T) {
+	var attempts int32
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			return []*ber.Packet{newResultPacket(messageID, ApplicationBindResponse, LDAPResultBusy)}
+		}
+		return []*ber.Packet{newResultPacket(messageID, ApplicationBindResponse, LDAPResultSuccess)}
+	})
+	defer conn.Close()
+	conn.WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: ExponentialBackoff(time.Millisecond, 10*time.Millisecond)})
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.Bind("cn=admin", "secret"); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		if n := atomic.LoadInt32(&attempts); n != 3 {
+			t.Errorf("expected 3 attempts, got %d", n)
+		}
+	})
+}
+
+func TestRetryBindInvalidCredentials(t *testing.T) {
+	var attempts int32
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		atomic.AddInt32(&attempts, 1)
+		messageID := request.Children[0].Value.(int64)
+		return []*ber.Packet{newResultPacket(messageID, ApplicationBindResponse, LDAPResultInvalidCredentials)}
+	})
+	defer conn.Close()
+	conn.WithRetry(RetryPolicy{MaxAttempts: 3})
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.Bind("cn=admin", "wrong"); !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+			t.Errorf("expected LDAPResultInvalidCredentials, got %v", err)
+		}
+		if n := atomic.LoadInt32(&attempts); n != 1 {
+			t.Errorf("expected 1 attempt, got %d", n)
+		}
+	})
+}
+
+func TestRetryStartTLS(t *testing.T) {
+	var attempts int32
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		atomic.AddInt32(&attempts, 1)
+		messageID := request.Children[0].Value.(int64)
+		return []*ber.Packet{newResultPacket(messageID, ApplicationExtendedResponse, LDAPResultUnavailable)}
+	})
+	defer conn.Close()
+	conn.WithRetry(RetryPolicy{MaxAttempts: 4})
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.StartTLS(nil); !IsErrorWithCode(err, LDAPResultUnavailable) {
+			t.Errorf("expected LDAPResultUnavailable, got %v", err)
+		}
+		if n := atomic.LoadInt32(&attempts); n != 4 {
+			t.Errorf("expected 4 attempts, got %d", n)
+		}
+	})
+}
+
+func TestRetrySASLBinds(t *testing.T) {
+	binds := map[string]func(conn *Conn) error{
+		"DIGEST-MD5": func(conn *Conn) error { return conn.DigestMD5Bind("joe", "", "secret") },
+		"SCRAM":      func(conn *Conn) error { return conn.SCRAMBind("joe", "secret", SASLMechanismSCRAMSHA256) },
+		"NTLM":       func(conn *Conn) error { return conn.NTLMBind("EXAMPLE", "joe", "secret") },
+		"GSSAPI": func(conn *Conn) error {
+			return conn.GSSAPIBind(&fakeGSSAPIClient{t: t}, "ldap/dc1.example.com", "")
+		},
+	}
+	for name, bind := range binds {
+		var attempts int32
+		conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+			atomic.AddInt32(&attempts, 1)
+			messageID := request.Children[0].Value.(int64)
+			return []*ber.Packet{newResultPacket(messageID, ApplicationBindResponse, LDAPResultBusy)}
+		})
+		conn.WithRetry(RetryPolicy{MaxAttempts: 3})
+
+		runWithTimeout(t, time.Second, func() {
+			if err := bind(conn); !IsErrorWithCode(err, LDAPResultBusy) {
+				t.Errorf("%s: expected LDAPResultBusy, got %v", name, err)
+			}
+			if n := atomic.LoadInt32(&attempts); n != 3 {
+				t.Errorf("%s: expected 3 attempts, got %d", name, n)
+			}
+		})
+		conn.Close()
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	for retry, expected := range []time.Duration{10, 20, 40, 50, 50} {
+		if delay := backoff(retry + 1); delay != expected*time.Millisecond {
+			t.Errorf("retry %d: expected %s, got %s", retry+1, expected*time.Millisecond, delay)
+		}
+	}
+}
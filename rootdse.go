@@ -1,41 +1,137 @@
 package ldap
 
 import (
-	"errors"
+	"context"
+	"strings"
+	"time"
 )
 
 // ROOTDSE common attributes
 const (
-	RootDSEdefaultNamingContext    = "defaultNamingContext"
-	RootDSEdnsHostName             = "dnsHostName"
-	RootDSEsupportedSASLMechanisms = "supportedSASLMechanisms"
-	RootDSEldapServiceName         = "ldapServiceName"
-	RootDSEhighestCommittedUSN     = "highestCommittedUSN"
-	RootDSEsubschemaSubentry       = "subschemaSubentry"
-	RootDSEschemaNamingContext     = "schemaNamingContext"
-	RootDSEsupportedControl        = "supportedControl"
+	RootDSEdefaultNamingContext       = "defaultNamingContext"
+	RootDSEdnsHostName                = "dnsHostName"
+	RootDSEsupportedSASLMechanisms    = "supportedSASLMechanisms"
+	RootDSEldapServiceName            = "ldapServiceName"
+	RootDSEhighestCommittedUSN        = "highestCommittedUSN"
+	RootDSEsubschemaSubentry          = "subschemaSubentry"
+	RootDSEnamingContexts             = "namingContexts"
+	RootDSEconfigurationNamingContext = "configurationNamingContext"
+	RootDSEschemaNamingContext        = "schemaNamingContext"
+	RootDSErootDomainNamingContext    = "rootDomainNamingContext"
+	RootDSEsupportedControl           = "supportedControl"
+	RootDSEsupportedExtension         = "supportedExtension"
 )
 
-// RootDSE allows to retrieve the RootDSE entry, returning the provided attributes
+// rootDSETimeLimit is the time limit in seconds of the RootDSE search, which should always
+// be fast since it reads a single entry
+const rootDSETimeLimit = 10
+
+// rootDSETimeout is the time the client waits for the RootDSE, as a hung server never
+// enforces the time limit of the search. It leaves the server the time to return its own
+// time limit error first.
+var rootDSETimeout = (rootDSETimeLimit + 5) * time.Second
+
+// RootDSE allows to retrieve the RootDSE entry, returning the provided attributes.
+// See RootDSEWithContext.
 func (conn *Conn) RootDSE(fields ...string) (*Entry, error) {
+	return conn.RootDSEWithContext(context.Background(), fields...)
+}
+
+// RootDSEWithContext retrieves the RootDSE entry, returning the provided attributes. The
+// search has a time limit of 10 seconds, enforced by the server, and the client gives up
+// waiting for it after 15 seconds with an error with the ResultCode LDAPResultTimeout, even
+// if ctx has no deadline, so that a hung server cannot block it forever. ctx.Err() is
+// returned if ctx is done before then.
+//
+// If the server returns no entry, for example because the RootDSE cannot be read with the
+// current bind, an error with the ResultCode LDAPResultNoSuchObject matching ErrNoEntries
+// with errors.Is is returned.
+func (conn *Conn) RootDSEWithContext(ctx context.Context, fields ...string) (*Entry, error) {
 	if len(fields) == 0 {
 		fields = nil
 	}
 	// scan root DSE
 	search := NewSearchRequest(
 		"",
-		ScopeBaseObject, NeverDerefAliases, 0, 0, false,
+		ScopeBaseObject, NeverDerefAliases, 0, rootDSETimeLimit, false,
 		"(objectclass=*)",
 		fields,
 		nil)
+	search.RequestTimeout = rootDSETimeout
 
-	res, err := conn.Search(search)
+	result, err := conn.SearchWithContext(ctx, search)
 	if err != nil {
 		return nil, err
 	}
-	if len(res.Entries) != 1 {
-		return nil, errors.New("Cannot get RootDSE")
+	switch len(result.Entries) {
+	case 0:
+		return nil, NewError(LDAPResultNoSuchObject, ErrNoEntries)
+	case 1:
+		return result.Entries[0], nil
+	}
+	return nil, ErrMultipleEntries
+}
+
+// SupportsExtension reads the supportedExtension attribute of the RootDSE and returns true
+// if the server advertises the extended operation oid, for example "1.3.6.1.4.1.1466.20037"
+// for StartTLS.
+func (conn *Conn) SupportsExtension(oid string) (bool, error) {
+	return conn.rootDSEHasValue(RootDSEsupportedExtension, oid)
+}
+
+// SupportsControl reads the supportedControl attribute of the RootDSE and returns true if
+// the server advertises the control oid, for example ControlTypePaging.
+func (conn *Conn) SupportsControl(oid string) (bool, error) {
+	return conn.rootDSEHasValue(RootDSEsupportedControl, oid)
+}
+
+// NamingContexts reads the RootDSE and returns the DNs of the naming contexts held by the
+// server, listed in its namingContexts attribute, followed by the Active Directory
+// configuration, schema and forest root domain naming contexts if they are not listed.
+func (conn *Conn) NamingContexts() ([]string, error) {
+	rootDSE, err := conn.RootDSE(RootDSEnamingContexts, RootDSEconfigurationNamingContext, RootDSEschemaNamingContext, RootDSErootDomainNamingContext)
+	if err != nil {
+		return nil, err
 	}
-	rootEntry := res.Entries[0]
-	return rootEntry, nil
+	var namingContexts []string
+	seen := make(map[string]bool)
+	for _, attribute := range []string{RootDSEnamingContexts, RootDSEconfigurationNamingContext, RootDSEschemaNamingContext, RootDSErootDomainNamingContext} {
+		for _, dn := range rootDSE.GetAttributeValues(attribute) {
+			if key := strings.ToLower(dn); dn != "" && !seen[key] {
+				seen[key] = true
+				namingContexts = append(namingContexts, dn)
+			}
+		}
+	}
+	return namingContexts, nil
+}
+
+// rootDSEHasValue returns true if the attribute of the RootDSE has the given value
+func (conn *Conn) rootDSEHasValue(attribute, value string) (bool, error) {
+	rootDSE, err := conn.RootDSE(attribute)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range rootDSE.GetAttributeValues(attribute) {
+		if v == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Ping checks that the connection is alive with a base search of the root DSE requesting
+// no attributes, which is cheap for the server to answer. It returns nil if the server
+// answered, the error of the search otherwise: ErrorNetwork if the connection is broken,
+// or ctx.Err() if ctx is done before the answer is received.
+func (conn *Conn) Ping(ctx context.Context) error {
+	search := NewSearchRequest(
+		"",
+		ScopeBaseObject, NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{"1.1"},
+		nil)
+
+	_, err := conn.SearchWithContext(ctx, search)
+	return err
 }
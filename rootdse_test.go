@@ -0,0 +1,112 @@
+package ldap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestSupportsExtensionAndControl(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		attribute := request.Children[1].Children[7].Children[0].Value.(string)
+		var value string
+		switch attribute {
+		case RootDSEsupportedExtension:
+			value = "1.3.6.1.4.1.1466.20037"
+		case RootDSEsupportedControl:
+			value = ControlTypePaging
+		}
+		return []*ber.Packet{
+			newSearchResultEntryPacket(messageID, "", attribute, value),
+			newSearchResultDonePacket(messageID, LDAPResultSuccess),
+		}
+	})
+	defer conn.Close()
+
+	for _, test := range []struct {
+		fn       func(string) (bool, error)
+		oid      string
+		expected bool
+	}{
+		{conn.SupportsExtension, "1.3.6.1.4.1.1466.20037", true},
+		{conn.SupportsExtension, "1.3.6.1.4.1.4203.1.11.1", false},
+		{conn.SupportsControl, ControlTypePaging, true},
+		{conn.SupportsControl, ControlTypeManageDsaIT, false},
+	} {
+		supported, err := test.fn(test.oid)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if supported != test.expected {
+			t.Errorf("%s: expected %t, got %t", test.oid, test.expected, supported)
+		}
+	}
+}
+
+func TestRootDSEWithContext(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		if timeLimit := request.Children[1].Children[4].Value.(int64); timeLimit != rootDSETimeLimit {
+			t.Errorf("expected a time limit of %d seconds, got %d", rootDSETimeLimit, timeLimit)
+		}
+		// the RootDSE cannot be read anonymously
+		return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultSuccess)}
+	})
+	defer conn.Close()
+
+	_, err := conn.RootDSEWithContext(context.Background(), RootDSEdefaultNamingContext)
+	if !IsErrorWithCode(err, LDAPResultNoSuchObject) || err.(*Error).Err != ErrNoEntries {
+		t.Errorf("expected a LDAPResultNoSuchObject error wrapping ErrNoEntries, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := conn.RootDSEWithContext(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRootDSEHungServer(t *testing.T) {
+	defer func(timeout time.Duration) { rootDSETimeout = timeout }(rootDSETimeout)
+	rootDSETimeout = 50 * time.Millisecond
+
+	// the server never answers
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		return nil
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		if _, err := conn.RootDSE(); !IsErrorWithCode(err, LDAPResultTimeout) {
+			t.Errorf("expected LDAPResultTimeout, got %v", err)
+		}
+	})
+}
+
+func TestNamingContexts(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		return []*ber.Packet{
+			newSearchResultEntryPacket(messageID, "",
+				RootDSEnamingContexts, "DC=example,DC=com",
+				RootDSEconfigurationNamingContext, "CN=Configuration,DC=example,DC=com",
+				RootDSEschemaNamingContext, "CN=Schema,CN=Configuration,DC=example,DC=com",
+				RootDSErootDomainNamingContext, "dc=EXAMPLE,dc=com"),
+			newSearchResultDonePacket(messageID, LDAPResultSuccess),
+		}
+	})
+	defer conn.Close()
+
+	namingContexts, err := conn.NamingContexts()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"DC=example,DC=com", "CN=Configuration,DC=example,DC=com", "CN=Schema,CN=Configuration,DC=example,DC=com"}
+	if !reflect.DeepEqual(namingContexts, expected) {
+		t.Errorf("expected %q, got %q", expected, namingContexts)
+	}
+}
@@ -0,0 +1,398 @@
+// This file contains the parsing of the subschema subentry as specified in rfc 4512
+//
+// https://tools.ietf.org/html/rfc4512#section-4.1
+//
+//   AttributeTypeDescription = LPAREN WSP
+//       numericoid                    ; object identifier
+//       [ SP "NAME" SP qdescrs ]      ; short names (descriptors)
+//       [ SP "DESC" SP qdstring ]     ; description
+//       [ SP "OBSOLETE" ]             ; not active
+//       [ SP "SUP" SP oid ]           ; supertype
+//       [ SP "EQUALITY" SP oid ]      ; equality matching rule
+//       [ SP "ORDERING" SP oid ]      ; ordering matching rule
+//       [ SP "SUBSTR" SP oid ]        ; substrings matching rule
+//       [ SP "SYNTAX" SP noidlen ]    ; value syntax
+//       [ SP "SINGLE-VALUE" ]         ; single-value
+//       [ SP "COLLECTIVE" ]           ; collective
+//       [ SP "NO-USER-MODIFICATION" ] ; not user modifiable
+//       [ SP "USAGE" SP usage ]       ; usage
+//       extensions WSP RPAREN         ; extensions
+//
+//   ObjectClassDescription = LPAREN WSP
+//       numericoid                 ; object identifier
+//       [ SP "NAME" SP qdescrs ]   ; short names (descriptors)
+//       [ SP "DESC" SP qdstring ]  ; description
+//       [ SP "OBSOLETE" ]          ; not active
+//       [ SP "SUP" SP oids ]       ; superior object classes
+//       [ SP kind ]                ; kind of class
+//       [ SP "MUST" SP oids ]      ; attribute types
+//       [ SP "MAY" SP oids ]       ; attribute types
+//       extensions WSP RPAREN
+//
+//   MatchingRuleDescription = LPAREN WSP
+//       numericoid                 ; object identifier
+//       [ SP "NAME" SP qdescrs ]   ; short names (descriptors)
+//       [ SP "DESC" SP qdstring ]  ; description
+//       [ SP "OBSOLETE" ]          ; not active
+//       SP "SYNTAX" SP numericoid  ; assertion syntax
+//       extensions WSP RPAREN      ; extensions
+//
+
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Schema holds the definitions of a subschema subentry
+type Schema struct {
+	// DN is the DN of the subschema subentry
+	DN             string
+	AttributeTypes []*AttributeType
+	ObjectClasses  []*ObjectClass
+	MatchingRules  []*MatchingRule
+
+	// attributeTypes and objectClasses index the definitions by lowercased names and OID
+	attributeTypes map[string]*AttributeType
+	objectClasses  map[string]*ObjectClass
+}
+
+// AttributeType is an attribute type definition
+type AttributeType struct {
+	OID         string
+	Names       []string
+	Description string
+	Obsolete    bool
+	// Superior is the name or OID of the supertype, if any
+	Superior  string
+	Equality  string
+	Ordering  string
+	Substring string
+	// Syntax is the OID of the value syntax, and SyntaxLength its suggested maximum length, if any
+	Syntax             string
+	SyntaxLength       int
+	SingleValue        bool
+	Collective         bool
+	NoUserModification bool
+	Usage              string
+	// Extensions contains the values of the X- extensions, such as X-ORIGIN
+	Extensions map[string][]string
+}
+
+// ObjectClass is an object class definition
+type ObjectClass struct {
+	OID         string
+	Names       []string
+	Description string
+	Obsolete    bool
+	// Superior contains the names or OIDs of the superior object classes
+	Superior []string
+	// Kind is "ABSTRACT", "STRUCTURAL" or "AUXILIARY"
+	Kind string
+	// Must and May contain the names or OIDs of the required and allowed attribute types
+	Must []string
+	May  []string
+	// Extensions contains the values of the X- extensions, such as X-ORIGIN
+	Extensions map[string][]string
+}
+
+// MatchingRule is a matching rule definition
+type MatchingRule struct {
+	OID         string
+	Names       []string
+	Description string
+	Obsolete    bool
+	Syntax      string
+	// Extensions contains the values of the X- extensions, such as X-ORIGIN
+	Extensions map[string][]string
+}
+
+// Schema reads the subschema subentry named by the subschemaSubentry attribute of the
+// root DSE, and parses its attribute types, object classes and matching rules
+func (l *Conn) Schema() (*Schema, error) {
+	rootDSE, err := l.RootDSE(RootDSEsubschemaSubentry)
+	if err != nil {
+		return nil, err
+	}
+	dn := rootDSE.GetAttributeValue(RootDSEsubschemaSubentry)
+	if dn == "" {
+		return nil, errors.New("ldap: the root DSE has no subschemaSubentry")
+	}
+
+	entry, err := l.SearchOne(NewSearchRequest(
+		dn,
+		ScopeBaseObject, NeverDerefAliases, 0, 0, false,
+		"(objectClass=subschema)",
+		[]string{"attributeTypes", "objectClasses", "matchingRules"},
+		nil))
+	if err != nil {
+		return nil, err
+	}
+	return parseSchema(entry)
+}
+
+func parseSchema(entry *Entry) (*Schema, error) {
+	schema := &Schema{
+		DN:             entry.DN,
+		attributeTypes: map[string]*AttributeType{},
+		objectClasses:  map[string]*ObjectClass{},
+	}
+	for _, definition := range entry.GetAttributeValues("attributeTypes") {
+		attributeType, err := ParseAttributeType(definition)
+		if err != nil {
+			return nil, err
+		}
+		schema.AttributeTypes = append(schema.AttributeTypes, attributeType)
+		schema.attributeTypes[strings.ToLower(attributeType.OID)] = attributeType
+		for _, name := range attributeType.Names {
+			schema.attributeTypes[strings.ToLower(name)] = attributeType
+		}
+	}
+	for _, definition := range entry.GetAttributeValues("objectClasses") {
+		objectClass, err := ParseObjectClass(definition)
+		if err != nil {
+			return nil, err
+		}
+		schema.ObjectClasses = append(schema.ObjectClasses, objectClass)
+		schema.objectClasses[strings.ToLower(objectClass.OID)] = objectClass
+		for _, name := range objectClass.Names {
+			schema.objectClasses[strings.ToLower(name)] = objectClass
+		}
+	}
+	for _, definition := range entry.GetAttributeValues("matchingRules") {
+		matchingRule, err := ParseMatchingRule(definition)
+		if err != nil {
+			return nil, err
+		}
+		schema.MatchingRules = append(schema.MatchingRules, matchingRule)
+	}
+	return schema, nil
+}
+
+// AttributeType returns the attribute type with the given name or OID, case insensitively,
+// or nil if there is none
+func (s *Schema) AttributeType(name string) *AttributeType {
+	return s.attributeTypes[strings.ToLower(name)]
+}
+
+// ObjectClass returns the object class with the given name or OID, case insensitively,
+// or nil if there is none
+func (s *Schema) ObjectClass(name string) *ObjectClass {
+	return s.objectClasses[strings.ToLower(name)]
+}
+
+// ParseAttributeType parses an AttributeTypeDescription, as the values of attributeTypes
+func ParseAttributeType(definition string) (*AttributeType, error) {
+	d, err := parseSchemaDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+	attributeType := &AttributeType{
+		OID:                d.oid,
+		Names:              d.values["NAME"],
+		Description:        d.value("DESC"),
+		Obsolete:           d.flag("OBSOLETE"),
+		Superior:           d.value("SUP"),
+		Equality:           d.value("EQUALITY"),
+		Ordering:           d.value("ORDERING"),
+		Substring:          d.value("SUBSTR"),
+		SingleValue:        d.flag("SINGLE-VALUE"),
+		Collective:         d.flag("COLLECTIVE"),
+		NoUserModification: d.flag("NO-USER-MODIFICATION"),
+		Usage:              d.value("USAGE"),
+		Extensions:         d.extensions,
+	}
+	attributeType.Syntax = d.value("SYNTAX")
+	if i := strings.IndexByte(attributeType.Syntax, '{'); i >= 0 && strings.HasSuffix(attributeType.Syntax, "}") {
+		length, err := strconv.Atoi(attributeType.Syntax[i+1 : len(attributeType.Syntax)-1])
+		if err != nil {
+			return nil, fmt.Errorf("ldap: invalid syntax length in schema definition %q", definition)
+		}
+		attributeType.Syntax, attributeType.SyntaxLength = attributeType.Syntax[:i], length
+	}
+	return attributeType, nil
+}
+
+// ParseObjectClass parses an ObjectClassDescription, as the values of objectClasses
+func ParseObjectClass(definition string) (*ObjectClass, error) {
+	d, err := parseSchemaDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+	objectClass := &ObjectClass{
+		OID:         d.oid,
+		Names:       d.values["NAME"],
+		Description: d.value("DESC"),
+		Obsolete:    d.flag("OBSOLETE"),
+		Superior:    d.values["SUP"],
+		Must:        d.values["MUST"],
+		May:         d.values["MAY"],
+		Extensions:  d.extensions,
+	}
+	for _, kind := range []string{"ABSTRACT", "STRUCTURAL", "AUXILIARY"} {
+		if d.flag(kind) {
+			objectClass.Kind = kind
+		}
+	}
+	if objectClass.Kind == "" {
+		objectClass.Kind = "STRUCTURAL"
+	}
+	return objectClass, nil
+}
+
+// ParseMatchingRule parses a MatchingRuleDescription, as the values of matchingRules
+func ParseMatchingRule(definition string) (*MatchingRule, error) {
+	d, err := parseSchemaDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+	return &MatchingRule{
+		OID:         d.oid,
+		Names:       d.values["NAME"],
+		Description: d.value("DESC"),
+		Obsolete:    d.flag("OBSOLETE"),
+		Syntax:      d.value("SYNTAX"),
+		Extensions:  d.extensions,
+	}, nil
+}
+
+// schemaFlags are the keywords of the schema definitions which have no value
+var schemaFlags = map[string]bool{
+	"OBSOLETE":             true,
+	"SINGLE-VALUE":         true,
+	"COLLECTIVE":           true,
+	"NO-USER-MODIFICATION": true,
+	"ABSTRACT":             true,
+	"STRUCTURAL":           true,
+	"AUXILIARY":            true,
+}
+
+// schemaDefinition holds the fields of a schema definition, by keyword
+type schemaDefinition struct {
+	oid        string
+	values     map[string][]string
+	extensions map[string][]string
+}
+
+func (d *schemaDefinition) value(keyword string) string {
+	if values := d.values[keyword]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (d *schemaDefinition) flag(keyword string) bool {
+	_, ok := d.values[keyword]
+	return ok
+}
+
+// parseSchemaDefinition parses the generic structure of the schema definitions: an OID
+// followed by keywords, each followed by a value, or by a list of values in parentheses,
+// such as NAME ( 'cn' 'commonName' ) or MUST ( sn $ cn ), unless it is a flag
+func parseSchemaDefinition(definition string) (*schemaDefinition, error) {
+	tokens, err := tokenizeSchemaDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+	invalid := fmt.Errorf("ldap: invalid schema definition %q", definition)
+	if len(tokens) < 3 || tokens[0] != "(" || tokens[len(tokens)-1] != ")" || tokens[1] == "(" || tokens[1] == ")" {
+		return nil, invalid
+	}
+
+	d := &schemaDefinition{
+		oid:    tokens[1],
+		values: map[string][]string{},
+	}
+	tokens = tokens[2 : len(tokens)-1]
+	for len(tokens) > 0 {
+		keyword := tokens[0]
+		tokens = tokens[1:]
+		if schemaFlags[keyword] {
+			d.values[keyword] = nil
+			continue
+		}
+		if len(tokens) == 0 {
+			return nil, invalid
+		}
+
+		var values []string
+		if tokens[0] == "(" {
+			end := 1
+			for end < len(tokens) && tokens[end] != ")" {
+				if tokens[end] == "(" {
+					return nil, invalid
+				}
+				if tokens[end] != "$" {
+					values = append(values, unquoteSchemaToken(tokens[end]))
+				}
+				end++
+			}
+			if end == len(tokens) {
+				return nil, invalid
+			}
+			tokens = tokens[end+1:]
+		} else if tokens[0] != ")" {
+			values = []string{unquoteSchemaToken(tokens[0])}
+			tokens = tokens[1:]
+		} else {
+			return nil, invalid
+		}
+
+		if strings.HasPrefix(keyword, "X-") {
+			if d.extensions == nil {
+				d.extensions = map[string][]string{}
+			}
+			d.extensions[keyword] = values
+		} else {
+			d.values[keyword] = values
+		}
+	}
+	return d, nil
+}
+
+// tokenizeSchemaDefinition splits a schema definition into parentheses, dollars, quoted
+// strings, which keep their quotes, and words
+func tokenizeSchemaDefinition(definition string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(definition); {
+		switch c := definition[i]; c {
+		case ' ', '\t', '\n', '\r':
+			i++
+		case '(', ')', '$':
+			tokens = append(tokens, string(c))
+			i++
+		case '\'':
+			end := strings.IndexByte(definition[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("ldap: unterminated quoted string in schema definition %q", definition)
+			}
+			tokens = append(tokens, definition[i:i+end+2])
+			i += end + 2
+		default:
+			end := strings.IndexAny(definition[i:], " \t\n\r()$'")
+			if end < 0 {
+				end = len(definition) - i
+			}
+			tokens = append(tokens, definition[i:i+end])
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// unquoteSchemaToken returns the value of a quoted string token, with its \27 and \5C
+// escapes replaced, or the token itself if it is not quoted
+func unquoteSchemaToken(token string) string {
+	if len(token) < 2 || token[0] != '\'' {
+		return token
+	}
+	token = token[1 : len(token)-1]
+	if strings.IndexByte(token, '\\') < 0 {
+		return token
+	}
+	replacer := strings.NewReplacer(`\27`, "'", `\5C`, `\`, `\5c`, `\`)
+	return replacer.Replace(token)
+}
@@ -0,0 +1,138 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestParseAttributeType(t *testing.T) {
+	attributeType, err := ParseAttributeType("( 2.5.4.3 NAME ( 'cn' 'commonName' ) DESC 'RFC4519: common name(s) for which the entity is known by' SUP name EQUALITY caseIgnoreMatch SUBSTR caseIgnoreSubstringsMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15{64} X-ORIGIN ( 'RFC 4519' 'user defined' ) )")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := &AttributeType{
+		OID:          "2.5.4.3",
+		Names:        []string{"cn", "commonName"},
+		Description:  "RFC4519: common name(s) for which the entity is known by",
+		Superior:     "name",
+		Equality:     "caseIgnoreMatch",
+		Substring:    "caseIgnoreSubstringsMatch",
+		Syntax:       "1.3.6.1.4.1.1466.115.121.1.15",
+		SyntaxLength: 64,
+		Extensions:   map[string][]string{"X-ORIGIN": {"RFC 4519", "user defined"}},
+	}
+	if !reflect.DeepEqual(attributeType, expected) {
+		t.Errorf("got %+v, expected %+v", attributeType, expected)
+	}
+
+	attributeType, err = ParseAttributeType("( 2.5.18.1 NAME 'createTimestamp' DESC 'it\\27s \\5C' EQUALITY generalizedTimeMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.24 SINGLE-VALUE NO-USER-MODIFICATION USAGE directoryOperation )")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected = &AttributeType{
+		OID:                "2.5.18.1",
+		Names:              []string{"createTimestamp"},
+		Description:        `it's \`,
+		Equality:           "generalizedTimeMatch",
+		Syntax:             "1.3.6.1.4.1.1466.115.121.1.24",
+		SingleValue:        true,
+		NoUserModification: true,
+		Usage:              "directoryOperation",
+	}
+	if !reflect.DeepEqual(attributeType, expected) {
+		t.Errorf("got %+v, expected %+v", attributeType, expected)
+	}
+}
+
+func TestParseObjectClass(t *testing.T) {
+	objectClass, err := ParseObjectClass("( 2.5.6.6 NAME 'person' DESC 'RFC2256: a person' SUP top STRUCTURAL MUST ( sn $ cn ) MAY ( userPassword $ telephoneNumber $ seeAlso $ description ) )")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := &ObjectClass{
+		OID:         "2.5.6.6",
+		Names:       []string{"person"},
+		Description: "RFC2256: a person",
+		Superior:    []string{"top"},
+		Kind:        "STRUCTURAL",
+		Must:        []string{"sn", "cn"},
+		May:         []string{"userPassword", "telephoneNumber", "seeAlso", "description"},
+	}
+	if !reflect.DeepEqual(objectClass, expected) {
+		t.Errorf("got %+v, expected %+v", objectClass, expected)
+	}
+
+	objectClass, err = ParseObjectClass("(1.3.6.1.4.1.1466.101.120.111 NAME 'extensibleObject' SUP top AUXILIARY)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if objectClass.Kind != "AUXILIARY" || objectClass.Must != nil || !reflect.DeepEqual(objectClass.Superior, []string{"top"}) {
+		t.Errorf("unexpected object class %+v", objectClass)
+	}
+}
+
+func TestParseSchemaDefinitionErrors(t *testing.T) {
+	for _, definition := range []string{
+		"",
+		"2.5.4.3 NAME 'cn'",
+		"( 2.5.4.3 NAME 'cn'",
+		"( 2.5.4.3 NAME ( 'cn' 'commonName' )",
+		"( 2.5.4.3 NAME 'cn )",
+		"( 2.5.4.3 NAME )",
+		"( )",
+	} {
+		if _, err := ParseAttributeType(definition); err == nil {
+			t.Errorf("expected an error parsing %q", definition)
+		}
+	}
+	if _, err := ParseAttributeType("( 2.5.4.3 SYNTAX 1.3.6.1.4.1.1466.115.121.1.15{x} )"); err == nil {
+		t.Error("expected an error parsing an invalid syntax length")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		switch request.Children[1].Children[0].Value.(string) {
+		case "":
+			return []*ber.Packet{
+				newSearchResultEntryPacket(messageID, "", "subschemaSubentry", "cn=Subschema"),
+				newSearchResultDonePacket(messageID, LDAPResultSuccess),
+			}
+		case "cn=Subschema":
+			return []*ber.Packet{
+				newSearchResultEntryPacket(messageID, "cn=Subschema",
+					"attributeTypes", "( 2.5.4.3 NAME ( 'cn' 'commonName' ) SUP name )",
+					"objectClasses", "( 2.5.6.6 NAME 'person' SUP top STRUCTURAL MUST ( sn $ cn ) )",
+					"matchingRules", "( 2.5.13.2 NAME 'caseIgnoreMatch' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )"),
+				newSearchResultDonePacket(messageID, LDAPResultSuccess),
+			}
+		}
+		return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultNoSuchObject)}
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		schema, err := conn.Schema()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if schema.DN != "cn=Subschema" || len(schema.AttributeTypes) != 1 || len(schema.ObjectClasses) != 1 || len(schema.MatchingRules) != 1 {
+			t.Fatalf("unexpected schema %+v", schema)
+		}
+		for _, name := range []string{"cn", "CommonName", "2.5.4.3"} {
+			if schema.AttributeType(name) != schema.AttributeTypes[0] {
+				t.Errorf("attribute type %q not found", name)
+			}
+		}
+		if schema.ObjectClass("Person") != schema.ObjectClasses[0] || schema.ObjectClass("top") != nil {
+			t.Error("unexpected object class lookup results")
+		}
+		if schema.MatchingRules[0].Syntax != "1.3.6.1.4.1.1466.115.121.1.15" {
+			t.Errorf("unexpected matching rule %+v", schema.MatchingRules[0])
+		}
+	})
+}
@@ -0,0 +1,219 @@
+// This file contains the SCRAM SASL mechanisms as specified in rfc 5802 and rfc 7677
+//
+// https://tools.ietf.org/html/rfc5802
+// https://tools.ietf.org/html/rfc7677
+//
+
+package ldap
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SCRAM mechanisms supported by SCRAMBind
+const (
+	SASLMechanismSCRAMSHA1   = "SCRAM-SHA-1"
+	SASLMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+)
+
+// scramGS2Header is the gs2-header sent by a client which neither supports
+// channel binding nor requests an authorization identity.
+const scramGS2Header = "n,,"
+
+type scramClient struct {
+	hash     func() hash.Hash
+	username string
+	password string
+	nonce    string
+
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newSCRAMClient(mechanism, username, password string) (*scramClient, error) {
+	c := &scramClient{
+		username: username,
+		password: password,
+	}
+	switch mechanism {
+	case SASLMechanismSCRAMSHA1:
+		c.hash = sha1.New
+	case SASLMechanismSCRAMSHA256:
+		c.hash = sha256.New
+	default:
+		return nil, fmt.Errorf("ldap: unsupported SCRAM mechanism %q", mechanism)
+	}
+
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("ldap: failed to generate SCRAM nonce: %s", err)
+	}
+	c.nonce = base64.StdEncoding.EncodeToString(nonce)
+	return c, nil
+}
+
+// clientFirst returns the client-first-message
+func (c *scramClient) clientFirst() []byte {
+	saslName := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(c.username)
+	c.clientFirstBare = "n=" + saslName + ",r=" + c.nonce
+	return []byte(scramGS2Header + c.clientFirstBare)
+}
+
+// clientFinal computes the client-final-message from the server-first-message
+func (c *scramClient) clientFinal(serverFirst []byte) ([]byte, error) {
+	attrs, err := parseSCRAMAttributes(serverFirst)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := attrs['m']; ok {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: unsupported SCRAM extension requested by server"))
+	}
+	nonce := attrs['r']
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid SCRAM server nonce"))
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs['s'])
+	if err != nil || len(salt) == 0 {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid SCRAM salt"))
+	}
+	iterations, err := strconv.Atoi(attrs['i'])
+	if err != nil || iterations < 1 {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid SCRAM iteration count"))
+	}
+
+	saltedPassword := c.hi([]byte(c.password), salt, iterations)
+	clientKey := c.hmac(saltedPassword, []byte("Client Key"))
+	h := c.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	clientFinalWithoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(scramGS2Header)) + ",r=" + nonce
+	authMessage := []byte(c.clientFirstBare + "," + string(serverFirst) + "," + clientFinalWithoutProof)
+
+	clientSignature := c.hmac(storedKey, authMessage)
+	clientProof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		clientProof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	serverKey := c.hmac(saltedPassword, []byte("Server Key"))
+	c.serverSignature = c.hmac(serverKey, authMessage)
+
+	return []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(clientProof)), nil
+}
+
+// verifyServerFinal checks the server signature sent in the server-final-message
+func (c *scramClient) verifyServerFinal(serverFinal []byte) error {
+	attrs, err := parseSCRAMAttributes(serverFinal)
+	if err != nil {
+		return err
+	}
+	if e, ok := attrs['e']; ok {
+		return NewError(LDAPResultInvalidCredentials, fmt.Errorf("ldap: SCRAM authentication failed: %s", e))
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs['v'])
+	if err != nil || len(signature) == 0 {
+		return NewError(ErrorUnexpectedResponse, errors.New("ldap: missing SCRAM server signature"))
+	}
+	if !hmac.Equal(signature, c.serverSignature) {
+		return NewError(ErrorUnexpectedResponse, errors.New("ldap: SCRAM server signature mismatch"))
+	}
+	return nil
+}
+
+func (c *scramClient) hmac(key, data []byte) []byte {
+	mac := hmac.New(c.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// hi is the PBKDF2 based Hi() function of rfc 5802, with an output length
+// equal to the hash length
+func (c *scramClient) hi(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(c.hash, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	result := make([]byte, len(u))
+	copy(result, u)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+func parseSCRAMAttributes(message []byte) (map[byte]string, error) {
+	attrs := make(map[byte]string)
+	for _, field := range strings.Split(string(message), ",") {
+		if len(field) < 2 || field[1] != '=' {
+			return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: invalid SCRAM message %q", message))
+		}
+		attrs[field[0]] = field[2:]
+	}
+	return attrs, nil
+}
+
+// SCRAMBind performs a SASL bind using the SCRAM-SHA-1 or SCRAM-SHA-256 mechanism.
+//
+// The full client-first/server-first/client-final/server-final exchange is
+// performed, and an error is returned if the server fails to prove that it
+// knows the password, which protects against a man-in-the-middle.
+// Channel binding is not supported, and the password is used as given (no SASLprep).
+//
+// The exchange is restarted as specified by WithRetry.
+func (l *Conn) SCRAMBind(username, password, mechanism string) (err error) {
+	defer l.recordBind(&err, func(conn *Conn) error {
+		return conn.SCRAMBind(username, password, mechanism)
+	})
+	return l.withRetry(context.Background(), func() error {
+		return l.scramBind(username, password, mechanism)
+	})
+}
+
+func (l *Conn) scramBind(username, password, mechanism string) error {
+	client, err := newSCRAMClient(mechanism, username, password)
+	if err != nil {
+		return err
+	}
+
+	serverFirst, err := l.SASLBind(mechanism, client.clientFirst())
+	if !IsErrorWithCode(err, LDAPResultSaslBindInProgress) {
+		if err == nil {
+			err = NewError(ErrorUnexpectedResponse, errors.New("ldap: SCRAM exchange ended early"))
+		}
+		return err
+	}
+
+	clientFinal, err := client.clientFinal(serverFirst)
+	if err != nil {
+		return err
+	}
+
+	serverFinal, err := l.SASLBind(mechanism, clientFinal)
+	inProgress := IsErrorWithCode(err, LDAPResultSaslBindInProgress)
+	if err != nil && !inProgress {
+		return err
+	}
+	if err := client.verifyServerFinal(serverFinal); err != nil {
+		return err
+	}
+	if inProgress {
+		// the server sent its final message as a challenge: complete with an empty response
+		_, err = l.SASLBind(mechanism, nil)
+	}
+	return err
+}
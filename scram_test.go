@@ -0,0 +1,73 @@
+package ldap
+
+import (
+	"testing"
+)
+
+func TestSCRAMClient(t *testing.T) {
+	// Test vectors from rfc 5802 section 5 and rfc 7677 section 3
+	testcases := []struct {
+		mechanism   string
+		nonce       string
+		serverFirst string
+		clientFinal string
+		serverFinal string
+	}{
+		{
+			mechanism:   SASLMechanismSCRAMSHA1,
+			nonce:       "fyko+d2lbbFgONRv9qkxdawL",
+			serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+			clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+			serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+		},
+		{
+			mechanism:   SASLMechanismSCRAMSHA256,
+			nonce:       "rOprNGfwEbeRWgbNEkqO",
+			serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+			serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+		},
+	}
+
+	for _, tc := range testcases {
+		client, err := newSCRAMClient(tc.mechanism, "user", "pencil")
+		if err != nil {
+			t.Fatalf("%s: %s", tc.mechanism, err)
+		}
+		client.nonce = tc.nonce
+
+		if first := string(client.clientFirst()); first != "n,,n=user,r="+tc.nonce {
+			t.Errorf("%s: unexpected client-first-message %q", tc.mechanism, first)
+		}
+		final, err := client.clientFinal([]byte(tc.serverFirst))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.mechanism, err)
+		}
+		if string(final) != tc.clientFinal {
+			t.Errorf("%s: unexpected client-final-message %q", tc.mechanism, final)
+		}
+		if err := client.verifyServerFinal([]byte(tc.serverFinal)); err != nil {
+			t.Errorf("%s: server signature rejected: %s", tc.mechanism, err)
+		}
+		if err := client.verifyServerFinal([]byte("v=AAAA")); !IsErrorWithCode(err, ErrorUnexpectedResponse) {
+			t.Errorf("%s: expected forged server signature to be rejected, got %v", tc.mechanism, err)
+		}
+	}
+}
+
+func TestSCRAMClientInvalidNonce(t *testing.T) {
+	client, err := newSCRAMClient(SASLMechanismSCRAMSHA256, "user", "pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.clientFirst()
+	if _, err := client.clientFinal([]byte("r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")); err == nil {
+		t.Error("expected server nonce not extending the client nonce to be rejected")
+	}
+}
+
+func TestSCRAMClientUnsupportedMechanism(t *testing.T) {
+	if _, err := newSCRAMClient("SCRAM-MD5", "user", "pencil"); err == nil {
+		t.Error("expected unsupported mechanism to be rejected")
+	}
+}
@@ -56,10 +56,13 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -106,9 +109,15 @@ func NewEntry(dn string, attributes map[string][]string) *Entry {
 
 	var encodedAttributes []*EntryAttribute
 	for _, attributeName := range attributeNames {
+		values := attributes[attributeName]
+		byteValues := make([][]byte, len(values))
+		for i, value := range values {
+			byteValues[i] = []byte(value)
+		}
 		encodedAttributes = append(encodedAttributes, &EntryAttribute{
-			Name: attributeName,
-			S:    attributes[attributeName],
+			Name:       attributeName,
+			S:          values,
+			ByteValues: byteValues,
 		})
 	}
 	return &Entry{
@@ -117,7 +126,8 @@ func NewEntry(dn string, attributes map[string][]string) *Entry {
 	}
 }
 
-// Entry represents a single search result entry
+// Entry represents a single search result entry. Its getters match the attribute names
+// case insensitively, as servers may return them with a different case than requested.
 type Entry struct {
 	// DN is the distinguished name of the entry
 	DN string
@@ -125,23 +135,71 @@ type Entry struct {
 	Attributes []*EntryAttribute
 }
 
-// GetAttribute returns the EntryAttribute for the named attribute, or nil
-func (e *Entry) GetAttribute(attribute string) *EntryAttribute {
+// lookupAttribute returns the first attribute with the given name, ignoring case
+func (e *Entry) lookupAttribute(attribute string) *EntryAttribute {
 	for _, attr := range e.Attributes {
-		if attr.Name == attribute {
+		if len(attr.Name) == len(attribute) && strings.EqualFold(attr.Name, attribute) {
 			return attr
 		}
 	}
 	return nil
 }
 
-// GetAttributeValues returns the string values for the named attribute, or an empty list
-func (e *Entry) GetAttributeValues(attribute string) []string {
+// AttributeIndex maps the attribute names of an entry to its attributes, ignoring case. It
+// is a snapshot of the attributes at the time it was built: changes made to the entry
+// afterwards are not reflected in it.
+type AttributeIndex struct {
+	byName map[string]*EntryAttribute
+}
+
+// IndexAttributes returns an index of the attributes of the entry, to look up many attributes
+// of a large entry without scanning its attributes for each lookup. The entry getters do not
+// use it, and always reflect the current attributes.
+func (e *Entry) IndexAttributes() *AttributeIndex {
+	index := &AttributeIndex{byName: make(map[string]*EntryAttribute, len(e.Attributes))}
 	for _, attr := range e.Attributes {
-		if attr.Name == attribute {
-			return attr.S
+		name := strings.ToLower(attr.Name)
+		if _, ok := index.byName[name]; !ok {
+			index.byName[name] = attr
 		}
 	}
+	return index
+}
+
+// GetAttribute returns the EntryAttribute for the named attribute, or nil
+func (i *AttributeIndex) GetAttribute(attribute string) *EntryAttribute {
+	return i.byName[strings.ToLower(attribute)]
+}
+
+// GetAttributeValues returns the string values for the named attribute, or an empty list
+func (i *AttributeIndex) GetAttributeValues(attribute string) []string {
+	if attr := i.GetAttribute(attribute); attr != nil {
+		return attr.S
+	}
+	return []string{}
+}
+
+// GetAttributeValue returns the first string value for the named attribute, or ""
+func (i *AttributeIndex) GetAttributeValue(attribute string) string {
+	values := i.GetAttributeValues(attribute)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// GetAttribute returns the EntryAttribute for the named attribute, or nil
+func (e *Entry) GetAttribute(attribute string) *EntryAttribute {
+	return e.lookupAttribute(attribute)
+}
+
+// GetAttributeValues returns the string values for the named attribute, or an empty list.
+// The name is matched with its options, such as "description;lang-en": see
+// GetAttributeValuesAllOptions and GetAttributeValuesWithOptions for all the variants.
+func (e *Entry) GetAttributeValues(attribute string) []string {
+	if attr := e.lookupAttribute(attribute); attr != nil {
+		return attr.S
+	}
 	return []string{}
 }
 
@@ -154,6 +212,23 @@ func (e *Entry) GetAttributeValue(attribute string) string {
 	return values[0]
 }
 
+// GetRawAttributeValues returns the byte values for the named attribute, or an empty list
+func (e *Entry) GetRawAttributeValues(attribute string) [][]byte {
+	if attr := e.lookupAttribute(attribute); attr != nil {
+		return attr.ByteValues
+	}
+	return [][]byte{}
+}
+
+// GetRawAttributeValue returns the first byte value for the named attribute, or an empty slice
+func (e *Entry) GetRawAttributeValue(attribute string) []byte {
+	values := e.GetRawAttributeValues(attribute)
+	if len(values) == 0 {
+		return []byte{}
+	}
+	return values[0]
+}
+
 // Print outputs a human-readable description
 func (e *Entry) Print() {
 	fmt.Printf("DN: %s\n", e.DN)
@@ -182,6 +257,9 @@ type EntryAttribute struct {
 	I []int64
 	// O => Octet String (raw value, not encoded)
 	O []string
+	// ByteValues holds the raw bytes of all the values, in the order returned by the server,
+	// for binary attributes such as objectSid or userCertificate
+	ByteValues [][]byte
 }
 
 // StrValue returns the first string value of an attribute
@@ -213,6 +291,41 @@ type SearchResult struct {
 	Referrals []string
 	// Controls are the returned controls
 	Controls []Control
+	// Partial is true when the search was truncated because a size or time limit was
+	// exceeded: the result is then returned along with the error, holding the entries
+	// received before the limit was reached.
+	Partial bool
+
+	// index holds the searchResultIndex of Entries, built by the first lookup by DN
+	index atomic.Value
+}
+
+// searchResultIndex maps the canonical DNs of the entries of a search result to the entries
+type searchResultIndex struct {
+	byDN map[string]*Entry
+}
+
+// Entry returns the entry with the given DN, or nil if the result holds no such entry. The
+// DNs are compared ignoring the case of their attribute types and values, the order of the
+// attributes of multi-valued RDNs, and insignificant spaces, so that "CN=John  Smith, DC=Example"
+// matches "cn=john smith,dc=example".
+//
+// The entries are indexed by DN on the first lookup, so that their DNs are not parsed again
+// for each lookup. The index is a snapshot of the entries at that time: entries added,
+// replaced or renamed afterwards are not reflected in the lookups.
+func (s *SearchResult) Entry(dn string) *Entry {
+	index, _ := s.index.Load().(*searchResultIndex)
+	if index == nil {
+		index = &searchResultIndex{byDN: make(map[string]*Entry, len(s.Entries))}
+		for _, entry := range s.Entries {
+			key := canonicalDN(entry.DN)
+			if _, ok := index.byDN[key]; !ok {
+				index.byDN[key] = entry
+			}
+		}
+		s.index.Store(index)
+	}
+	return index.byDN[canonicalDN(dn)]
 }
 
 // Print outputs a human-readable description
@@ -234,15 +347,39 @@ type SearchRequest struct {
 	BaseDN       string
 	Scope        int
 	DerefAliases int
-	SizeLimit    int
-	TimeLimit    int
-	TypesOnly    bool
-	Filter       string
-	Attributes   []string
-	Controls     []Control
+	// SizeLimit, if not zero, is the maximum number of entries the server returns.
+	// SearchWithPaging also enforces it across all the pages, see MaxEntries.
+	SizeLimit int
+	TimeLimit int
+	// TypesOnly requests the attribute descriptions of the entries without their values:
+	// the returned attributes then have no values
+	TypesOnly  bool
+	Filter     string
+	Attributes []string
+	Controls   []Control
+
+	// MaxEntries, if not zero, limits the number of entries returned by SearchWithPaging
+	// across all the pages, without sending the limit to the server. If SizeLimit is also
+	// set, the smallest limit applies. Once more entries are received, the paged search is
+	// abandoned and an error matching ErrSizeLimitExceeded is returned along with the
+	// entries received up to the limit.
+	MaxEntries int
+
+	// RequestTimeout, if not zero, limits the time to wait for the whole result of the
+	// search, overriding the timeout set with Conn.SetTimeout. When it expires, the search
+	// is abandoned and an error with code LDAPResultTimeout is returned.
+	RequestTimeout time.Duration
+
+	// CompiledFilter, if set, is sent instead of Filter, avoiding to compile the filter
+	// for every search. It is only read, so it can be shared between requests.
+	// See CompileFilter and SubstituteFilterValues.
+	CompiledFilter *ber.Packet
 }
 
 func (req *SearchRequest) appendTo(envelope *ber.Packet) error {
+	if FindControl(req.Controls, ControlTypeVLVRequest) != nil && FindControl(req.Controls, ControlTypeServerSideSort) == nil {
+		return NewError(LDAPResultSortControlMissing, errors.New("ldap: the virtual list view control requires a server side sort control"))
+	}
 	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchRequest, nil, "Search Request")
 	pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, req.BaseDN, "Base DN"))
 	pkt.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(req.Scope), "Scope"))
@@ -251,9 +388,13 @@ func (req *SearchRequest) appendTo(envelope *ber.Packet) error {
 	pkt.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, uint64(req.TimeLimit), "Time Limit"))
 	pkt.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, req.TypesOnly, "Types Only"))
 	// compile and encode filter
-	filterPacket, err := CompileFilter(req.Filter)
-	if err != nil {
-		return err
+	filterPacket := req.CompiledFilter
+	if filterPacket == nil {
+		var err error
+		filterPacket, err = CompileFilter(req.Filter)
+		if err != nil {
+			return err
+		}
 	}
 	pkt.AppendChild(filterPacket)
 	// encode attributes
@@ -265,7 +406,7 @@ func (req *SearchRequest) appendTo(envelope *ber.Packet) error {
 
 	envelope.AppendChild(pkt)
 	if len(req.Controls) > 0 {
-		envelope.AppendChild(encodeControls(req.Controls))
+		envelope.AppendChild(EncodeControls(req.Controls))
 	}
 
 	return nil
@@ -301,7 +442,19 @@ func NewSearchRequest(
 //  - given SearchRequest contains a control of type ControlTypePaging with pagingSize equal to the size requested: no change to the search request
 //  - given SearchRequest contains a control of type ControlTypePaging with pagingSize not equal to the size requested: fail without issuing any queries
 // A requested pagingSize of 0 is interpreted as no limit by LDAP servers.
+//
+// An error is returned, along with the entries received so far, if a response does not contain a paging control.
+//
+// The number of entries is limited by the SizeLimit and MaxEntries fields of the search request.
+// See SearchPage to request the pages one at a time.
 func (l *Conn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
+	return l.SearchWithPagingContext(context.Background(), searchRequest, pagingSize)
+}
+
+// SearchWithPagingContext is like SearchWithPaging, but stops requesting pages once ctx is done.
+// In that case, the paged search is abandoned by sending a page request of size zero with the
+// last cookie, and ctx.Err() is returned along with the entries received so far.
+func (l *Conn) SearchWithPagingContext(ctx context.Context, searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
 	var pagingControl *ControlPaging
 
 	control := FindControl(searchRequest.Controls, ControlTypePaging)
@@ -319,115 +472,483 @@ func (l *Conn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32)
 		pagingControl = castControl
 	}
 
+	maxEntries := searchRequest.MaxEntries
+	if searchRequest.SizeLimit > 0 && (maxEntries <= 0 || searchRequest.SizeLimit < maxEntries) {
+		maxEntries = searchRequest.SizeLimit
+	}
+	errMaxEntries := NewError(LDAPResultSizeLimitExceeded, fmt.Errorf("ldap: more than %d entries returned by the paged search", maxEntries))
+
 	searchResult := new(SearchResult)
 	for {
-		result, err := l.Search(searchRequest)
-		l.Debug.Printf("Looking for Paging Control...")
+		result, cookie, err := l.searchPage(ctx, searchRequest, func(entry *Entry) error {
+			if maxEntries > 0 && len(searchResult.Entries) >= maxEntries {
+				return errMaxEntries
+			}
+			searchResult.Entries = append(searchResult.Entries, entry)
+			return nil
+		})
+		if result != nil {
+			searchResult.Referrals = append(searchResult.Referrals, result.Referrals...)
+			searchResult.Controls = append(searchResult.Controls, result.Controls...)
+		}
 		if err != nil {
+			if (ctx.Err() != nil || err == errMaxEntries) && len(pagingControl.Cookie) > 0 {
+				l.abandonPaging(searchRequest, pagingControl)
+			}
+			searchResult.Partial = isLimitExceeded(err)
 			return searchResult, err
 		}
-		if result == nil {
-			return searchResult, NewError(ErrorNetwork, errors.New("ldap: packet not received"))
-		}
 
-		for _, entry := range result.Entries {
-			searchResult.Entries = append(searchResult.Entries, entry)
-		}
-		for _, referral := range result.Referrals {
-			searchResult.Referrals = append(searchResult.Referrals, referral)
-		}
-		for _, control := range result.Controls {
-			searchResult.Controls = append(searchResult.Controls, control)
+		if len(cookie) == 0 {
+			l.debugf("Could not find cookie.  Breaking...")
+			break
 		}
+		pagingControl.SetCookie(cookie)
 
-		l.Debug.Printf("Looking for Paging Control...")
-		pagingResult := FindControl(result.Controls, ControlTypePaging)
-		if pagingResult == nil {
-			pagingControl = nil
-			l.Debug.Printf("Could not find paging control.  Breaking...")
-			break
+		if err := ctx.Err(); err != nil {
+			l.abandonPaging(searchRequest, pagingControl)
+			return searchResult, err
 		}
+	}
 
-		cookie := pagingResult.(*ControlPaging).Cookie
-		if len(cookie) == 0 {
-			pagingControl = nil
-			l.Debug.Printf("Could not find cookie.  Breaking...")
-			break
+	return searchResult, nil
+}
+
+// SearchPage performs a single page of a paged search (rfc 2696): it requests pagingSize
+// entries from the position given by cookie, which is nil for the first page, and returns
+// them with the cookie of the next page. The returned cookie is empty once the last page
+// is returned.
+//
+// Unlike SearchWithPaging, the caller requests the pages, so that the cookie can be kept
+// between them, for example across stateless HTTP requests. Servers only accept a cookie for
+// the same search request, usually on a connection bound with the same identity, and some
+// only on the connection which returned it. A paged search is abandoned by requesting a page
+// of size zero with the last cookie.
+//
+// The search request is not modified: a copy is sent, in which any paging control is replaced.
+func (l *Conn) SearchPage(searchRequest *SearchRequest, pagingSize uint32, cookie []byte) (*SearchResult, []byte, error) {
+	pagingControl := NewControlPaging(pagingSize)
+	pagingControl.SetCookie(cookie)
+	pagedRequest := *searchRequest
+	pagedRequest.Controls = []Control{pagingControl}
+	for _, control := range searchRequest.Controls {
+		if control.GetControlType() != ControlTypePaging {
+			pagedRequest.Controls = append(pagedRequest.Controls, control)
 		}
-		pagingControl.SetCookie(cookie)
 	}
 
-	if pagingControl != nil {
-		l.Debug.Printf("Abandoning Paging...")
-		pagingControl.PagingSize = 0
-		l.Search(searchRequest)
+	var entries []*Entry
+	result, next, err := l.searchPage(context.Background(), &pagedRequest, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if result == nil {
+		return nil, nil, err
 	}
+	result.Entries = append(result.Entries, entries...)
+	return result, next, err
+}
 
-	return searchResult, nil
+// searchPage performs the search request, which holds a paging control, calling fn for each
+// entry. It returns the result with the cookie of the next page.
+func (l *Conn) searchPage(ctx context.Context, searchRequest *SearchRequest, fn func(*Entry) error) (*SearchResult, []byte, error) {
+	result, err := l.searchWithCallback(ctx, searchRequest, fn)
+	if err != nil {
+		return result, nil, err
+	}
+	if result == nil {
+		return nil, nil, NewError(ErrorNetwork, errors.New("ldap: packet not received"))
+	}
+
+	l.debugf("Looking for Paging Control...")
+	pagingResult, ok := FindControl(result.Controls, ControlTypePaging).(*ControlPaging)
+	if !ok {
+		l.debugf("Could not find paging control.  Breaking...")
+		return result, nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: paging control missing from search response"))
+	}
+	return result, pagingResult.Cookie, nil
+}
+
+// SearchAutoPagingSize is the page size used by SearchAuto when it retries a search with paging.
+// It is below the default MaxPageSize of Active Directory.
+const SearchAutoPagingSize = 500
+
+// SearchAuto performs the given search request without paging, and if the server refuses to
+// return all the entries because of its size or administrative limits, performs it again with
+// paging to return the complete result. The search request is not modified.
+//
+// The search is not retried when the SizeLimit of the request is set, since the limit may be
+// the one requested, nor when the request already has a paging control.
+func (l *Conn) SearchAuto(searchRequest *SearchRequest) (*SearchResult, error) {
+	result, err := l.Search(searchRequest)
+	if err == nil || searchRequest.SizeLimit > 0 || FindControl(searchRequest.Controls, ControlTypePaging) != nil {
+		return result, err
+	}
+	if !IsErrorWithCode(err, LDAPResultSizeLimitExceeded) && !IsErrorWithCode(err, LDAPResultAdminLimitExceeded) {
+		return result, err
+	}
+	l.debugf("Search limit exceeded, retrying with paging: %s", err)
+	pagedRequest := *searchRequest
+	pagedRequest.Controls = append([]Control(nil), searchRequest.Controls...)
+	return l.SearchWithPaging(&pagedRequest, SearchAutoPagingSize)
 }
 
-// Search performs the given search request
+// abandonPaging tells the server to release the resources of a paged search,
+// by requesting a page of size zero with the last cookie received.
+func (l *Conn) abandonPaging(searchRequest *SearchRequest, pagingControl *ControlPaging) {
+	l.debugf("Abandoning Paging...")
+	pagingSize := pagingControl.PagingSize
+	pagingControl.PagingSize = 0
+	if _, err := l.Search(searchRequest); err != nil {
+		l.debugf("Abandoning Paging failed: %s", err)
+	}
+	pagingControl.PagingSize = pagingSize
+}
+
+// Search performs the given search request.
+//
+// If the server returns a size or time limit exceeded error, the result is returned along with
+// the error, with the entries received so far and Partial set to true.
 func (l *Conn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
-	msgCtx, err := l.doRequest(searchRequest)
+	return l.SearchWithContext(context.Background(), searchRequest)
+}
+
+var (
+	// ErrNoEntries is returned by SearchOne when no entry matches the search
+	ErrNoEntries = errors.New("ldap: no entry found")
+	// ErrMultipleEntries is returned by SearchOne when more than one entry matches the search
+	ErrMultipleEntries = errors.New("ldap: more than one entry found")
+)
+
+// SearchOne performs the given search request and returns its only entry. It returns
+// ErrNoEntries if no entry matches, and ErrMultipleEntries if more than one does.
+func (l *Conn) SearchOne(searchRequest *SearchRequest) (*Entry, error) {
+	result, err := l.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrNoEntries
+	case 1:
+		return result.Entries[0], nil
+	}
+	return nil, ErrMultipleEntries
+}
+
+// SearchWithContext performs the given search request. If ctx is done before the search
+// completes, ctx.Err() is returned.
+func (l *Conn) SearchWithContext(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error) {
+	var entries []*Entry
+	result, err := l.searchWithCallback(ctx, searchRequest, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil && (result == nil || !result.Partial) {
+		return nil, err
+	}
+	result.Entries = append(result.Entries, entries...)
+	return result, err
+}
+
+// isLimitExceeded returns true if err is a size or time limit exceeded error, after which the
+// entries already received are returned in a partial result
+func isLimitExceeded(err error) bool {
+	return IsErrorWithCode(err, LDAPResultSizeLimitExceeded) || IsErrorWithCode(err, LDAPResultTimeLimitExceeded)
+}
+
+// PendingSearch is a search request started by StartSearch
+type PendingSearch struct {
+	// MessageID is the message ID of the search request, which can be passed to Abandon
+	MessageID int64
+
+	done   chan struct{}
+	result *SearchResult
+	err    error
+}
+
+// Wait waits for the search to complete and returns its result. If the search was
+// abandoned, the error has the ResultCode LDAPResultCanceled.
+func (s *PendingSearch) Wait() (*SearchResult, error) {
+	<-s.done
+	return s.result, s.err
+}
+
+// StartSearch sends the given search request without waiting for its result, so that it
+// can be abandoned with the MessageID of the returned PendingSearch. Unlike Search, the
+// referrals are not followed even if referral chasing is enabled: they are returned in the
+// Referrals of the result.
+func (l *Conn) StartSearch(searchRequest *SearchRequest) (*PendingSearch, error) {
+	ctx := context.Background()
+	cancel := func() {}
+	if searchRequest.RequestTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, searchRequest.RequestTimeout)
+	}
+	msgCtx, err := l.doRequest(ctx, searchRequest)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	search := &PendingSearch{MessageID: msgCtx.id, done: make(chan struct{})}
+	go func() {
+		defer close(search.done)
+		defer cancel()
+		defer l.finishMessage(msgCtx)
+
+		var entries []*Entry
+		result, _, err := l.readSearchResults(ctx, msgCtx, func(entry *Entry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+		switch {
+		case err == context.DeadlineExceeded:
+			search.err = NewError(LDAPResultTimeout, errors.New("ldap: search request timed out"))
+		case err != nil && (result == nil || !result.Partial):
+			search.err = err
+		default:
+			result.Entries = append(result.Entries, entries...)
+			search.result, search.err = result, err
+		}
+	}()
+	return search, nil
+}
+
+// SearchWithCallback performs the given search request, calling fn for each entry as
+// soon as it is received instead of buffering all the entries in memory. The returned
+// SearchResult holds the referrals and controls but no entries.
+//
+// If fn returns an error, the search is abandoned and that error is returned.
+func (l *Conn) SearchWithCallback(searchRequest *SearchRequest, fn func(*Entry) error) (*SearchResult, error) {
+	return l.searchWithCallback(context.Background(), searchRequest, fn)
+}
+
+// SearchStream is a search started by StreamSearch, whose entries are received from a channel
+type SearchStream struct {
+	// Entries receives the entries of the search as soon as they are read from the network.
+	// It is closed when the search completes, after which Wait returns the result.
+	Entries <-chan *Entry
+
+	done   chan struct{}
+	result *SearchResult
+	err    error
+}
+
+// Wait waits for the search to complete and returns its result, which holds the referrals
+// and controls but no entries. The entries must have been received from Entries, or ctx
+// given to StreamSearch cancelled, before calling Wait.
+func (s *SearchStream) Wait() (*SearchResult, error) {
+	<-s.done
+	return s.result, s.err
+}
+
+// StreamSearch starts the given search request, whose entries are sent to the Entries
+// channel of the returned SearchStream, buffering at most bufferSize entries.
+//
+// Once the buffer is full, no more response is read from the network until the consumer
+// receives an entry, so that a slow consumer slows down the server through TCP flow control
+// instead of making the client buffer an unbounded number of entries in memory. Since the
+// responses of the connection are read in order, the other requests of the connection are
+// blocked too: use a dedicated connection for a slow consumer.
+//
+// The consumer must receive all the entries, or cancel ctx to abandon the search. The time
+// spent waiting for the consumer counts towards the RequestTimeout of the search request
+// and the deadline of ctx, and a consumer blocking for longer than the timeout set with
+// SetTimeout may make the search fail with a timeout error.
+func (l *Conn) StreamSearch(ctx context.Context, searchRequest *SearchRequest, bufferSize int) *SearchStream {
+	entries := make(chan *Entry, bufferSize)
+	stream := &SearchStream{Entries: entries, done: make(chan struct{})}
+	go func() {
+		defer close(stream.done)
+		defer close(entries)
+		stream.result, stream.err = l.searchWithCallback(ctx, searchRequest, func(entry *Entry) error {
+			select {
+			case entries <- entry:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return stream
+}
+
+func (l *Conn) searchWithCallback(ctx context.Context, searchRequest *SearchRequest, fn func(*Entry) error) (*SearchResult, error) {
+	if searchRequest.RequestTimeout <= 0 {
+		return l.searchFollowingReferrals(ctx, l.getReferralConfig(), searchRequest, fn, 0)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, searchRequest.RequestTimeout)
+	defer cancel()
+	result, err := l.searchFollowingReferrals(timeoutCtx, l.getReferralConfig(), searchRequest, fn, 0)
+	if err == context.DeadlineExceeded && ctx.Err() == nil {
+		return nil, NewError(LDAPResultTimeout, errors.New("ldap: search request timed out"))
+	}
+	return result, err
+}
+
+// searchFollowingReferrals performs the search, then follows the referrals returned by the
+// server if config is not nil. hops is the number of referrals already followed.
+func (l *Conn) searchFollowingReferrals(ctx context.Context, config *ReferralConfig, searchRequest *SearchRequest, fn func(*Entry) error, hops int) (*SearchResult, error) {
+	result, referral, err := l.search(ctx, searchRequest, fn)
+	if config == nil {
+		return result, err
+	}
+
+	if IsErrorWithCode(err, LDAPResultReferral) {
+		// the whole search must be performed by another server
+		_, err = followReferral(config, referral, hops, func(conn *Conn, ref *referralURL) error {
+			var err error
+			result, err = conn.searchFollowingReferrals(ctx, config, referredSearchRequest(searchRequest, ref, false), fn, hops+1)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
 	if err != nil {
+		if result != nil && result.Partial {
+			return result, err
+		}
 		return nil, err
 	}
+
+	references := result.Referrals
+	result.Referrals = make([]string, 0)
+	for _, reference := range references {
+		followed, err := followReferral(config, []string{reference}, hops, func(conn *Conn, ref *referralURL) error {
+			referredResult, err := conn.searchFollowingReferrals(ctx, config, referredSearchRequest(searchRequest, ref, true), fn, hops+1)
+			if err != nil {
+				return err
+			}
+			result.Referrals = append(result.Referrals, referredResult.Referrals...)
+			return nil
+		})
+		switch {
+		case IsErrorWithCode(err, LDAPResultReferralLimitExceeded):
+			return nil, err
+		case !followed:
+			l.debugf("unable to follow search reference %s: %s", reference, err)
+			result.Referrals = append(result.Referrals, reference)
+		case err != nil:
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// referredSearchRequest returns the search request to send to the server of a referral.
+// continuation is true for search result references, which continue the search in
+// another part of the tree (rfc4511 4.5.3).
+func referredSearchRequest(searchRequest *SearchRequest, ref *referralURL, continuation bool) *SearchRequest {
+	referred := *searchRequest
+	if ref.dn != "" {
+		referred.BaseDN = ref.dn
+	}
+	switch {
+	case ref.scope >= 0:
+		referred.Scope = ref.scope
+	case continuation && searchRequest.Scope == ScopeSingleLevel:
+		referred.Scope = ScopeBaseObject
+	}
+	if ref.filter != "" {
+		referred.Filter = ref.filter
+		referred.CompiledFilter = nil
+	}
+	// a paging cookie is only meaningful to the server which returned it
+	referred.Controls = nil
+	for _, control := range searchRequest.Controls {
+		if control.GetControlType() != ControlTypePaging {
+			referred.Controls = append(referred.Controls, control)
+		}
+	}
+	return &referred
+}
+
+// search performs the search request on this connection. When the server returns a
+// referral result, the referral URLs are returned along with the error.
+func (l *Conn) search(ctx context.Context, searchRequest *SearchRequest, fn func(*Entry) error) (*SearchResult, []string, error) {
+	msgCtx, err := l.doRequest(ctx, searchRequest)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer l.finishMessage(msgCtx)
+	return l.readSearchResults(ctx, msgCtx, fn)
+}
 
+// readSearchResults reads the responses of the search request sent with msgCtx, calling
+// fn for each entry.
+func (l *Conn) readSearchResults(ctx context.Context, msgCtx *messageContext, fn func(*Entry) error) (*SearchResult, []string, error) {
 	result := &SearchResult{
 		Entries:   make([]*Entry, 0),
 		Referrals: make([]string, 0),
 		Controls:  make([]Control, 0)}
 
 	for {
-		packet, err := l.readPacket(msgCtx)
+		packet, err := l.readPacket(ctx, msgCtx)
 		if err != nil {
-			return nil, err
+			if err == ctx.Err() {
+				l.abandonMessage(msgCtx)
+			}
+			return nil, nil, err
 		}
 
 		switch packet.Children[1].Tag {
 		case 4:
-			entry := new(Entry)
-			entry.DN = packet.Children[1].Children[0].Value.(string)
-			for _, child := range packet.Children[1].Children[1].Children {
-				attr := new(EntryAttribute)
-				attr.Name = child.Children[0].Value.(string)
-				for _, value := range child.Children[1].Children {
-					if value.Value == nil {
-						attr.O = append(attr.O, string(value.ByteValue))
-					} else {
-						switch v := value.Value.(type) {
-						case bool:
-							attr.B = append(attr.B, v)
-						case int64:
-							attr.I = append(attr.I, v)
-						case string:
-							attr.S = append(attr.S, v)
-						default:
-							attr.O = append(attr.O, string(value.ByteValue))
-						}
-					}
+			if err := fn(decodeSearchResultEntry(packet)); err != nil {
+				l.debugf("%d: abandoning search: %s", msgCtx.id, err)
+				if abandonErr := l.abandonMessage(msgCtx); abandonErr != nil {
+					l.debugf("%d: failed to abandon search: %s", msgCtx.id, abandonErr)
 				}
-				entry.Attributes = append(entry.Attributes, attr)
+				return nil, nil, err
 			}
-			result.Entries = append(result.Entries, entry)
 		case 5:
 			err := GetLDAPError(packet)
-			if err != nil {
-				return nil, err
+			if err != nil && !isLimitExceeded(err) {
+				return nil, getReferral(packet), err
 			}
 			if len(packet.Children) == 3 {
 				for _, child := range packet.Children[2].Children {
 					decodedChild, err := DecodeControl(child)
 					if err != nil {
-						return nil, fmt.Errorf("failed to decode child control: %s", err)
+						return nil, nil, fmt.Errorf("failed to decode child control: %s", err)
 					}
 					result.Controls = append(result.Controls, decodedChild)
 				}
 			}
-			return result, nil
+			result.Partial = err != nil
+			return result, nil, err
 		case 19:
 			result.Referrals = append(result.Referrals, packet.Children[1].Children[0].Value.(string))
 		}
 	}
 }
+
+func decodeSearchResultEntry(packet *ber.Packet) *Entry {
+	entry := new(Entry)
+	entry.DN = packet.Children[1].Children[0].Value.(string)
+	for _, child := range packet.Children[1].Children[1].Children {
+		attr := new(EntryAttribute)
+		attr.Name = child.Children[0].Value.(string)
+		for _, value := range child.Children[1].Children {
+			attr.ByteValues = append(attr.ByteValues, value.Data.Bytes())
+			if value.Value == nil {
+				attr.O = append(attr.O, string(value.ByteValue))
+			} else {
+				switch v := value.Value.(type) {
+				case bool:
+					attr.B = append(attr.B, v)
+				case int64:
+					attr.I = append(attr.I, v)
+				case string:
+					attr.S = append(attr.S, v)
+				default:
+					attr.O = append(attr.O, string(value.ByteValue))
+				}
+			}
+		}
+		entry.Attributes = append(entry.Attributes, attr)
+	}
+	return entry
+}
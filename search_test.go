@@ -1,8 +1,21 @@
 package ldap
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
 )
 
 // TestNewEntry tests that repeated calls to NewEntry return the same value with the same input
@@ -29,3 +42,829 @@ func TestNewEntry(t *testing.T) {
 		iteration = iteration + 1
 	}
 }
+
+// TestSearchWithCallbackAbandon tests that an error returned by the callback
+// abandons the search on the server side.
+func TestSearchWithCallbackAbandon(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	stop := errors.New("stop")
+	type searchResult struct {
+		entries []*Entry
+		err     error
+	}
+	results := make(chan searchResult, 1)
+	go func() {
+		var entries []*Entry
+		_, err := conn.SearchWithCallback(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil), func(entry *Entry) error {
+			entries = append(entries, entry)
+			return stop
+		})
+		results <- searchResult{entries, err}
+	}()
+
+	var request *ber.Packet
+	runWithTimeout(t, time.Second, func() {
+		var err error
+		if request, err = ptc.ReceiveRequest(); err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+	})
+	messageID := request.Children[0].Value.(int64)
+
+	runWithTimeout(t, time.Second, func() {
+		if err := ptc.SendResponse(newSearchResultEntryPacket(messageID, "cn=a,dc=example,dc=com", "cn", "a")); err != nil {
+			t.Fatalf("unable to send response packet: %s", err)
+		}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		result := <-results
+		if result.err != stop {
+			t.Errorf("expected callback error, got %v", result.err)
+		}
+		if len(result.entries) != 1 || result.entries[0].GetAttributeValue("cn") != "a" {
+			t.Errorf("unexpected entries: %v", result.entries)
+		}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		abandon, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive abandon packet: %s", err)
+		}
+		if abandon.Children[1].ClassType != ber.ClassApplication || abandon.Children[1].Tag != ApplicationAbandonRequest {
+			t.Fatalf("expected an abandon request, got tag %d", abandon.Children[1].Tag)
+		}
+		if id, _ := ber.ParseInt64(abandon.Children[1].Data.Bytes()); id != messageID {
+			t.Errorf("abandoned message %d, expected %d", id, messageID)
+		}
+	})
+}
+
+// TestStartSearchAbandon tests that a search started with StartSearch can be abandoned
+// with its message ID, and that Wait then returns without waiting for the server.
+func TestStartSearchAbandon(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	search, err := conn.StartSearch(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	runWithTimeout(t, time.Second, func() {
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+		if messageID := request.Children[0].Value.(int64); messageID != search.MessageID {
+			t.Errorf("expected message ID %d, got %d", messageID, search.MessageID)
+		}
+	})
+
+	if err := conn.Abandon(search.MessageID); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	runWithTimeout(t, time.Second, func() {
+		abandon, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive abandon packet: %s", err)
+		}
+		if abandon.Children[1].Tag != ApplicationAbandonRequest {
+			t.Fatalf("expected an abandon request, got tag %d", abandon.Children[1].Tag)
+		}
+		if id, _ := ber.ParseInt64(abandon.Children[1].Data.Bytes()); id != search.MessageID {
+			t.Errorf("abandoned message %d, expected %d", id, search.MessageID)
+		}
+	})
+	runWithTimeout(t, time.Second, func() {
+		if _, err := search.Wait(); !IsErrorWithCode(err, LDAPResultCanceled) {
+			t.Errorf("expected LDAPResultCanceled, got %v", err)
+		}
+	})
+
+	// the connection is still usable, and a late response of the abandoned search is ignored
+	if err := ptc.SendResponse(newSearchResultDonePacket(search.MessageID, LDAPResultSuccess)); err != nil {
+		t.Fatalf("unable to send response packet: %s", err)
+	}
+	go func() {
+		request, err := ptc.ReceiveRequest()
+		if err == nil {
+			ptc.SendResponse(newSearchResultDonePacket(request.Children[0].Value.(int64), LDAPResultSuccess))
+		}
+	}()
+	runWithTimeout(t, time.Second, func() {
+		if _, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+}
+
+func newSearchResultEntryPacket(messageID int64, dn string, attrValues ...string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for i := 0; i+1 < len(attrValues); i += 2 {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attrValues[i], "Attribute Name"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Attribute Values")
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attrValues[i+1], "Attribute Value"))
+		attribute.AppendChild(values)
+		attributes.AppendChild(attribute)
+	}
+	entry.AppendChild(attributes)
+	packet.AppendChild(entry)
+	return packet
+}
+
+// TestSearchWithPagingMissingControl tests that a server ignoring the paging
+// control is reported instead of silently returning a single page.
+func TestSearchWithPagingMissingControl(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := conn.SearchWithPaging(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil), 10)
+		errs <- err
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+		if err := ptc.SendResponse(newSearchResultDonePacket(request.Children[0].Value.(int64), LDAPResultSuccess)); err != nil {
+			t.Fatalf("unable to send response packet: %s", err)
+		}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		if err := <-errs; !IsErrorWithCode(err, ErrorUnexpectedResponse) {
+			t.Errorf("expected ErrorUnexpectedResponse, got %v", err)
+		}
+	})
+}
+
+// newPagingTestConn returns a connection to a server returning pages of two entries, and
+// the channel receiving the paging size and cookie of the page requests
+func newPagingTestConn(t *testing.T, pages int) (*Conn, chan *ControlPaging) {
+	requests := make(chan *ControlPaging, 10)
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		if request.Children[1].Tag != ApplicationSearchRequest {
+			return nil
+		}
+		messageID := request.Children[0].Value.(int64)
+		control, err := DecodeControl(request.Children[2].Children[0])
+		if err != nil {
+			t.Errorf("failed to decode request control: %s", err)
+			return nil
+		}
+		paging := control.(*ControlPaging)
+		requests <- paging
+		if paging.PagingSize == 0 {
+			return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultSuccess, &ControlPaging{})}
+		}
+
+		page := 0
+		if len(paging.Cookie) > 0 {
+			page = int(paging.Cookie[0] - '0')
+		}
+		next := &ControlPaging{}
+		if page < pages-1 {
+			next.Cookie = []byte{byte('1' + page)}
+		}
+		return []*ber.Packet{
+			newSearchResultEntryPacket(messageID, fmt.Sprintf("cn=entry%d-0,dc=example,dc=com", page)),
+			newSearchResultEntryPacket(messageID, fmt.Sprintf("cn=entry%d-1,dc=example,dc=com", page)),
+			newSearchResultDonePacket(messageID, LDAPResultSuccess, next),
+		}
+	})
+	return conn, requests
+}
+
+func TestSearchWithPagingMaxEntries(t *testing.T) {
+	for _, test := range []struct {
+		sizeLimit, maxEntries int
+		expected              int
+		abandoned             bool
+	}{
+		{maxEntries: 3, expected: 3, abandoned: true},
+		{sizeLimit: 5, expected: 5, abandoned: true},
+		{sizeLimit: 3, maxEntries: 5, expected: 3, abandoned: true},
+		{maxEntries: 6, expected: 6},
+		{expected: 6},
+	} {
+		conn, requests := newPagingTestConn(t, 3)
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, test.sizeLimit, 0, false, "(objectClass=*)", nil, nil)
+		searchRequest.MaxEntries = test.maxEntries
+
+		runWithTimeout(t, time.Second, func() {
+			result, err := conn.SearchWithPaging(searchRequest, 2)
+			if test.abandoned && !IsErrorWithCode(err, LDAPResultSizeLimitExceeded) {
+				t.Errorf("%+v: expected ErrSizeLimitExceeded, got %v", test, err)
+			} else if !test.abandoned && err != nil {
+				t.Errorf("%+v: unexpected error: %s", test, err)
+			}
+			if len(result.Entries) != test.expected {
+				t.Errorf("%+v: expected %d entries, got %d", test, test.expected, len(result.Entries))
+			}
+		})
+		conn.Close()
+
+		var last *ControlPaging
+		for len(requests) > 0 {
+			last = <-requests
+		}
+		if abandoned := last.PagingSize == 0; abandoned != test.abandoned {
+			t.Errorf("%+v: unexpected last page request %+v", test, last)
+		} else if abandoned && len(last.Cookie) == 0 {
+			t.Errorf("%+v: expected the paged search to be abandoned with the last cookie", test)
+		}
+	}
+}
+
+func TestSearchAuto(t *testing.T) {
+	for _, code := range []uint16{LDAPResultSizeLimitExceeded, LDAPResultAdminLimitExceeded} {
+		var pagedRequests int
+		conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+			if request.Children[1].Tag != ApplicationSearchRequest {
+				return nil
+			}
+			messageID := request.Children[0].Value.(int64)
+			if len(request.Children) < 3 {
+				return []*ber.Packet{
+					newSearchResultEntryPacket(messageID, "cn=entry0,dc=example,dc=com"),
+					newSearchResultDonePacket(messageID, code),
+				}
+			}
+			pagedRequests++
+			control, err := DecodeControl(request.Children[2].Children[0])
+			if err != nil {
+				t.Errorf("failed to decode request control: %s", err)
+				return nil
+			}
+			if paging := control.(*ControlPaging); paging.PagingSize != SearchAutoPagingSize {
+				t.Errorf("unexpected paging size %d", paging.PagingSize)
+			}
+			return []*ber.Packet{
+				newSearchResultEntryPacket(messageID, "cn=entry0,dc=example,dc=com"),
+				newSearchResultEntryPacket(messageID, "cn=entry1,dc=example,dc=com"),
+				newSearchResultDonePacket(messageID, LDAPResultSuccess, &ControlPaging{}),
+			}
+		})
+
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		result, err := conn.SearchAuto(searchRequest)
+		if err != nil {
+			t.Errorf("code %d: unexpected error: %s", code, err)
+		} else if len(result.Entries) != 2 {
+			t.Errorf("code %d: expected 2 entries, got %d", code, len(result.Entries))
+		}
+		if pagedRequests != 1 {
+			t.Errorf("code %d: expected 1 paged request, got %d", code, pagedRequests)
+		}
+		if len(searchRequest.Controls) != 0 {
+			t.Errorf("code %d: the search request was modified: %v", code, searchRequest.Controls)
+		}
+
+		// a size limit set by the caller is not worked around
+		searchRequest.SizeLimit = 1
+		if _, err := conn.SearchAuto(searchRequest); !IsErrorWithCode(err, code) {
+			t.Errorf("code %d: expected the limit error, got %v", code, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestSearchPartialResult(t *testing.T) {
+	for _, code := range []uint16{LDAPResultSizeLimitExceeded, LDAPResultTimeLimitExceeded} {
+		conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+			if request.Children[1].Tag != ApplicationSearchRequest {
+				return nil
+			}
+			messageID := request.Children[0].Value.(int64)
+			done := &ControlPaging{}
+			if len(request.Children) > 2 {
+				control, err := DecodeControl(request.Children[2].Children[0])
+				if err != nil {
+					t.Errorf("failed to decode request control: %s", err)
+					return nil
+				}
+				if len(control.(*ControlPaging).Cookie) == 0 {
+					// first page
+					return []*ber.Packet{
+						newSearchResultEntryPacket(messageID, "cn=entry0,dc=example,dc=com"),
+						newSearchResultDonePacket(messageID, LDAPResultSuccess, &ControlPaging{Cookie: []byte("1")}),
+					}
+				}
+			}
+			return []*ber.Packet{
+				newSearchResultEntryPacket(messageID, "cn=entry1,dc=example,dc=com"),
+				newSearchResultEntryPacket(messageID, "cn=entry2,dc=example,dc=com"),
+				newSearchResultDonePacket(messageID, code, done),
+			}
+		})
+
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		result, err := conn.Search(searchRequest)
+		if !IsErrorWithCode(err, code) {
+			t.Errorf("code %d: unexpected error %v", code, err)
+		}
+		if result == nil || !result.Partial || len(result.Entries) != 2 || len(result.Controls) != 1 {
+			t.Errorf("code %d: expected a partial result with 2 entries, got %+v", code, result)
+		}
+
+		result, err = conn.SearchWithPaging(searchRequest, 10)
+		if !IsErrorWithCode(err, code) {
+			t.Errorf("code %d: unexpected paged search error %v", code, err)
+		}
+		if result == nil || !result.Partial || len(result.Entries) != 3 {
+			t.Errorf("code %d: expected a partial paged result with 3 entries, got %+v", code, result)
+		}
+		conn.Close()
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	conn, requests := newPagingTestConn(t, 3)
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	var entries []*Entry
+	var cookie []byte
+	for page := 0; ; page++ {
+		result, next, err := conn.SearchPage(searchRequest, 2, cookie)
+		if err != nil {
+			t.Fatalf("page %d: unexpected error: %s", page, err)
+		}
+		request := <-requests
+		if request.PagingSize != 2 || !bytes.Equal(request.Cookie, cookie) {
+			t.Errorf("page %d: unexpected page request %+v", page, request)
+		}
+		entries = append(entries, result.Entries...)
+		if len(next) == 0 {
+			break
+		}
+		cookie = next
+	}
+	if len(entries) != 6 {
+		t.Errorf("expected 6 entries, got %d", len(entries))
+	}
+	if len(searchRequest.Controls) != 0 {
+		t.Errorf("the search request was modified: %v", searchRequest.Controls)
+	}
+
+	// abandon the search after the first page
+	_, cookie, err := conn.SearchPage(searchRequest, 2, nil)
+	if err != nil || len(cookie) == 0 {
+		t.Fatalf("unexpected first page: %v, %v", cookie, err)
+	}
+	<-requests
+	if _, next, err := conn.SearchPage(searchRequest, 0, cookie); err != nil || len(next) != 0 {
+		t.Errorf("unexpected abandon result: %v, %v", next, err)
+	}
+	if request := <-requests; request.PagingSize != 0 || !bytes.Equal(request.Cookie, cookie) {
+		t.Errorf("unexpected abandon request %+v", request)
+	}
+}
+
+func TestStreamSearch(t *testing.T) {
+	const count = 100
+	client, server := net.Pipe()
+	conn := NewConn(client, false)
+	conn.Start()
+	defer conn.Close()
+
+	var sent int32
+	go func() {
+		defer server.Close()
+		request, err := ber.ReadPacket(server)
+		if err != nil {
+			return
+		}
+		messageID := request.Children[0].Value.(int64)
+		for i := 0; i < count; i++ {
+			if _, err := server.Write(newSearchResultEntryPacket(messageID, fmt.Sprintf("cn=entry%d,dc=example,dc=com", i)).Bytes()); err != nil {
+				return
+			}
+			atomic.AddInt32(&sent, 1)
+		}
+		server.Write(newSearchResultDonePacket(messageID, LDAPResultSuccess).Bytes())
+		// consume the abandon requests, if any
+		ioutil.ReadAll(server)
+	}()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	stream := conn.StreamSearch(context.Background(), searchRequest, 5)
+
+	// the responses are not read while the consumer does not receive the entries
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&sent); n >= count/2 {
+		t.Errorf("expected the server to be blocked by the consumer, %d entries sent", n)
+	}
+
+	i := 0
+	for entry := range stream.Entries {
+		if expected := fmt.Sprintf("cn=entry%d,dc=example,dc=com", i); entry.DN != expected {
+			t.Errorf("unexpected entry %q, expected %q", entry.DN, expected)
+		}
+		i++
+	}
+	if i != count {
+		t.Errorf("expected %d entries, got %d", count, i)
+	}
+	if _, err := stream.Wait(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestStreamSearchCancel(t *testing.T) {
+	conn, _ := newPagingTestConn(t, 1)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, []Control{NewControlPaging(10)})
+	stream := conn.StreamSearch(ctx, searchRequest, 0)
+	<-stream.Entries
+	cancel()
+	if _, err := stream.Wait(); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func newSearchResultDonePacket(messageID int64, resultCode uint16, controls ...Control) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	done := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultDone, nil, "Search Result Done")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	packet.AppendChild(done)
+	if len(controls) > 0 {
+		packet.AppendChild(EncodeControls(controls))
+	}
+	return packet
+}
+
+func TestSearchRequestCompiledFilter(t *testing.T) {
+	filter, err := CompileFilter("(uid=jdoe)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	req := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "", nil, nil)
+	req.CompiledFilter = filter
+
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	if err := req.appendTo(envelope); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(envelope.Children[0].Children[6].Bytes(), filter.Bytes()) {
+		t.Errorf("compiled filter was not used")
+	}
+}
+
+func TestSearchRequestVLVWithoutSort(t *testing.T) {
+	req := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, []Control{NewControlVLVRequest(0, 9, 1, 0)})
+
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	if err := req.appendTo(envelope); !IsErrorWithCode(err, LDAPResultSortControlMissing) {
+		t.Errorf("expected LDAPResultSortControlMissing, got %v", err)
+	}
+
+	req.Controls = append(req.Controls, NewControlServerSideSort([]SortKey{{AttributeType: "cn"}}))
+	if err := req.appendTo(envelope); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestDecodeSearchResultEntryRawValues(t *testing.T) {
+	sid := "\x01\x05\x00\x00\x00\x00\x00\x05\x15\x00\x00\x00\xff\xfe"
+	packet, err := ber.DecodePacketErr(newSearchResultEntryPacket(1, "cn=jdoe,dc=example,dc=com", "objectSid", sid, "cn", "jdoe").Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	entry := decodeSearchResultEntry(packet)
+	if raw := entry.GetRawAttributeValue("objectSid"); !bytes.Equal(raw, []byte(sid)) {
+		t.Errorf("got raw value %x, expected %x", raw, sid)
+	}
+	if values := entry.GetRawAttributeValues("cn"); len(values) != 1 || string(values[0]) != "jdoe" {
+		t.Errorf("unexpected raw values %q", values)
+	}
+	if value := entry.GetAttributeValue("cn"); value != "jdoe" {
+		t.Errorf("got value %q, expected %q", value, "jdoe")
+	}
+	if values := entry.GetRawAttributeValues("missing"); len(values) != 0 {
+		t.Errorf("expected no values for a missing attribute, got %q", values)
+	}
+}
+
+// TestSearchRequestTimeout tests that a search exceeding its own timeout is
+// abandoned and reported as a timeout.
+func TestSearchRequestTimeout(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	req := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	req.RequestTimeout = 10 * time.Millisecond
+
+	runWithTimeout(t, time.Second, func() {
+		if _, err := conn.Search(req); !IsErrorWithCode(err, LDAPResultTimeout) {
+			t.Errorf("expected LDAPResultTimeout, got %v", err)
+		}
+
+		search, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+		abandon, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+		if abandon.Children[1].Tag != ApplicationAbandonRequest {
+			t.Fatalf("expected an abandon request, got %d", abandon.Children[1].Tag)
+		}
+		if id, _ := ber.ParseInt64(abandon.Children[1].Data.Bytes()); id != search.Children[0].Value.(int64) {
+			t.Errorf("abandoned message %d, expected %d", id, search.Children[0].Value.(int64))
+		}
+	})
+}
+
+// TestSearchRequestTimeoutOverridesConnTimeout tests that the timeout of the connection does
+// not apply to the requests with their own deadline
+func TestSearchRequestTimeoutOverridesConnTimeout(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		if request.Children[1].Tag == ApplicationAbandonRequest {
+			return nil
+		}
+		time.Sleep(150 * time.Millisecond)
+		messageID := request.Children[0].Value.(int64)
+		if request.Children[1].Tag == ApplicationBindRequest {
+			return []*ber.Packet{newResultPacket(messageID, ApplicationBindResponse, LDAPResultSuccess)}
+		}
+		return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultSuccess)}
+	})
+	defer conn.Close()
+	conn.SetTimeout(50 * time.Millisecond)
+
+	req := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	runWithTimeout(t, 2*time.Second, func() {
+		if _, err := conn.Search(req); err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("expected the timeout of the connection, got %v", err)
+		}
+
+		req.RequestTimeout = time.Second
+		if _, err := conn.Search(req); err != nil {
+			t.Errorf("unexpected error with a RequestTimeout: %s", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := conn.SimpleBindWithContext(ctx, NewSimpleBindRequest("cn=admin", "secret", nil)); err != nil {
+			t.Errorf("unexpected error with a context deadline: %s", err)
+		}
+	})
+}
+
+// TestSearchTypesOnly tests that TypesOnly is sent, and that attributes without values are decoded.
+func TestSearchTypesOnly(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		if typesOnly := request.Children[1].Children[5].Value.(bool); !typesOnly {
+			return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultProtocolError)}
+		}
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+		entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+		entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn=joe,dc=example,dc=com", "Object Name"))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for _, name := range []string{"cn", "mail"} {
+			attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+			attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Attribute Name"))
+			attribute.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Attribute Values"))
+			attributes.AppendChild(attribute)
+		}
+		entry.AppendChild(attributes)
+		packet.AppendChild(entry)
+		return []*ber.Packet{packet, newSearchResultDonePacket(messageID, LDAPResultSuccess)}
+	})
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		searchRequest := NewSearchRequest("cn=joe,dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, true, "(objectClass=*)", nil, nil)
+		result, err := conn.Search(searchRequest)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(result.Entries) != 1 || len(result.Entries[0].Attributes) != 2 {
+			t.Fatalf("expected 1 entry with 2 attributes, got %v", result.Entries)
+		}
+		entry := result.Entries[0]
+		if entry.GetAttribute("mail") == nil {
+			t.Error("expected the mail attribute to be returned")
+		}
+		if values := entry.GetAttributeValues("mail"); len(values) != 0 {
+			t.Errorf("expected no values, got %v", values)
+		}
+		if value := entry.GetAttributeValue("cn"); value != "" {
+			t.Errorf("expected no value, got %q", value)
+		}
+	})
+}
+
+func TestSearchOne(t *testing.T) {
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		var responses []*ber.Packet
+		switch request.Children[1].Children[0].Value.(string) {
+		case "ou=many,dc=example,dc=com":
+			responses = append(responses, newSearchResultEntryPacket(messageID, "cn=b,ou=many,dc=example,dc=com"))
+			fallthrough
+		case "ou=one,dc=example,dc=com":
+			responses = append(responses, newSearchResultEntryPacket(messageID, "cn=a,ou=one,dc=example,dc=com"))
+		case "ou=missing,dc=example,dc=com":
+			return []*ber.Packet{newSearchResultDonePacket(messageID, LDAPResultNoSuchObject)}
+		}
+		return append(responses, newSearchResultDonePacket(messageID, LDAPResultSuccess))
+	})
+	defer conn.Close()
+
+	search := func(baseDN string) (*Entry, error) {
+		return conn.SearchOne(NewSearchRequest(baseDN, ScopeSingleLevel, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	}
+	runWithTimeout(t, time.Second, func() {
+		if entry, err := search("ou=one,dc=example,dc=com"); err != nil || entry.DN != "cn=a,ou=one,dc=example,dc=com" {
+			t.Errorf("unexpected result %v, %v", entry, err)
+		}
+		if _, err := search("ou=none,dc=example,dc=com"); err != ErrNoEntries {
+			t.Errorf("expected ErrNoEntries, got %v", err)
+		}
+		if _, err := search("ou=many,dc=example,dc=com"); err != ErrMultipleEntries {
+			t.Errorf("expected ErrMultipleEntries, got %v", err)
+		}
+		if _, err := search("ou=missing,dc=example,dc=com"); !IsErrorWithCode(err, LDAPResultNoSuchObject) {
+			t.Errorf("expected LDAPResultNoSuchObject, got %v", err)
+		}
+	})
+}
+
+func TestSearchResultEntry(t *testing.T) {
+	result := &SearchResult{Entries: []*Entry{
+		NewEntry("cn=John Smith,ou=People,dc=example,dc=com", nil),
+		NewEntry("cn=admins+ou=groups,dc=example,dc=com", nil),
+	}}
+
+	for _, dn := range []string{
+		"cn=John Smith,ou=People,dc=example,dc=com",
+		"CN=john  smith, OU=people, DC=Example, DC=com",
+		" cn = John Smith ,ou=People,dc=example,dc=com",
+	} {
+		if entry := result.Entry(dn); entry != result.Entries[0] {
+			t.Errorf("%q: expected the first entry, got %v", dn, entry)
+		}
+	}
+	if entry := result.Entry("ou=Groups+CN=Admins,dc=example,dc=com"); entry != result.Entries[1] {
+		t.Errorf("expected the second entry, got %v", entry)
+	}
+	if entry := result.Entry("cn=Jane Smith,ou=People,dc=example,dc=com"); entry != nil {
+		t.Errorf("expected no entry, got %v", entry)
+	}
+
+	// the index is a snapshot of the entries at the first lookup
+	result.Entries = append(result.Entries, NewEntry("cn=Jane Smith,ou=People,dc=example,dc=com", nil))
+	if entry := result.Entry("cn=jane smith,ou=people,dc=example,dc=com"); entry != nil {
+		t.Errorf("expected the entry appended after the first lookup not to be indexed, got %v", entry)
+	}
+	if entry := (&SearchResult{Entries: result.Entries}).Entry("cn=jane smith,ou=people,dc=example,dc=com"); entry != result.Entries[2] {
+		t.Errorf("expected the appended entry in a new result, got %v", entry)
+	}
+	if entry := new(SearchResult).Entry("dc=example,dc=com"); entry != nil {
+		t.Errorf("expected no entry in an empty result, got %v", entry)
+	}
+}
+
+// newBulkSearchTestConn returns a connection to a server answering every search request
+// with the given number of entries at once, so that most of them are still queued in the
+// connection when the first one is received. The result holds a paging control with a
+// cookie if the request has a paging control.
+func newBulkSearchTestConn(entries int) *Conn {
+	return newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		if request.Children[1].Tag != ApplicationSearchRequest {
+			return nil
+		}
+		messageID := request.Children[0].Value.(int64)
+		var responses []*ber.Packet
+		for i := 0; i < entries; i++ {
+			responses = append(responses, newSearchResultEntryPacket(messageID, fmt.Sprintf("cn=entry%d,dc=example,dc=com", i)))
+		}
+		var controls []Control
+		if len(request.Children) > 2 {
+			controls = append(controls, &ControlPaging{Cookie: []byte("next")})
+		}
+		return append(responses, newSearchResultDonePacket(messageID, LDAPResultSuccess, controls...))
+	})
+}
+
+// TestSearchAbandonWithQueuedResponses tests that abandoning a search does not deadlock
+// while many of its responses are still queued in the connection
+func TestSearchAbandonWithQueuedResponses(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	conn := newBulkSearchTestConn(50)
+	defer conn.Close()
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+
+	stop := errors.New("stop")
+	runWithTimeout(t, 2*time.Second, func() {
+		_, err := conn.SearchWithCallback(searchRequest, func(entry *Entry) error {
+			time.Sleep(20 * time.Millisecond)
+			return stop
+		})
+		if err != stop {
+			t.Errorf("expected the callback error, got %v", err)
+		}
+	})
+
+	// the connection is still usable
+	runWithTimeout(t, 2*time.Second, func() {
+		result, err := conn.Search(searchRequest)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(result.Entries) != 50 {
+			t.Errorf("expected 50 entries, got %d", len(result.Entries))
+		}
+	})
+
+	// the entries received after the search was abandoned are dropped silently
+	conn.Close()
+	if output.Len() > 0 {
+		t.Errorf("expected no log output, got %q", output.String())
+	}
+}
+
+// TestStreamSearchCancelWithQueuedEntries tests that cancelling a stream does not deadlock
+// while many entries are still queued in the connection, whether the search is abandoned
+// by the callback blocked on a full buffer or while waiting for the next response
+func TestStreamSearchCancelWithQueuedEntries(t *testing.T) {
+	conn := newBulkSearchTestConn(50)
+	defer conn.Close()
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+
+	for _, bufferSize := range []int{2, 100} {
+		runWithTimeout(t, 2*time.Second, func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			stream := conn.StreamSearch(ctx, searchRequest, bufferSize)
+			<-stream.Entries
+			cancel()
+			if _, err := stream.Wait(); err != context.Canceled && err != nil {
+				t.Errorf("buffer of %d: expected context.Canceled, got %v", bufferSize, err)
+			}
+		})
+	}
+
+	runWithTimeout(t, 2*time.Second, func() {
+		if _, err := conn.Search(searchRequest); err != nil {
+			t.Errorf("unexpected error after cancelling the streams: %s", err)
+		}
+	})
+}
+
+func TestSearchWithPagingMaxEntriesWithQueuedEntries(t *testing.T) {
+	conn := newBulkSearchTestConn(50)
+	defer conn.Close()
+
+	runWithTimeout(t, 2*time.Second, func() {
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		searchRequest.MaxEntries = 3
+		result, err := conn.SearchWithPaging(searchRequest, 100)
+		if !IsErrorWithCode(err, LDAPResultSizeLimitExceeded) {
+			t.Errorf("expected LDAPResultSizeLimitExceeded, got %v", err)
+		}
+		if result == nil || len(result.Entries) != 3 {
+			t.Errorf("expected 3 entries, got %v", result)
+		}
+	})
+}
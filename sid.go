@@ -0,0 +1,125 @@
+// This file contains the conversions of the objectSid and objectGUID attributes of
+// Active Directory between their binary values and their string representations
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/f992ad60-0fe4-4b87-9fed-beb478836861
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/49e490b8-f972-45d6-a3a4-99f924998d97
+//
+
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	enchex "encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxSIDSubAuthorities is the maximum number of sub-authorities of a SID
+const maxSIDSubAuthorities = 15
+
+// FormatSID returns the string representation of the binary SID raw, such as the value of
+// the objectSid attribute, e.g. "S-1-5-21-1004336348-1177238915-682003330-512"
+func FormatSID(raw []byte) (string, error) {
+	if len(raw) < 8 || raw[0] != 1 || int(raw[1]) > maxSIDSubAuthorities || len(raw) != 8+4*int(raw[1]) {
+		return "", fmt.Errorf("ldap: invalid SID of %d bytes", len(raw))
+	}
+
+	var authority uint64
+	for _, b := range raw[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString("S-1-")
+	if authority >= 1<<32 {
+		fmt.Fprintf(&buffer, "0x%012X", authority)
+	} else {
+		buffer.WriteString(strconv.FormatUint(authority, 10))
+	}
+	for i := 0; i < int(raw[1]); i++ {
+		buffer.WriteString("-")
+		buffer.WriteString(strconv.FormatUint(uint64(binary.LittleEndian.Uint32(raw[8+4*i:])), 10))
+	}
+	return buffer.String(), nil
+}
+
+// ParseSID returns the binary SID represented by sid, as returned by FormatSID, which can
+// be used in a search filter escaped with EscapeFilterBytes
+func ParseSID(sid string) ([]byte, error) {
+	parts := strings.Split(sid, "-")
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "S") || parts[1] != "1" || len(parts)-3 > maxSIDSubAuthorities {
+		return nil, fmt.Errorf("ldap: invalid SID %q", sid)
+	}
+
+	var authority uint64
+	var err error
+	if len(parts[2]) > 2 && strings.EqualFold(parts[2][:2], "0x") {
+		authority, err = strconv.ParseUint(parts[2][2:], 16, 48)
+	} else {
+		authority, err = strconv.ParseUint(parts[2], 10, 32)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid SID %q: %s", sid, err)
+	}
+
+	raw := make([]byte, 8, 8+4*(len(parts)-3))
+	raw[0], raw[1] = 1, byte(len(parts)-3)
+	for i := 7; i >= 2; i-- {
+		raw[i] = byte(authority)
+		authority >>= 8
+	}
+	for _, part := range parts[3:] {
+		subAuthority, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: invalid SID %q: %s", sid, err)
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(subAuthority))
+		raw = append(raw, b[:]...)
+	}
+	return raw, nil
+}
+
+// FormatGUID returns the string representation of the binary GUID raw, such as the value
+// of the objectGUID attribute, e.g. "{a0ae5b2f-5c4e-4c5f-9d4b-2b1a3c9e8f01}". As in
+// Windows, the first three fields are stored in little-endian byte order.
+func FormatGUID(raw []byte) (string, error) {
+	if len(raw) != 16 {
+		return "", fmt.Errorf("ldap: invalid GUID of %d bytes", len(raw))
+	}
+	return fmt.Sprintf("{%08x-%04x-%04x-%x-%x}",
+		binary.LittleEndian.Uint32(raw[0:4]),
+		binary.LittleEndian.Uint16(raw[4:6]),
+		binary.LittleEndian.Uint16(raw[6:8]),
+		raw[8:10],
+		raw[10:16]), nil
+}
+
+// ParseGUID returns the binary GUID represented by guid, with or without braces, as
+// returned by FormatGUID, which can be used in a search filter escaped with EscapeFilterBytes
+func ParseGUID(guid string) ([]byte, error) {
+	s := guid
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = s[1 : len(s)-1]
+	}
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return nil, fmt.Errorf("ldap: invalid GUID %q", guid)
+	}
+	raw, err := enchex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid GUID %q: %s", guid, err)
+	}
+	// the first three fields are little-endian
+	reverseBytes(raw[0:4])
+	reverseBytes(raw[4:6])
+	reverseBytes(raw[6:8])
+	return raw, nil
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
@@ -0,0 +1,65 @@
+package ldap
+
+import (
+	"bytes"
+	enchex "encoding/hex"
+	"testing"
+)
+
+func TestSID(t *testing.T) {
+	for _, test := range []struct {
+		sid string
+		raw string
+	}{
+		{"S-1-5-21-1004336348-1177238915-682003330-512", "010500000000000515000000dcf4dc3b833d2b46828ba62800020000"},
+		{"S-1-5-32-544", "01020000000000052000000020020000"},
+		{"S-1-1-0", "010100000000000100000000"},
+		{"S-1-5", "0100000000000005"},
+		{"S-1-0xFFFFFFFF0000-1", "0101ffffffff000001000000"},
+	} {
+		raw, _ := enchex.DecodeString(test.raw)
+		sid, err := FormatSID(raw)
+		if err != nil || sid != test.sid {
+			t.Errorf("%s: unexpected formatted SID %q, %v", test.raw, sid, err)
+		}
+		parsed, err := ParseSID(test.sid)
+		if err != nil || !bytes.Equal(parsed, raw) {
+			t.Errorf("%s: unexpected parsed SID %x, %v", test.sid, parsed, err)
+		}
+	}
+
+	for _, raw := range []string{"", "0105000000000005", "020000000000000500", "0101000000000005"} {
+		b, _ := enchex.DecodeString(raw)
+		if _, err := FormatSID(b); err == nil {
+			t.Errorf("%s: expected an error", raw)
+		}
+	}
+	for _, sid := range []string{"", "S-1", "S-2-5", "X-1-5", "S-1-5-x", "S-1-4294967296", "S-1-5-4294967296", "S-1-5-1-2-3-4-5-6-7-8-9-10-11-12-13-14-15-16"} {
+		if _, err := ParseSID(sid); err == nil {
+			t.Errorf("%q: expected an error", sid)
+		}
+	}
+}
+
+func TestGUID(t *testing.T) {
+	raw, _ := enchex.DecodeString("2f5baea04e5c5f4c9d4b2b1a3c9e8f01")
+	guid, err := FormatGUID(raw)
+	if err != nil || guid != "{a0ae5b2f-5c4e-4c5f-9d4b-2b1a3c9e8f01}" {
+		t.Errorf("unexpected formatted GUID %q, %v", guid, err)
+	}
+	for _, guid := range []string{"{a0ae5b2f-5c4e-4c5f-9d4b-2b1a3c9e8f01}", "A0AE5B2F-5C4E-4C5F-9D4B-2B1A3C9E8F01"} {
+		parsed, err := ParseGUID(guid)
+		if err != nil || !bytes.Equal(parsed, raw) {
+			t.Errorf("%s: unexpected parsed GUID %x, %v", guid, parsed, err)
+		}
+	}
+
+	if _, err := FormatGUID(raw[:15]); err == nil {
+		t.Error("expected an error formatting a GUID of 15 bytes")
+	}
+	for _, guid := range []string{"", "{a0ae5b2f-5c4e-4c5f-9d4b-2b1a3c9e8f0}", "a0ae5b2f5c4e4c5f9d4b2b1a3c9e8f01", "g0ae5b2f-5c4e-4c5f-9d4b-2b1a3c9e8f01"} {
+		if _, err := ParseGUID(guid); err == nil {
+			t.Errorf("%q: expected an error", guid)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+//go:build go1.21
+// +build go1.21
+
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// SetLogger sets the logger receiving, at debug level, an event for the completion of
+// each operation with its message ID, operation, result code and duration. The output
+// of Debug, if enabled, is also written to logger instead of the standard logger. A nil
+// logger restores the default behavior.
+//
+// The passwords are redacted from the logged packets, unless SetRedactCredentials(false)
+// is called.
+func (l *Conn) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		l.setLogger(nil)
+		return
+	}
+	l.setLogger(slogLogger{logger})
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (s slogLogger) enabled() bool {
+	return s.logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+func (s slogLogger) printf(format string, args ...interface{}) {
+	if s.enabled() {
+		s.logger.Debug(fmt.Sprintf(format, args...))
+	}
+}
+
+func (s slogLogger) printPacket(packet *ber.Packet) {
+	if s.enabled() {
+		var dump bytes.Buffer
+		writePacket(&dump, packet, "")
+		s.logger.Debug("ldap packet", slog.String("packet", dump.String()))
+	}
+}
+
+func (s slogLogger) logRequest(messageID int64, op string, resultCode uint16, d time.Duration, err error) {
+	if !s.enabled() {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.Int64("messageID", messageID),
+		slog.String("operation", op),
+		slog.Int("resultCode", int(resultCode)),
+		slog.Duration("duration", d),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	s.logger.LogAttrs(context.Background(), slog.LevelDebug, "ldap request", attrs...)
+}
@@ -0,0 +1,105 @@
+//go:build go1.21
+// +build go1.21
+
+package ldap
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSetLogger(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	conn := NewConn(ptc, false)
+	conn.Debug.Enable(true)
+	conn.Start()
+	defer conn.Close()
+	go func() {
+		defer ptc.Close()
+		for {
+			request, err := ptc.ReceiveRequest()
+			if err != nil {
+				return
+			}
+			ptc.SendResponse(newResultPacket(request.Children[0].Value.(int64), ApplicationBindResponse, LDAPResultInvalidCredentials))
+		}
+	}()
+
+	var output syncBuffer
+	conn.SetLogger(slog.New(slog.NewTextHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	// the credentials are redacted by default
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.Bind("cn=admin,dc=example,dc=com", "s3cr3t"); !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+			t.Errorf("expected LDAPResultInvalidCredentials, got %v", err)
+		}
+	})
+
+	logged := output.String()
+	if !strings.Contains(logged, "operation=bind resultCode=49") {
+		t.Errorf("missing bind event in %s", logged)
+	}
+	if !strings.Contains(logged, redactedValue) {
+		t.Errorf("missing bind request packet in %s", logged)
+	}
+	if strings.Contains(logged, "s3cr3t") {
+		t.Errorf("password was logged in %s", logged)
+	}
+}
+
+func TestRedactCredentials(t *testing.T) {
+	bindRequest := &SimpleBindRequest{Username: "cn=admin,dc=example,dc=com", Password: "s3cr3t"}
+	saslRequest := requestFunc(func(envelope *ber.Packet) error {
+		pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindRequest, nil, "Bind Request")
+		pkt.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
+		pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "User Name"))
+		auth := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, "", "authentication")
+		auth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "PLAIN", "SASL Mech"))
+		auth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "\x00admin\x00s3cr3t", "Credentials"))
+		pkt.AppendChild(auth)
+		envelope.AppendChild(pkt)
+		return nil
+	})
+	passwordModifyRequest := NewPasswordModifyRequest("", "0ld", "s3cr3t")
+
+	for _, req := range []request{bindRequest, saslRequest, passwordModifyRequest} {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+		if err := req.appendTo(packet); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		original := packet.Bytes()
+
+		var dump bytes.Buffer
+		writePacket(&dump, redactCredentials(packet), "")
+		if strings.Contains(dump.String(), "s3cr3t") || !strings.Contains(dump.String(), redactedValue) {
+			t.Errorf("credentials were not redacted from %T:\n%s", req, dump.String())
+		}
+		if !bytes.Equal(packet.Bytes(), original) {
+			t.Errorf("the original %T packet was modified", req)
+		}
+	}
+}
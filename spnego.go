@@ -0,0 +1,142 @@
+// This file contains the GSS-SPNEGO SASL mechanism, which wraps the GSS-API tokens in the
+// SPNEGO tokens specified in rfc 4178, as used by Active Directory
+//
+// https://tools.ietf.org/html/rfc4178
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-spng
+//
+
+package ldap
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+var (
+	// oidSPNEGO identifies the SPNEGO pseudo mechanism
+	oidSPNEGO = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 2}
+	// oidKerberos5 and oidMicrosoftKerberos5 identify the Kerberos mechanism, the latter
+	// being the OID used by Windows
+	oidKerberos5          = asn1.ObjectIdentifier{1, 2, 840, 113554, 1, 2, 2}
+	oidMicrosoftKerberos5 = asn1.ObjectIdentifier{1, 2, 840, 48018, 1, 2, 2}
+)
+
+// spnegoReject is the negState of a NegTokenResp rejecting the negotiation
+const spnegoReject = 2
+
+// negTokenInit is the NegTokenInit of rfc 4178 section 4.2.1, without the optional fields
+// which are not sent
+type negTokenInit struct {
+	MechTypes []asn1.ObjectIdentifier `asn1:"explicit,tag:0"`
+	MechToken []byte                  `asn1:"explicit,tag:2"`
+}
+
+// negTokenResp is the NegTokenResp of rfc 4178 section 4.2.2
+type negTokenResp struct {
+	NegState      asn1.Enumerated       `asn1:"explicit,optional,tag:0"`
+	SupportedMech asn1.ObjectIdentifier `asn1:"explicit,optional,tag:1"`
+	ResponseToken []byte                `asn1:"explicit,optional,tag:2"`
+	MechListMIC   []byte                `asn1:"explicit,optional,tag:3"`
+}
+
+// negTokenRespToken is the NegTokenResp sent by the client, holding a response token only
+type negTokenRespToken struct {
+	ResponseToken []byte `asn1:"explicit,tag:2"`
+}
+
+// encodeSPNEGOInit returns the initial SPNEGO token proposing Kerberos, with the initial
+// Kerberos token of the client
+func encodeSPNEGOInit(mechToken []byte) ([]byte, error) {
+	init, err := asn1.Marshal(negTokenInit{
+		MechTypes: []asn1.ObjectIdentifier{oidKerberos5, oidMicrosoftKerberos5},
+		MechToken: mechToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// NegotiationToken ::= CHOICE { negTokenInit [0] NegTokenInit, ... }
+	negotiationToken, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: init})
+	if err != nil {
+		return nil, err
+	}
+	mech, err := asn1.Marshal(oidSPNEGO)
+	if err != nil {
+		return nil, err
+	}
+	// InitialContextToken ::= [APPLICATION 0] IMPLICIT SEQUENCE { thisMech, innerContextToken }
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassApplication, Tag: 0, IsCompound: true, Bytes: append(mech, negotiationToken...)})
+}
+
+// encodeSPNEGOResp returns the SPNEGO token sending a subsequent Kerberos token
+func encodeSPNEGOResp(responseToken []byte) ([]byte, error) {
+	resp, err := asn1.Marshal(negTokenRespToken{ResponseToken: responseToken})
+	if err != nil {
+		return nil, err
+	}
+	// NegotiationToken ::= CHOICE { ..., negTokenResp [1] NegTokenResp }
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: resp})
+}
+
+// decodeSPNEGOResp decodes the NegTokenResp sent by the server
+func decodeSPNEGOResp(token []byte) (*negTokenResp, error) {
+	var negotiationToken asn1.RawValue
+	if _, err := asn1.Unmarshal(token, &negotiationToken); err != nil {
+		return nil, err
+	}
+	if negotiationToken.Class != asn1.ClassContextSpecific || negotiationToken.Tag != 1 {
+		return nil, fmt.Errorf("unexpected SPNEGO token with tag %d", negotiationToken.Tag)
+	}
+	resp := &negTokenResp{}
+	if _, err := asn1.Unmarshal(negotiationToken.Bytes, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// gssSPNEGOBind performs a SASL/GSS-SPNEGO bind with Kerberos. Unlike GSSAPI, there is no
+// security layer negotiation once the security context is established: the bind completes
+// when the server accepts the context. The mechListMIC of the server is not checked.
+func (l *Conn) gssSPNEGOBind(client GSSAPIClient, servicePrincipal string) error {
+	token, needContinue, err := client.InitSecContext(servicePrincipal, nil)
+	if err != nil {
+		return err
+	}
+	request, err := encodeSPNEGOInit(token)
+	if err != nil {
+		return err
+	}
+
+	for {
+		response, err := l.SASLBind(SASLMechanismGSSSPNEGO, request)
+		inProgress := IsErrorWithCode(err, LDAPResultSaslBindInProgress)
+		if err != nil && !inProgress {
+			return err
+		}
+
+		token = nil
+		if len(response) > 0 {
+			resp, err := decodeSPNEGOResp(response)
+			if err != nil {
+				return NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: invalid SPNEGO response: %s", err))
+			}
+			if resp.NegState == spnegoReject {
+				return NewError(LDAPResultInvalidCredentials, errors.New("ldap: SPNEGO negotiation rejected by the server"))
+			}
+			if len(resp.ResponseToken) > 0 && needContinue {
+				if token, needContinue, err = client.InitSecContext(servicePrincipal, resp.ResponseToken); err != nil {
+					return err
+				}
+			}
+		}
+		if !inProgress {
+			return nil
+		}
+		if len(token) == 0 {
+			return NewError(ErrorUnexpectedResponse, errors.New("ldap: GSS-SPNEGO exchange in progress without a token to send"))
+		}
+		if request, err = encodeSPNEGOResp(token); err != nil {
+			return err
+		}
+	}
+}
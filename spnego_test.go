@@ -0,0 +1,106 @@
+package ldap
+
+import (
+	"encoding/asn1"
+	"reflect"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// decodeSPNEGOInit returns the NegTokenInit of an initial SPNEGO token
+func decodeSPNEGOInit(t *testing.T, token []byte) negTokenInit {
+	var initialContextToken, negotiationToken asn1.RawValue
+	var mech asn1.ObjectIdentifier
+	var init negTokenInit
+	if _, err := asn1.Unmarshal(token, &initialContextToken); err != nil || initialContextToken.Class != asn1.ClassApplication {
+		t.Fatalf("invalid initial context token %x: %v", token, err)
+	}
+	rest, err := asn1.Unmarshal(initialContextToken.Bytes, &mech)
+	if err != nil || !mech.Equal(oidSPNEGO) {
+		t.Fatalf("unexpected mechanism %v: %v", mech, err)
+	}
+	if _, err := asn1.Unmarshal(rest, &negotiationToken); err != nil || negotiationToken.Tag != 0 {
+		t.Fatalf("invalid negotiation token %x: %v", rest, err)
+	}
+	if _, err := asn1.Unmarshal(negotiationToken.Bytes, &init); err != nil {
+		t.Fatalf("invalid NegTokenInit: %s", err)
+	}
+	return init
+}
+
+func encodeTestSPNEGOResp(t *testing.T, resp negTokenResp) []byte {
+	bytes, err := asn1.Marshal(resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	token, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: bytes})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return token
+}
+
+func TestGSSSPNEGOBind(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		resp     negTokenResp
+		code     uint16
+		expected uint16
+	}{
+		{"accepted", negTokenResp{SupportedMech: oidKerberos5, ResponseToken: []byte("AP-REP")}, LDAPResultSuccess, LDAPResultSuccess},
+		{"rejected", negTokenResp{NegState: spnegoReject}, LDAPResultSaslBindInProgress, LDAPResultInvalidCredentials},
+	} {
+		var init negTokenInit
+		conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+			messageID := request.Children[0].Value.(int64)
+			saslCreds := request.Children[1].Children[2]
+			if mechanism := saslCreds.Children[0].Value.(string); mechanism != SASLMechanismGSSSPNEGO {
+				return []*ber.Packet{newSASLBindResponsePacket(messageID, LDAPResultAuthMethodNotSupported, nil)}
+			}
+			init = decodeSPNEGOInit(t, saslCreds.Children[1].Data.Bytes())
+			return []*ber.Packet{newSASLBindResponsePacket(messageID, test.code, encodeTestSPNEGOResp(t, test.resp))}
+		})
+
+		client := &fakeGSSAPIClient{t: t}
+		runWithTimeout(t, time.Second, func() {
+			err := conn.GSSAPIBindWithMechanism(client, SASLMechanismGSSSPNEGO, "ldap/dc1.example.com", "")
+			if test.expected == LDAPResultSuccess && err != nil {
+				t.Errorf("%s: unexpected error: %s", test.name, err)
+			} else if test.expected != LDAPResultSuccess && !IsErrorWithCode(err, test.expected) {
+				t.Errorf("%s: expected result code %d, got %v", test.name, test.expected, err)
+			}
+		})
+		conn.Close()
+
+		expected := negTokenInit{MechTypes: []asn1.ObjectIdentifier{oidKerberos5, oidMicrosoftKerberos5}, MechToken: []byte("AP-REQ")}
+		if !reflect.DeepEqual(init, expected) {
+			t.Errorf("%s: unexpected NegTokenInit %+v", test.name, init)
+		}
+		if test.expected == LDAPResultSuccess && client.legs != 2 {
+			t.Errorf("%s: expected the AP-REP to be processed", test.name)
+		}
+		if !client.deleted {
+			t.Errorf("%s: expected the security context to be deleted", test.name)
+		}
+	}
+}
+
+func TestEncodeSPNEGOResp(t *testing.T) {
+	token, err := encodeSPNEGOResp([]byte("token"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp, err := decodeSPNEGOResp(token)
+	if err != nil || string(resp.ResponseToken) != "token" || resp.NegState != 0 {
+		t.Errorf("unexpected NegTokenResp %+v: %v", resp, err)
+	}
+}
+
+func TestGSSAPIBindWithUnsupportedMechanism(t *testing.T) {
+	client := &fakeGSSAPIClient{t: t}
+	if err := (&Conn{}).GSSAPIBindWithMechanism(client, "NTLM", "ldap/dc1.example.com", ""); !IsErrorWithCode(err, LDAPResultAuthMethodNotSupported) {
+		t.Errorf("expected LDAPResultAuthMethodNotSupported, got %v", err)
+	}
+}
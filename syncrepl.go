@@ -0,0 +1,262 @@
+// This file contains the content synchronization operation (syncrepl) as specified in
+// rfc 4533
+//
+// https://tools.ietf.org/html/rfc4533
+//
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const (
+	syncInfoOID = "1.3.6.1.4.1.4203.1.9.1.4"
+)
+
+// SyncReplMode is the mode of a content synchronization search
+type SyncReplMode int
+
+const (
+	// SyncReplRefreshOnly returns the changes since the cookie, then completes the search
+	SyncReplRefreshOnly SyncReplMode = 1
+	// SyncReplRefreshAndPersist returns the changes since the cookie, then keeps the search
+	// open to return the following changes as they happen
+	SyncReplRefreshAndPersist SyncReplMode = 3
+)
+
+// SyncReplEventType is the type of a SyncReplEvent
+type SyncReplEventType int
+
+const (
+	// SyncReplPresent reports an entry which is unchanged since the cookie
+	SyncReplPresent SyncReplEventType = 0
+	// SyncReplAdd reports an entry added since the cookie
+	SyncReplAdd SyncReplEventType = 1
+	// SyncReplModify reports an entry modified since the cookie
+	SyncReplModify SyncReplEventType = 2
+	// SyncReplDelete reports an entry deleted since the cookie
+	SyncReplDelete SyncReplEventType = 3
+	// SyncReplCookie reports a new cookie without any entry, for example at the end of the
+	// refresh phase
+	SyncReplCookie SyncReplEventType = 4
+)
+
+// SyncReplEvent is a change reported by a content synchronization search
+type SyncReplEvent struct {
+	// Type is the type of the change
+	Type SyncReplEventType
+	// EntryUUID is the entryUUID of the entry, nil for SyncReplCookie events
+	EntryUUID []byte
+	// Entry is the entry for SyncReplAdd and SyncReplModify events. For the other events,
+	// it only holds the DN of the entry if the server sent it, and is nil otherwise.
+	Entry *Entry
+	// Cookie is the cookie to resume the synchronization from once the event is processed
+	Cookie []byte
+	// RefreshDone is true for the SyncReplCookie event marking the end of the refresh phase
+	RefreshDone bool
+}
+
+// SyncReplSearch performs a content synchronization search starting from the given cookie,
+// nil for a full synchronization, calling handler for each change returned by the server.
+//
+// In SyncReplRefreshOnly mode, it returns once the changes since the cookie are received.
+// In SyncReplRefreshAndPersist mode, it keeps receiving the changes until an error occurs:
+// use SyncReplSearchWithContext to stop it. An error with the ResultCode
+// LDAPResultSyncRefreshRequired means that the synchronization must restart without cookie.
+func (l *Conn) SyncReplSearch(searchRequest *SearchRequest, mode SyncReplMode, cookie []byte, handler func(SyncReplEvent)) error {
+	return l.SyncReplSearchWithContext(context.Background(), searchRequest, mode, cookie, handler)
+}
+
+// SyncReplSearchWithContext is like SyncReplSearch, but the search is abandoned when ctx
+// is done, and ctx.Err() is returned.
+func (l *Conn) SyncReplSearchWithContext(ctx context.Context, searchRequest *SearchRequest, mode SyncReplMode, cookie []byte, handler func(SyncReplEvent)) error {
+	syncRequest := NewControlSyncRequest(mode, cookie)
+	req := *searchRequest
+	req.Controls = nil
+	for _, control := range searchRequest.Controls {
+		if c, ok := control.(*ControlSyncRequest); ok {
+			syncRequest.ReloadHint = c.ReloadHint
+			continue
+		}
+		req.Controls = append(req.Controls, control)
+	}
+	req.Controls = append(req.Controls, syncRequest)
+
+	msgCtx, err := l.doRequest(ctx, &req)
+	if err != nil {
+		return err
+	}
+	defer l.finishMessage(msgCtx)
+
+	emit := func(event SyncReplEvent) {
+		if event.Cookie != nil {
+			cookie = event.Cookie
+		}
+		event.Cookie = cookie
+		handler(event)
+	}
+	abandon := func(err error) error {
+		l.debugf("%d: abandoning sync search: %s", msgCtx.id, err)
+		if abandonErr := l.abandonMessage(msgCtx); abandonErr != nil {
+			l.debugf("%d: failed to abandon sync search: %s", msgCtx.id, abandonErr)
+		}
+		return err
+	}
+
+	for {
+		packet, err := l.readPacket(ctx, msgCtx)
+		if err != nil {
+			if err == ctx.Err() {
+				return abandon(err)
+			}
+			return err
+		}
+
+		switch packet.Children[1].Tag {
+		case ApplicationSearchResultEntry:
+			event, err := decodeSyncReplEntry(packet)
+			if err != nil {
+				return abandon(err)
+			}
+			emit(event)
+		case ApplicationIntermediateResponse:
+			events, err := decodeSyncInfo(packet)
+			if err != nil {
+				return abandon(err)
+			}
+			for _, event := range events {
+				emit(event)
+			}
+		case ApplicationSearchResultDone:
+			if err := GetLDAPError(packet); err != nil {
+				return err
+			}
+			if len(packet.Children) == 3 {
+				for _, child := range packet.Children[2].Children {
+					control, err := DecodeControl(child)
+					if err != nil {
+						return fmt.Errorf("failed to decode child control: %s", err)
+					}
+					if syncDone, ok := control.(*ControlSyncDone); ok {
+						emit(SyncReplEvent{Type: SyncReplCookie, Cookie: syncDone.Cookie, RefreshDone: true})
+					}
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// decodeSyncReplEntry returns the event of a search result entry holding a sync state
+// control
+func decodeSyncReplEntry(packet *ber.Packet) (SyncReplEvent, error) {
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			control, err := DecodeControl(child)
+			if err != nil {
+				return SyncReplEvent{}, fmt.Errorf("failed to decode child control: %s", err)
+			}
+			if syncState, ok := control.(*ControlSyncState); ok {
+				return SyncReplEvent{
+					Type:      syncState.State,
+					EntryUUID: syncState.EntryUUID,
+					Entry:     decodeSearchResultEntry(packet),
+					Cookie:    syncState.Cookie,
+				}, nil
+			}
+		}
+	}
+	return SyncReplEvent{}, NewError(ErrorUnexpectedResponse, errors.New("ldap: sync state control missing from search result entry"))
+}
+
+// decodeSyncInfo returns the events of a Sync Info Message:
+//
+//	syncInfoValue ::= CHOICE {
+//	     newcookie      [0] syncCookie,
+//	     refreshDelete  [1] SEQUENCE {
+//	         cookie         syncCookie OPTIONAL,
+//	         refreshDone    BOOLEAN DEFAULT TRUE
+//	     },
+//	     refreshPresent [2] SEQUENCE {
+//	         cookie         syncCookie OPTIONAL,
+//	         refreshDone    BOOLEAN DEFAULT TRUE
+//	     },
+//	     syncIdSet      [3] SEQUENCE {
+//	         cookie         syncCookie OPTIONAL,
+//	         refreshDeletes BOOLEAN DEFAULT FALSE,
+//	         syncUUIDs      SET OF syncUUID
+//	     }
+//	}
+//
+// Other intermediate responses are ignored.
+func decodeSyncInfo(packet *ber.Packet) ([]SyncReplEvent, error) {
+	var name string
+	var value []byte
+	for _, child := range packet.Children[1].Children {
+		switch {
+		case child.ClassType == ber.ClassContext && child.Tag == 0:
+			name = child.Data.String()
+		case child.ClassType == ber.ClassContext && child.Tag == 1:
+			value = child.Data.Bytes()
+		}
+	}
+	if name != syncInfoOID {
+		return nil, nil
+	}
+
+	info, err := ber.DecodePacketErr(value)
+	if err != nil {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: invalid sync info message: %s", err))
+	}
+	if info.ClassType != ber.ClassContext {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid sync info message"))
+	}
+
+	if info.Tag == 0 {
+		return []SyncReplEvent{{Type: SyncReplCookie, Cookie: info.Data.Bytes()}}, nil
+	}
+	var cookie []byte
+	var flag *bool
+	var uuids [][]byte
+	for _, child := range info.Children {
+		switch child.Tag {
+		case ber.TagOctetString:
+			cookie = child.Data.Bytes()
+		case ber.TagBoolean:
+			if b, ok := child.Value.(bool); ok {
+				flag = &b
+			}
+		case ber.TagSet:
+			for _, uuid := range child.Children {
+				uuids = append(uuids, uuid.Data.Bytes())
+			}
+		}
+	}
+
+	switch info.Tag {
+	case 1, 2:
+		// refreshDelete and refreshPresent
+		refreshDone := flag == nil || *flag
+		return []SyncReplEvent{{Type: SyncReplCookie, Cookie: cookie, RefreshDone: refreshDone}}, nil
+	case 3:
+		// syncIdSet: the new cookie only applies once all the entries are processed
+		eventType := SyncReplPresent
+		if flag != nil && *flag {
+			eventType = SyncReplDelete
+		}
+		events := make([]SyncReplEvent, 0, len(uuids)+1)
+		for _, uuid := range uuids {
+			events = append(events, SyncReplEvent{Type: eventType, EntryUUID: uuid})
+		}
+		if cookie != nil {
+			events = append(events, SyncReplEvent{Type: SyncReplCookie, Cookie: cookie})
+		}
+		return events, nil
+	}
+	return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: unknown sync info message %d", info.Tag))
+}
@@ -0,0 +1,165 @@
+package ldap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func newSyncInfoPacket(messageID int64, info *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationIntermediateResponse, nil, "Intermediate Response")
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, syncInfoOID, "responseName"))
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 1, string(info.Bytes()), "responseValue"))
+	packet.AppendChild(response)
+	return packet
+}
+
+func newSyncStateEntryPacket(messageID int64, dn string, state SyncReplEventType, uuid, cookie string) *ber.Packet {
+	syncState := &ControlSyncState{State: state, EntryUUID: []byte(uuid)}
+	if cookie != "" {
+		syncState.Cookie = []byte(cookie)
+	}
+	packet := newSearchResultEntryPacket(messageID, dn, "cn", "a")
+	packet.AppendChild(EncodeControls([]Control{syncState}))
+	return packet
+}
+
+func TestSyncReplSearchRefreshOnly(t *testing.T) {
+	var syncRequest *ControlSyncRequest
+	conn := newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		for _, child := range request.Children[2].Children {
+			control, err := DecodeControl(child)
+			if err != nil || control == nil {
+				continue
+			}
+			if c, ok := control.(*ControlString); ok && c.ControlType == ControlTypeSyncRequest {
+				syncRequest = &ControlSyncRequest{}
+				value := ber.DecodePacket([]byte(c.ControlValue))
+				syncRequest.Mode = SyncReplMode(value.Children[0].Value.(int64))
+				syncRequest.Cookie = value.Children[1].Data.Bytes()
+			}
+		}
+
+		idSet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "syncIdSet")
+		idSet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "c2", "cookie"))
+		idSet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "refreshDeletes"))
+		uuids := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "syncUUIDs")
+		uuids.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "uuid-b", "syncUUID"))
+		uuids.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "uuid-c", "syncUUID"))
+		idSet.AppendChild(uuids)
+
+		return []*ber.Packet{
+			newSyncStateEntryPacket(messageID, "cn=a,dc=example,dc=com", SyncReplAdd, "uuid-a", "c1"),
+			newSyncInfoPacket(messageID, idSet),
+			newSyncInfoPacket(messageID, ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, "c3", "newcookie")),
+			newSearchResultDonePacket(messageID, LDAPResultSuccess, &ControlSyncDone{Cookie: []byte("c4")}),
+		}
+	})
+	defer conn.Close()
+
+	var events []SyncReplEvent
+	runWithTimeout(t, time.Second, func() {
+		err := conn.SyncReplSearch(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil), SyncReplRefreshOnly, []byte("c0"), func(event SyncReplEvent) {
+			events = append(events, event)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	if syncRequest == nil || syncRequest.Mode != SyncReplRefreshOnly || string(syncRequest.Cookie) != "c0" {
+		t.Errorf("unexpected sync request control %+v", syncRequest)
+	}
+	if len(events) != 6 {
+		t.Fatalf("expected 6 events, got %d: %+v", len(events), events)
+	}
+	if events[0].Type != SyncReplAdd || string(events[0].EntryUUID) != "uuid-a" || events[0].Entry.GetAttributeValue("cn") != "a" || string(events[0].Cookie) != "c1" {
+		t.Errorf("unexpected add event %+v", events[0])
+	}
+	for i, uuid := range []string{"uuid-b", "uuid-c"} {
+		if event := events[i+1]; event.Type != SyncReplDelete || string(event.EntryUUID) != uuid || event.Entry != nil || string(event.Cookie) != "c1" {
+			t.Errorf("unexpected delete event %+v", event)
+		}
+	}
+	var cookies []string
+	for _, event := range events[3:] {
+		if event.Type != SyncReplCookie {
+			t.Errorf("unexpected event %+v", event)
+		}
+		cookies = append(cookies, string(event.Cookie))
+	}
+	if !reflect.DeepEqual(cookies, []string{"c2", "c3", "c4"}) {
+		t.Errorf("unexpected cookies %v", cookies)
+	}
+	if !events[5].RefreshDone {
+		t.Errorf("expected the refresh to be done")
+	}
+}
+
+func TestSyncReplSearchPersist(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan SyncReplEvent, 10)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.SyncReplSearchWithContext(ctx, NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil), SyncReplRefreshAndPersist, nil, func(event SyncReplEvent) {
+			events <- event
+		})
+	}()
+
+	var messageID int64
+	runWithTimeout(t, time.Second, func() {
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+		messageID = request.Children[0].Value.(int64)
+	})
+
+	refreshPresent := ber.Encode(ber.ClassContext, ber.TypeConstructed, 2, nil, "refreshPresent")
+	refreshPresent.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "c1", "cookie"))
+	for _, response := range []*ber.Packet{
+		newSyncInfoPacket(messageID, refreshPresent),
+		newSyncStateEntryPacket(messageID, "cn=a,dc=example,dc=com", SyncReplModify, "uuid-a", ""),
+	} {
+		if err := ptc.SendResponse(response); err != nil {
+			t.Fatalf("unable to send response packet: %s", err)
+		}
+	}
+
+	runWithTimeout(t, time.Second, func() {
+		if event := <-events; event.Type != SyncReplCookie || !event.RefreshDone || string(event.Cookie) != "c1" {
+			t.Errorf("unexpected refresh done event %+v", event)
+		}
+		if event := <-events; event.Type != SyncReplModify || string(event.Cookie) != "c1" {
+			t.Errorf("unexpected modify event %+v", event)
+		}
+	})
+
+	cancel()
+	runWithTimeout(t, time.Second, func() {
+		if err := <-errs; err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		abandon, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive abandon packet: %s", err)
+		}
+		if id, _ := ber.ParseInt64(abandon.Children[1].Data.Bytes()); abandon.Children[1].Tag != ApplicationAbandonRequest || id != messageID {
+			t.Errorf("expected the abandon of message %d", messageID)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+//go:build !go1.8
+// +build !go1.8
+
+package ldap
+
+import "crypto/tls"
+
+// cloneTLSConfig copies the fields of config, as tls.Config.Clone is only available
+// from Go 1.8
+func cloneTLSConfig(config *tls.Config) *tls.Config {
+	return &tls.Config{
+		Rand:                        config.Rand,
+		Time:                        config.Time,
+		Certificates:                config.Certificates,
+		NameToCertificate:           config.NameToCertificate,
+		GetCertificate:              config.GetCertificate,
+		RootCAs:                     config.RootCAs,
+		NextProtos:                  config.NextProtos,
+		ServerName:                  config.ServerName,
+		ClientAuth:                  config.ClientAuth,
+		ClientCAs:                   config.ClientCAs,
+		InsecureSkipVerify:          config.InsecureSkipVerify,
+		CipherSuites:                config.CipherSuites,
+		PreferServerCipherSuites:    config.PreferServerCipherSuites,
+		SessionTicketsDisabled:      config.SessionTicketsDisabled,
+		SessionTicketKey:            config.SessionTicketKey,
+		ClientSessionCache:          config.ClientSessionCache,
+		MinVersion:                  config.MinVersion,
+		MaxVersion:                  config.MaxVersion,
+		CurvePreferences:            config.CurvePreferences,
+		DynamicRecordSizingDisabled: config.DynamicRecordSizingDisabled,
+		Renegotiation:               config.Renegotiation,
+	}
+}
@@ -0,0 +1,208 @@
+// This file contains the transactions extended operations as specified in rfc 5805
+//
+// https://tools.ietf.org/html/rfc5805
+//
+// txnEndReq ::= SEQUENCE {
+//      commit         BOOLEAN DEFAULT TRUE,
+//      identifier     OCTET STRING }
+//
+// txnEndRes ::= SEQUENCE {
+//      messageID MessageID OPTIONAL,
+//           -- msgid associated with non-success resultCode
+//      updatesControls SEQUENCE OF updateControls SEQUENCE {
+//           messageID MessageID,
+//                -- msgid associated with controls
+//           controls  Controls
+//      } OPTIONAL
+// }
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const (
+	startTxnOID = "1.3.6.1.1.21.1"
+	endTxnOID   = "1.3.6.1.1.21.3"
+)
+
+// Tx is a transaction started with Conn.StartTx. The update operations performed with its
+// methods are only applied, all together, by Commit.
+type Tx struct {
+	conn *Conn
+	// Identifier is the transaction identifier returned by the server
+	Identifier []byte
+	// messageIDs are the message IDs of the operations of the transaction, in order
+	messageIDs []int64
+	failed     int
+}
+
+type endTxnRequest struct {
+	identifier []byte
+	commit     bool
+}
+
+func (req *endTxnRequest) appendTo(envelope *ber.Packet) error {
+	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedRequest, nil, "End Transaction Extended Operation")
+	pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, endTxnOID, "Extended Request Name: End Transaction OID"))
+	value := ber.Encode(ber.ClassContext, ber.TypePrimitive, 1, nil, "Extended Request Value: End Transaction Request")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "End Transaction Request")
+	if !req.commit {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, false, "Commit"))
+	}
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(req.identifier), "Identifier"))
+	value.AppendChild(seq)
+	pkt.AppendChild(value)
+	envelope.AppendChild(pkt)
+	return nil
+}
+
+var startTxnRequest = requestFunc(func(envelope *ber.Packet) error {
+	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedRequest, nil, "Start Transaction Extended Operation")
+	pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, startTxnOID, "Extended Request Name: Start Transaction OID"))
+	envelope.AppendChild(pkt)
+	return nil
+})
+
+// StartTx starts a transaction
+func (l *Conn) StartTx() (*Tx, error) {
+	packet, err := l.extendedRequest(startTxnRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Tx{conn: l, failed: -1}
+	for _, child := range packet.Children[1].Children {
+		if child.ClassType == ber.ClassContext && child.Tag == 11 {
+			tx.Identifier = child.Data.Bytes()
+		}
+	}
+	if len(tx.Identifier) == 0 {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: missing transaction identifier in Start Transaction response"))
+	}
+	return tx, nil
+}
+
+// extendedRequest performs the given extended request and returns the response packet
+// if it succeeded
+func (l *Conn) extendedRequest(req request) (*ber.Packet, error) {
+	msgCtx, err := l.doRequest(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	defer l.finishMessage(msgCtx)
+
+	packet, err := l.readPacket(context.Background(), msgCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if packet.Children[1].Tag != ApplicationExtendedResponse {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
+	}
+	if err := GetLDAPError(packet); err != nil {
+		return packet, err
+	}
+	return packet, nil
+}
+
+// Add adds the given add request to the transaction
+func (tx *Tx) Add(addRequest *AddRequest) error {
+	req := *addRequest
+	req.Controls = tx.controls(req.Controls)
+	return tx.do(&req, ApplicationAddResponse)
+}
+
+// Modify adds the given modify request to the transaction
+func (tx *Tx) Modify(modifyRequest *ModifyRequest) error {
+	req := *modifyRequest
+	req.Controls = tx.controls(req.Controls)
+	return tx.do(&req, ApplicationModifyResponse)
+}
+
+// Del adds the given delete request to the transaction
+func (tx *Tx) Del(delRequest *DelRequest) error {
+	req := *delRequest
+	req.Controls = tx.controls(req.Controls)
+	return tx.do(&req, ApplicationDelResponse)
+}
+
+// ModifyDN adds the given modify DN request to the transaction
+func (tx *Tx) ModifyDN(modifyDNRequest *ModifyDNRequest) error {
+	req := *modifyDNRequest
+	req.Controls = tx.controls(req.Controls)
+	return tx.do(&req, ApplicationModifyDNResponse)
+}
+
+// controls returns the given controls followed by the transaction specification control
+func (tx *Tx) controls(controls []Control) []Control {
+	return append(append([]Control{}, controls...), NewControlTransactionSpecification(tx.Identifier))
+}
+
+func (tx *Tx) do(req request, responseTag ber.Tag) error {
+	msgCtx, err := tx.conn.doRequest(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	defer tx.conn.finishMessage(msgCtx)
+	tx.messageIDs = append(tx.messageIDs, msgCtx.id)
+
+	packet, err := tx.conn.readPacket(context.Background(), msgCtx)
+	if err != nil {
+		return err
+	}
+
+	if packet.Children[1].Tag != responseTag {
+		return NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
+	}
+	return GetLDAPError(packet)
+}
+
+// Commit asks the server to apply all the operations of the transaction. If the server
+// fails to apply one of them, none is applied, and FailedOperation returns its index.
+func (tx *Tx) Commit() error {
+	return tx.end(true)
+}
+
+// Abort abandons the transaction: none of its operations is applied
+func (tx *Tx) Abort() error {
+	return tx.end(false)
+}
+
+// FailedOperation returns the index, in the order they were performed, of the operation
+// which caused Commit to fail, or -1 if the server did not report one
+func (tx *Tx) FailedOperation() int {
+	return tx.failed
+}
+
+func (tx *Tx) end(commit bool) error {
+	packet, err := tx.conn.extendedRequest(&endTxnRequest{identifier: tx.Identifier, commit: commit})
+	if packet == nil || err == nil {
+		return err
+	}
+
+	for _, child := range packet.Children[1].Children {
+		if child.ClassType != ber.ClassContext || child.Tag != 11 {
+			continue
+		}
+		value, decodeErr := ber.DecodePacketErr(child.Data.Bytes())
+		if decodeErr != nil || len(value.Children) == 0 {
+			break
+		}
+		messageID, ok := value.Children[0].Value.(int64)
+		if value.Children[0].ClassType != ber.ClassUniversal || !ok {
+			break
+		}
+		for i, id := range tx.messageIDs {
+			if id == messageID {
+				tx.failed = i
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func newExtendedResponsePacket(messageID int64, resultCode uint16, value []byte) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	if value != nil {
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 11, string(value), "responseValue"))
+	}
+	packet.AppendChild(response)
+	return packet
+}
+
+// newTxTestConn returns a connection to a server failing the commit of a transaction on
+// its second operation, and recording whether the transaction was aborted
+func newTxTestConn(t *testing.T, aborted *bool) *Conn {
+	var operationIDs []int64
+	return newTestServerConn(func(request *ber.Packet) []*ber.Packet {
+		messageID := request.Children[0].Value.(int64)
+		op := request.Children[1]
+		if op.Tag != ApplicationExtendedRequest {
+			if len(request.Children) < 3 {
+				t.Errorf("missing transaction specification control in request %d", op.Tag)
+				return []*ber.Packet{newResultPacket(messageID, op.Tag+1, LDAPResultProtocolError)}
+			}
+			control, err := DecodeControl(request.Children[2].Children[len(request.Children[2].Children)-1])
+			if err != nil {
+				t.Errorf("failed to decode control: %s", err)
+			} else if c, ok := control.(*ControlTransactionSpecification); !ok || string(c.Identifier) != "txn1" {
+				t.Errorf("unexpected control %s", control)
+			}
+			operationIDs = append(operationIDs, messageID)
+			return []*ber.Packet{newResultPacket(messageID, op.Tag+1, LDAPResultSuccess)}
+		}
+
+		switch string(op.Children[0].Data.Bytes()) {
+		case startTxnOID:
+			return []*ber.Packet{newExtendedResponsePacket(messageID, LDAPResultSuccess, []byte("txn1"))}
+		case endTxnOID:
+			value := ber.DecodePacket(op.Children[1].Data.Bytes())
+			identifier := value.Children[len(value.Children)-1].Value.(string)
+			if identifier != "txn1" {
+				t.Errorf("unexpected transaction identifier %q", identifier)
+			}
+			if len(value.Children) == 2 && !value.Children[0].Value.(bool) {
+				*aborted = true
+				return []*ber.Packet{newExtendedResponsePacket(messageID, LDAPResultSuccess, nil)}
+			}
+			txnEndRes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "txnEndRes")
+			txnEndRes.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, operationIDs[1], "messageID"))
+			return []*ber.Packet{newExtendedResponsePacket(messageID, LDAPResultEntryAlreadyExists, txnEndRes.Bytes())}
+		}
+		return []*ber.Packet{newExtendedResponsePacket(messageID, LDAPResultProtocolError, nil)}
+	})
+}
+
+func TestTxCommitFailure(t *testing.T) {
+	var aborted bool
+	conn := newTxTestConn(t, &aborted)
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		tx, err := conn.StartTx()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !bytes.Equal(tx.Identifier, []byte("txn1")) {
+			t.Errorf("unexpected identifier %q", tx.Identifier)
+		}
+		modifyDNRequest := NewModifyDNRequest("cn=joe,ou=a,dc=example,dc=com", "cn=joe", true, "ou=b,dc=example,dc=com")
+		if err := tx.ModifyDN(modifyDNRequest); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(modifyDNRequest.Controls) != 0 {
+			t.Errorf("the request controls should not be modified")
+		}
+		if err := tx.Add(NewAddRequest("cn=joe,ou=b,dc=example,dc=com", nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := tx.Del(NewDelRequest("cn=jim,ou=b,dc=example,dc=com", nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if err := tx.Commit(); !IsErrorWithCode(err, LDAPResultEntryAlreadyExists) {
+			t.Errorf("expected LDAPResultEntryAlreadyExists, got %v", err)
+		}
+		if index := tx.FailedOperation(); index != 1 {
+			t.Errorf("expected the second operation to have failed, got %d", index)
+		}
+	})
+}
+
+func TestTxAbort(t *testing.T) {
+	var aborted bool
+	conn := newTxTestConn(t, &aborted)
+	defer conn.Close()
+
+	runWithTimeout(t, time.Second, func() {
+		tx, err := conn.StartTx()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		modifyRequest := NewModifyRequest("cn=joe,dc=example,dc=com", nil)
+		modifyRequest.Replace("mail", []string{"joe@example.com"})
+		if err := tx.Modify(modifyRequest); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := tx.Abort(); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		if tx.FailedOperation() != -1 {
+			t.Errorf("expected no failed operation, got %d", tx.FailedOperation())
+		}
+	})
+	if !aborted {
+		t.Error("expected the transaction to be aborted")
+	}
+}
@@ -0,0 +1,34 @@
+//
+// https://tools.ietf.org/html/rfc4511
+//
+// UnbindRequest ::= [APPLICATION 2] NULL
+
+package ldap
+
+import (
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Unbind sends an unbind request to terminate the session, then closes the connection.
+// The server sends no response to an unbind request. Any subsequent operation on the
+// connection fails with a "connection closed" error.
+func (l *Conn) Unbind() error {
+	if l.IsClosing() {
+		return NewError(ErrorNetwork, errConnClosed)
+	}
+
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, l.nextMessageID(), "MessageID"))
+	packet.AppendChild(ber.Encode(ber.ClassApplication, ber.TypePrimitive, ApplicationUnbindRequest, nil, "Unbind Request"))
+	l.debugPacket(packet)
+
+	msgCtx, err := l.sendMessage(packet)
+	if err != nil {
+		return err
+	}
+	// the request is written before the connection is closed, as both
+	// are processed in order by processMessages
+	l.finishMessage(msgCtx)
+	l.Close()
+	return nil
+}
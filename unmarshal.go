@@ -0,0 +1,161 @@
+package ldap
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	dnType    = reflect.TypeOf(&DN{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// Unmarshal stores the attribute values of the entry in the struct pointed to by v, much
+// like json.Unmarshal. Each exported field receives the values of the attribute named by
+// its `ldap:"name"` tag, or by the field name if it has no tag. Fields tagged `ldap:"-"`
+// are skipped, and a field tagged `ldap:"dn"` or with the dn option, as in `ldap:",dn"`,
+// receives the DN of the entry. Fields whose attribute is missing from the entry are left
+// unchanged.
+//
+// The supported field types are string, bool, int and int64 (and the other signed integer
+// types), time.Time (see GetAttributeValueTime), *DN, []byte for the raw value, and slices
+// of these types for multi-valued attributes. An error is returned if a field which is not
+// a slice is mapped to an attribute with several values.
+func (e *Entry) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ldap: Unmarshal expects a non-nil pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := parseFieldTag(field)
+		if !ok {
+			continue
+		}
+
+		var values []string
+		var rawValues [][]byte
+		if tag.dn {
+			values, rawValues = []string{e.DN}, [][]byte{[]byte(e.DN)}
+		} else if attr := e.lookupAttribute(tag.name); attr != nil && len(attr.S) > 0 {
+			values, rawValues = attr.S, attr.ByteValues
+		} else {
+			continue
+		}
+		if err := unmarshalValues(rv.Field(i), values, rawValues); err != nil {
+			return fmt.Errorf("ldap: cannot unmarshal attribute %s into field %s: %s", tag.name, field.Name, err)
+		}
+	}
+	return nil
+}
+
+// fieldTag holds the options of the ldap tag of a struct field
+type fieldTag struct {
+	// name is the attribute name, the field name by default
+	name string
+	// omitEmpty is set by the omitempty option
+	omitEmpty bool
+	// dn is set by the dn option, or if the name is dn
+	dn bool
+}
+
+// parseFieldTag returns the options of the ldap tag of field, and false if the field is
+// unexported or tagged `ldap:"-"`
+func parseFieldTag(field reflect.StructField) (fieldTag, bool) {
+	tag := field.Tag.Get("ldap")
+	if field.PkgPath != "" || tag == "-" {
+		return fieldTag{}, false
+	}
+	options := strings.Split(tag, ",")
+	ft := fieldTag{name: options[0]}
+	for _, option := range options[1:] {
+		switch option {
+		case "omitempty":
+			ft.omitEmpty = true
+		case "dn":
+			ft.dn = true
+		}
+	}
+	if ft.name == "" {
+		ft.name = field.Name
+	}
+	if strings.EqualFold(ft.name, "dn") {
+		ft.dn = true
+	}
+	return ft, true
+}
+
+// unmarshalValues stores the values of an attribute in the field value fv
+func unmarshalValues(fv reflect.Value, values []string, rawValues [][]byte) error {
+	if len(rawValues) != len(values) {
+		rawValues = make([][]byte, len(values))
+		for i, value := range values {
+			rawValues[i] = []byte(value)
+		}
+	}
+
+	if fv.Kind() == reflect.Slice && fv.Type() != bytesType {
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i := range values {
+			if err := unmarshalValue(slice.Index(i), values[i], rawValues[i]); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	if len(values) != 1 {
+		return fmt.Errorf("%d values for a single-valued %s field", len(values), fv.Type())
+	}
+	return unmarshalValue(fv, values[0], rawValues[0])
+}
+
+// unmarshalValue stores a single value in the field value fv
+func unmarshalValue(fv reflect.Value, value string, rawValue []byte) error {
+	switch fv.Type() {
+	case bytesType:
+		fv.SetBytes(rawValue)
+		return nil
+	case timeType:
+		t, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case dnType:
+		dn, err := ParseDN(value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(dn))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := parseBoolean(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
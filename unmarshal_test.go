@@ -0,0 +1,99 @@
+package ldap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntryUnmarshal(t *testing.T) {
+	type user struct {
+		DN          string    `ldap:"dn"`
+		ParsedDN    *DN       `ldap:"dn"`
+		UID         string    `ldap:"uid"`
+		Mail        []string  `ldap:"mail"`
+		UIDNumber   int       `ldap:"uidNumber"`
+		Size        int64     `ldap:"size"`
+		Locked      bool      `ldap:"locked"`
+		Created     time.Time `ldap:"createTimestamp"`
+		Photo       []byte    `ldap:"jpegPhoto"`
+		Manager     *DN       `ldap:"manager"`
+		Description string
+		Ignored     string `ldap:"-"`
+		Missing     string `ldap:"missing"`
+		unexported  string
+	}
+	entry := NewEntry("uid=joe,dc=example,dc=com", map[string][]string{
+		"uid":             {"joe"},
+		"mail":            {"joe@example.com", "jdoe@example.com"},
+		"uidNumber":       {"1000"},
+		"size":            {"-12"},
+		"locked":          {"TRUE"},
+		"createTimestamp": {"20200102030405Z"},
+		"jpegPhoto":       {"\xff\xd8"},
+		"manager":         {"uid=boss,dc=example,dc=com"},
+		"description":     {"a user"},
+		"ignored":         {"ignored"},
+		"unexported":      {"ignored"},
+	})
+
+	u := user{Missing: "unchanged"}
+	if err := entry.Unmarshal(&u); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if u.DN != entry.DN || u.ParsedDN == nil || len(u.ParsedDN.RDNs) != 3 {
+		t.Errorf("unexpected DN %q / %v", u.DN, u.ParsedDN)
+	}
+	if u.UID != "joe" || !reflect.DeepEqual(u.Mail, []string{"joe@example.com", "jdoe@example.com"}) {
+		t.Errorf("unexpected string values %q %v", u.UID, u.Mail)
+	}
+	if u.UIDNumber != 1000 || u.Size != -12 || !u.Locked {
+		t.Errorf("unexpected values %d %d %t", u.UIDNumber, u.Size, u.Locked)
+	}
+	if !u.Created.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected time %s", u.Created)
+	}
+	if string(u.Photo) != "\xff\xd8" {
+		t.Errorf("unexpected raw value %x", u.Photo)
+	}
+	if u.Manager == nil || u.Manager.RDNs[0].Attributes[0].Value != "boss" {
+		t.Errorf("unexpected manager %v", u.Manager)
+	}
+	if u.Description != "a user" || u.Ignored != "" || u.Missing != "unchanged" || u.unexported != "" {
+		t.Errorf("unexpected fields %+v", u)
+	}
+}
+
+func TestEntryUnmarshalErrors(t *testing.T) {
+	entry := NewEntry("uid=joe,dc=example,dc=com", map[string][]string{
+		"mail":      {"joe@example.com", "jdoe@example.com"},
+		"uidNumber": {"joe"},
+		"locked":    {"yes"},
+	})
+
+	for _, test := range []struct {
+		v        interface{}
+		expected string
+	}{
+		{nil, "non-nil pointer to a struct"},
+		{struct{}{}, "non-nil pointer to a struct"},
+		{&struct {
+			Mail string `ldap:"mail"`
+		}{}, "2 values for a single-valued string field"},
+		{&struct {
+			UIDNumber int `ldap:"uidNumber"`
+		}{}, "attribute uidNumber into field UIDNumber"},
+		{&struct {
+			Locked bool `ldap:"locked"`
+		}{}, "neither TRUE nor FALSE"},
+		{&struct {
+			Mail []float64 `ldap:"mail"`
+		}{}, "unsupported field type float64"},
+	} {
+		err := entry.Unmarshal(test.v)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%T: expected an error containing %q, got %v", test.v, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// This file contains the "Who Am I?" extended operation as specified in rfc 4532
+//
+// https://tools.ietf.org/html/rfc4532
+//
+
+package ldap
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	whoAmIOID = "1.3.6.1.4.1.4203.1.11.3"
+)
+
+// WhoAmIResult holds the server response to a "Who Am I?" request
+type WhoAmIResult struct {
+	// AuthzID is the authorization identity of the session, either "dn:" followed by a DN
+	// or "u:" followed by a user name. It is empty for anonymous sessions.
+	AuthzID string
+}
+
+// WhoAmI returns the authorization identity the server associates with the session
+func (l *Conn) WhoAmI(controls []Control) (*WhoAmIResult, error) {
+	response, err := l.Extended(&ExtendedRequest{Name: whoAmIOID, Controls: controls})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WhoAmIResult{AuthzID: string(response.Value)}
+	if result.AuthzID != "" && !strings.HasPrefix(result.AuthzID, "dn:") && !strings.HasPrefix(result.AuthzID, "u:") {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid authzid in Who Am I? response: "+result.AuthzID))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestWhoAmI(t *testing.T) {
+	for _, authzID := range []string{"dn:uid=jdoe,dc=example,dc=com", "u:jdoe", ""} {
+		ptc := newPacketTranslatorConn()
+		conn := NewConn(ptc, false)
+		conn.Start()
+
+		go func(authzID string) {
+			request, err := ptc.ReceiveRequest()
+			if err != nil {
+				return
+			}
+			packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, request.Children[0].Value.(int64), "MessageID"))
+			response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+			response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+			response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+			response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+			if authzID != "" {
+				response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 11, authzID, "responseValue"))
+			}
+			packet.AppendChild(response)
+			ptc.SendResponse(packet)
+		}(authzID)
+
+		runWithTimeout(t, time.Second, func() {
+			result, err := conn.WhoAmI(nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if result.AuthzID != authzID {
+				t.Errorf("got authzid %q, expected %q", result.AuthzID, authzID)
+			}
+		})
+		conn.Close()
+	}
+}